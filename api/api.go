@@ -3,14 +3,25 @@ package api
 import (
 	"context"
 	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/aiAnalysis"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/application"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/benchmarkScraper"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/brandCatalog"
 	"github.com/ItaiHalperin/Device-Rec-API/internal/dataAccessLayer"
 	"github.com/ItaiHalperin/Device-Rec-API/internal/dataPipelineManager"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/databaseInterface"
 	"github.com/ItaiHalperin/Device-Rec-API/internal/errorMonitoring"
-	"github.com/ItaiHalperin/Device-Rec-API/internal/mongoDatabase"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/externalServices"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/helpers"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/nameMatching"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/specAPI"
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io"
 	"log"
 	"net/http"
-	"os"
+	"net/url"
 	"time"
 )
 
@@ -30,9 +41,17 @@ import (
 // @BasePath /api/v1
 // @schemes http
 type ServerCtrl struct {
-	ServerShutdownChannel <-chan os.Signal
+	App *application.Application
 }
 
+const (
+	eventsHeartbeatInterval = 15 * time.Second
+	lowestPriceWindow       = 90 * 24 * time.Hour
+	// minLowestPriceObservations is how many prices must be observed in lowestPriceWindow before one is flagged as
+	// the lowest; a device's only price is trivially its lowest.
+	minLowestPriceObservations = 2
+)
+
 // PingExample godoc
 // @Summary Ping example
 // @Schemes
@@ -46,6 +65,41 @@ func Ping(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "pong"})
 }
 
+// @Summary Readiness probe
+// @Description Reports whether the application is started and its database is up
+// @Tags health
+// @Produce  json
+// @Success 200 {object} dataTypes.Readiness
+// @Failure 503 {object} dataTypes.Readiness
+// @Router /api/v1/ready [get]
+func (service *ServerCtrl) Ready(c *gin.Context) {
+	readiness := service.App.GetReadiness(c.Request.Context())
+	if readiness.State != application.StateReady || !readiness.IsDatabaseUp {
+		c.JSON(http.StatusServiceUnavailable, readiness)
+		return
+	}
+	c.JSON(http.StatusOK, readiness)
+}
+
+// @Summary Effective configuration
+// @Description Shows the configuration the application runs with, with the API keys and the Mongo password redacted
+// @Tags admin
+// @Produce  json
+// @Success 200 {object} config.Config
+// @Router /api/v1/admin/config [get]
+func (service *ServerCtrl) GetConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, service.App.Config.Redacted())
+}
+
+// RequireReady rejects the requests that need the database until the application is ready.
+func (service *ServerCtrl) RequireReady(c *gin.Context) {
+	if !service.App.IsReady() {
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"message": "application is " + service.App.GetState()})
+		return
+	}
+	c.Next()
+}
+
 // GetUser @Summary Get a user by ID
 // @Description Get details of a user by their ID
 // @Tags user
@@ -58,49 +112,111 @@ func GetUser(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"user": id})
 }
 
-// @Summary LaunchProcess launch the data gathering process
+// @Summary Start the data gathering process
 // @Schemes
-// @Description Do launch the data gathering process
-// @Tags process
+// @Description Starts the data gathering pipeline in the background. Only one run is allowed at a time.
+// @Tags pipeline
 // @Accept  json
 // @Produce  json
-// @Success 200 {string} string "pong"
+// @Success 200 {object} dataTypes.PipelineStatus
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/pipeline/start [post]
 // @Router /api/v1/launchProcess [get]
-func (service *ServerCtrl) LaunchProcess(c *gin.Context) {
-	ctrl := dataTypes.FlowControl{Ctx: context.Background(), StopOnTooManyErrorsChannel: make(chan<- struct{})}
-	err := errorMonitoring.ResetErrorCounters(&ctrl)
-	if err != nil {
-		c.JSON(http.StatusOK, gin.H{"message": "failed to reset monitoring"})
+func (service *ServerCtrl) StartPipeline(c *gin.Context) {
+	err := service.App.Supervisor.Start()
+	if errorTypes.IsPipelineStateError(err) {
+		log.Println(err)
+		c.JSON(http.StatusConflict, gin.H{"message": "failed to start pipeline", "error": err.Error()})
 		return
 	}
-
-	channelForConnect := make(chan struct{}, 1)
-	ctxForConnection, cancel := context.WithTimeout(context.Background(), time.Second*30)
-	defer cancel()
-	ctrlForConnection := dataTypes.FlowControl{
-		Ctx:                        ctxForConnection,
-		StopOnTooManyErrorsChannel: channelForConnect,
-	}
-	var dal dataAccessLayer.DataAccessLayer
-	dal = dataAccessLayer.DataAccessLayer{}
-	database := &mongoDatabase.MongoDatabase{}
-	err = database.Connect(&ctrlForConnection)
 	if err != nil {
 		log.Println(err)
-		c.JSON(http.StatusOK, gin.H{"message": "failed to connect to database"})
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to reset monitoring"})
 		return
 	}
-	dal.Database = database
-	stopChannel, cancelFunc := dataPipelineManager.LaunchDataCollectionProcess(dal, dal)
-	select {
-	case <-stopChannel:
-		log.Println("canceling context...")
-		cancelFunc()
-		c.JSON(http.StatusOK, gin.H{"message": "finished due to too many errors"})
-	case <-service.ServerShutdownChannel:
-		cancelFunc()
-	}
+	c.JSON(http.StatusOK, service.App.Supervisor.Status())
+}
+
+// @Summary Stop the data gathering process
+// @Tags pipeline
+// @Produce  json
+// @Success 200 {object} dataTypes.PipelineStatus
+// @Failure 409 {object} map[string]string
+// @Router /api/v1/pipeline/stop [post]
+func (service *ServerCtrl) StopPipeline(c *gin.Context) {
+	service.changePipelineState(c, service.App.Supervisor.Stop)
+}
+
+// @Summary Pause the data gathering process
+// @Tags pipeline
+// @Produce  json
+// @Success 200 {object} dataTypes.PipelineStatus
+// @Failure 409 {object} map[string]string
+// @Router /api/v1/pipeline/pause [post]
+func (service *ServerCtrl) PausePipeline(c *gin.Context) {
+	service.changePipelineState(c, service.App.Supervisor.Pause)
+}
+
+// @Summary Resume the data gathering process
+// @Tags pipeline
+// @Produce  json
+// @Success 200 {object} dataTypes.PipelineStatus
+// @Failure 409 {object} map[string]string
+// @Router /api/v1/pipeline/resume [post]
+func (service *ServerCtrl) ResumePipeline(c *gin.Context) {
+	service.changePipelineState(c, service.App.Supervisor.Resume)
+}
+
+// @Summary Data gathering process status
+// @Description Reports state, uptime, devices processed and failed, the current device and the last error
+// @Tags pipeline
+// @Produce  json
+// @Success 200 {object} dataTypes.PipelineStatus
+// @Router /api/v1/pipeline/status [get]
+func (service *ServerCtrl) PipelineStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, service.App.Supervisor.Status())
+}
+
+// @Summary Live data gathering process events
+// @Description Streams pipeline events (enqueued, dequeued, specs-set, price-set, benchmark-set, benchmark-estimated, reviewed, uploaded, validated, refreshed, failed) as Server-Sent Events
+// @Tags pipeline
+// @Produce  text/event-stream
+// @Param device query string false "Only stream events of this device"
+// @Success 200 {object} dataTypes.PipelineEvent
+// @Router /api/v1/pipeline/events [get]
+func (service *ServerCtrl) PipelineEvents(c *gin.Context) {
+	events, unsubscribe := service.App.Events.Subscribe(c.Query("device"))
+	defer unsubscribe()
+
+	heartbeat := time.NewTicker(eventsHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent(event.Type, event)
+			return true
+		case <-heartbeat.C:
+			c.SSEvent("heartbeat", gin.H{"time": time.Now()})
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
 
+func (service *ServerCtrl) changePipelineState(c *gin.Context, change func() error) {
+	if err := change(); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, service.App.Supervisor.Status())
 }
 
 // @Summary ResetDatabase reset the database
@@ -111,7 +227,7 @@ func (service *ServerCtrl) LaunchProcess(c *gin.Context) {
 // @Produce  json
 // @Success 200 {string} string "pong"
 // @Router /api/v1/resetDatabase [get]
-func ResetDatabase(c *gin.Context) {
+func (service *ServerCtrl) ResetDatabase(c *gin.Context) {
 	resetCtx, cancelReset := context.WithTimeout(context.Background(), time.Second*2)
 	defer cancelReset()
 	ctrl := dataTypes.FlowControl{Ctx: resetCtx, StopOnTooManyErrorsChannel: make(chan<- struct{})}
@@ -121,21 +237,7 @@ func ResetDatabase(c *gin.Context) {
 		return
 	}
 
-	channelForConnection := make(chan struct{}, 1)
-	ctxForConnection, cancelConnection := context.WithTimeout(context.Background(), time.Second*2)
-	defer cancelConnection()
-	ctrlForConnection := dataTypes.FlowControl{
-		Ctx:                        ctxForConnection,
-		StopOnTooManyErrorsChannel: channelForConnection,
-	}
-	database := &mongoDatabase.MongoDatabase{}
-	err = database.Connect(&ctrlForConnection)
-	if err != nil {
-		log.Println(err)
-		c.JSON(http.StatusOK, gin.H{"message": "failed to connect to database"})
-		return
-	}
-	err = database.ResetDatabase(&ctrl)
+	err = service.App.Database.ResetDatabase(&ctrl)
 	if err != nil {
 		log.Println(err)
 		c.JSON(http.StatusOK, gin.H{"message": "failed to reset database"})
@@ -146,13 +248,13 @@ func ResetDatabase(c *gin.Context) {
 }
 
 // @Summary Top 3 Devices
-// @Description Returns the top 3 devices based on filters
+// @Description Returns the top 3 devices based on filters, flagging the ones whose price is the lowest in 90 days. When the filters name a market, the price range applies to that market's price, which is included in each listing. Storage, RAM and SortByValue filters search variants, ranking them by value score when SortByValue is set
 // @Tags process
 // @Param Filters body dataTypes.Filters true "Filters JSON"
-// @Success 200 {object} map[string]string
+// @Success 200 {object} map[string][]dataTypes.DeviceListing
 // @Failure 500 {object} map[string]string
 // @Router /api/v1/top-devices [get]
-func TopDevices(c *gin.Context) {
+func (service *ServerCtrl) TopDevices(c *gin.Context) {
 	var filters dataTypes.Filters
 	if err := c.ShouldBindJSON(&filters); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid input", "error": err.Error()})
@@ -160,21 +262,349 @@ func TopDevices(c *gin.Context) {
 	}
 
 	ctrl := dataTypes.FlowControl{Ctx: context.Background(), StopOnTooManyErrorsChannel: make(chan<- struct{})}
+	devices, err := service.App.Database.GetTop3(&filters, &ctrl)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to get top devices"})
+		return
+	}
+
+	listings := toListings(devices, service.App.Database, &ctrl)
+	setSearchedPrices(listings, &filters)
+	c.JSON(http.StatusOK, gin.H{"devices": listings})
+}
+
+// toListings flags the devices whose current price is the lowest observed in lowestPriceWindow, among at least
+// minLowestPriceObservations observations.
+func toListings(devices []dataTypes.Device, database databaseInterface.DatabaseInterface, ctrl *dataTypes.FlowControl) []dataTypes.DeviceListing {
+	listings := make([]dataTypes.DeviceListing, 0, len(devices))
+	deviceIDs := make([]primitive.ObjectID, 0, len(devices))
+	for _, device := range devices {
+		listings = append(listings, dataTypes.DeviceListing{Device: device})
+		deviceIDs = append(deviceIDs, device.ID)
+	}
+	if len(deviceIDs) == 0 {
+		return listings
+	}
+
+	lowestPrices, err := database.GetLowestPrices(deviceIDs, time.Now().Add(-lowestPriceWindow), ctrl)
+	if err != nil {
+		log.Printf("in api.toListings failed to get lowest prices: %v", err)
+		return listings
+	}
+	for i := range listings {
+		lowestPrice, ok := lowestPrices[listings[i].ID]
+		listings[i].IsLowestPriceIn90Days = ok && lowestPrice.Observations >= minLowestPriceObservations &&
+			listings[i].RealPrice <= lowestPrice.Price
+	}
+	return listings
+}
+
+// setSearchedPrices attaches each listing's value score and, when the filters name a market, its price there.
+func setSearchedPrices(listings []dataTypes.DeviceListing, filters *dataTypes.Filters) {
+	for i := range listings {
+		price, ok := helpers.GetSearchedPrice(&listings[i].Device, filters)
+		if !ok {
+			continue
+		}
+		listings[i].ValueScore = aiAnalysis.GetValueScore(listings[i].ValidatedFinalScore, price.Price)
+		if filters.Market != "" {
+			listings[i].MarketPrice = &price
+		}
+	}
+}
+
+// @Summary Subscribe to a price-drop alert
+// @Description Registers a callback URL that receives a signed webhook whenever the device's price drops to the threshold or below
+// @Tags alerts
+// @Accept  json
+// @Produce  json
+// @Param AlertRequest body dataTypes.AlertRequest true "Alert subscription"
+// @Success 201 {object} dataTypes.AlertSubscription
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/alerts [post]
+func (service *ServerCtrl) CreateAlert(c *gin.Context) {
+	var request dataTypes.AlertRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid input", "error": err.Error()})
+		return
+	}
+	deviceID, err := primitive.ObjectIDFromHex(request.DeviceID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid device id", "error": err.Error()})
+		return
+	}
+	if request.Threshold <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "threshold must be positive"})
+		return
+	}
+	callbackURL, err := url.Parse(request.CallbackURL)
+	if err != nil || (callbackURL.Scheme != "http" && callbackURL.Scheme != "https") || callbackURL.Host == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "callback url must be an absolute http(s) url"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	ctrl := dataTypes.FlowControl{Ctx: ctx, StopOnTooManyErrorsChannel: make(chan struct{}, 1)}
+	device, err := service.App.Database.GetDeviceByID(deviceID, &ctrl)
+	if err != nil {
+		log.Println(err)
+		if errorTypes.IsMissingDocumentError(err) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "device not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to get device"})
+		return
+	}
+
+	subscription := dataTypes.AlertSubscription{
+		DeviceID:    device.ID,
+		DeviceName:  device.Name,
+		Threshold:   request.Threshold,
+		CallbackURL: callbackURL.String(),
+		CreatedAt:   time.Now(),
+	}
+	err = service.App.Database.AddAlertSubscription(&subscription, &ctrl)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to add alert subscription"})
+		return
+	}
+	c.JSON(http.StatusCreated, subscription)
+}
+
+// @Summary Device price history
+// @Description Returns every observed price of a device along with its min, max and current price
+// @Tags devices
+// @Produce  json
+// @Param id path string true "Device ID"
+// @Success 200 {object} dataTypes.PriceHistory
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/devices/{id}/prices [get]
+func (service *ServerCtrl) GetDevicePrices(c *gin.Context) {
+	deviceID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid device id", "error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	ctrl := dataTypes.FlowControl{Ctx: ctx, StopOnTooManyErrorsChannel: make(chan struct{}, 1)}
+	prices, err := service.App.Database.GetPriceHistory(deviceID, time.Time{}, &ctrl)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to get price history"})
+		return
+	}
+	if len(prices) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"message": "no price history for device"})
+		return
+	}
+
+	history := dataTypes.PriceHistory{
+		DeviceID: deviceID.Hex(),
+		Currency: prices[len(prices)-1].Currency,
+		Current:  prices[len(prices)-1].Price,
+		Min:      prices[0].Price,
+		Max:      prices[0].Price,
+		Prices:   prices,
+	}
+	for _, price := range prices {
+		history.Min = min(history.Min, price.Price)
+		history.Max = max(history.Max, price.Price)
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+// @Summary Benchmark catalog
+// @Description Returns the cached Geekbench leaderboard of a platform, downloading it if it expired. With a name, returns only the entry that best matches it
+// @Tags benchmarks
+// @Produce  json
+// @Param platform path string true "Platform (ios or android)"
+// @Param name query string false "Device name to look up, e.g. Samsung Galaxy S24 Ultra"
+// @Success 200 {object} dataTypes.BenchmarkCatalogInfo
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 502 {object} map[string]string
+// @Router /api/v1/benchmarks/{platform} [get]
+func (service *ServerCtrl) GetBenchmarkCatalog(c *gin.Context) {
+	platform := c.Param("platform")
+	if platform != brandCatalog.IOSBenchmarkPlatform && platform != brandCatalog.AndroidBenchmarkPlatform {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "platform must be ios or android"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*60)
+	defer cancel()
+	ctrl := dataTypes.FlowControl{Ctx: ctx, StopOnTooManyErrorsChannel: make(chan struct{}, 1)}
+	catalog := service.App.BenchmarkCatalog
+	platformCatalog, err := catalog.GetPlatformCatalog(platform, &ctrl)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusBadGateway, gin.H{"message": "failed to get benchmark catalog", "error": err.Error()})
+		return
+	}
+
+	if name := c.Query("name"); name != "" {
+		entry, ok := benchmarkScraper.FindEntry(platformCatalog, nameMatching.NewMatcher("", service.App.NameAliases), name)
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"message": "no benchmark matches the name"})
+			return
+		}
+		c.JSON(http.StatusOK, entry)
+		return
+	}
+	c.JSON(http.StatusOK, dataTypes.BenchmarkCatalogInfo{BenchmarkCatalog: platformCatalog, ExpiresAt: catalog.ExpiresAt(platformCatalog)})
+}
 
-	channelForConnect := make(chan struct{}, 1)
-	ctrlForConnection := dataTypes.FlowControl{
-		Ctx:                        context.TODO(),
-		StopOnTooManyErrorsChannel: channelForConnect,
+// @Summary Ingest a specific device
+// @Description Resolves a device by brand and model (or by spec API detail URL) and either enqueues it at top priority or, when synchronous, processes it before responding
+// @Tags process
+// @Accept  json
+// @Produce  json
+// @Param IngestionRequest body dataTypes.IngestionRequest true "Device to ingest"
+// @Success 200 {object} dataTypes.IngestionJob
+// @Success 202 {object} dataTypes.IngestionJob
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Failure 502 {object} dataTypes.IngestionJob
+// @Failure 503 {object} map[string]string
+// @Router /api/v1/ingest [post]
+func (service *ServerCtrl) Ingest(c *gin.Context) {
+	var request dataTypes.IngestionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid input", "error": err.Error()})
+		return
 	}
-	database := &mongoDatabase.MongoDatabase{}
-	err := database.Connect(&ctrlForConnection)
+	if request.Detail == "" && request.Model == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "either a model name or a detail url is required"})
+		return
+	}
+	if request.Detail != "" && !specAPI.IsSpecAPIURL(request.Detail) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "detail must be a spec API url"})
+		return
+	}
+
+	resolveCtx, cancelResolve := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancelResolve()
+	ctrl := dataTypes.FlowControl{Ctx: resolveCtx, StopOnTooManyErrorsChannel: make(chan struct{}, 1)}
+
+	var deviceInQueue dataTypes.DeviceInQueue
+	var err error
+	if request.Detail != "" {
+		deviceInQueue, err = specAPI.ResolveDeviceByDetail(request.Detail, service.App.HTTPClient, &ctrl)
+	} else {
+		deviceInQueue, err = specAPI.ResolveDevice(request.Brand, request.Model, service.App.HTTPClient, &ctrl)
+	}
+	if err != nil {
+		log.Println(err)
+		if errorTypes.IsNoSuchDeviceError(err) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "failed to find device", "error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"message": "failed to resolve device", "error": err.Error()})
+		return
+	}
+
+	database := service.App.Database
+	isStoredDevice, err := database.IsStoredDevice(deviceInQueue.Name, &ctrl)
 	if err != nil {
 		log.Println(err)
-		c.JSON(http.StatusOK, gin.H{"message": "failed to connect to database"})
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to check for existing device"})
+		return
+	} else if isStoredDevice {
+		c.JSON(http.StatusConflict, gin.H{"message": "device already exists"})
 		return
 	}
 
-	devices, err := database.GetTop3(&filters, &ctrl)
+	hooks := service.App.Hooks()
+	job := hooks.Jobs.NewJob(deviceInQueue, request.Synchronous)
+	deviceInQueue.JobID = job.ID
+	deviceInQueue.Priority = dataTypes.TopQueuePriority
 
-	c.JSON(http.StatusOK, gin.H{"devices": devices})
+	if request.Synchronous {
+		var processErr error
+		err = service.App.RunTask(c.Request.Context(), func(ctx context.Context) {
+			processErr = processIngestedDevice(ctx, deviceInQueue, service.App.DataAccessLayer(), service.App.ExternalServices(), hooks, service.App.Config.ErrorLimits)
+		})
+		if err != nil {
+			hooks.Jobs.UpdateJob(job.ID, dataTypes.IngestionJobFailed, err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"message": "application is shutting down"})
+			return
+		}
+		job, _ = hooks.Jobs.GetJob(job.ID)
+		if processErr != nil {
+			c.JSON(http.StatusBadGateway, job)
+			return
+		}
+		c.JSON(http.StatusOK, job)
+		return
+	}
+
+	err = database.EnqueueDevice(deviceInQueue, &ctrl)
+	if err != nil {
+		log.Println(err)
+		hooks.Jobs.UpdateJob(job.ID, dataTypes.IngestionJobFailed, err)
+		if errorTypes.IsDeviceAlreadyExistsError(err) {
+			c.JSON(http.StatusConflict, gin.H{"message": "device already exists", "error": err.Error()})
+			return
+		}
+		if errorTypes.IsDeviceAlreadyQueuedError(err) {
+			c.JSON(http.StatusConflict, gin.H{"message": "device is already being ingested", "error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to enqueue device"})
+		return
+	}
+	hooks.Events.Publish(dataTypes.DeviceEnqueuedEvent, deviceInQueue.Name, "")
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// processIngestedDevice processes the device within the request, stopping when ctx is done or errorLimits are exceeded.
+func processIngestedDevice(ctx context.Context, deviceInQueue dataTypes.DeviceInQueue, dal dataAccessLayer.DataAccessLayer,
+	services externalServices.ExternalServices, hooks dataPipelineManager.Hooks, errorLimits dataTypes.ErrorLimits) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute*10)
+	defer cancel()
+	stopChannel := make(chan struct{}, 1)
+	ctrl := dataTypes.FlowControl{Ctx: ctx, StopOnTooManyErrorsChannel: stopChannel, ErrorLimits: &errorLimits}
+	go func() {
+		select {
+		case <-stopChannel:
+			log.Printf("in api.processIngestedDevice (device: %v) too many errors, canceling...", deviceInQueue.Name)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	_, err := dataPipelineManager.ProcessDevice(deviceInQueue, dal, services, hooks, &ctrl)
+	if err != nil {
+		log.Printf("in api.processIngestedDevice (device: %v) failed to process device: %v", deviceInQueue.Name, err)
+	}
+	return err
+}
+
+// @Summary Ingestion job status
+// @Description Returns the status of an ingestion job. Finished jobs are kept for an hour
+// @Tags process
+// @Produce  json
+// @Param id path string true "Job ID"
+// @Success 200 {object} dataTypes.IngestionJob
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/ingest/{id} [get]
+func (service *ServerCtrl) GetIngestionJob(c *gin.Context) {
+	job, ok := service.App.Jobs.GetJob(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"message": "job not found"})
+		return
+	}
+	c.JSON(http.StatusOK, job)
 }
@@ -0,0 +1,111 @@
+// migrateDatabase copies the whole content of one database backend into another, replacing what the target held.
+// A target that holds data is only replaced with -force, and -dry-run reports what would be copied and replaced
+// without changing anything. Both backends are configured as the API's are, by the configuration file, the
+// environment and the flags, e.g.:
+//
+//	BOLT_DATABASE_PATH=deviceRec.db go run ./cmd/migrateDatabase -from mongo -to bolt -dry-run
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/brandCatalog"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/config"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/databaseFactory"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/databaseInterface"
+	"log"
+	"os"
+	"time"
+)
+
+func main() {
+	var from, to string
+	var isDryRun, isForced bool
+	var timeout time.Duration
+	cfg, err := config.Load(os.Args[1:], func(flags *flag.FlagSet) {
+		flags.StringVar(&from, "from", config.MongoBackend, "backend to copy from: mongo or bolt")
+		flags.StringVar(&to, "to", config.BoltBackend, "backend to copy into: mongo or bolt")
+		flags.BoolVar(&isDryRun, "dry-run", false, "report what would be copied and replaced without changing the target")
+		flags.BoolVar(&isForced, "force", false, "replace a target that already holds data")
+		flags.DurationVar(&timeout, "timeout", 10*time.Minute, "time limit for the whole migration")
+	})
+	if err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
+	for _, backend := range []string{from, to} {
+		if backend != config.MongoBackend && backend != config.BoltBackend {
+			log.Fatalf("unknown backend %q, expected %v or %v", backend, config.MongoBackend, config.BoltBackend)
+		}
+	}
+	if from == to {
+		log.Fatalf("source and target are both %v", from)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err = migrate(from, to, isDryRun, isForced, cfg, &dataTypes.FlowControl{Ctx: ctx, StopOnTooManyErrorsChannel: make(chan struct{}, 1)})
+	if err != nil {
+		log.Fatalf("failed to migrate from %v to %v: %v", from, to, err)
+	}
+}
+
+func migrate(from, to string, isDryRun, isForced bool, cfg *config.Config, ctrl *dataTypes.FlowControl) error {
+	brands, err := brandCatalog.NewCatalog(cfg.Data.BrandCatalogFile, cfg.Data.BenchmarkEstimationMethod)
+	if err != nil {
+		return err
+	}
+	source := databaseFactory.NewBackendDatabase(from, cfg, brands)
+	err = source.Connect(ctrl)
+	if err != nil {
+		return err
+	}
+	defer disconnect(source)
+	target := databaseFactory.NewBackendDatabase(to, cfg, brands)
+	err = target.Connect(ctrl)
+	if err != nil {
+		return err
+	}
+	defer disconnect(target)
+
+	snapshot, err := source.ExportSnapshot(ctrl)
+	if err != nil {
+		return err
+	}
+	targetSnapshot, err := target.ExportSnapshot(ctrl)
+	if err != nil {
+		return err
+	}
+	isTargetEmpty := describe(&targetSnapshot) == describe(&dataTypes.DatabaseSnapshot{})
+	if isDryRun {
+		log.Printf("would migrate %v from %v to %v", describe(&snapshot), from, to)
+		if !isTargetEmpty {
+			log.Printf("would replace %v in %v, which requires -force", describe(&targetSnapshot), to)
+		}
+		return nil
+	}
+	if !isTargetEmpty && !isForced {
+		return fmt.Errorf("%v holds %v, pass -force to replace them", to, describe(&targetSnapshot))
+	}
+
+	err = target.ImportSnapshot(&snapshot, ctrl)
+	if err != nil {
+		return err
+	}
+	log.Printf("migrated %v from %v to %v", describe(&snapshot), from, to)
+	return nil
+}
+
+func describe(snapshot *dataTypes.DatabaseSnapshot) string {
+	return fmt.Sprintf("%v devices, %v queued devices, %v price points, %v alert subscriptions and %v benchmark catalogs",
+		len(snapshot.Devices), len(snapshot.Queue), len(snapshot.PriceHistory), len(snapshot.AlertSubscriptions),
+		len(snapshot.BenchmarkCatalogs))
+}
+
+func disconnect(database databaseInterface.DatabaseInterface) {
+	err := database.Disconnect(&dataTypes.FlowControl{Ctx: context.Background(), StopOnTooManyErrorsChannel: make(chan struct{}, 1)})
+	if err != nil {
+		log.Printf("WARNING: failed to disconnect: %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+// migrateSchema applies the pending schema migrations of the Mongo database, or with -dry-run lists them with the
+// documents each one would change. Mongo is configured as the API's is, by the configuration file, the environment
+// and the flags, e.g.:
+//
+//	go run ./cmd/migrateSchema -mongo-uri mongodb://localhost:27017 -dry-run
+//
+// A dry run only reads. Otherwise connecting bootstraps the metadata documents and the indexes first, as the API does.
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/brandCatalog"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/config"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/mongoDatabase"
+	"log"
+	"os"
+	"time"
+)
+
+func main() {
+	var isDryRun bool
+	var timeout time.Duration
+	cfg, err := config.Load(os.Args[1:], func(flags *flag.FlagSet) {
+		flags.BoolVar(&isDryRun, "dry-run", false, "list the pending migrations without changing the database")
+		flags.DurationVar(&timeout, "timeout", 30*time.Minute, "time limit for all the migrations")
+	})
+	if err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err = migrate(isDryRun, *cfg, &dataTypes.FlowControl{Ctx: ctx, StopOnTooManyErrorsChannel: make(chan struct{}, 1)})
+	if err != nil {
+		log.Fatalf("failed to migrate: %v", err)
+	}
+}
+
+func migrate(isDryRun bool, cfg config.Config, ctrl *dataTypes.FlowControl) error {
+	// The migrations run below, so connecting mustn't apply them.
+	cfg.Mongo.MigrateOnStartup = false
+	brands, err := brandCatalog.NewCatalog(cfg.Data.BrandCatalogFile, cfg.Data.BenchmarkEstimationMethod)
+	if err != nil {
+		return err
+	}
+	database := mongoDatabase.NewMongoDatabase(cfg.Mongo, cfg.Database.MaxQueueSize, brands)
+	connect := database.Connect
+	if isDryRun {
+		connect = database.ConnectWithoutBootstrap
+	}
+	err = connect(ctrl)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := database.Disconnect(&dataTypes.FlowControl{Ctx: context.Background(), StopOnTooManyErrorsChannel: make(chan struct{}, 1)})
+		if err != nil {
+			log.Printf("WARNING: failed to disconnect: %v", err)
+		}
+	}()
+
+	migrations, err := database.Migrate(isDryRun, ctrl)
+	for _, migration := range migrations {
+		if isDryRun {
+			log.Printf("pending migration %v %v would affect %v documents", migration.Version, migration.Name, migration.AffectedDocuments)
+		} else {
+			log.Printf("applied migration %v %v to %v documents", migration.Version, migration.Name, migration.AffectedDocuments)
+		}
+	}
+	if err == nil && len(migrations) == 0 {
+		log.Println("no pending migrations")
+	}
+	return err
+}
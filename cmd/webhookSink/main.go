@@ -0,0 +1,45 @@
+// webhookSink is a local HTTP sink for testing price-drop alerts: it verifies the signature of every alert it
+// receives and logs its payload, e.g.:
+//
+//	go run ./cmd/webhookSink -sink-address :9090
+package main
+
+import (
+	"flag"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/config"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/priceAlerts"
+	"io"
+	"log"
+	"net/http"
+	"os"
+)
+
+func main() {
+	var address string
+	cfg, err := config.Load(os.Args[1:], func(flags *flag.FlagSet) {
+		flags.StringVar(&address, "sink-address", ":9090", "address the sink listens on")
+	})
+	if err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
+	secret := []byte(cfg.Keys.AlertWebhookSecret)
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Printf("failed to read alert: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if !priceAlerts.IsValidSignature(body, secret, r.Header.Get(priceAlerts.SignatureHeader)) {
+			log.Printf("rejected alert with invalid signature: %s", body)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		log.Printf("received alert: %s", body)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	log.Printf("listening for price alerts on %v", address)
+	log.Fatal(http.ListenAndServe(address, nil))
+}
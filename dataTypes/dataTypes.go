@@ -17,6 +17,69 @@ const (
 	HighEnd
 )
 
+const (
+	NormalQueuePriority = iota
+	TopQueuePriority
+)
+
+// DetailLink and ImageLink index the links of the device name to links maps the listings are gathered into.
+const (
+	DetailLink = 0
+	ImageLink  = 1
+)
+
+const (
+	IngestionJobQueued     = "queued"
+	IngestionJobProcessing = "processing"
+	IngestionJobDone       = "done"
+	IngestionJobFailed     = "failed"
+)
+
+const (
+	DeviceEnqueuedEvent         = "enqueued"
+	DeviceDequeuedEvent         = "dequeued"
+	SpecsSetEvent               = "specs-set"
+	PriceSetEvent               = "price-set"
+	BenchmarkSetEvent           = "benchmark-set"
+	BenchmarkEstimatedEvent     = "benchmark-estimated"
+	BenchmarkInferredEvent      = "benchmark-chipset-inferred"
+	DeviceReviewedEvent         = "reviewed"
+	DeviceUploadedEvent         = "uploaded"
+	DeviceValidatedEvent        = "validated"
+	DeviceProcessingFailedEvent = "failed"
+	DeviceRefreshedEvent        = "refreshed"
+)
+
+const (
+	PriceRefresh     = "price"
+	BenchmarkRefresh = "benchmark"
+	ReviewRefresh    = "review"
+)
+
+const (
+	ParsedMethod    = "parsed"
+	AiMethod        = "ai"
+	EstimatedMethod = "estimated"
+	InferredMethod  = "inferred"
+)
+
+// ChipsetInferredEstimation marks benchmarks taken from the median of measured devices with the same chipset.
+const ChipsetInferredEstimation = "chipset-inferred"
+
+const (
+	RealPriceProvenance       = "real-price"
+	SingleCoreScoreProvenance = "benchmark-single-core-score"
+	MultiCoreScoreProvenance  = "benchmark-multi-core-score"
+	GPUScoreProvenance        = "benchmark-gpu-score"
+	ReviewProvenancePrefix    = "review-"
+)
+
+const (
+	StageSucceeded = "succeeded"
+	StageFailed    = "failed"
+	StageSkipped   = "skipped"
+)
+
 const EarliestYearBound = 2019
 
 type Year struct {
@@ -26,25 +89,172 @@ type Year struct {
 }
 
 type Device struct {
-	ID                    primitive.ObjectID `bson:"_id"`
-	Month                 primitive.ObjectID `bson:"month"`
-	Year                  primitive.ObjectID `bson:"year"`
-	Brand                 string             `bson:"brand"`
-	Name                  string             `bson:"name"`
-	Specs                 Specifications     `bson:"specs"`
-	Review                ReviewData         `bson:"review"`
-	Benchmark             BenchmarkScores    `bson:"benchmark"`
-	ValidatedFinalScore   float64            `bson:"validated-final-score"`
-	UnvalidatedFinalScore float64            `bson:"unvalidated-final-score"`
-	RealPrice             int                `bson:"real-price"`
-	PriceCategory         int                `bson:"price-category"`
-	Image                 string             `bson:"image"`
+	ID                    primitive.ObjectID    `bson:"_id"`
+	Month                 primitive.ObjectID    `bson:"month"`
+	Year                  primitive.ObjectID    `bson:"year"`
+	Brand                 string                `bson:"brand"`
+	Name                  string                `bson:"name"`
+	Specs                 Specifications        `bson:"specs"`
+	Review                ReviewData            `bson:"review"`
+	Benchmark             BenchmarkScores       `bson:"benchmark"`
+	ValidatedFinalScore   float64               `bson:"validated-final-score"`
+	UnvalidatedFinalScore float64               `bson:"unvalidated-final-score"`
+	RealPrice             int                   `bson:"real-price"`
+	Currency              string                `bson:"currency"`
+	Prices                []MarketPrice         `bson:"prices"`
+	Variants              []Variant             `bson:"variants"`
+	PriceCategory         int                   `bson:"price-category"`
+	Image                 string                `bson:"image"`
+	Detail                string                `bson:"detail"`
+	StageResults          []StageResult         `bson:"stage-results"`
+	Provenance            map[string]Provenance `bson:"provenance"`
+}
+
+// Variant is one storage/RAM configuration of a device, priced separately.
+type Variant struct {
+	StorageGB int           `bson:"storage-gb"`
+	RAMGB     float64       `bson:"ram-gb"`
+	RealPrice int           `bson:"real-price"`
+	Currency  string        `bson:"currency"`
+	Prices    []MarketPrice `bson:"prices"`
+}
+
+// MarketPrice is a device's price in one target market, either scraped from a source serving that market or
+// converted from the primary market's price.
+type MarketPrice struct {
+	Market      string `bson:"market" json:"market"`
+	Price       int    `bson:"price" json:"price"`
+	Currency    string `bson:"currency" json:"currency"`
+	Source      string `bson:"source" json:"source"`
+	URL         string `bson:"url,omitempty" json:"url,omitempty"`
+	IsConverted bool   `bson:"is-converted" json:"is_converted"`
+}
+
+type PricePoint struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	DeviceID  primitive.ObjectID `bson:"device-id" json:"-"`
+	Price     int                `bson:"price" json:"price"`
+	Currency  string             `bson:"currency" json:"currency"`
+	SourceURL string             `bson:"source-url" json:"source_url"`
+	Time      time.Time          `bson:"time" json:"time"`
+}
+
+// LowestPrice is the lowest of a device's price observations in a window, and how many observations there were.
+type LowestPrice struct {
+	Price        int
+	Observations int
+}
+
+type PriceHistory struct {
+	DeviceID string       `json:"device_id"`
+	Currency string       `json:"currency"`
+	Current  int          `json:"current"`
+	Min      int          `json:"min"`
+	Max      int          `json:"max"`
+	Prices   []PricePoint `json:"prices"`
+}
+
+type AlertRequest struct {
+	DeviceID    string `json:"device_id"`
+	Threshold   int    `json:"threshold"`
+	CallbackURL string `json:"callback_url"`
+}
+
+type AlertSubscription struct {
+	ID          primitive.ObjectID `bson:"_id" json:"id"`
+	DeviceID    primitive.ObjectID `bson:"device-id" json:"device_id"`
+	DeviceName  string             `bson:"device-name" json:"device_name"`
+	Threshold   int                `bson:"threshold" json:"threshold"`
+	CallbackURL string             `bson:"callback-url" json:"callback_url"`
+	CreatedAt   time.Time          `bson:"created-at" json:"created_at"`
+}
+
+// PriceAlert is the payload delivered to a subscription's callback URL.
+type PriceAlert struct {
+	SubscriptionID string    `json:"subscription_id"`
+	DeviceID       string    `json:"device_id"`
+	DeviceName     string    `json:"device_name"`
+	Threshold      int       `json:"threshold"`
+	OldPrice       int       `json:"old_price"`
+	NewPrice       int       `json:"new_price"`
+	Currency       string    `json:"currency"`
+	SourceURL      string    `json:"source_url"`
+	Time           time.Time `json:"time"`
+}
+
+// BenchmarkCatalog is a parsed Geekbench leaderboard of a single platform.
+type BenchmarkCatalog struct {
+	Platform string `bson:"_id" json:"platform"`
+	URL      string `bson:"url" json:"url"`
+	// GPUAPI is the compute API of the GPU scores, e.g. "metal" or "vulkan".
+	GPUAPI    string           `bson:"gpu-api" json:"gpu_api"`
+	FetchedAt time.Time        `bson:"fetched-at" json:"fetched_at"`
+	Entries   []BenchmarkEntry `bson:"entries" json:"entries"`
+}
+
+// BenchmarkCatalogInfo is a leaderboard as the API shows it, along with when it is due to be downloaded again.
+type BenchmarkCatalogInfo struct {
+	BenchmarkCatalog
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type BenchmarkEntry struct {
+	Name            string `bson:"name" json:"name"`
+	SingleCoreScore int    `bson:"single-core-score" json:"single_core_score"`
+	MultiCoreScore  int    `bson:"multi-core-score" json:"multi_core_score"`
+	// GPUScore is the compute score on the platform's GPU API; zero when the leaderboard doesn't list the device.
+	GPUScore int `bson:"gpu-score" json:"gpu_score"`
+}
+
+// DeviceListing is a device as it is listed in search results.
+type DeviceListing struct {
+	Device
+	IsLowestPriceIn90Days bool         `json:"is_lowest_price_in_90_days"`
+	MarketPrice           *MarketPrice `json:"market_price,omitempty"`
+	// ValueScore is the device's score per 1000 units of its price, or of its variant's price when the search
+	// was at the variant level.
+	ValueScore float64 `json:"value_score"`
+}
+
+// Provenance records where the value of a single device field came from.
+type Provenance struct {
+	Source string    `bson:"source"`
+	URL    string    `bson:"url,omitempty"`
+	Method string    `bson:"method"`
+	Time   time.Time `bson:"time"`
+}
+
+type StageResult struct {
+	Stage    string        `bson:"stage"`
+	Status   string        `bson:"status"`
+	Error    string        `bson:"error,omitempty"`
+	Duration time.Duration `bson:"duration" swaggertype:"primitive,integer"`
 }
 
 type BenchmarkScores struct {
-	IsEstimatedBenchmark bool    `bson:"is-estimated-benchmark"`
-	MultiCoreScore       float64 `bson:"multi-core-score"`
-	SingleCoreScore      float64 `bson:"single-core-score"`
+	IsEstimatedBenchmark bool `bson:"is-estimated-benchmark"`
+	// IsChipsetInferred marks scores reused from measured devices with the same chipset. They are not measured, so
+	// IsEstimatedBenchmark is set as well.
+	IsChipsetInferred bool    `bson:"is-chipset-inferred"`
+	MultiCoreScore    float64 `bson:"multi-core-score"`
+	SingleCoreScore   float64 `bson:"single-core-score"`
+	// GPUScore is optional: zero means no GPU benchmark was found, and the benchmark is scored with a neutral GPU
+	// score. It is inferred from the chipset, but never estimated.
+	GPUScore float64 `bson:"gpu-score"`
+	// Estimation is set only on estimated and chipset-inferred benchmarks.
+	Estimation *BenchmarkEstimation `bson:"estimation,omitempty"`
+}
+
+// BenchmarkEstimation records which estimator produced an estimated benchmark and how certain it is.
+type BenchmarkEstimation struct {
+	Method string `bson:"method"`
+	// ConfidenceLevel is the probability that the true scores fall in the intervals. Zero means the estimator
+	// gives no intervals.
+	ConfidenceLevel    float64     `bson:"confidence-level"`
+	SingleCoreInterval MinMaxFloat `bson:"single-core-interval"`
+	MultiCoreInterval  MinMaxFloat `bson:"multi-core-interval"`
+	// SampleSize is the number of devices the estimator was fitted on, or the chipset's scores taken from.
+	SampleSize int `bson:"sample-size"`
 }
 
 type ReviewData struct {
@@ -64,6 +274,25 @@ type Specifications struct {
 	PixelDensity       float64   `bson:"pixel-density"`
 	RefreshRate        int       `bson:"refresh-rate"`
 	Nits               int       `bson:"nits"`
+	// The fields below are optional: a zero value means the spec API didn't list them.
+	Chipset           string     `bson:"chipset"`
+	RAMOptionsGB      []float64  `bson:"ram-options-gb"`
+	StorageOptionsGB  []int      `bson:"storage-options-gb"`
+	WeightGrams       float64    `bson:"weight-grams"`
+	Dimensions        Dimensions `bson:"dimensions"`
+	WiredChargingW    float64    `bson:"wired-charging-w"`
+	WirelessChargingW float64    `bson:"wireless-charging-w"`
+	IPRating          string     `bson:"ip-rating"`
+	OS                string     `bson:"os"`
+	OSVersion         float64    `bson:"os-version"`
+	MajorOSUpgrades   int        `bson:"major-os-upgrades"`
+	Has5G             bool       `bson:"has-5g"`
+}
+
+type Dimensions struct {
+	HeightMM    float64 `bson:"height-mm"`
+	WidthMM     float64 `bson:"width-mm"`
+	ThicknessMM float64 `bson:"thickness-mm"`
 }
 
 type Month struct {
@@ -93,15 +322,45 @@ type MinMaxValues struct {
 	Magnitude       MinMaxFloat `bson:"magnitude"`
 	SingleCoreScore MinMaxFloat `bson:"single-core-score"`
 	MultiCoreScore  MinMaxFloat `bson:"multi-core-score"`
+	GPUScore        MinMaxFloat `bson:"gpu-score"`
 	BatteryCapacity MinMaxFloat `bson:"battery-capacity"`
 	PixelDensity    MinMaxFloat `bson:"pixel-density"`
 	Nits            MinMaxFloat `bson:"nits"`
 }
 
 type DeviceInQueue struct {
-	Name   string `bson:"name"`
-	Image  string `bson:"image"`
-	Detail string `bson:"detail"`
+	Name          string             `bson:"name"`
+	Image         string             `bson:"image"`
+	Detail        string             `bson:"detail"`
+	Priority      int                `bson:"priority"`
+	JobID         string             `bson:"job-id,omitempty"`
+	DeviceID      primitive.ObjectID `bson:"device-id,omitempty"`
+	RefreshFields []string           `bson:"refresh-fields,omitempty"`
+}
+
+type IngestionRequest struct {
+	Brand       string `json:"brand"`
+	Model       string `json:"model"`
+	Detail      string `json:"detail"`
+	Synchronous bool   `json:"synchronous"`
+}
+
+type IngestionJob struct {
+	ID          string    `json:"id"`
+	DeviceName  string    `json:"device_name"`
+	Detail      string    `json:"detail"`
+	Synchronous bool      `json:"synchronous"`
+	Status      string    `json:"status"`
+	Error       string    `json:"error,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type PipelineEvent struct {
+	Type       string    `json:"type"`
+	DeviceName string    `json:"device_name"`
+	Message    string    `json:"message,omitempty"`
+	Time       time.Time `json:"time"`
 }
 
 type ErrorCounters struct {
@@ -127,9 +386,28 @@ type DeviceIDsDocument struct {
 	DeviceIDs []primitive.ObjectID `bson:"device-ids"`
 }
 
+// ErrorLimits are how many errors of each kind are tolerated before the flow is stopped.
+type ErrorLimits struct {
+	CleanUpErrors              int `json:"clean_up_errors"`
+	SentimentAnalysisErrors    int `json:"sentiment_analysis_errors"`
+	CreatingNewAiClientErrors  int `json:"creating_new_ai_client_errors"`
+	AiNetworkErrors            int `json:"ai_network_errors"`
+	FailedAiInstructionErrors  int `json:"failed_ai_instruction_errors"`
+	GettingURLErrors           int `json:"getting_url_errors"`
+	GettingDocumentErrors      int `json:"getting_document_errors"`
+	ParsingErrors              int `json:"parsing_errors"`
+	MissingDocumentErrors      int `json:"missing_document_errors"`
+	DatabaseNetworkErrors      int `json:"database_network_errors"`
+	GeneralDatabaseErrors      int `json:"general_database_errors"`
+	InvalidConstIDStringErrors int `json:"invalid_const_id_string_errors"`
+}
+
 type FlowControl struct {
 	Ctx                        context.Context
 	StopOnTooManyErrorsChannel chan<- struct{}
+	// ErrorLimits stop the flow, through StopOnTooManyErrorsChannel, once one kind of error exceeds its limit. Without
+	// them errors are only counted.
+	ErrorLimits *ErrorLimits
 }
 
 type ParsingErrorLog struct {
@@ -148,8 +426,68 @@ type Filters struct {
 	DisplaySize MinMaxFloat
 	RefreshRate MinMaxInt
 	Brands      []string
+	// Market, when set, applies the Price range to the device's price in that market instead of its primary price.
+	Market string
+	// Storage (GB) and RAM (GB) ranges are ignored when their Max is 0. Setting either of them, or SortByValue,
+	// searches variants instead of devices: each result holds the single variant that matched, and the Price
+	// range applies to the variant's price.
+	Storage     MinMaxInt
+	RAM         MinMaxFloat
+	SortByValue bool
+	// The spec filters below are ignored when left at their zero value, and so are ranges whose Max is 0.
+	// Chipset matches any chipset containing it, case-insensitively, and IPRatings any of the listed ratings.
+	Chipset          string
+	Weight           MinMaxFloat
+	Height           MinMaxFloat
+	Width            MinMaxFloat
+	Thickness        MinMaxFloat
+	WiredCharging    MinMaxFloat
+	WirelessCharging MinMaxFloat
+	IPRatings        []string
+	OS               string
+	OSVersion        MinMaxFloat
+	MinOSUpgrades    int
+	Requires5G       bool
+}
+
+type PipelineStatus struct {
+	State            string    `json:"state"`
+	StartedAt        time.Time `json:"started_at"`
+	Uptime           string    `json:"uptime"`
+	DevicesProcessed int       `json:"devices_processed"`
+	DevicesFailed    int       `json:"devices_failed"`
+	CurrentDevice    string    `json:"current_device"`
+	LastError        string    `json:"last_error"`
+}
+
+// Readiness is what the readiness probe reports: the application is ready once it is started and its database is up.
+type Readiness struct {
+	State        string `json:"state"`
+	IsDatabaseUp bool   `json:"is_database_up"`
+	Pipeline     string `json:"pipeline"`
+}
+
+// SchemaMigration is a schema migration of the Mongo data model, as it is recorded once applied.
+type SchemaMigration struct {
+	Version           int       `bson:"_id" json:"version"`
+	Name              string    `bson:"name" json:"name"`
+	AppliedAt         time.Time `bson:"applied-at" json:"applied_at"`
+	AffectedDocuments int64     `bson:"affected-documents" json:"affected_documents"`
 }
 
 type ValidationFlag struct {
 	IsUnfinishedValidation bool `bson:"is-unfinished-validation"`
 }
+
+// DatabaseSnapshot is the whole content of a database, as it is migrated from one backend to another.
+type DatabaseSnapshot struct {
+	Devices                 []Device                            `bson:"devices"`
+	Years                   []Year                              `bson:"years"`
+	Months                  []Month                             `bson:"months"`
+	MinMax                  ValidatedAndUnvalidatedMinMaxValues `bson:"min-max"`
+	Queue                   []DeviceInQueue                     `bson:"queue"`
+	PriceHistory            []PricePoint                        `bson:"price-history"`
+	AlertSubscriptions      []AlertSubscription                 `bson:"alert-subscriptions"`
+	BenchmarkCatalogs       []BenchmarkCatalog                  `bson:"benchmark-catalogs"`
+	IsInterruptedValidation bool                                `bson:"is-interrupted-validation"`
+}
@@ -15,32 +15,29 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/api/v1/launchProcess": {
+        "/api/v1/admin/config": {
             "get": {
-                "description": "Do launch the data gathering process",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Shows the configuration the application runs with, with the API keys and the Mongo password redacted",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "process"
+                    "admin"
                 ],
-                "summary": "LaunchProcess launch the data gathering process",
+                "summary": "Effective configuration",
                 "responses": {
                     "200": {
-                        "description": "pong",
+                        "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/config.Config"
                         }
                     }
                 }
             }
         },
-        "/api/v1/ping": {
-            "get": {
-                "description": "Do Ping",
+        "/api/v1/alerts": {
+            "post": {
+                "description": "Registers a callback URL that receives a signed webhook whenever the device's price drops to the threshold or below",
                 "consumes": [
                     "application/json"
                 ],
@@ -48,63 +45,232 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "example"
+                    "alerts"
+                ],
+                "summary": "Subscribe to a price-drop alert",
+                "parameters": [
+                    {
+                        "description": "Alert subscription",
+                        "name": "AlertRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dataTypes.AlertRequest"
+                        }
+                    }
                 ],
-                "summary": "Ping example",
                 "responses": {
-                    "200": {
-                        "description": "pong",
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/dataTypes.AlertSubscription"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     }
                 }
             }
         },
-        "/api/v1/resetDatabase": {
+        "/api/v1/benchmarks/{platform}": {
             "get": {
-                "description": "Reset the device databse",
-                "consumes": [
+                "description": "Returns the cached Geekbench leaderboard of a platform, downloading it if it expired. With a name, returns only the entry that best matches it",
+                "produces": [
                     "application/json"
                 ],
+                "tags": [
+                    "benchmarks"
+                ],
+                "summary": "Benchmark catalog",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Platform (ios or android)",
+                        "name": "platform",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Device name to look up, e.g. Samsung Galaxy S24 Ultra",
+                        "name": "name",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dataTypes.BenchmarkCatalogInfo"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/devices/{id}/prices": {
+            "get": {
+                "description": "Returns every observed price of a device along with its min, max and current price",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "process"
+                    "devices"
+                ],
+                "summary": "Device price history",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Device ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "ResetDatabase reset the database",
                 "responses": {
                     "200": {
-                        "description": "pong",
+                        "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/dataTypes.PriceHistory"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     }
                 }
             }
         },
-        "/api/v1/top-devices": {
-            "get": {
-                "description": "Returns the top 3 devices based on filters",
+        "/api/v1/ingest": {
+            "post": {
+                "description": "Resolves a device by brand and model (or by spec API detail URL) and either enqueues it at top priority or, when synchronous, processes it before responding",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
                     "process"
                 ],
-                "summary": "Top 3 Devices",
+                "summary": "Ingest a specific device",
                 "parameters": [
                     {
-                        "description": "Filters JSON",
-                        "name": "Filters",
+                        "description": "Device to ingest",
+                        "name": "IngestionRequest",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/dataTypes.Filters"
+                            "$ref": "#/definitions/dataTypes.IngestionRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dataTypes.IngestionJob"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/dataTypes.IngestionJob"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -120,20 +286,39 @@ const docTemplate = `{
                                 "type": "string"
                             }
                         }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/dataTypes.IngestionJob"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
                     }
                 }
             }
         },
-        "/api/v1/user/{id}": {
+        "/api/v1/ingest/{id}": {
             "get": {
-                "description": "Get details of a user by their ID",
+                "description": "Returns the status of an ingestion job. Finished jobs are kept for an hour",
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "user"
+                    "process"
                 ],
+                "summary": "Ingestion job status",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "User ID",
+                        "type": "string",
+                        "description": "Job ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -143,59 +328,1275 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/dataTypes.IngestionJob"
                         }
                     },
                     "404": {
-                        "description": "User not found",
+                        "description": "Not Found",
                         "schema": {
-                            "type": "string"
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "dataTypes.Filters": {
-            "type": "object",
-            "properties": {
-                "brands": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
-                    }
-                },
-                "displaySize": {
-                    "$ref": "#/definitions/dataTypes.MinMaxFloat"
-                },
-                "price": {
-                    "$ref": "#/definitions/dataTypes.MinMaxInt"
-                },
-                "refreshRate": {
-                    "$ref": "#/definitions/dataTypes.MinMaxInt"
-                }
-            }
         },
-        "dataTypes.MinMaxFloat": {
-            "type": "object",
-            "properties": {
-                "max": {
-                    "type": "number"
-                },
-                "min": {
-                    "type": "number"
+        "/api/v1/launchProcess": {
+            "get": {
+                "description": "Starts the data gathering pipeline in the background. Only one run is allowed at a time.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "pipeline"
+                ],
+                "summary": "Start the data gathering process",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dataTypes.PipelineStatus"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
                 }
             }
         },
-        "dataTypes.MinMaxInt": {
-            "type": "object",
-            "properties": {
-                "max": {
-                    "type": "integer"
+        "/api/v1/ping": {
+            "get": {
+                "description": "Do Ping",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "example"
+                ],
+                "summary": "Ping example",
+                "responses": {
+                    "200": {
+                        "description": "pong",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/pipeline/events": {
+            "get": {
+                "description": "Streams pipeline events (enqueued, dequeued, specs-set, price-set, benchmark-set, benchmark-estimated, reviewed, uploaded, validated, refreshed, failed) as Server-Sent Events",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "pipeline"
+                ],
+                "summary": "Live data gathering process events",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only stream events of this device",
+                        "name": "device",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dataTypes.PipelineEvent"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/pipeline/pause": {
+            "post": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "pipeline"
+                ],
+                "summary": "Pause the data gathering process",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dataTypes.PipelineStatus"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/pipeline/resume": {
+            "post": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "pipeline"
+                ],
+                "summary": "Resume the data gathering process",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dataTypes.PipelineStatus"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/pipeline/start": {
+            "post": {
+                "description": "Starts the data gathering pipeline in the background. Only one run is allowed at a time.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "pipeline"
+                ],
+                "summary": "Start the data gathering process",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dataTypes.PipelineStatus"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/pipeline/status": {
+            "get": {
+                "description": "Reports state, uptime, devices processed and failed, the current device and the last error",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "pipeline"
+                ],
+                "summary": "Data gathering process status",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dataTypes.PipelineStatus"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/pipeline/stop": {
+            "post": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "pipeline"
+                ],
+                "summary": "Stop the data gathering process",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dataTypes.PipelineStatus"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/ready": {
+            "get": {
+                "description": "Reports whether the application is started and its database is up",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Readiness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dataTypes.Readiness"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/dataTypes.Readiness"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/resetDatabase": {
+            "get": {
+                "description": "Reset the device databse",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "process"
+                ],
+                "summary": "ResetDatabase reset the database",
+                "responses": {
+                    "200": {
+                        "description": "pong",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/top-devices": {
+            "get": {
+                "description": "Returns the top 3 devices based on filters, flagging the ones whose price is the lowest in 90 days. When the filters name a market, the price range applies to that market's price, which is included in each listing. Storage, RAM and SortByValue filters search variants, ranking them by value score when SortByValue is set",
+                "tags": [
+                    "process"
+                ],
+                "summary": "Top 3 Devices",
+                "parameters": [
+                    {
+                        "description": "Filters JSON",
+                        "name": "Filters",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dataTypes.Filters"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/dataTypes.DeviceListing"
+                                }
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/{id}": {
+            "get": {
+                "description": "Get details of a user by their ID",
+                "tags": [
+                    "user"
+                ],
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "config.AIConfig": {
+            "type": "object",
+            "properties": {
+                "model_name": {
+                    "type": "string"
+                }
+            }
+        },
+        "config.Config": {
+            "type": "object",
+            "properties": {
+                "ai": {
+                    "$ref": "#/definitions/config.AIConfig"
+                },
+                "data": {
+                    "$ref": "#/definitions/config.DataConfig"
+                },
+                "database": {
+                    "$ref": "#/definitions/config.DatabaseConfig"
+                },
+                "error_limits": {
+                    "description": "ErrorLimits are how many errors of each kind the pipeline tolerates before it stops.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/dataTypes.ErrorLimits"
+                        }
+                    ]
+                },
+                "keys": {
+                    "$ref": "#/definitions/config.KeysConfig"
+                },
+                "mongo": {
+                    "$ref": "#/definitions/config.MongoConfig"
+                },
+                "pipeline": {
+                    "$ref": "#/definitions/config.PipelineConfig"
+                },
+                "server": {
+                    "$ref": "#/definitions/config.ServerConfig"
+                }
+            }
+        },
+        "config.DataConfig": {
+            "type": "object",
+            "properties": {
+                "benchmark_catalog_ttl": {
+                    "description": "BenchmarkCatalogTTL is how long a downloaded benchmark leaderboard is used before it is downloaded again.",
+                    "type": "string"
+                },
+                "benchmark_estimation_method": {
+                    "description": "BenchmarkEstimationMethod is used by the brands that don't set one: last-year-equivalent, regression or none.",
+                    "type": "string"
+                },
+                "brand_catalog_file": {
+                    "description": "BrandCatalogFile overrides and extends the default brands. It is optional.",
+                    "type": "string"
+                },
+                "currency_rates_file": {
+                    "type": "string"
+                },
+                "markets": {
+                    "description": "Markets are the market codes prices are gathered for, each optionally followed by \":\" and the currency to use\ninstead of the market's default, e.g. [\"IL\", \"US:EUR\"]. The first one is the primary market.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "name_aliases_file": {
+                    "description": "NameAliasesFile maps names as sources spell them to the names devices are stored under. It is optional.",
+                    "type": "string"
+                }
+            }
+        },
+        "config.DatabaseConfig": {
+            "type": "object",
+            "properties": {
+                "backend": {
+                    "description": "Backend is mongo, memory or bolt.",
+                    "type": "string"
+                },
+                "bolt_path": {
+                    "type": "string"
+                },
+                "max_queue_size": {
+                    "description": "MaxQueueSize caps the devices the enqueuer keeps waiting in the queue.",
+                    "type": "integer"
+                }
+            }
+        },
+        "config.KeysConfig": {
+            "type": "object",
+            "properties": {
+                "alert_webhook_secret": {
+                    "type": "string"
+                },
+                "custom_search_key": {
+                    "type": "string"
+                },
+                "gen_ai_key": {
+                    "type": "string"
+                },
+                "price_search_engine_id": {
+                    "type": "string"
+                },
+                "review_search_engine_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "config.MongoCollections": {
+            "type": "object",
+            "properties": {
+                "alert_subscriptions": {
+                    "type": "string"
+                },
+                "benchmark_catalog": {
+                    "type": "string"
+                },
+                "devices": {
+                    "type": "string"
+                },
+                "metadata": {
+                    "type": "string"
+                },
+                "months": {
+                    "type": "string"
+                },
+                "price_history": {
+                    "type": "string"
+                },
+                "queue": {
+                    "type": "string"
+                },
+                "schema_migrations": {
+                    "type": "string"
+                },
+                "years": {
+                    "type": "string"
+                }
+            }
+        },
+        "config.MongoConfig": {
+            "type": "object",
+            "properties": {
+                "collections": {
+                    "$ref": "#/definitions/config.MongoCollections"
+                },
+                "database": {
+                    "type": "string"
+                },
+                "migrate_on_startup": {
+                    "description": "MigrateOnStartup applies the pending schema migrations whenever the API connects. It is off by default, since\nmigrations delete what they moved: they are applied by cmd/migrateSchema, after checking them with -dry-run.",
+                    "type": "boolean"
+                },
+                "uri": {
+                    "type": "string"
+                }
+            }
+        },
+        "config.PipelineConfig": {
+            "type": "object",
+            "properties": {
+                "empty_listing_interval": {
+                    "description": "EmptyListingInterval is waited after a listing found no devices.",
+                    "type": "string"
+                },
+                "enqueue_interval": {
+                    "description": "EnqueueInterval separates two listings of every brand's devices.",
+                    "type": "string"
+                },
+                "refresh_interval": {
+                    "description": "RefreshInterval separates two checks for stale devices.",
+                    "type": "string"
+                },
+                "refresh_retry_interval": {
+                    "description": "RefreshRetryInterval is waited after a check for stale devices failed.",
+                    "type": "string"
+                },
+                "retry_interval": {
+                    "description": "RetryInterval is waited after the enqueuer or the uploader failed.",
+                    "type": "string"
+                },
+                "upload_interval": {
+                    "description": "UploadInterval separates two uploaded devices.",
+                    "type": "string"
+                }
+            }
+        },
+        "config.ServerConfig": {
+            "type": "object",
+            "properties": {
+                "address": {
+                    "type": "string"
+                },
+                "connect_timeout": {
+                    "description": "ConnectTimeout limits each attempt to connect to the database on startup.",
+                    "type": "string"
+                },
+                "http_timeout": {
+                    "description": "HTTPTimeout limits every request the scrapers send.",
+                    "type": "string"
+                }
+            }
+        },
+        "dataTypes.AlertRequest": {
+            "type": "object",
+            "properties": {
+                "callback_url": {
+                    "type": "string"
+                },
+                "device_id": {
+                    "type": "string"
+                },
+                "threshold": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dataTypes.AlertSubscription": {
+            "type": "object",
+            "properties": {
+                "callback_url": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "device_id": {
+                    "type": "string"
+                },
+                "device_name": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "threshold": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dataTypes.BenchmarkCatalogInfo": {
+            "type": "object",
+            "properties": {
+                "entries": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dataTypes.BenchmarkEntry"
+                    }
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "fetched_at": {
+                    "type": "string"
+                },
+                "gpu_api": {
+                    "description": "GPUAPI is the compute API of the GPU scores, e.g. \"metal\" or \"vulkan\".",
+                    "type": "string"
+                },
+                "platform": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "dataTypes.BenchmarkEntry": {
+            "type": "object",
+            "properties": {
+                "gpu_score": {
+                    "description": "GPUScore is the compute score on the platform's GPU API; zero when the leaderboard doesn't list the device.",
+                    "type": "integer"
+                },
+                "multi_core_score": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "single_core_score": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dataTypes.BenchmarkEstimation": {
+            "type": "object",
+            "properties": {
+                "confidenceLevel": {
+                    "description": "ConfidenceLevel is the probability that the true scores fall in the intervals. Zero means the estimator\ngives no intervals.",
+                    "type": "number"
+                },
+                "method": {
+                    "type": "string"
+                },
+                "multiCoreInterval": {
+                    "$ref": "#/definitions/dataTypes.MinMaxFloat"
+                },
+                "sampleSize": {
+                    "description": "SampleSize is the number of devices the estimator was fitted on, or the chipset's scores taken from.",
+                    "type": "integer"
+                },
+                "singleCoreInterval": {
+                    "$ref": "#/definitions/dataTypes.MinMaxFloat"
+                }
+            }
+        },
+        "dataTypes.BenchmarkScores": {
+            "type": "object",
+            "properties": {
+                "estimation": {
+                    "description": "Estimation is set only on estimated and chipset-inferred benchmarks.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/dataTypes.BenchmarkEstimation"
+                        }
+                    ]
+                },
+                "gpuscore": {
+                    "description": "GPUScore is optional: zero means no GPU benchmark was found, and the benchmark is scored with a neutral GPU\nscore. It is inferred from the chipset, but never estimated.",
+                    "type": "number"
+                },
+                "isChipsetInferred": {
+                    "description": "IsChipsetInferred marks scores reused from measured devices with the same chipset. They are not measured, so\nIsEstimatedBenchmark is set as well.",
+                    "type": "boolean"
+                },
+                "isEstimatedBenchmark": {
+                    "type": "boolean"
+                },
+                "multiCoreScore": {
+                    "type": "number"
+                },
+                "singleCoreScore": {
+                    "type": "number"
+                }
+            }
+        },
+        "dataTypes.DeviceListing": {
+            "type": "object",
+            "properties": {
+                "benchmark": {
+                    "$ref": "#/definitions/dataTypes.BenchmarkScores"
+                },
+                "brand": {
+                    "type": "string"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "detail": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "image": {
+                    "type": "string"
+                },
+                "is_lowest_price_in_90_days": {
+                    "type": "boolean"
+                },
+                "market_price": {
+                    "$ref": "#/definitions/dataTypes.MarketPrice"
+                },
+                "month": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "priceCategory": {
+                    "type": "integer"
+                },
+                "prices": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dataTypes.MarketPrice"
+                    }
+                },
+                "provenance": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/dataTypes.Provenance"
+                    }
+                },
+                "realPrice": {
+                    "type": "integer"
+                },
+                "review": {
+                    "$ref": "#/definitions/dataTypes.ReviewData"
+                },
+                "specs": {
+                    "$ref": "#/definitions/dataTypes.Specifications"
+                },
+                "stageResults": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dataTypes.StageResult"
+                    }
+                },
+                "unvalidatedFinalScore": {
+                    "type": "number"
+                },
+                "validatedFinalScore": {
+                    "type": "number"
+                },
+                "value_score": {
+                    "description": "ValueScore is the device's score per 1000 units of its price, or of its variant's price when the search\nwas at the variant level.",
+                    "type": "number"
+                },
+                "variants": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dataTypes.Variant"
+                    }
+                },
+                "year": {
+                    "type": "string"
+                }
+            }
+        },
+        "dataTypes.Dimensions": {
+            "type": "object",
+            "properties": {
+                "heightMM": {
+                    "type": "number"
+                },
+                "thicknessMM": {
+                    "type": "number"
+                },
+                "widthMM": {
+                    "type": "number"
+                }
+            }
+        },
+        "dataTypes.ErrorLimits": {
+            "type": "object",
+            "properties": {
+                "ai_network_errors": {
+                    "type": "integer"
+                },
+                "clean_up_errors": {
+                    "type": "integer"
+                },
+                "creating_new_ai_client_errors": {
+                    "type": "integer"
+                },
+                "database_network_errors": {
+                    "type": "integer"
+                },
+                "failed_ai_instruction_errors": {
+                    "type": "integer"
+                },
+                "general_database_errors": {
+                    "type": "integer"
+                },
+                "getting_document_errors": {
+                    "type": "integer"
+                },
+                "getting_url_errors": {
+                    "type": "integer"
+                },
+                "invalid_const_id_string_errors": {
+                    "type": "integer"
+                },
+                "missing_document_errors": {
+                    "type": "integer"
+                },
+                "parsing_errors": {
+                    "type": "integer"
+                },
+                "sentiment_analysis_errors": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dataTypes.Filters": {
+            "type": "object",
+            "properties": {
+                "brands": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "chipset": {
+                    "description": "The spec filters below are ignored when left at their zero value, and so are ranges whose Max is 0.\nChipset matches any chipset containing it, case-insensitively, and IPRatings any of the listed ratings.",
+                    "type": "string"
+                },
+                "displaySize": {
+                    "$ref": "#/definitions/dataTypes.MinMaxFloat"
+                },
+                "height": {
+                    "$ref": "#/definitions/dataTypes.MinMaxFloat"
+                },
+                "ipratings": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "market": {
+                    "description": "Market, when set, applies the Price range to the device's price in that market instead of its primary price.",
+                    "type": "string"
+                },
+                "minOSUpgrades": {
+                    "type": "integer"
+                },
+                "os": {
+                    "type": "string"
+                },
+                "osversion": {
+                    "$ref": "#/definitions/dataTypes.MinMaxFloat"
+                },
+                "price": {
+                    "$ref": "#/definitions/dataTypes.MinMaxInt"
+                },
+                "ram": {
+                    "$ref": "#/definitions/dataTypes.MinMaxFloat"
+                },
+                "refreshRate": {
+                    "$ref": "#/definitions/dataTypes.MinMaxInt"
+                },
+                "requires5G": {
+                    "type": "boolean"
+                },
+                "sortByValue": {
+                    "type": "boolean"
+                },
+                "storage": {
+                    "description": "Storage (GB) and RAM (GB) ranges are ignored when their Max is 0. Setting either of them, or SortByValue,\nsearches variants instead of devices: each result holds the single variant that matched, and the Price\nrange applies to the variant's price.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/dataTypes.MinMaxInt"
+                        }
+                    ]
+                },
+                "thickness": {
+                    "$ref": "#/definitions/dataTypes.MinMaxFloat"
+                },
+                "weight": {
+                    "$ref": "#/definitions/dataTypes.MinMaxFloat"
+                },
+                "width": {
+                    "$ref": "#/definitions/dataTypes.MinMaxFloat"
+                },
+                "wiredCharging": {
+                    "$ref": "#/definitions/dataTypes.MinMaxFloat"
+                },
+                "wirelessCharging": {
+                    "$ref": "#/definitions/dataTypes.MinMaxFloat"
+                }
+            }
+        },
+        "dataTypes.IngestionJob": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "detail": {
+                    "type": "string"
+                },
+                "device_name": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "synchronous": {
+                    "type": "boolean"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "dataTypes.IngestionRequest": {
+            "type": "object",
+            "properties": {
+                "brand": {
+                    "type": "string"
+                },
+                "detail": {
+                    "type": "string"
+                },
+                "model": {
+                    "type": "string"
+                },
+                "synchronous": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "dataTypes.MarketPrice": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "is_converted": {
+                    "type": "boolean"
+                },
+                "market": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "integer"
+                },
+                "source": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "dataTypes.MinMaxFloat": {
+            "type": "object",
+            "properties": {
+                "max": {
+                    "type": "number"
+                },
+                "min": {
+                    "type": "number"
+                }
+            }
+        },
+        "dataTypes.MinMaxInt": {
+            "type": "object",
+            "properties": {
+                "max": {
+                    "type": "integer"
+                },
+                "min": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dataTypes.PipelineEvent": {
+            "type": "object",
+            "properties": {
+                "device_name": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "time": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "dataTypes.PipelineStatus": {
+            "type": "object",
+            "properties": {
+                "current_device": {
+                    "type": "string"
+                },
+                "devices_failed": {
+                    "type": "integer"
+                },
+                "devices_processed": {
+                    "type": "integer"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                },
+                "uptime": {
+                    "type": "string"
+                }
+            }
+        },
+        "dataTypes.PriceHistory": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "current": {
+                    "type": "integer"
+                },
+                "device_id": {
+                    "type": "string"
+                },
+                "max": {
+                    "type": "integer"
                 },
                 "min": {
                     "type": "integer"
+                },
+                "prices": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dataTypes.PricePoint"
+                    }
+                }
+            }
+        },
+        "dataTypes.PricePoint": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "integer"
+                },
+                "source_url": {
+                    "type": "string"
+                },
+                "time": {
+                    "type": "string"
+                }
+            }
+        },
+        "dataTypes.Provenance": {
+            "type": "object",
+            "properties": {
+                "method": {
+                    "type": "string"
+                },
+                "source": {
+                    "type": "string"
+                },
+                "time": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "dataTypes.Readiness": {
+            "type": "object",
+            "properties": {
+                "is_database_up": {
+                    "type": "boolean"
+                },
+                "pipeline": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                }
+            }
+        },
+        "dataTypes.ReviewData": {
+            "type": "object",
+            "properties": {
+                "reviewMagnitude": {
+                    "type": "number"
+                },
+                "reviewSentiment": {
+                    "type": "number"
+                },
+                "unvalidatedReviewScore": {
+                    "type": "number"
+                },
+                "validatedReviewScore": {
+                    "type": "number"
+                }
+            }
+        },
+        "dataTypes.Specifications": {
+            "type": "object",
+            "properties": {
+                "batteryCapacity": {
+                    "type": "number"
+                },
+                "chipset": {
+                    "description": "The fields below are optional: a zero value means the spec API didn't list them.",
+                    "type": "string"
+                },
+                "dimensions": {
+                    "$ref": "#/definitions/dataTypes.Dimensions"
+                },
+                "displayResolution": {
+                    "type": "string"
+                },
+                "displaySize": {
+                    "type": "number"
+                },
+                "has5G": {
+                    "type": "boolean"
+                },
+                "iprating": {
+                    "type": "string"
+                },
+                "mainCamerasSetup": {
+                    "type": "string"
+                },
+                "majorOSUpgrades": {
+                    "type": "integer"
+                },
+                "nits": {
+                    "type": "integer"
+                },
+                "os": {
+                    "type": "string"
+                },
+                "osversion": {
+                    "type": "number"
+                },
+                "pixelDensity": {
+                    "type": "number"
+                },
+                "ramoptionsGB": {
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                },
+                "refreshRate": {
+                    "type": "integer"
+                },
+                "releaseDate": {
+                    "type": "string"
+                },
+                "selfieCamerasSetup": {
+                    "type": "string"
+                },
+                "storageOptionsGB": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "weightGrams": {
+                    "type": "number"
+                },
+                "wiredChargingW": {
+                    "type": "number"
+                },
+                "wirelessChargingW": {
+                    "type": "number"
+                }
+            }
+        },
+        "dataTypes.StageResult": {
+            "type": "object",
+            "properties": {
+                "duration": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "stage": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "dataTypes.Variant": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "prices": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dataTypes.MarketPrice"
+                    }
+                },
+                "ramgb": {
+                    "type": "number"
+                },
+                "realPrice": {
+                    "type": "integer"
+                },
+                "storageGB": {
+                    "type": "integer"
                 }
             }
         }
@@ -10,6 +10,7 @@ require (
 	github.com/swaggo/files v1.0.1
 	github.com/swaggo/gin-swagger v1.6.0
 	github.com/swaggo/swag v1.16.4
+	go.etcd.io/bbolt v1.4.0
 	go.mongodb.org/mongo-driver v1.17.2
 	google.golang.org/api v0.218.0
 )
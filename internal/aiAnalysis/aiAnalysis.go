@@ -1,7 +1,6 @@
 package aiAnalysis
 
 import (
-	language "cloud.google.com/go/language/apiv2"
 	"cloud.google.com/go/language/apiv2/languagepb"
 	"context"
 	"encoding/json"
@@ -13,16 +12,20 @@ import (
 	"github.com/ItaiHalperin/Device-Rec-API/internal/helpers"
 	"github.com/ItaiHalperin/Device-Rec-API/internal/parsingErrorLogger"
 	"github.com/google/generative-ai-go/genai"
-	"google.golang.org/api/option"
 	"log"
-	"os"
+	"sort"
 	"strings"
 	"time"
 )
 
 const (
-	singleCoreScoreWeight = 0.6
-	multiCoreScoreWeight  = 0.4
+	singleCoreScoreWeight = 0.45
+	multiCoreScoreWeight  = 0.3
+	gpuScoreWeight        = 0.25
+	// neutralGPUScore is the normalized GPU score of devices without one, or of every device while the GPU scores
+	// have no range, the middle of the normalized range, so that every device is weighted the same way and a missing
+	// score neither rewards nor punishes it.
+	neutralGPUScore = 0.5
 
 	densityScoreWeight     = 0.3
 	nitsScoreWeight        = 0.3
@@ -42,7 +45,7 @@ const (
 	estimatedBenchmarkScoreWeight = 30
 )
 
-func AnalyseSentimentMagnitude(review string, ctrl *dataTypes.FlowControl) (float64, float64, error) {
+func (analyzer *Analyzer) AnalyseSentimentMagnitude(review string, ctrl *dataTypes.FlowControl) (float64, float64, error) {
 	if ctrl.Ctx.Err() != nil {
 		errorHandling.LogErrorToScreen(errorHandling.LogParams{
 			DeviceName: "",
@@ -53,25 +56,11 @@ func AnalyseSentimentMagnitude(review string, ctrl *dataTypes.FlowControl) (floa
 		return 0, 0, ctrl.Ctx.Err()
 	}
 
-	apiKey := os.Getenv("GEN_AI_KEY")
-	ctxForNewClient, cancelForNewClient := context.WithTimeout(ctrl.Ctx, time.Second*30)
-	defer cancelForNewClient()
-
-	client, err := language.NewClient(ctxForNewClient, option.WithAPIKey(apiKey))
+	client, release, err := analyzer.getLanguageClient(ctrl)
 	if err != nil {
 		return 0, 0, err
 	}
-	defer func(client *language.Client) {
-		if err = client.Close(); err != nil {
-			errorHandling.LogErrorToScreen(errorHandling.LogParams{
-				DeviceName: "",
-				Function:   "aiAnalysis.AnalyseSentimentMagnitude",
-				ErrorMsg:   "WARNING: Failed to close AI client",
-				IsCtxError: false,
-			}, ctrl.Ctx.Err())
-			errorMonitoring.IncrementError(errorMonitoring.CleanUpError, ctrl)
-		}
-	}(client)
+	defer release()
 	ctxForAnalyze, cancelForAnalyze := context.WithTimeout(ctrl.Ctx, time.Second*30)
 	defer cancelForAnalyze()
 	resp, err := client.AnalyzeSentiment(ctxForAnalyze, &languagepb.AnalyzeSentimentRequest{
@@ -99,7 +88,7 @@ func AnalyseSentimentMagnitude(review string, ctrl *dataTypes.FlowControl) (floa
 	return float64(verdictSentiment.Score), float64(verdictSentiment.Magnitude), nil
 }
 
-func IsCorrectWebpage(instruction, brandAndName, searchSnippet string, ctrl *dataTypes.FlowControl) (bool, error) {
+func (analyzer *Analyzer) IsCorrectWebpage(instruction, brandAndName, searchSnippet string, ctrl *dataTypes.FlowControl) (bool, error) {
 	if ctrl.Ctx.Err() != nil {
 		errorHandling.LogErrorToScreen(errorHandling.LogParams{
 			DeviceName: "",
@@ -111,7 +100,7 @@ func IsCorrectWebpage(instruction, brandAndName, searchSnippet string, ctrl *dat
 		return false, ctrl.Ctx.Err()
 	}
 
-	isCorrect, err := GetBoolAIResponse(instruction, brandAndName+"\n"+"\""+searchSnippet+"\"", ctrl)
+	isCorrect, err := analyzer.GetBoolAIResponse(instruction, brandAndName+"\n"+"\""+searchSnippet+"\"", ctrl)
 	if err != nil {
 		log.Printf("in aiAnlysis.IsCorrectWebpage failed to get response from ai: %v", err)
 		return false, err
@@ -120,13 +109,13 @@ func IsCorrectWebpage(instruction, brandAndName, searchSnippet string, ctrl *dat
 	return isCorrect, nil
 }
 
-func GetPriceCategory(brandAndName string, ctrl *dataTypes.FlowControl) (int, error) {
+func (analyzer *Analyzer) GetPriceCategory(brandAndName string, ctrl *dataTypes.FlowControl) (int, error) {
 	if ctrl.Ctx.Err() != nil {
 		log.Printf("stopping aiAnlysis.GetPriceCategory: %v", ctrl.Ctx.Err())
 		return 0, ctrl.Ctx.Err()
 	}
 
-	response, err := GetStringAIResponse("You receive a phone model in the form \"[brand] [phone name]\""+
+	response, err := analyzer.GetStringAIResponse("You receive a phone model in the form \"[brand] [phone name]\""+
 		" and you return a classification in terms of launch price range (LOW_END, LOW_MID_RANGE, HIGH_MID_RANGE, HIGH_END) and nothing further.",
 		brandAndName, ctrl)
 	if err != nil {
@@ -152,12 +141,12 @@ func GetPriceCategory(brandAndName string, ctrl *dataTypes.FlowControl) (int, er
 	return 0, errorTypes.NewFailedAiInstructionError("in aiAnalysisAI.GetPriceCategory ai didn't return a price category")
 }
 
-func GetBoolAIResponse(instruction, prompt string, ctrl *dataTypes.FlowControl) (bool, error) {
+func (analyzer *Analyzer) GetBoolAIResponse(instruction, prompt string, ctrl *dataTypes.FlowControl) (bool, error) {
 	if ctrl.Ctx.Err() != nil {
 		log.Printf("stopping aiAnlysis.GetBoolAIResponse: %v", ctrl.Ctx.Err())
 		return false, ctrl.Ctx.Err()
 	}
-	resp, err := getAiResponse[bool](instruction, prompt, ctrl)
+	resp, err := getAiResponse[bool](analyzer, instruction, prompt, ctrl)
 	if err != nil {
 		log.Printf("in aiAnlysis.GetBoolAIResponse failed to get response from ai: %v", err)
 		return false, err
@@ -177,12 +166,12 @@ func GetBoolAIResponse(instruction, prompt string, ctrl *dataTypes.FlowControl)
 	log.Printf("in aiAnlysis.GetBoolAIResponse failed to get text from resp: %v", err)
 	return false, err
 }
-func GetStringAIResponse(instruction, prompt string, ctrl *dataTypes.FlowControl) (string, error) {
+func (analyzer *Analyzer) GetStringAIResponse(instruction, prompt string, ctrl *dataTypes.FlowControl) (string, error) {
 	if ctrl.Ctx.Err() != nil {
 		log.Printf("stopping aiAnlysis.GetStringAIResponse: %v", ctrl.Ctx.Err())
 		return "", ctrl.Ctx.Err()
 	}
-	resp, err := getAiResponse[string](instruction, prompt, ctrl)
+	resp, err := getAiResponse[string](analyzer, instruction, prompt, ctrl)
 	if err != nil {
 		log.Printf("in aiAnlysis.GetStringAIResponse failed to get response from ai: %v", err)
 		return "", err
@@ -198,12 +187,12 @@ func GetStringAIResponse(instruction, prompt string, ctrl *dataTypes.FlowControl
 	errorMonitoring.IncrementError(errorMonitoring.ParsingError, ctrl)
 	return "", errorTypes.NewParsingError(fmt.Sprintf("in aiAnlysis.GetStringAIResponse failed to parse response from ai: %v", err))
 }
-func GetIntAIResponse(instruction, prompt string, ctrl *dataTypes.FlowControl) (int, error) {
+func (analyzer *Analyzer) GetIntAIResponse(instruction, prompt string, ctrl *dataTypes.FlowControl) (int, error) {
 	if ctrl.Ctx.Err() != nil {
 		log.Printf("stopping aiAnlysis.GetIntAIResponse: %v", ctrl.Ctx.Err())
 		return 0, ctrl.Ctx.Err()
 	}
-	resp, err := GetStringAIResponse(instruction, prompt, ctrl)
+	resp, err := analyzer.GetStringAIResponse(instruction, prompt, ctrl)
 	if err != nil {
 		log.Printf("in aiAnlysis.GetIntAIResponse failed to get string response from ai: %v", err)
 		return 0, err
@@ -220,30 +209,19 @@ func GetIntAIResponse(instruction, prompt string, ctrl *dataTypes.FlowControl) (
 	return int(floatResp), nil
 }
 
-func getAiResponse[T any](instruction, prompt string, ctrl *dataTypes.FlowControl) (*genai.GenerateContentResponse, error) {
+func getAiResponse[T any](analyzer *Analyzer, instruction, prompt string, ctrl *dataTypes.FlowControl) (*genai.GenerateContentResponse, error) {
 	if ctrl.Ctx.Err() != nil {
 		log.Printf("stopping aiAnlysis.getAiResponse: %v", ctrl.Ctx.Err())
 		return nil, ctrl.Ctx.Err()
 	}
 
-	apiKey := os.Getenv("GEN_AI_KEY")
-	ctxForNewClient, cancelForNewClient := context.WithTimeout(ctrl.Ctx, time.Second*30)
-	defer cancelForNewClient()
-	client, err := genai.NewClient(ctxForNewClient, option.WithAPIKey(apiKey))
+	client, release, err := analyzer.getGenerativeClient(ctrl)
 	if err != nil {
-		log.Printf("WARNING: Failed to create AI client: %v", err)
-		errorMonitoring.IncrementError(errorMonitoring.CreatingNewAiClientError, ctrl)
 		return nil, err
 	}
-	defer func(client *genai.Client) {
-		err := client.Close()
-		if err != nil {
-			log.Printf("WARNING: Failed to close AI client: %v", err)
-			errorMonitoring.IncrementError(errorMonitoring.CleanUpError, ctrl)
-		}
-	}(client)
+	defer release()
 
-	model := client.GenerativeModel("gemini-1.5-flash")
+	model := client.GenerativeModel(analyzer.GetAiSource())
 
 	model.SetTemperature(0)
 	model.SetTopK(1)
@@ -313,7 +291,13 @@ func GetFinalScore(newMinMaxMagnitudeSentiment dataTypes.MinMaxValues, device *d
 		refreshRateScore = refreshRate144hzScore
 	}
 
-	normalizedBenchmarkScore := singleCoreScoreWeight*normalizedSingleCoreScore + multiCoreScoreWeight*normalizedMultiCoreScore
+	normalizedGPUScore := neutralGPUScore
+	gpuScoreRange := newMinMaxMagnitudeSentiment.GPUScore
+	if device.Benchmark.GPUScore > 0 && gpuScoreRange.Max > gpuScoreRange.Min {
+		normalizedGPUScore = helpers.CalculateNormalizedValue(gpuScoreRange.Min, gpuScoreRange.Max, device.Benchmark.GPUScore)
+	}
+	normalizedBenchmarkScore := singleCoreScoreWeight*normalizedSingleCoreScore + multiCoreScoreWeight*normalizedMultiCoreScore +
+		gpuScoreWeight*normalizedGPUScore
 	normalizedDisplayScore := normalizedNitsScore*nitsScoreWeight*normalizedPixelDensity*densityScoreWeight + refreshRateScore*refreshRateScoreWeight
 
 	var weights scoreWeights
@@ -337,3 +321,34 @@ func GetFinalScore(newMinMaxMagnitudeSentiment dataTypes.MinMaxValues, device *d
 		+weights.benchmark*normalizedBenchmarkScore +
 		weights.review*device.Review.ValidatedReviewScore
 }
+
+// GetValueScore is the final score per 1000 units of price, so cheaper devices and variants of equal quality rank higher.
+func GetValueScore(finalScore float64, price int) float64 {
+	if price <= 0 {
+		return 0
+	}
+	return finalScore * 1000 / float64(price)
+}
+
+// TopByValue returns the 3 priced devices with the highest value score, at the price the filters searched.
+func TopByValue(devices []dataTypes.Device, filters *dataTypes.Filters) []dataTypes.Device {
+	valueScores := make(map[int]float64, len(devices))
+	var priced []int
+	for i := range devices {
+		price, ok := helpers.GetSearchedPrice(&devices[i], filters)
+		if !ok {
+			continue
+		}
+		valueScores[i] = GetValueScore(devices[i].ValidatedFinalScore, price.Price)
+		priced = append(priced, i)
+	}
+	sort.SliceStable(priced, func(a, b int) bool {
+		return valueScores[priced[a]] > valueScores[priced[b]]
+	})
+
+	top := make([]dataTypes.Device, 0, 3)
+	for _, i := range priced[:min(3, len(priced))] {
+		top = append(top, devices[i])
+	}
+	return top
+}
@@ -0,0 +1,108 @@
+package aiAnalysis
+
+import (
+	language "cloud.google.com/go/language/apiv2"
+	"context"
+	"errors"
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorMonitoring"
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/option"
+	"log"
+	"time"
+)
+
+// Analyzer runs the analyses with one API key and generative model. Once opened, every analysis shares its clients.
+// Until then, each analysis creates the client it needs and closes it when done.
+type Analyzer struct {
+	apiKey     string
+	modelName  string
+	generative *genai.Client
+	language   *language.Client
+}
+
+func NewAnalyzer(apiKey, modelName string) *Analyzer {
+	return &Analyzer{apiKey: apiKey, modelName: modelName}
+}
+
+// Open creates the shared clients. They live until Close, so they don't depend on ctrl's context. It must not be
+// called while analyses run.
+func (analyzer *Analyzer) Open(ctrl *dataTypes.FlowControl) error {
+	generative, err := genai.NewClient(context.Background(), option.WithAPIKey(analyzer.apiKey))
+	if err != nil {
+		log.Printf("WARNING: Failed to create AI client: %v", err)
+		errorMonitoring.IncrementError(errorMonitoring.CreatingNewAiClientError, ctrl)
+		return err
+	}
+	languageClient, err := language.NewClient(context.Background(), option.WithAPIKey(analyzer.apiKey))
+	if err != nil {
+		log.Printf("WARNING: Failed to create language client: %v", err)
+		errorMonitoring.IncrementError(errorMonitoring.CreatingNewAiClientError, ctrl)
+		if closeErr := generative.Close(); closeErr != nil {
+			log.Printf("WARNING: Failed to close AI client: %v", closeErr)
+		}
+		return err
+	}
+	analyzer.generative = generative
+	analyzer.language = languageClient
+	return nil
+}
+
+// Close closes the shared clients, if they were opened. It must not be called while analyses run.
+func (analyzer *Analyzer) Close() error {
+	if analyzer.generative == nil {
+		return nil
+	}
+	err := errors.Join(analyzer.generative.Close(), analyzer.language.Close())
+	analyzer.generative = nil
+	analyzer.language = nil
+	return err
+}
+
+// GetAiSource returns the generative model, which is recorded as the source of what it analysed.
+func (analyzer *Analyzer) GetAiSource() string {
+	return analyzer.modelName
+}
+
+// getGenerativeClient returns the shared generative client, or a new one. release closes a new client and does
+// nothing to the shared one.
+func (analyzer *Analyzer) getGenerativeClient(ctrl *dataTypes.FlowControl) (*genai.Client, func(), error) {
+	if analyzer.generative != nil {
+		return analyzer.generative, func() {}, nil
+	}
+
+	ctxForNewClient, cancelForNewClient := context.WithTimeout(ctrl.Ctx, time.Second*30)
+	defer cancelForNewClient()
+	client, err := genai.NewClient(ctxForNewClient, option.WithAPIKey(analyzer.apiKey))
+	if err != nil {
+		log.Printf("WARNING: Failed to create AI client: %v", err)
+		errorMonitoring.IncrementError(errorMonitoring.CreatingNewAiClientError, ctrl)
+		return nil, nil, err
+	}
+	return client, func() {
+		if err := client.Close(); err != nil {
+			log.Printf("WARNING: Failed to close AI client: %v", err)
+			errorMonitoring.IncrementError(errorMonitoring.CleanUpError, ctrl)
+		}
+	}, nil
+}
+
+// getLanguageClient is getGenerativeClient for the sentiment analysis client.
+func (analyzer *Analyzer) getLanguageClient(ctrl *dataTypes.FlowControl) (*language.Client, func(), error) {
+	if analyzer.language != nil {
+		return analyzer.language, func() {}, nil
+	}
+
+	ctxForNewClient, cancelForNewClient := context.WithTimeout(ctrl.Ctx, time.Second*30)
+	defer cancelForNewClient()
+	client, err := language.NewClient(ctxForNewClient, option.WithAPIKey(analyzer.apiKey))
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, func() {
+		if err := client.Close(); err != nil {
+			log.Printf("WARNING: Failed to close AI client: %v", err)
+			errorMonitoring.IncrementError(errorMonitoring.CleanUpError, ctrl)
+		}
+	}, nil
+}
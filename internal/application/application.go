@@ -0,0 +1,284 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/aiAnalysis"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/benchmarkScraper"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/brandCatalog"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/config"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/currencyConversion"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/dataAccessLayer"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/dataPipelineManager"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/databaseFactory"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/databaseInterface"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/externalServices"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/ingestionJobs"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/nameMatching"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/priceScraper"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	StateStarting = "starting"
+	StateReady    = "ready"
+	StateStopping = "stopping"
+	StateStopped  = "stopped"
+)
+
+const (
+	connectRetryInterval = 5 * time.Second
+	readinessTimeout     = 5 * time.Second
+)
+
+// Application holds what the API handlers and the data pipeline share: the configuration, one database connection, the AI analyzer,
+// the HTTP client, the reference data files, the pipeline's event bus, the ingestion jobs and the price sources and listeners. main builds it once, starts it and shuts it down on exit.
+type Application struct {
+	Config     *config.Config
+	Database   databaseInterface.DatabaseInterface
+	HTTPClient *http.Client
+	Analyzer   *aiAnalysis.Analyzer
+	Supervisor *dataPipelineManager.Supervisor
+	// BenchmarkCatalog caches the benchmark leaderboards for the handlers and the pipeline.
+	BenchmarkCatalog  *benchmarkScraper.Catalog
+	Brands            *brandCatalog.Catalog
+	NameAliases       *nameMatching.Aliases
+	CurrencyConverter *currencyConversion.Converter
+	Events            *dataPipelineManager.EventBus
+	Jobs              *ingestionJobs.Store
+	Prices            *priceScraper.Registry
+	// lifecycleMutex keeps Start from connecting while Shutdown disconnects.
+	lifecycleMutex sync.Mutex
+	mutex          sync.Mutex
+	state          string
+	isConnected    bool
+	isAnalyzerOpen bool
+	// tasks counts the running RunTask calls, which end when tasksCtx is cancelled on Shutdown.
+	tasks       sync.WaitGroup
+	tasksCtx    context.Context
+	cancelTasks context.CancelFunc
+}
+
+// New builds an unconnected application. It fails if the brand catalog can't be loaded.
+func New(cfg *config.Config) (*Application, error) {
+	brands, err := brandCatalog.NewCatalog(cfg.Data.BrandCatalogFile, cfg.Data.BenchmarkEstimationMethod)
+	if err != nil {
+		log.Printf("in application.New failed to load brand catalog: %v", err)
+		return nil, err
+	}
+	nameAliases := nameMatching.NewAliases(cfg.Data.NameAliasesFile)
+	database := databaseFactory.NewBackendDatabase(cfg.Database.Backend, cfg, brands)
+	tasksCtx, cancelTasks := context.WithCancel(context.Background())
+	httpClient := &http.Client{Timeout: time.Duration(cfg.Server.HTTPTimeout)}
+	app := &Application{
+		Config:     cfg,
+		Database:   database,
+		HTTPClient: httpClient,
+		Analyzer:   aiAnalysis.NewAnalyzer(cfg.Keys.GenAIKey, cfg.AI.ModelName),
+		BenchmarkCatalog: benchmarkScraper.NewCatalog(database, httpClient, time.Duration(cfg.Data.BenchmarkCatalogTTL),
+			brands, nameAliases),
+		Brands:            brands,
+		NameAliases:       nameAliases,
+		CurrencyConverter: currencyConversion.NewConverter(cfg.Data.CurrencyRatesFile),
+		Events:            dataPipelineManager.NewEventBus(),
+		Jobs:              ingestionJobs.NewStore(),
+		Prices:            priceScraper.NewRegistry(),
+		state:             StateStarting,
+		tasksCtx:          tasksCtx,
+		cancelTasks:       cancelTasks,
+	}
+	app.Supervisor = dataPipelineManager.NewSupervisor(app.DataAccessLayer(), app.ExternalServices(), app.Hooks(), cfg.Pipeline, cfg.ErrorLimits)
+	return app, nil
+}
+
+// DataAccessLayer returns what the pipeline reads and writes through.
+func (app *Application) DataAccessLayer() dataAccessLayer.DataAccessLayer {
+	return dataAccessLayer.DataAccessLayer{Database: app.Database, BenchmarkCatalog: app.BenchmarkCatalog}
+}
+
+// ExternalServices returns what the pipeline gathers data through.
+func (app *Application) ExternalServices() externalServices.ExternalServices {
+	return externalServices.ExternalServices{
+		HTTPClient:        app.HTTPClient,
+		Analyzer:          app.Analyzer,
+		Keys:              app.Config.Keys,
+		Brands:            app.Brands,
+		NameAliases:       app.NameAliases,
+		CurrencyConverter: app.CurrencyConverter,
+		Markets:           app.Config.Data.Markets,
+	}
+}
+
+// Hooks returns what the pipeline reports through and scrapes prices with.
+func (app *Application) Hooks() dataPipelineManager.Hooks {
+	return dataPipelineManager.Hooks{Events: app.Events, Jobs: app.Jobs, Prices: app.Prices}
+}
+
+// Start opens the AI analyzer's shared clients, then connects to the database, retrying until it is up or ctx is
+// done. The application is ready once it is connected.
+func (app *Application) Start(ctx context.Context) error {
+	ctrl := &dataTypes.FlowControl{Ctx: ctx, StopOnTooManyErrorsChannel: make(chan struct{}, 1)}
+	app.openAnalyzer(ctrl)
+
+	for {
+		isConnected, err := app.connect(ctrl)
+		if err == nil {
+			if isConnected {
+				log.Println("in application.Start application is ready")
+			}
+			return nil
+		}
+		log.Printf("in application.Start failed to connect to database, retrying in %v: %v", connectRetryInterval, err)
+		select {
+		case <-time.After(connectRetryInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// openAnalyzer opens the analyzer's shared clients before anything can analyse. Without them, every analysis creates
+// its own clients.
+func (app *Application) openAnalyzer(ctrl *dataTypes.FlowControl) {
+	app.lifecycleMutex.Lock()
+	defer app.lifecycleMutex.Unlock()
+	if app.GetState() != StateStarting {
+		return
+	}
+	if err := app.Analyzer.Open(ctrl); err != nil {
+		log.Printf("WARNING: in application.openAnalyzer failed to open AI clients, every analysis creates its own clients: %v", err)
+		return
+	}
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	app.isAnalyzerOpen = true
+}
+
+// connect connects to the database and checks that it is up. It reports false, with no error, if the application
+// began shutting down.
+func (app *Application) connect(ctrl *dataTypes.FlowControl) (bool, error) {
+	app.lifecycleMutex.Lock()
+	defer app.lifecycleMutex.Unlock()
+	if app.GetState() != StateStarting {
+		return false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctrl.Ctx, time.Duration(app.Config.Server.ConnectTimeout))
+	defer cancel()
+	connectCtrl := &dataTypes.FlowControl{Ctx: ctx, StopOnTooManyErrorsChannel: ctrl.StopOnTooManyErrorsChannel}
+	err := app.Database.Connect(connectCtrl)
+	if err != nil {
+		return false, err
+	}
+	if !app.Database.IsUp(connectCtrl) {
+		if err = app.Database.Disconnect(connectCtrl); err != nil {
+			log.Printf("WARNING: in application.connect failed to disconnect from unreachable database: %v", err)
+		}
+		return false, errorTypes.NewGeneralDatabaseError("database is unreachable")
+	}
+
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	app.isConnected = true
+	app.state = StateReady
+	return true, nil
+}
+
+// RunTask runs task, which works on the database outside the pipeline, with a context that is also cancelled when the
+// application shuts down. Shutdown waits for it before disconnecting. Once shutdown began, task isn't run and an
+// ApplicationStateError is returned.
+func (app *Application) RunTask(ctx context.Context, task func(ctx context.Context)) error {
+	app.mutex.Lock()
+	if app.state == StateStopping || app.state == StateStopped {
+		app.mutex.Unlock()
+		return errorTypes.NewApplicationStateError("application is shutting down")
+	}
+	app.tasks.Add(1)
+	app.mutex.Unlock()
+	defer app.tasks.Done()
+
+	taskCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(app.tasksCtx, cancel)
+	defer stop()
+	task(taskCtx)
+	return nil
+}
+
+// Shutdown cancels the running tasks and stops the pipeline and waits for both, then disconnects from the database
+// and closes the AI analyzer's clients. The database stays connected if they don't stop before ctx is done.
+func (app *Application) Shutdown(ctx context.Context) error {
+	app.mutex.Lock()
+	app.state = StateStopping
+	app.mutex.Unlock()
+	app.cancelTasks()
+
+	if err := app.Supervisor.Stop(); err != nil && !errorTypes.IsPipelineStateError(err) {
+		log.Printf("in application.Shutdown failed to stop pipeline: %v", err)
+	}
+	if err := app.Supervisor.Wait(ctx); err != nil {
+		log.Printf("in application.Shutdown pipeline didn't stop: %v", err)
+		return err
+	}
+	if err := app.waitForTasks(ctx); err != nil {
+		log.Printf("in application.Shutdown tasks didn't stop: %v", err)
+		return err
+	}
+
+	app.lifecycleMutex.Lock()
+	defer app.lifecycleMutex.Unlock()
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	var shutdownErr error
+	if app.isConnected {
+		shutdownErr = app.Database.Disconnect(&dataTypes.FlowControl{Ctx: ctx, StopOnTooManyErrorsChannel: make(chan struct{}, 1)})
+		app.isConnected = false
+	}
+	if app.isAnalyzerOpen {
+		shutdownErr = errors.Join(shutdownErr, app.Analyzer.Close())
+		app.isAnalyzerOpen = false
+	}
+	app.state = StateStopped
+	return shutdownErr
+}
+
+func (app *Application) waitForTasks(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		app.tasks.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (app *Application) GetState() string {
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	return app.state
+}
+
+// IsReady reports whether the application is started and still serving requests.
+func (app *Application) IsReady() bool {
+	return app.GetState() == StateReady
+}
+
+// GetReadiness checks that the database is still up.
+func (app *Application) GetReadiness(ctx context.Context) dataTypes.Readiness {
+	readiness := dataTypes.Readiness{State: app.GetState(), Pipeline: app.Supervisor.Status().State}
+	if readiness.State == StateReady {
+		ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+		defer cancel()
+		readiness.IsDatabaseUp = app.Database.IsUp(&dataTypes.FlowControl{Ctx: ctx, StopOnTooManyErrorsChannel: make(chan struct{}, 1)})
+	}
+	return readiness
+}
@@ -0,0 +1,67 @@
+package benchmarkEstimation
+
+import (
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/aiAnalysis"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/helpers"
+	"slices"
+)
+
+// IsChipsetPeer reports whether candidate is another device with the given chipset key whose benchmark was measured.
+func IsChipsetPeer(device, candidate *dataTypes.Device, chipset string) bool {
+	return candidate.ID != device.ID && GetChipsetKey(candidate.Specs.Chipset) == chipset &&
+		!candidate.Benchmark.IsEstimatedBenchmark && !candidate.Benchmark.IsChipsetInferred &&
+		candidate.Benchmark.SingleCoreScore > 0 && candidate.Benchmark.MultiCoreScore > 0
+}
+
+// SetChipsetInferredScores sets the device's scores to the median scores of its chipset peers, which must not be
+// empty, and scores it against validatedMinMax.
+func SetChipsetInferredScores(device *dataTypes.Device, peers []dataTypes.Device, validatedMinMax dataTypes.MinMaxValues) {
+	var singleCoreScores, multiCoreScores, gpuScores []float64
+	for _, peer := range peers {
+		singleCoreScores = append(singleCoreScores, peer.Benchmark.SingleCoreScore)
+		multiCoreScores = append(multiCoreScores, peer.Benchmark.MultiCoreScore)
+		if peer.Benchmark.GPUScore > 0 {
+			gpuScores = append(gpuScores, peer.Benchmark.GPUScore)
+		}
+	}
+
+	device.Benchmark.IsEstimatedBenchmark = true
+	device.Benchmark.IsChipsetInferred = true
+	device.Benchmark.SingleCoreScore = median(singleCoreScores)
+	device.Benchmark.MultiCoreScore = median(multiCoreScores)
+	device.Benchmark.Estimation = &dataTypes.BenchmarkEstimation{
+		Method:             dataTypes.ChipsetInferredEstimation,
+		SingleCoreInterval: dataTypes.MinMaxFloat{Min: slices.Min(singleCoreScores), Max: slices.Max(singleCoreScores)},
+		MultiCoreInterval:  dataTypes.MinMaxFloat{Min: slices.Min(multiCoreScores), Max: slices.Max(multiCoreScores)},
+		SampleSize:         len(peers),
+	}
+	helpers.RecordProvenance(device, dataTypes.SingleCoreScoreProvenance, dataTypes.ChipsetInferredEstimation, "", dataTypes.InferredMethod)
+	helpers.RecordProvenance(device, dataTypes.MultiCoreScoreProvenance, dataTypes.ChipsetInferredEstimation, "", dataTypes.InferredMethod)
+	ClearInferredGPUScore(device)
+	if device.Benchmark.GPUScore == 0 && len(gpuScores) > 0 {
+		device.Benchmark.GPUScore = median(gpuScores)
+		helpers.RecordProvenance(device, dataTypes.GPUScoreProvenance, dataTypes.ChipsetInferredEstimation, "", dataTypes.InferredMethod)
+	}
+	device.ValidatedFinalScore = aiAnalysis.GetFinalScore(validatedMinMax, device, dataTypes.ValidatedScores)
+}
+
+// ClearInferredGPUScore removes a GPU score inferred from the device's chipset, so that it is inferred again from the
+// current peers rather than kept from the ones it was first inferred from. A measured GPU score is kept.
+func ClearInferredGPUScore(device *dataTypes.Device) {
+	if device.Provenance[dataTypes.GPUScoreProvenance].Method != dataTypes.InferredMethod {
+		return
+	}
+	device.Benchmark.GPUScore = 0
+	delete(device.Provenance, dataTypes.GPUScoreProvenance)
+}
+
+func median(values []float64) float64 {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
@@ -0,0 +1,172 @@
+package benchmarkEstimation
+
+import (
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/aiAnalysis"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/brandCatalog"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/helpers"
+	"log"
+	"math"
+	"sync"
+)
+
+// YearOverYearIncrease is how much faster a device is assumed to be than its predecessor of last year.
+const YearOverYearIncrease = 0.10
+
+// DeviceStore is the part of the database the estimator reads from.
+type DeviceStore interface {
+	GetAllDevices(*dataTypes.FlowControl) ([]dataTypes.Device, error)
+	GetLastYearEquivalentBenchmarkScores(*dataTypes.Device, *dataTypes.FlowControl) (float64, float64, error)
+	GetValidatedAndUnvalidatedMinMaxValues(*dataTypes.FlowControl) (dataTypes.ValidatedAndUnvalidatedMinMaxValues, error)
+	SetChipsetInferredBenchmarkScores(*dataTypes.Device, *dataTypes.FlowControl) error
+}
+
+// Estimator fits the regression model on first use and keeps it until InvalidateModel is called, so that estimating
+// many devices fits it only once. The database that owns it invalidates the model whenever ChangesModel.
+type Estimator struct {
+	store  DeviceStore
+	brands *brandCatalog.Catalog
+	// mutex guards the fitted model. It isn't held while fitting, so the store can invalidate the model while
+	// holding its own locks; generation tells a fit that the model was invalidated meanwhile.
+	mutex      sync.Mutex
+	model      *Model
+	modelErr   error
+	isFitted   bool
+	generation int
+}
+
+func NewEstimator(store DeviceStore, brands *brandCatalog.Catalog) *Estimator {
+	return &Estimator{store: store, brands: brands}
+}
+
+// InvalidateModel makes the next estimation fit the model again.
+func (estimator *Estimator) InvalidateModel() {
+	estimator.mutex.Lock()
+	defer estimator.mutex.Unlock()
+	estimator.model = nil
+	estimator.modelErr = nil
+	estimator.isFitted = false
+	estimator.generation++
+}
+
+func (estimator *Estimator) getModel(ctrl *dataTypes.FlowControl) (*Model, error) {
+	estimator.mutex.Lock()
+	if estimator.isFitted {
+		defer estimator.mutex.Unlock()
+		return estimator.model, estimator.modelErr
+	}
+	generation := estimator.generation
+	estimator.mutex.Unlock()
+
+	allDevices, err := estimator.store.GetAllDevices(ctrl)
+	if err != nil {
+		log.Printf("in benchmarkEstimation.getModel failed to get all devices: %v", err)
+		return nil, err
+	}
+	model, modelErr := FitModel(allDevices)
+
+	estimator.mutex.Lock()
+	defer estimator.mutex.Unlock()
+	if estimator.generation == generation {
+		estimator.model, estimator.modelErr, estimator.isFitted = model, modelErr, true
+	}
+	return model, modelErr
+}
+
+// SetEstimatedBenchmarkScores estimates the device's benchmark with its brand's estimation method. Devices that
+// can't be estimated keep their scores.
+func (estimator *Estimator) SetEstimatedBenchmarkScores(device *dataTypes.Device, ctrl *dataTypes.FlowControl) error {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping benchmarkEstimation.SetEstimatedBenchmarkScores: %v", ctrl.Ctx.Err())
+		return ctrl.Ctx.Err()
+	}
+
+	brand, err := estimator.brands.GetBrand(device.Brand)
+	if err != nil {
+		log.Printf("in benchmarkEstimation.SetEstimatedBenchmarkScores (device: %v) failed to get brand: %v", device.Name, err)
+		return err
+	}
+
+	var singleCoreScore, multiCoreScore float64
+	var estimation dataTypes.BenchmarkEstimation
+	isEstimated := false
+	if brand.Estimation.Method == brandCatalog.RegressionEstimation {
+		model, err := estimator.getModel(ctrl)
+		if err == nil {
+			singleCoreScore, multiCoreScore, estimation, err = model.Estimate(device)
+		}
+		if err != nil && !errorTypes.IsRegressionFitError(err) {
+			log.Printf("in benchmarkEstimation.SetEstimatedBenchmarkScores (device: %v) failed to fit regression: %v", device.Name, err)
+			return err
+		}
+		if err != nil {
+			log.Printf("in benchmarkEstimation.SetEstimatedBenchmarkScores (device: %v) falling back to last year equivalent: %v", device.Name, err)
+		}
+		isEstimated = err == nil
+	}
+
+	if !isEstimated {
+		singleCoreScore, multiCoreScore, err = estimator.store.GetLastYearEquivalentBenchmarkScores(device, ctrl)
+		if err != nil {
+			if errorTypes.IsNoLastYearEquivalentError(err) {
+				return nil
+			} else {
+				log.Printf("in benchmarkEstimation.SetEstimatedBenchmarkScores (device: %v) failed to get last year equivelant benchmark scores: %v", device.Name, err)
+				return err
+			}
+		}
+		estimation = dataTypes.BenchmarkEstimation{Method: brandCatalog.LastYearEquivalentEstimation}
+	}
+
+	minMax, err := estimator.store.GetValidatedAndUnvalidatedMinMaxValues(ctrl)
+	if err != nil {
+		log.Printf("in benchmarkEstimation.SetEstimatedBenchmarkScores (device: %v) failed to get minmax: %v", device.Name, err)
+		return err
+	}
+
+	device.Benchmark.SingleCoreScore = singleCoreScore
+	device.Benchmark.MultiCoreScore = multiCoreScore
+	device.Benchmark.Estimation = &estimation
+	helpers.RecordProvenance(device, dataTypes.SingleCoreScoreProvenance, estimation.Method, "", dataTypes.EstimatedMethod)
+	helpers.RecordProvenance(device, dataTypes.MultiCoreScoreProvenance, estimation.Method, "", dataTypes.EstimatedMethod)
+	device.ValidatedFinalScore = aiAnalysis.GetFinalScore(minMax.Validated, device, dataTypes.ValidatedScores)
+	return nil
+}
+
+// ReestimateBenchmarkScores infers the device's benchmark from its chipset, and estimates it when no measured device
+// shares the chipset.
+func (estimator *Estimator) ReestimateBenchmarkScores(device *dataTypes.Device, ctrl *dataTypes.FlowControl) error {
+	err := estimator.store.SetChipsetInferredBenchmarkScores(device, ctrl)
+	if err != nil && !errorTypes.IsNoChipsetPeerError(err) {
+		log.Printf("in benchmarkEstimation.ReestimateBenchmarkScores (device: %v) failed to infer benchmark score from chipset: %v", device.Name, err)
+		return err
+	}
+	if err != nil {
+		device.Benchmark.IsEstimatedBenchmark = true
+		device.Benchmark.IsChipsetInferred = false
+		ClearInferredGPUScore(device)
+		err = estimator.SetEstimatedBenchmarkScores(device, ctrl)
+	}
+	if err != nil {
+		log.Printf("in benchmarkEstimation.ReestimateBenchmarkScores (device: %v) failed to estimate benchmark score: %v", device.Name, err)
+		return err
+	}
+	return nil
+}
+
+// IsReestimated reports whether the device's benchmark was inferred or estimated, and so is redone by
+// ReestimateBenchmarkScores.
+func IsReestimated(device *dataTypes.Device) bool {
+	return device.Benchmark.IsEstimatedBenchmark || device.Benchmark.IsChipsetInferred
+}
+
+// ApplyPriceCategoryPenalty lowers the scores of a last year equivalent by the penalty for every price category it
+// is away from the device.
+func ApplyPriceCategoryPenalty(singleCoreScore, multiCoreScore float64, equivalentPriceCategory, priceCategory int, penalty float64) (float64, float64) {
+	if penalty <= 0 {
+		return singleCoreScore, multiCoreScore
+	}
+	priceCategoryDiff := math.Abs(float64(equivalentPriceCategory - priceCategory))
+	return singleCoreScore * (1 - priceCategoryDiff*penalty), multiCoreScore * (1 - priceCategoryDiff*penalty)
+}
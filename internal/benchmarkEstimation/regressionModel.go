@@ -0,0 +1,162 @@
+package benchmarkEstimation
+
+import (
+	"fmt"
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/brandCatalog"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/regression"
+	"log"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	regressionRidge = 1e-3
+	// minCategorySamples is how many training devices a brand or chipset needs to get its own feature.
+	minCategorySamples = 2
+	confidenceLevel    = 0.95
+	confidenceZ        = 1.96
+	hoursPerYear       = 365.25 * 24
+)
+
+// releaseDateEpoch keeps the release date feature small; any fixed date would do.
+var releaseDateEpoch = time.Date(2007, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Model estimates a device's benchmark from its release date, price category, brand and chipset. Scores are fitted
+// in log space, since they grow by a roughly constant factor each year.
+type Model struct {
+	brands     []string
+	chipsets   []string
+	singleCore *regression.Model
+	multiCore  *regression.Model
+}
+
+func isTrainingDevice(device *dataTypes.Device) bool {
+	return !device.Benchmark.IsEstimatedBenchmark && !device.Benchmark.IsChipsetInferred && device.Benchmark.SingleCoreScore > 0 &&
+		device.Benchmark.MultiCoreScore > 0 && !device.Specs.ReleaseDate.IsZero()
+}
+
+// ChangesModel reports whether storing device in place of previous, or as a new device when previous is nil, changes
+// what the model is fitted on.
+func ChangesModel(previous, device *dataTypes.Device) bool {
+	if previous == nil || !isTrainingDevice(previous) {
+		return isTrainingDevice(device)
+	}
+	if !isTrainingDevice(device) {
+		return true
+	}
+	return previous.Brand != device.Brand || previous.Specs.Chipset != device.Specs.Chipset ||
+		!previous.Specs.ReleaseDate.Equal(device.Specs.ReleaseDate) || previous.PriceCategory != device.PriceCategory ||
+		previous.Benchmark.SingleCoreScore != device.Benchmark.SingleCoreScore ||
+		previous.Benchmark.MultiCoreScore != device.Benchmark.MultiCoreScore
+}
+
+// FitModel fits the model on every given device whose benchmark was measured rather than estimated or inferred.
+func FitModel(allDevices []dataTypes.Device) (*Model, error) {
+	var trainingDevices []dataTypes.Device
+	brandCounts := make(map[string]int)
+	chipsetCounts := make(map[string]int)
+	for _, device := range allDevices {
+		if !isTrainingDevice(&device) {
+			continue
+		}
+		trainingDevices = append(trainingDevices, device)
+		brandCounts[getBrandKey(device.Brand)]++
+		if chipset := GetChipsetKey(device.Specs.Chipset); chipset != "" {
+			chipsetCounts[chipset]++
+		}
+	}
+
+	model := &Model{brands: getFrequentKeys(brandCounts), chipsets: getFrequentKeys(chipsetCounts)}
+	features := make([][]float64, len(trainingDevices))
+	singleCoreTargets := make([]float64, len(trainingDevices))
+	multiCoreTargets := make([]float64, len(trainingDevices))
+	for i, device := range trainingDevices {
+		features[i] = model.getFeatures(&device)
+		singleCoreTargets[i] = math.Log(device.Benchmark.SingleCoreScore)
+		multiCoreTargets[i] = math.Log(device.Benchmark.MultiCoreScore)
+	}
+
+	var err error
+	model.singleCore, err = regression.Fit(features, singleCoreTargets, regressionRidge)
+	if err != nil {
+		log.Printf("in benchmarkEstimation.FitModel failed to fit single core scores: %v", err)
+		return nil, err
+	}
+	model.multiCore, err = regression.Fit(features, multiCoreTargets, regressionRidge)
+	if err != nil {
+		log.Printf("in benchmarkEstimation.FitModel failed to fit multi core scores: %v", err)
+		return nil, err
+	}
+	log.Printf("in benchmarkEstimation.FitModel fitted on %d devices, %d brands and %d chipsets",
+		len(trainingDevices), len(model.brands), len(model.chipsets))
+	return model, nil
+}
+
+// getFeatures encodes the device as its release date in years, its price category and one indicator per brand and
+// chipset of the model. Unknown brands and chipsets have no indicator set.
+func (model *Model) getFeatures(device *dataTypes.Device) []float64 {
+	features := make([]float64, 2, 2+len(model.brands)+len(model.chipsets))
+	features[0] = device.Specs.ReleaseDate.Sub(releaseDateEpoch).Hours() / hoursPerYear
+	features[1] = float64(device.PriceCategory)
+
+	brand := getBrandKey(device.Brand)
+	for _, modelBrand := range model.brands {
+		features = append(features, indicator(brand == modelBrand))
+	}
+	chipset := GetChipsetKey(device.Specs.Chipset)
+	for _, modelChipset := range model.chipsets {
+		features = append(features, indicator(chipset != "" && chipset == modelChipset))
+	}
+	return features
+}
+
+// Estimate returns the device's single and multi core scores along with their prediction intervals.
+func (model *Model) Estimate(device *dataTypes.Device) (float64, float64, dataTypes.BenchmarkEstimation, error) {
+	if device.Specs.ReleaseDate.IsZero() {
+		return 0, 0, dataTypes.BenchmarkEstimation{}, errorTypes.NewRegressionFitError(fmt.Sprintf("in benchmarkEstimation.Estimate (device: %v) no release date", device.Name))
+	}
+
+	features := model.getFeatures(device)
+	singleCoreMin, singleCoreMax := model.singleCore.PredictionInterval(features, confidenceZ)
+	multiCoreMin, multiCoreMax := model.multiCore.PredictionInterval(features, confidenceZ)
+	estimation := dataTypes.BenchmarkEstimation{
+		Method:             brandCatalog.RegressionEstimation,
+		ConfidenceLevel:    confidenceLevel,
+		SingleCoreInterval: dataTypes.MinMaxFloat{Min: math.Exp(singleCoreMin), Max: math.Exp(singleCoreMax)},
+		MultiCoreInterval:  dataTypes.MinMaxFloat{Min: math.Exp(multiCoreMin), Max: math.Exp(multiCoreMax)},
+		SampleSize:         model.singleCore.SampleSize,
+	}
+	return math.Exp(model.singleCore.Predict(features)), math.Exp(model.multiCore.Predict(features)), estimation, nil
+}
+
+func getFrequentKeys(counts map[string]int) []string {
+	var keys []string
+	for key, count := range counts {
+		if count >= minCategorySamples {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func getBrandKey(brand string) string {
+	return strings.ToLower(strings.TrimSpace(brand))
+}
+
+// GetChipsetKey drops the process node the spec API appends, e.g. "Apple A17 Pro (3 nm)" becomes "apple a17 pro".
+func GetChipsetKey(chipset string) string {
+	chipset, _, _ = strings.Cut(chipset, "(")
+	return strings.ToLower(strings.TrimSpace(chipset))
+}
+
+func indicator(condition bool) float64 {
+	if condition {
+		return 1
+	}
+	return 0
+}
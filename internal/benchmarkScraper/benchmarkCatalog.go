@@ -0,0 +1,220 @@
+package benchmarkScraper
+
+import (
+	"fmt"
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/brandCatalog"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/helpers"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/nameMatching"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/parsingErrorLogger"
+	"github.com/PuerkitoBio/goquery"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	singleCoreSelector = "div#single-core.tab-pane.fade.show.active"
+	multiCoreSelector  = "div#multi-core.tab-pane.fade"
+
+	metalGPUAPI  = "metal"
+	vulkanGPUAPI = "vulkan"
+)
+
+// gpuAPIs are the compute leaderboard tabs read as each platform's GPU score.
+var gpuAPIs = map[string]string{
+	brandCatalog.IOSBenchmarkPlatform:     metalGPUAPI,
+	brandCatalog.AndroidBenchmarkPlatform: vulkanGPUAPI,
+}
+
+// CatalogStore persists the parsed leaderboards so that they outlive the process.
+type CatalogStore interface {
+	GetBenchmarkCatalog(string, *dataTypes.FlowControl) (dataTypes.BenchmarkCatalog, error)
+	SaveBenchmarkCatalog(*dataTypes.BenchmarkCatalog, *dataTypes.FlowControl) error
+}
+
+// Catalog serves benchmark scores from the Geekbench leaderboards, downloading each platform's leaderboard at most
+// once per TTL. Parsed leaderboards are kept in memory and in the store. One catalog is shared by the whole
+// application, so that the leaderboards are downloaded once for all of it.
+type Catalog struct {
+	store CatalogStore
+	// client fetches the leaderboards.
+	client *http.Client
+	ttl    time.Duration
+	// brands tell which platform's leaderboard lists a brand's devices, and aliases resolve the names looked up.
+	brands  *brandCatalog.Catalog
+	aliases *nameMatching.Aliases
+	// mutex guards platforms and loads, and is never held while loading.
+	mutex     sync.Mutex
+	platforms map[string]dataTypes.BenchmarkCatalog
+	// loads holds a channel per platform being loaded, closed once the load ends.
+	loads map[string]chan struct{}
+}
+
+// NewCatalog returns a catalog backed by store, which fetches the leaderboards with client and downloads them again
+// once they are older than ttl.
+func NewCatalog(store CatalogStore, client *http.Client, ttl time.Duration, brands *brandCatalog.Catalog,
+	aliases *nameMatching.Aliases) *Catalog {
+	return &Catalog{
+		store:     store,
+		client:    client,
+		ttl:       ttl,
+		brands:    brands,
+		aliases:   aliases,
+		platforms: make(map[string]dataTypes.BenchmarkCatalog),
+		loads:     make(map[string]chan struct{}),
+	}
+}
+
+// ExpiresAt is when the given leaderboard is downloaded again.
+func (catalog *Catalog) ExpiresAt(platformCatalog dataTypes.BenchmarkCatalog) time.Time {
+	return platformCatalog.FetchedAt.Add(catalog.ttl)
+}
+
+// GetPlatformCatalog returns the platform's leaderboard from memory, then from the store, and downloads it only when
+// neither holds a fresh copy. If the download fails, a stale copy is returned instead when there is one. Callers
+// asking for a platform that is being loaded wait for that load instead of starting another.
+func (catalog *Catalog) GetPlatformCatalog(platform string, ctrl *dataTypes.FlowControl) (dataTypes.BenchmarkCatalog, error) {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping benchmarkScraper.GetPlatformCatalog: %v", ctrl.Ctx.Err())
+		return dataTypes.BenchmarkCatalog{}, ctrl.Ctx.Err()
+	}
+
+	for {
+		catalog.mutex.Lock()
+		cached, isCached := catalog.platforms[platform]
+		if isCached && time.Now().Before(catalog.ExpiresAt(cached)) {
+			catalog.mutex.Unlock()
+			return cached, nil
+		}
+		load, isLoading := catalog.loads[platform]
+		if !isLoading {
+			load = make(chan struct{})
+			catalog.loads[platform] = load
+			catalog.mutex.Unlock()
+			defer func() {
+				catalog.mutex.Lock()
+				delete(catalog.loads, platform)
+				catalog.mutex.Unlock()
+				close(load)
+			}()
+			return catalog.loadPlatformCatalog(platform, cached, isCached, ctrl)
+		}
+		catalog.mutex.Unlock()
+
+		select {
+		case <-load:
+		case <-ctrl.Ctx.Done():
+			log.Printf("stopping benchmarkScraper.GetPlatformCatalog: %v", ctrl.Ctx.Err())
+			return dataTypes.BenchmarkCatalog{}, ctrl.Ctx.Err()
+		}
+	}
+}
+
+// loadPlatformCatalog reads the platform's leaderboard from the store, and downloads it if the stored copy is missing
+// or stale. cached is the copy held in memory, if isCached.
+func (catalog *Catalog) loadPlatformCatalog(platform string, cached dataTypes.BenchmarkCatalog, isCached bool,
+	ctrl *dataTypes.FlowControl) (dataTypes.BenchmarkCatalog, error) {
+	stored, err := catalog.store.GetBenchmarkCatalog(platform, ctrl)
+	if err == nil {
+		cached, isCached = stored, true
+		catalog.setPlatformCatalog(stored)
+		if time.Now().Before(catalog.ExpiresAt(stored)) {
+			return stored, nil
+		}
+	} else if !errorTypes.IsMissingDocumentError(err) {
+		log.Printf("WARNING: in benchmarkScraper.loadPlatformCatalog (platform: %v) failed to read stored catalog: %v", platform, err)
+	}
+
+	fetched, err := fetchPlatformCatalog(platform, catalog.client, ctrl)
+	if err != nil {
+		if isCached && ctrl.Ctx.Err() == nil {
+			log.Printf("WARNING: in benchmarkScraper.loadPlatformCatalog (platform: %v) using catalog from %v, failed to refresh it: %v", platform, cached.FetchedAt, err)
+			return cached, nil
+		}
+		log.Printf("in benchmarkScraper.loadPlatformCatalog (platform: %v) failed to fetch catalog: %v", platform, err)
+		return dataTypes.BenchmarkCatalog{}, err
+	}
+
+	if err = catalog.store.SaveBenchmarkCatalog(&fetched, ctrl); err != nil {
+		log.Printf("WARNING: in benchmarkScraper.loadPlatformCatalog (platform: %v) failed to store catalog: %v", platform, err)
+	}
+	catalog.setPlatformCatalog(fetched)
+	return fetched, nil
+}
+
+func (catalog *Catalog) setPlatformCatalog(platformCatalog dataTypes.BenchmarkCatalog) {
+	catalog.mutex.Lock()
+	defer catalog.mutex.Unlock()
+	catalog.platforms[platformCatalog.Platform] = platformCatalog
+}
+
+// fetchPlatformCatalog downloads and parses the platform's leaderboard.
+func fetchPlatformCatalog(platform string, client *http.Client, ctrl *dataTypes.FlowControl) (dataTypes.BenchmarkCatalog, error) {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping benchmarkScraper.fetchPlatformCatalog: %v", ctrl.Ctx.Err())
+		return dataTypes.BenchmarkCatalog{}, ctrl.Ctx.Err()
+	}
+
+	url := getBenchmarkPageURL(platform)
+	log.Printf("in benchmarkScraper.fetchPlatformCatalog fetching %v", url)
+	doc, err := helpers.GetDocumentByURL(client, url, ctrl)
+	if err != nil {
+		log.Printf("in benchmarkScraper.fetchPlatformCatalog failed to get benchmark page: %v", err)
+		return dataTypes.BenchmarkCatalog{}, err
+	}
+
+	platformCatalog := dataTypes.BenchmarkCatalog{Platform: platform, URL: url, GPUAPI: gpuAPIs[platform], FetchedAt: time.Now()}
+	indexes := make(map[string]int)
+	readScores(doc, singleCoreSelector, url, func(entry *dataTypes.BenchmarkEntry, score int) {
+		entry.SingleCoreScore = score
+	}, indexes, &platformCatalog, ctrl)
+	readScores(doc, multiCoreSelector, url, func(entry *dataTypes.BenchmarkEntry, score int) {
+		entry.MultiCoreScore = score
+	}, indexes, &platformCatalog, ctrl)
+	if platformCatalog.GPUAPI != "" {
+		readScores(doc, "div#"+platformCatalog.GPUAPI+".tab-pane", url, func(entry *dataTypes.BenchmarkEntry, score int) {
+			entry.GPUScore = score
+		}, indexes, &platformCatalog, ctrl)
+	}
+
+	if len(platformCatalog.Entries) == 0 {
+		errMsg := fmt.Sprintf("in benchmarkScraper.fetchPlatformCatalog (url: %v) found no devices in benchmark page", url)
+		parsingErrorLogger.LogErrorInJsonFile(errMsg, ctrl)
+		return dataTypes.BenchmarkCatalog{}, errorTypes.NewParsingError(errMsg)
+	}
+	return platformCatalog, nil
+}
+
+// readScores sets the score of every row of the selected leaderboard tab on its device's entry, adding entries for
+// devices not seen yet.
+func readScores(doc *goquery.Document, selector, url string, setScore func(*dataTypes.BenchmarkEntry, int),
+	indexes map[string]int, platformCatalog *dataTypes.BenchmarkCatalog, ctrl *dataTypes.FlowControl) {
+	doc.Find(selector).Find("tr").Each(func(i int, s *goquery.Selection) {
+		deviceName := strings.TrimSpace(s.Find("td.name a").Text())
+		scoreString := strings.TrimSpace(s.Find("td.score").Text())
+		if deviceName == "" || scoreString == "" {
+			return
+		}
+
+		score, err := strconv.Atoi(scoreString)
+		if err != nil {
+			errMsg := fmt.Sprintf("in benchmarkScraper.readScores (url: %v) failed to parse score of %v: %v", url, deviceName, err)
+			log.Println(errMsg)
+			parsingErrorLogger.LogErrorInJsonFile(errMsg, ctrl)
+			return
+		}
+
+		index, ok := indexes[deviceName]
+		if !ok {
+			index = len(platformCatalog.Entries)
+			indexes[deviceName] = index
+			platformCatalog.Entries = append(platformCatalog.Entries, dataTypes.BenchmarkEntry{Name: deviceName})
+		}
+		setScore(&platformCatalog.Entries[index], score)
+	})
+}
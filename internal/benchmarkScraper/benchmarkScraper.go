@@ -3,114 +3,160 @@ package benchmarkScraper
 import (
 	"fmt"
 	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
-	"github.com/ItaiHalperin/Device-Rec-API/internal/errorMonitoring"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/brandCatalog"
 	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
 	"github.com/ItaiHalperin/Device-Rec-API/internal/helpers"
-	"github.com/PuerkitoBio/goquery"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/nameMatching"
 	"log"
-	"strconv"
 	"strings"
 )
 
-func SetBenchmarkScores(device *dataTypes.Device, ctrl *dataTypes.FlowControl) error {
+const benchmarkSource = "geekbench"
+
+// BenchmarkSource sets some of a device's benchmark scores. A required source's failure fails the benchmark
+// stage; an optional source's failure only leaves its scores unset.
+type BenchmarkSource interface {
+	Name() string
+	IsRequired() bool
+	SetScores(device *dataTypes.Device, ctrl *dataTypes.FlowControl) error
+}
+
+// DefaultSources are the Geekbench CPU scores, which every device needs, and the Geekbench GPU compute score.
+func DefaultSources(catalog *Catalog) []BenchmarkSource {
+	return []BenchmarkSource{
+		geekbenchCPUSource{catalog: catalog},
+		geekbenchGPUSource{catalog: catalog},
+	}
+}
+
+// SetBenchmarkScores runs every source, and returns the first required source's error once all of them ran.
+func SetBenchmarkScores(device *dataTypes.Device, sources []BenchmarkSource, ctrl *dataTypes.FlowControl) error {
 	if ctrl.Ctx.Err() != nil {
 		log.Printf("stopping benchmarkScraper.SetBenchmarkScores: %v", ctrl.Ctx.Err())
 		return ctrl.Ctx.Err()
 	}
 
-	singleCoreScore, multiCoreScore, err := GetSingleMultiScores(device.Brand, device.Name, ctrl)
+	var requiredErr error
+	for _, source := range sources {
+		err := source.SetScores(device, ctrl)
+		if err == nil {
+			continue
+		}
+		if ctrl.Ctx.Err() != nil {
+			return ctrl.Ctx.Err()
+		}
+		if source.IsRequired() {
+			log.Printf("in benchmarkScraper.SetBenchmarkScores (device: %v) required source %v failed: %v", device.Name, source.Name(), err)
+			if requiredErr == nil {
+				requiredErr = err
+			}
+			continue
+		}
+		log.Printf("in benchmarkScraper.SetBenchmarkScores (device: %v) optional source %v failed: %v", device.Name, source.Name(), err)
+	}
+	return requiredErr
+}
+
+type geekbenchCPUSource struct {
+	catalog *Catalog
+}
+
+func (geekbenchCPUSource) Name() string     { return benchmarkSource + "-cpu" }
+func (geekbenchCPUSource) IsRequired() bool { return true }
+
+func (source geekbenchCPUSource) SetScores(device *dataTypes.Device, ctrl *dataTypes.FlowControl) error {
+	entry, benchmarkURL, err := source.catalog.getEntry(device.Brand, device.Name, ctrl)
 	if err != nil {
-		log.Printf("in benchmarkScraper.SetBenchmarkScores failed to get single and multi core scores: %v", err)
+		log.Printf("in benchmarkScraper.SetScores failed to get single and multi core scores: %v", err)
 		return err
 	}
+	if entry.SingleCoreScore == 0 || entry.MultiCoreScore == 0 {
+		log.Printf("in benchmarkScraper.SetScores (device: %v) found no single or multi core score", device.Name)
+		return errorTypes.NewNoSuchPhoneBenchmarkError(fmt.Sprintf("in benchmarkScraper.SetScores found no single or multi core score of %v", device.Name))
+	}
 
-	device.Benchmark.MultiCoreScore = float64(multiCoreScore)
-	device.Benchmark.SingleCoreScore = float64(singleCoreScore)
+	device.Benchmark.MultiCoreScore = float64(entry.MultiCoreScore)
+	device.Benchmark.SingleCoreScore = float64(entry.SingleCoreScore)
+	helpers.RecordProvenance(device, dataTypes.SingleCoreScoreProvenance, benchmarkSource, benchmarkURL, dataTypes.ParsedMethod)
+	helpers.RecordProvenance(device, dataTypes.MultiCoreScoreProvenance, benchmarkSource, benchmarkURL, dataTypes.ParsedMethod)
 	return nil
 }
 
-func GetSingleMultiScores(brand, model string, ctrl *dataTypes.FlowControl) (int, int, error) {
-	if ctrl.Ctx.Err() != nil {
-		log.Printf("stopping benchmarkScraper.getSingleMultiScores: %v", ctrl.Ctx.Err())
-		return 0, 0, ctrl.Ctx.Err()
-	}
+// geekbenchGPUSource reads the compute leaderboard of the device's platform: Metal on iOS and Vulkan on Android.
+type geekbenchGPUSource struct {
+	catalog *Catalog
+}
 
-	var url string
-	if brand == "Apple" {
-		url = "https://browser.geekbench.com/ios-benchmarks/"
-	} else {
-		model = brand + " " + model
-		url = "https://browser.geekbench.com/android-benchmarks/"
-	}
+func (geekbenchGPUSource) Name() string     { return benchmarkSource + "-gpu" }
+func (geekbenchGPUSource) IsRequired() bool { return false }
 
-	doc, err := helpers.GetDocumentByURL(url, ctrl)
+func (source geekbenchGPUSource) SetScores(device *dataTypes.Device, ctrl *dataTypes.FlowControl) error {
+	entry, benchmarkURL, err := source.catalog.getEntry(device.Brand, device.Name, ctrl)
 	if err != nil {
-		log.Printf("in benchmarkScraper.getSingleMultiScores failed to get benchmark page: %v", err)
-		return 0, 0, err
-	}
-
-	singleCoreScore, err := getSingleScore(model, doc, ctrl)
-	if err != nil {
-		log.Printf("in benchmarkScraper.getSingleMultiScores failed to get single core score: %v", err)
-		return 0, 0, err
+		log.Printf("in benchmarkScraper.SetScores failed to get gpu score: %v", err)
+		return err
 	}
-
-	multiCoreScore, err := getMultiScore(model, doc, ctrl)
-	if err != nil {
-		log.Printf("in benchmarkScraper.getSingleMultiScores failed to get multi core score: %v", err)
-		return 0, 0, err
+	if entry.GPUScore == 0 {
+		log.Printf("in benchmarkScraper.SetScores (device: %v) found no gpu score", device.Name)
+		return errorTypes.NewNoSuchPhoneBenchmarkError(fmt.Sprintf("in benchmarkScraper.SetScores found no gpu score of %v", device.Name))
 	}
 
-	return singleCoreScore, multiCoreScore, nil
+	device.Benchmark.GPUScore = float64(entry.GPUScore)
+	helpers.RecordProvenance(device, dataTypes.GPUScoreProvenance, benchmarkSource, benchmarkURL, dataTypes.ParsedMethod)
+	return nil
 }
 
-func getSingleScore(modelName string, doc *goquery.Document, ctrl *dataTypes.FlowControl) (int, error) {
-	if ctrl.Ctx.Err() != nil {
-		log.Printf("stopping benchmarkScraper.getSingleScore: %v", ctrl.Ctx.Err())
-		return 0, ctrl.Ctx.Err()
+func getBenchmarkPageURL(platform string) string {
+	if platform == brandCatalog.IOSBenchmarkPlatform {
+		return "https://browser.geekbench.com/ios-benchmarks/"
 	}
-	return getScore(modelName, "div#single-core.tab-pane.fade.show.active", doc, ctrl)
+	return "https://browser.geekbench.com/android-benchmarks/"
 }
 
-func getMultiScore(modelName string, doc *goquery.Document, ctrl *dataTypes.FlowControl) (int, error) {
+// getEntry looks the model up in its platform's leaderboard and returns its entry along with the leaderboard's URL.
+func (catalog *Catalog) getEntry(brand, model string, ctrl *dataTypes.FlowControl) (dataTypes.BenchmarkEntry, string, error) {
 	if ctrl.Ctx.Err() != nil {
-		log.Printf("stopping benchmarkScraper.getMultiScore: %v", ctrl.Ctx.Err())
-		return 0, ctrl.Ctx.Err()
+		log.Printf("stopping benchmarkScraper.getEntry: %v", ctrl.Ctx.Err())
+		return dataTypes.BenchmarkEntry{}, "", ctrl.Ctx.Err()
 	}
-	return getScore(modelName, "div#multi-core.tab-pane.fade", doc, ctrl)
-}
 
-func getScore(modelName, selector string, doc *goquery.Document, ctrl *dataTypes.FlowControl) (int, error) {
-	if ctrl.Ctx.Err() != nil {
-		log.Printf("stopping benchmarkScraper.getScore: %v", ctrl.Ctx.Err())
-		return 0, ctrl.Ctx.Err()
+	catalogBrand, err := catalog.brands.GetBrand(brand)
+	if err != nil {
+		log.Printf("in benchmarkScraper.getEntry failed to get brand: %v", err)
+		return dataTypes.BenchmarkEntry{}, "", err
+	}
+	// Geekbench lists Android phones under their brand name and iPhones without it.
+	if catalogBrand.BenchmarkPlatform != brandCatalog.IOSBenchmarkPlatform {
+		model = brand + " " + model
 	}
 
-	var score int
-	var err error
-
-	doc.Find(selector).Find("tr").Each(func(i int, s *goquery.Selection) {
-		deviceName := strings.TrimSpace(s.Find("td.name a").Text())
-		curScore := strings.TrimSpace(s.Find("td.score").Text())
-
-		if deviceName != "" && curScore != "" {
-			if strings.ToLower(deviceName) == strings.ToLower(modelName) {
-				score, err = strconv.Atoi(curScore)
-				return
-			}
-		}
-	})
-
+	platformCatalog, err := catalog.GetPlatformCatalog(catalogBrand.BenchmarkPlatform, ctrl)
 	if err != nil {
-		errorMonitoring.IncrementError(errorMonitoring.ParsingError, ctrl)
-		log.Printf("in benchmarkScraper.getSingleMultiScore, found device %v but error parsing score: %v", modelName, err)
-		return 0, errorTypes.NewParsingError(fmt.Sprintf("in benchmarkScraper.getSingleMultiScore found device but error parsing score: %v", err))
+		log.Printf("in benchmarkScraper.getEntry failed to get benchmark catalog: %v", err)
+		return dataTypes.BenchmarkEntry{}, "", err
 	}
 
-	if score == 0 {
-		log.Printf("in benchmarkScraper.getSingleMultiScore failed to find device %v in benchmark page", modelName)
-		return 0, errorTypes.NewNoSuchPhoneBenchmarkError(fmt.Sprintf("in benchmarkScraper.getSingleMultiScore failed to find device %v in benchmark page", modelName))
+	entry, ok := FindEntry(platformCatalog, nameMatching.NewMatcher(brand, catalog.aliases), model)
+	if !ok {
+		log.Printf("in benchmarkScraper.getEntry failed to find device %v in benchmark page", model)
+		return dataTypes.BenchmarkEntry{}, "", errorTypes.NewNoSuchPhoneBenchmarkError(fmt.Sprintf("in benchmarkScraper.getEntry failed to find device %v in benchmark page", model))
+	}
+	return entry, platformCatalog.URL, nil
+}
+
+// FindEntry returns the leaderboard entry whose device name best matches modelName.
+func FindEntry(platformCatalog dataTypes.BenchmarkCatalog, matcher *nameMatching.Matcher, modelName string) (dataTypes.BenchmarkEntry, bool) {
+	deviceNames := make([]string, len(platformCatalog.Entries))
+	for i, entry := range platformCatalog.Entries {
+		deviceNames[i] = entry.Name
 	}
 
-	return score, nil
+	i, similarity := matcher.BestMatch(modelName, deviceNames)
+	if i == -1 {
+		return dataTypes.BenchmarkEntry{}, false
+	}
+	if !strings.EqualFold(deviceNames[i], modelName) {
+		log.Printf("in benchmarkScraper.FindEntry matched %v to %v (similarity: %.2f)", modelName, deviceNames[i], similarity)
+	}
+	return platformCatalog.Entries[i], true
 }
@@ -0,0 +1,235 @@
+package boltDatabase
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/brandCatalog"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorMonitoring"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/memoryDatabase"
+	"go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/bson"
+	"log"
+	"sync"
+	"time"
+)
+
+// The buckets are the memory backend's collections, one per Mongo collection.
+const (
+	devicesBucket            = memoryDatabase.DevicesCollection
+	yearsBucket              = memoryDatabase.YearsCollection
+	monthsBucket             = memoryDatabase.MonthsCollection
+	queueBucket              = memoryDatabase.QueueCollection
+	priceHistoryBucket       = memoryDatabase.PriceHistoryCollection
+	alertSubscriptionsBucket = memoryDatabase.AlertSubscriptionsCollection
+	benchmarkCatalogBucket   = memoryDatabase.BenchmarkCatalogCollection
+	// metadataBucket holds the min-max values and the validation flag, which Mongo keeps in its metadata collection.
+	metadataBucket = memoryDatabase.MetadataCollection
+
+	minMaxKey         = memoryDatabase.MinMaxKey
+	validationFlagKey = memoryDatabase.ValidationFlagKey
+)
+
+// BoltDatabase is a single-file database for small deployments. It serves every read from the memory backend it
+// embeds, loaded from the file on Connect. Every change is committed to the file, in one bolt transaction per
+// operation, before the memory backend shows it. Documents are stored as BSON, one bucket per Mongo collection, under
+// the keys the memory backend gives them.
+type BoltDatabase struct {
+	*memoryDatabase.MemoryDatabase
+	path string
+	// mutex guards db and connections.
+	mutex sync.Mutex
+	db    *bbolt.DB
+	// connections counts the Connect calls not yet matched by a Disconnect; the file is closed when none are left.
+	connections int
+}
+
+func NewBoltDatabase(path string, maxQueueSize int, brands *brandCatalog.Catalog) *BoltDatabase {
+	bdb := &BoltDatabase{MemoryDatabase: memoryDatabase.NewMemoryDatabase(maxQueueSize, brands), path: path}
+	bdb.MemoryDatabase.SetCommitter(bdb.commit)
+	return bdb
+}
+
+// Connect opens the file and loads it, unless it is already open. The memory backend numbers the queue anew on
+// loading, so the queue is rewritten under its new keys.
+func (bdb *BoltDatabase) Connect(ctrl *dataTypes.FlowControl) error {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping boltDatabase.Connect: %v", ctrl.Ctx.Err())
+		return ctrl.Ctx.Err()
+	}
+
+	bdb.mutex.Lock()
+	defer bdb.mutex.Unlock()
+	if bdb.db != nil {
+		bdb.connections++
+		return nil
+	}
+
+	db, err := bbolt.Open(bdb.path, 0600, &bbolt.Options{Timeout: 10 * time.Second})
+	if err != nil {
+		log.Printf("in boltDatabase.Connect failed to open %v: %v", bdb.path, err)
+		errorMonitoring.IncrementError(errorMonitoring.GeneralDatabaseError, ctrl)
+		return errorTypes.NewGeneralDatabaseError(fmt.Sprintf("failed to open %v", bdb.path))
+	}
+
+	snapshot, err := readSnapshot(db)
+	if err == nil {
+		var changes []memoryDatabase.Change
+		changes, err = bdb.MemoryDatabase.LoadSnapshot(&snapshot, ctrl)
+		if err == nil {
+			err = db.Update(func(tx *bbolt.Tx) error {
+				return applyChanges(tx, changes)
+			})
+		}
+	}
+	if err != nil {
+		log.Printf("in boltDatabase.Connect failed to load %v: %v", bdb.path, err)
+		if closeErr := db.Close(); closeErr != nil {
+			log.Printf("WARNING: failed to close %v: %v", bdb.path, closeErr)
+			errorMonitoring.IncrementError(errorMonitoring.CleanUpError, ctrl)
+		}
+		errorMonitoring.IncrementError(errorMonitoring.GeneralDatabaseError, ctrl)
+		return errorTypes.NewGeneralDatabaseError(fmt.Sprintf("failed to load %v", bdb.path))
+	}
+
+	bdb.db = db
+	bdb.connections = 1
+	log.Printf("in boltDatabase.Connect loaded %v devices from %v", len(snapshot.Devices), bdb.path)
+	return nil
+}
+
+// Disconnect closes the file once every Connect has been matched by a Disconnect.
+func (bdb *BoltDatabase) Disconnect(ctrl *dataTypes.FlowControl) error {
+	bdb.mutex.Lock()
+	defer bdb.mutex.Unlock()
+	if bdb.db == nil {
+		return nil
+	}
+	bdb.connections--
+	if bdb.connections > 0 {
+		return nil
+	}
+
+	err := bdb.db.Close()
+	bdb.db = nil
+	if err != nil {
+		log.Printf("in boltDatabase.Disconnect failed to close %v: %v", bdb.path, err)
+		errorMonitoring.IncrementError(errorMonitoring.CleanUpError, ctrl)
+		return errorTypes.NewGeneralDatabaseError(fmt.Sprintf("failed to close %v", bdb.path))
+	}
+	return nil
+}
+
+func (bdb *BoltDatabase) IsUp(ctrl *dataTypes.FlowControl) bool {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping boltDatabase.IsUp: %v", ctrl.Ctx.Err())
+		return false
+	}
+
+	bdb.mutex.Lock()
+	defer bdb.mutex.Unlock()
+	return bdb.db != nil
+}
+
+// commit writes the changes of one operation of the memory backend to the file, in one bolt transaction.
+func (bdb *BoltDatabase) commit(changes []memoryDatabase.Change, ctrl *dataTypes.FlowControl) error {
+	bdb.mutex.Lock()
+	defer bdb.mutex.Unlock()
+	if bdb.db == nil {
+		log.Printf("in boltDatabase.commit %v is not open", bdb.path)
+		errorMonitoring.IncrementError(errorMonitoring.GeneralDatabaseError, ctrl)
+		return errorTypes.NewGeneralDatabaseError("database is not connected")
+	}
+
+	err := bdb.db.Update(func(tx *bbolt.Tx) error {
+		return applyChanges(tx, changes)
+	})
+	if err != nil {
+		log.Printf("in boltDatabase.commit failed to write %v: %v", bdb.path, err)
+		errorMonitoring.IncrementError(errorMonitoring.GeneralDatabaseError, ctrl)
+		return errorTypes.NewGeneralDatabaseError(fmt.Sprintf("failed to write %v", bdb.path))
+	}
+	return nil
+}
+
+// applyChanges stores, deletes and empties what the changes say, in order.
+func applyChanges(tx *bbolt.Tx, changes []memoryDatabase.Change) error {
+	for _, change := range changes {
+		if change.Key == nil {
+			if err := tx.DeleteBucket([]byte(change.Collection)); err != nil && !errors.Is(err, bbolt.ErrBucketNotFound) {
+				return fmt.Errorf("bucket %v: %w", change.Collection, err)
+			}
+			continue
+		}
+
+		bucket, err := tx.CreateBucketIfNotExists([]byte(change.Collection))
+		if err != nil {
+			return fmt.Errorf("bucket %v: %w", change.Collection, err)
+		}
+		if change.Document == nil {
+			err = bucket.Delete(change.Key)
+		} else {
+			err = putDocument(bucket, change.Key, change.Document)
+		}
+		if err != nil {
+			return fmt.Errorf("bucket %v: %w", change.Collection, err)
+		}
+	}
+	return nil
+}
+
+func putDocument(bucket *bbolt.Bucket, key []byte, document interface{}) error {
+	data, err := bson.Marshal(document)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(key, data)
+}
+
+// readSnapshot reads the whole file. Missing buckets read as empty, as in a new file.
+func readSnapshot(db *bbolt.DB) (dataTypes.DatabaseSnapshot, error) {
+	var snapshot dataTypes.DatabaseSnapshot
+	err := db.View(func(tx *bbolt.Tx) error {
+		var validationFlag dataTypes.ValidationFlag
+		err := errors.Join(
+			getDocuments(tx.Bucket([]byte(devicesBucket)), &snapshot.Devices),
+			getDocuments(tx.Bucket([]byte(yearsBucket)), &snapshot.Years),
+			getDocuments(tx.Bucket([]byte(monthsBucket)), &snapshot.Months),
+			getDocuments(tx.Bucket([]byte(queueBucket)), &snapshot.Queue),
+			getDocuments(tx.Bucket([]byte(priceHistoryBucket)), &snapshot.PriceHistory),
+			getDocuments(tx.Bucket([]byte(alertSubscriptionsBucket)), &snapshot.AlertSubscriptions),
+			getDocuments(tx.Bucket([]byte(benchmarkCatalogBucket)), &snapshot.BenchmarkCatalogs),
+			getDocument(tx.Bucket([]byte(metadataBucket)), []byte(minMaxKey), &snapshot.MinMax),
+			getDocument(tx.Bucket([]byte(metadataBucket)), []byte(validationFlagKey), &validationFlag),
+		)
+		snapshot.IsInterruptedValidation = validationFlag.IsUnfinishedValidation
+		return err
+	})
+	return snapshot, err
+}
+
+func getDocuments[T any](bucket *bbolt.Bucket, documents *[]T) error {
+	if bucket == nil {
+		return nil
+	}
+	return bucket.ForEach(func(_, data []byte) error {
+		var document T
+		if err := bson.Unmarshal(data, &document); err != nil {
+			return err
+		}
+		*documents = append(*documents, document)
+		return nil
+	})
+}
+
+func getDocument(bucket *bbolt.Bucket, key []byte, document interface{}) error {
+	if bucket == nil {
+		return nil
+	}
+	data := bucket.Get(key)
+	if data == nil {
+		return nil
+	}
+	return bson.Unmarshal(data, document)
+}
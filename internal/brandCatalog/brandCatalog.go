@@ -0,0 +1,214 @@
+package brandCatalog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/config"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
+	"io/fs"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	LastYearEquivalentEstimation = config.LastYearEquivalentEstimation
+	RegressionEstimation         = config.RegressionEstimation
+	NoEstimation                 = config.NoEstimation
+
+	IOSBenchmarkPlatform     = "ios"
+	AndroidBenchmarkPlatform = "android"
+)
+
+// Brand describes how to enumerate a brand's phones in the spec API and how to treat them later on.
+type Brand struct {
+	Name string `json:"name"`
+	// Directory is the brand's slug in the spec API, e.g. "apple-phones-48".
+	Directory string `json:"directory"`
+	// Series keeps only the phones whose name contains one of these, case-insensitively. Empty keeps all.
+	Series []string `json:"series"`
+	// Exclusions are case-insensitive regular expressions; phones whose name matches any of them are skipped.
+	Exclusions []string `json:"exclusions"`
+	// MaxPage caps the listing pages read; 0 reads until an empty page or the listing's last page.
+	MaxPage           int              `json:"max_page"`
+	BenchmarkPlatform string           `json:"benchmark_platform"`
+	Estimation        EstimationPolicy `json:"estimation"`
+
+	exclusionRegexes []*regexp.Regexp
+}
+
+// EstimationPolicy decides how a benchmark is estimated when the brand's device has none.
+type EstimationPolicy struct {
+	// Method is last-year-equivalent, regression or none. It defaults to the catalog's default estimation method.
+	// regression fits a model on the stored devices' release dates, price categories, brands and chipsets, and
+	// falls back to last-year-equivalent until enough devices are stored; set {"method": "regression"} to use it.
+	Method string `json:"method"`
+	// AnyPriceCategory lets last year's equivalent be of any price category; otherwise it must be within
+	// PriceCategorySpread categories of the device's.
+	AnyPriceCategory    bool `json:"any_price_category"`
+	PriceCategorySpread int  `json:"price_category_spread"`
+	// PriceCategoryPenalty lowers the estimated scores by this fraction per price category of difference.
+	PriceCategoryPenalty float64 `json:"price_category_penalty"`
+}
+
+type catalogFile struct {
+	Brands []Brand `json:"brands"`
+}
+
+var defaultBrands = []Brand{
+	{
+		Name:              "Apple",
+		Directory:         "apple-phones-48",
+		Series:            []string{"iphone"},
+		Exclusions:        []string{"ipad", "cdma", "watch"},
+		BenchmarkPlatform: IOSBenchmarkPlatform,
+		Estimation:        EstimationPolicy{AnyPriceCategory: true},
+	},
+	{
+		Name:              "Google",
+		Directory:         "google-phones-107",
+		Series:            []string{"pixel"},
+		Exclusions:        []string{"tablet", "fold", "watch"},
+		BenchmarkPlatform: AndroidBenchmarkPlatform,
+		Estimation:        EstimationPolicy{PriceCategorySpread: 1, PriceCategoryPenalty: 0.25},
+	},
+	{
+		Name:      "Samsung",
+		Directory: "samsung-phones-9",
+		Series:    []string{"galaxy"},
+		Exclusions: []string{"watch", "tab", "flip", "fold", `\(india\)`, "grand", "indulge", "nexus", "lte",
+			"prevail", "attain", " star ", " zoom ", " duos ", `\(`, " pop ", " s ", " young ", " express ", "core",
+			"alpha", "sport", "edge", "ii", " active ", " quantum ", " lite ", " stellar ", " apollo ", " ace ",
+			"view", " light ", "xcover", "galaxy m", "neo"},
+		BenchmarkPlatform: AndroidBenchmarkPlatform,
+	},
+}
+
+// Catalog is the default brands overridden, by name, and extended by a catalog file.
+type Catalog struct {
+	brands []Brand
+	// defaultEstimationMethod is the estimation method of the brands that don't set one.
+	defaultEstimationMethod string
+}
+
+// NewCatalog loads the catalog from the file at path once, so that an invalid file is reported on startup rather
+// than by every device. A missing catalog file is not an error.
+func NewCatalog(path, defaultEstimationMethod string) (*Catalog, error) {
+	brands := make([]Brand, len(defaultBrands))
+	copy(brands, defaultBrands)
+
+	catalogJson, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("in brandCatalog.NewCatalog failed to read catalog file %v: %v", path, err)
+		return nil, err
+	}
+	if err == nil {
+		var file catalogFile
+		if err = json.Unmarshal(catalogJson, &file); err != nil {
+			log.Printf("in brandCatalog.NewCatalog failed to unmarshal catalog file %v: %v", path, err)
+			return nil, errorTypes.NewInvalidBrandCatalogError(fmt.Sprintf("in brandCatalog.NewCatalog invalid catalog file %v: %v", path, err))
+		}
+		for _, brand := range file.Brands {
+			brands = overrideBrand(brands, brand)
+		}
+	}
+
+	for i := range brands {
+		if err = compileBrand(&brands[i], defaultEstimationMethod); err != nil {
+			return nil, err
+		}
+	}
+	return &Catalog{brands: brands, defaultEstimationMethod: defaultEstimationMethod}, nil
+}
+
+// GetBrands returns the catalog's brands.
+func (catalog *Catalog) GetBrands() []Brand {
+	brands := make([]Brand, len(catalog.brands))
+	copy(brands, catalog.brands)
+	return brands
+}
+
+// GetBrand returns the catalog entry of the named brand. Brands missing from the catalog get default settings.
+func (catalog *Catalog) GetBrand(name string) (Brand, error) {
+	for _, brand := range catalog.brands {
+		if strings.EqualFold(brand.Name, name) {
+			return brand, nil
+		}
+	}
+	brand := Brand{Name: name}
+	err := compileBrand(&brand, catalog.defaultEstimationMethod)
+	return brand, err
+}
+
+func overrideBrand(brands []Brand, brand Brand) []Brand {
+	for i := range brands {
+		if strings.EqualFold(brands[i].Name, brand.Name) {
+			brands[i] = brand
+			return brands
+		}
+	}
+	return append(brands, brand)
+}
+
+// compileBrand validates the brand, fills in its defaults and compiles its exclusions.
+func compileBrand(brand *Brand, defaultEstimationMethod string) error {
+	if strings.TrimSpace(brand.Name) == "" {
+		return errorTypes.NewInvalidBrandCatalogError("in brandCatalog.compileBrand brand without a name")
+	}
+	if brand.MaxPage < 0 {
+		return errorTypes.NewInvalidBrandCatalogError(fmt.Sprintf("in brandCatalog.compileBrand (brand: %v) negative max page", brand.Name))
+	}
+	if brand.BenchmarkPlatform == "" {
+		brand.BenchmarkPlatform = AndroidBenchmarkPlatform
+	}
+	if brand.BenchmarkPlatform != AndroidBenchmarkPlatform && brand.BenchmarkPlatform != IOSBenchmarkPlatform {
+		return errorTypes.NewInvalidBrandCatalogError(fmt.Sprintf("in brandCatalog.compileBrand (brand: %v) unknown benchmark platform %v", brand.Name, brand.BenchmarkPlatform))
+	}
+	if brand.Estimation.Method == "" {
+		brand.Estimation.Method = defaultEstimationMethod
+	}
+	if brand.Estimation.Method != LastYearEquivalentEstimation && brand.Estimation.Method != RegressionEstimation &&
+		brand.Estimation.Method != NoEstimation {
+		return errorTypes.NewInvalidBrandCatalogError(fmt.Sprintf("in brandCatalog.compileBrand (brand: %v) unknown estimation method %v", brand.Name, brand.Estimation.Method))
+	}
+
+	brand.exclusionRegexes = make([]*regexp.Regexp, 0, len(brand.Exclusions))
+	for _, exclusion := range brand.Exclusions {
+		exclusionRegex, err := regexp.Compile("(?i)" + exclusion)
+		if err != nil {
+			return errorTypes.NewInvalidBrandCatalogError(fmt.Sprintf("in brandCatalog.compileBrand (brand: %v) invalid exclusion %q: %v", brand.Name, exclusion, err))
+		}
+		brand.exclusionRegexes = append(brand.exclusionRegexes, exclusionRegex)
+	}
+	return nil
+}
+
+// IsListed reports whether a phone from the brand's listing belongs to one of its series and isn't excluded.
+func (brand Brand) IsListed(phoneName string) bool {
+	for _, exclusionRegex := range brand.exclusionRegexes {
+		if exclusionRegex.MatchString(phoneName) {
+			return false
+		}
+	}
+	if len(brand.Series) == 0 {
+		return true
+	}
+	lowerName := strings.ToLower(phoneName)
+	for _, series := range brand.Series {
+		if strings.Contains(lowerName, strings.ToLower(series)) {
+			return true
+		}
+	}
+	return false
+}
+
+// PriceCategories are the price categories last year's equivalent of a device in the given category may have.
+func (policy EstimationPolicy) PriceCategories(priceCategory int) dataTypes.MinMaxInt {
+	if policy.AnyPriceCategory {
+		return dataTypes.MinMaxInt{Min: dataTypes.LowEnd, Max: dataTypes.HighEnd}
+	}
+	return dataTypes.MinMaxInt{Min: priceCategory - policy.PriceCategorySpread, Max: priceCategory + policy.PriceCategorySpread}
+}
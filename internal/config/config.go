@@ -0,0 +1,504 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultFilePath = "config.json"
+	FileEnvVar      = "CONFIG_FILE"
+
+	AddressEnvVar              = "LISTEN_ADDRESS"
+	BackendEnvVar              = "DATABASE_BACKEND"
+	BoltPathEnvVar             = "BOLT_DATABASE_PATH"
+	MaxQueueSizeEnvVar         = "MAX_QUEUE_SIZE"
+	MongoURIEnvVar             = "MONGO_URI"
+	MongoDatabaseEnvVar        = "MONGO_DATABASE"
+	AIModelNameEnvVar          = "AI_MODEL_NAME"
+	GenAIKeyEnvVar             = "GEN_AI_KEY"
+	CustomSearchKeyEnvVar      = "CUSTOM_SEARCH_KEY"
+	PriceSearchEngineIDEnvVar  = "PRICE_SEARCH_ENGINE_ID"
+	ReviewSearchEngineIDEnvVar = "REVIEW_SEARCH_ENGINE_ID"
+	AlertWebhookSecretEnvVar   = "ALERT_WEBHOOK_SECRET"
+	BrandCatalogFileEnvVar     = "BRAND_CATALOG_FILE"
+	EstimationMethodEnvVar     = "BENCHMARK_ESTIMATION_METHOD"
+	NameAliasesFileEnvVar      = "NAME_ALIASES_FILE"
+	CurrencyRatesFileEnvVar    = "CURRENCY_RATES_FILE"
+	// MarketsEnvVar is a comma separated list of markets, e.g. "IL,US:EUR".
+	MarketsEnvVar             = "PRICE_MARKETS"
+	BenchmarkCatalogTTLEnvVar = "BENCHMARK_CATALOG_TTL"
+
+	MongoBackend = "mongo"
+	// MemoryBackend keeps everything in memory, so the API and the pipeline run with no Mongo at all. Its data is
+	// lost when the process exits.
+	MemoryBackend = "memory"
+	// BoltBackend keeps everything in a single bbolt file, for small deployments without Mongo.
+	BoltBackend = "bolt"
+
+	LastYearEquivalentEstimation = "last-year-equivalent"
+	RegressionEstimation         = "regression"
+	NoEstimation                 = "none"
+
+	redactedSecret = "REDACTED"
+)
+
+var estimationMethods = []string{LastYearEquivalentEstimation, RegressionEstimation, NoEstimation}
+
+// Config is everything the API and the pipeline can be configured with. It is read from the defaults, overridden by
+// the configuration file, then by the environment, then by the command line flags.
+type Config struct {
+	Server   ServerConfig   `json:"server"`
+	Database DatabaseConfig `json:"database"`
+	Mongo    MongoConfig    `json:"mongo"`
+	Pipeline PipelineConfig `json:"pipeline"`
+	Data     DataConfig     `json:"data"`
+	// ErrorLimits are how many errors of each kind the pipeline tolerates before it stops.
+	ErrorLimits dataTypes.ErrorLimits `json:"error_limits"`
+	AI          AIConfig              `json:"ai"`
+	Keys        KeysConfig            `json:"keys"`
+}
+
+type ServerConfig struct {
+	Address string `json:"address"`
+	// ConnectTimeout limits each attempt to connect to the database on startup.
+	ConnectTimeout Duration `json:"connect_timeout" swaggertype:"string"`
+	// HTTPTimeout limits every request the scrapers send.
+	HTTPTimeout Duration `json:"http_timeout" swaggertype:"string"`
+}
+
+type DatabaseConfig struct {
+	// Backend is mongo, memory or bolt.
+	Backend  string `json:"backend"`
+	BoltPath string `json:"bolt_path"`
+	// MaxQueueSize caps the devices the enqueuer keeps waiting in the queue.
+	MaxQueueSize int `json:"max_queue_size"`
+}
+
+type MongoConfig struct {
+	URI         string           `json:"uri"`
+	Database    string           `json:"database"`
+	Collections MongoCollections `json:"collections"`
+	// MigrateOnStartup applies the pending schema migrations whenever the API connects. It is off by default, since
+	// migrations delete what they moved: they are applied by cmd/migrateSchema, after checking them with -dry-run.
+	MigrateOnStartup bool `json:"migrate_on_startup"`
+}
+
+type MongoCollections struct {
+	Queue              string `json:"queue"`
+	Metadata           string `json:"metadata"`
+	Devices            string `json:"devices"`
+	Years              string `json:"years"`
+	Months             string `json:"months"`
+	PriceHistory       string `json:"price_history"`
+	AlertSubscriptions string `json:"alert_subscriptions"`
+	BenchmarkCatalog   string `json:"benchmark_catalog"`
+	SchemaMigrations   string `json:"schema_migrations"`
+}
+
+// PipelineConfig holds how long the pipeline's loops sleep.
+type PipelineConfig struct {
+	// EnqueueInterval separates two listings of every brand's devices.
+	EnqueueInterval Duration `json:"enqueue_interval" swaggertype:"string"`
+	// EmptyListingInterval is waited after a listing found no devices.
+	EmptyListingInterval Duration `json:"empty_listing_interval" swaggertype:"string"`
+	// UploadInterval separates two uploaded devices.
+	UploadInterval Duration `json:"upload_interval" swaggertype:"string"`
+	// RetryInterval is waited after the enqueuer or the uploader failed.
+	RetryInterval Duration `json:"retry_interval" swaggertype:"string"`
+	// RefreshInterval separates two checks for stale devices.
+	RefreshInterval Duration `json:"refresh_interval" swaggertype:"string"`
+	// RefreshRetryInterval is waited after a check for stale devices failed.
+	RefreshRetryInterval Duration `json:"refresh_retry_interval" swaggertype:"string"`
+}
+
+// DataConfig sets the files the pipeline reads its reference data from, and how it uses that data.
+type DataConfig struct {
+	// BrandCatalogFile overrides and extends the default brands. It is optional.
+	BrandCatalogFile string `json:"brand_catalog_file"`
+	// BenchmarkEstimationMethod is used by the brands that don't set one: last-year-equivalent, regression or none.
+	BenchmarkEstimationMethod string `json:"benchmark_estimation_method"`
+	// NameAliasesFile maps names as sources spell them to the names devices are stored under. It is optional.
+	NameAliasesFile   string `json:"name_aliases_file"`
+	CurrencyRatesFile string `json:"currency_rates_file"`
+	// Markets are the market codes prices are gathered for, each optionally followed by ":" and the currency to use
+	// instead of the market's default, e.g. ["IL", "US:EUR"]. The first one is the primary market.
+	Markets []string `json:"markets"`
+	// BenchmarkCatalogTTL is how long a downloaded benchmark leaderboard is used before it is downloaded again.
+	BenchmarkCatalogTTL Duration `json:"benchmark_catalog_ttl" swaggertype:"string"`
+}
+
+type AIConfig struct {
+	ModelName string `json:"model_name"`
+}
+
+// KeysConfig holds the API keys. Only the search engine IDs are shown unredacted.
+type KeysConfig struct {
+	GenAIKey             string `json:"gen_ai_key"`
+	CustomSearchKey      string `json:"custom_search_key"`
+	PriceSearchEngineID  string `json:"price_search_engine_id"`
+	ReviewSearchEngineID string `json:"review_search_engine_id"`
+	AlertWebhookSecret   string `json:"alert_webhook_secret"`
+}
+
+// Duration is a time.Duration written as a Go duration string, such as "5m".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var durationString string
+	if err := json.Unmarshal(data, &durationString); err != nil {
+		return err
+	}
+	duration, err := time.ParseDuration(durationString)
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Address:        ":8080",
+			ConnectTimeout: Duration(30 * time.Second),
+			HTTPTimeout:    Duration(30 * time.Second),
+		},
+		Database: DatabaseConfig{
+			Backend:      MongoBackend,
+			BoltPath:     "deviceRec.db",
+			MaxQueueSize: 30,
+		},
+		Mongo: MongoConfig{
+			URI:      "mongodb://localhost:27017",
+			Database: "local",
+			Collections: MongoCollections{
+				Queue:              "queue",
+				Metadata:           "metadata",
+				Devices:            "devices",
+				Years:              "years",
+				Months:             "months",
+				PriceHistory:       "price_history",
+				AlertSubscriptions: "alert_subscriptions",
+				BenchmarkCatalog:   "benchmark_catalog",
+				SchemaMigrations:   "schema_migrations",
+			},
+		},
+		Pipeline: PipelineConfig{
+			EnqueueInterval:      Duration(5 * time.Minute),
+			EmptyListingInterval: Duration(24 * time.Second),
+			UploadInterval:       Duration(30 * time.Second),
+			RetryInterval:        Duration(10 * time.Second),
+			RefreshInterval:      Duration(time.Hour),
+			RefreshRetryInterval: Duration(10 * time.Minute),
+		},
+		Data: DataConfig{
+			BrandCatalogFile:          "brandCatalog.json",
+			BenchmarkEstimationMethod: LastYearEquivalentEstimation,
+			NameAliasesFile:           "nameAliases.json",
+			CurrencyRatesFile:         "currencyRates.json",
+			Markets:                   []string{"IL"},
+			BenchmarkCatalogTTL:       Duration(24 * time.Hour),
+		},
+		ErrorLimits: dataTypes.ErrorLimits{
+			CleanUpErrors:              10,
+			SentimentAnalysisErrors:    3,
+			CreatingNewAiClientErrors:  3,
+			AiNetworkErrors:            3,
+			FailedAiInstructionErrors:  1,
+			GettingURLErrors:           5,
+			GettingDocumentErrors:      5,
+			ParsingErrors:              5,
+			MissingDocumentErrors:      1,
+			DatabaseNetworkErrors:      3,
+			GeneralDatabaseErrors:      1,
+			InvalidConstIDStringErrors: 1,
+		},
+		AI: AIConfig{ModelName: "gemini-1.5-flash"},
+	}
+}
+
+// Load reads the configuration and validates it. The file is set by the -config flag or CONFIG_FILE, and may set any
+// field; a missing config.json is not an error. The environment and the flags set the fields most deployments change.
+// Tools with flags of their own add them with addFlags, and read them once Load returns.
+func Load(args []string, addFlags ...func(flags *flag.FlagSet)) (*Config, error) {
+	config := Default()
+	stringFlags := []struct {
+		name  string
+		usage string
+		field *string
+	}{
+		{"address", "address to listen on", &config.Server.Address},
+		{"database-backend", "database backend: mongo, memory or bolt", &config.Database.Backend},
+		{"bolt-path", "file of the bolt backend", &config.Database.BoltPath},
+		{"mongo-uri", "Mongo connection string", &config.Mongo.URI},
+		{"mongo-database", "Mongo database name", &config.Mongo.Database},
+		{"ai-model", "generative model used by the analyses", &config.AI.ModelName},
+	}
+
+	flags := flag.NewFlagSet("Device-Rec-API", flag.ContinueOnError)
+	filePath := flags.String("config", "", "configuration file, defaults to "+FileEnvVar+" or "+defaultFilePath)
+	maxQueueSize := flags.Int("max-queue-size", 0, "maximum number of devices waiting in the queue")
+	stringFlagValues := make(map[string]*string)
+	for _, stringFlag := range stringFlags {
+		stringFlagValues[stringFlag.name] = flags.String(stringFlag.name, "", stringFlag.usage)
+	}
+	for _, addFlag := range addFlags {
+		addFlag(flags)
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, errorTypes.NewInvalidConfigError(fmt.Sprintf("invalid flags: %v", err))
+	}
+	isFlagSet := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		isFlagSet[f.Name] = true
+	})
+
+	if !isFlagSet["config"] {
+		*filePath = os.Getenv(FileEnvVar)
+	}
+	if err := readFile(&config, *filePath); err != nil {
+		return nil, err
+	}
+	if err := readEnv(&config); err != nil {
+		return nil, err
+	}
+	for _, stringFlag := range stringFlags {
+		if isFlagSet[stringFlag.name] {
+			*stringFlag.field = *stringFlagValues[stringFlag.name]
+		}
+	}
+	if isFlagSet["max-queue-size"] {
+		config.Database.MaxQueueSize = *maxQueueSize
+	}
+
+	config.Database.Backend = strings.ToLower(strings.TrimSpace(config.Database.Backend))
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// readFile overrides config with the fields the file sets. An empty path reads config.json if it exists.
+func readFile(config *Config, path string) error {
+	isDefaultPath := path == ""
+	if isDefaultPath {
+		path = defaultFilePath
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) && isDefaultPath {
+		return nil
+	}
+	if err != nil {
+		log.Printf("in config.readFile failed to open configuration file %v: %v", path, err)
+		return errorTypes.NewInvalidConfigError(fmt.Sprintf("failed to open configuration file %v: %v", path, err))
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(config); err != nil {
+		log.Printf("in config.readFile failed to decode configuration file %v: %v", path, err)
+		return errorTypes.NewInvalidConfigError(fmt.Sprintf("invalid configuration file %v: %v", path, err))
+	}
+	return nil
+}
+
+func readEnv(config *Config) error {
+	envValues := map[string]*string{
+		AddressEnvVar:              &config.Server.Address,
+		BackendEnvVar:              &config.Database.Backend,
+		BoltPathEnvVar:             &config.Database.BoltPath,
+		MongoURIEnvVar:             &config.Mongo.URI,
+		MongoDatabaseEnvVar:        &config.Mongo.Database,
+		AIModelNameEnvVar:          &config.AI.ModelName,
+		GenAIKeyEnvVar:             &config.Keys.GenAIKey,
+		CustomSearchKeyEnvVar:      &config.Keys.CustomSearchKey,
+		PriceSearchEngineIDEnvVar:  &config.Keys.PriceSearchEngineID,
+		ReviewSearchEngineIDEnvVar: &config.Keys.ReviewSearchEngineID,
+		AlertWebhookSecretEnvVar:   &config.Keys.AlertWebhookSecret,
+		BrandCatalogFileEnvVar:     &config.Data.BrandCatalogFile,
+		EstimationMethodEnvVar:     &config.Data.BenchmarkEstimationMethod,
+		NameAliasesFileEnvVar:      &config.Data.NameAliasesFile,
+		CurrencyRatesFileEnvVar:    &config.Data.CurrencyRatesFile,
+	}
+	for envVar, field := range envValues {
+		if value := strings.TrimSpace(os.Getenv(envVar)); value != "" {
+			*field = value
+		}
+	}
+
+	if maxQueueSizeString := strings.TrimSpace(os.Getenv(MaxQueueSizeEnvVar)); maxQueueSizeString != "" {
+		maxQueueSize, err := strconv.Atoi(maxQueueSizeString)
+		if err != nil {
+			return errorTypes.NewInvalidConfigError(fmt.Sprintf("invalid %v %q", MaxQueueSizeEnvVar, maxQueueSizeString))
+		}
+		config.Database.MaxQueueSize = maxQueueSize
+	}
+	if marketsString := strings.TrimSpace(os.Getenv(MarketsEnvVar)); marketsString != "" {
+		config.Data.Markets = strings.Split(marketsString, ",")
+	}
+	if ttlString := strings.TrimSpace(os.Getenv(BenchmarkCatalogTTLEnvVar)); ttlString != "" {
+		ttl, err := time.ParseDuration(ttlString)
+		if err != nil {
+			return errorTypes.NewInvalidConfigError(fmt.Sprintf("invalid %v %q", BenchmarkCatalogTTLEnvVar, ttlString))
+		}
+		config.Data.BenchmarkCatalogTTL = Duration(ttl)
+	}
+	return nil
+}
+
+// Validate reports every invalid field at once.
+func (config *Config) Validate() error {
+	var problems []string
+	addProblem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if config.Server.Address == "" {
+		addProblem("server.address is empty")
+	}
+	checkPositiveDurations(map[string]Duration{
+		"server.connect_timeout":          config.Server.ConnectTimeout,
+		"server.http_timeout":             config.Server.HTTPTimeout,
+		"pipeline.enqueue_interval":       config.Pipeline.EnqueueInterval,
+		"pipeline.empty_listing_interval": config.Pipeline.EmptyListingInterval,
+		"pipeline.upload_interval":        config.Pipeline.UploadInterval,
+		"pipeline.retry_interval":         config.Pipeline.RetryInterval,
+		"pipeline.refresh_interval":       config.Pipeline.RefreshInterval,
+		"pipeline.refresh_retry_interval": config.Pipeline.RefreshRetryInterval,
+		"data.benchmark_catalog_ttl":      config.Data.BenchmarkCatalogTTL,
+	}, addProblem)
+
+	switch config.Database.Backend {
+	case MongoBackend, MemoryBackend:
+	case BoltBackend:
+		if config.Database.BoltPath == "" {
+			addProblem("database.bolt_path is empty")
+		}
+	default:
+		addProblem("database.backend %q is not %v, %v or %v", config.Database.Backend, MongoBackend, MemoryBackend, BoltBackend)
+	}
+	if config.Database.MaxQueueSize <= 0 {
+		addProblem("database.max_queue_size %v is not positive", config.Database.MaxQueueSize)
+	}
+
+	uri, err := url.Parse(config.Mongo.URI)
+	if err != nil || (uri.Scheme != "mongodb" && uri.Scheme != "mongodb+srv") {
+		addProblem("mongo.uri is not a mongodb:// or mongodb+srv:// connection string")
+	}
+	if config.Mongo.Database == "" {
+		addProblem("mongo.database is empty")
+	}
+	checkDistinctNames("mongo.collections", map[string]string{
+		"queue":               config.Mongo.Collections.Queue,
+		"metadata":            config.Mongo.Collections.Metadata,
+		"devices":             config.Mongo.Collections.Devices,
+		"years":               config.Mongo.Collections.Years,
+		"months":              config.Mongo.Collections.Months,
+		"price_history":       config.Mongo.Collections.PriceHistory,
+		"alert_subscriptions": config.Mongo.Collections.AlertSubscriptions,
+		"benchmark_catalog":   config.Mongo.Collections.BenchmarkCatalog,
+		"schema_migrations":   config.Mongo.Collections.SchemaMigrations,
+	}, addProblem)
+	if config.Data.CurrencyRatesFile == "" {
+		addProblem("data.currency_rates_file is empty")
+	}
+	if !slices.Contains(estimationMethods, config.Data.BenchmarkEstimationMethod) {
+		addProblem("data.benchmark_estimation_method %q is not one of %v", config.Data.BenchmarkEstimationMethod, strings.Join(estimationMethods, ", "))
+	}
+	if len(config.Data.Markets) == 0 {
+		addProblem("data.markets is empty")
+	}
+	for i, market := range config.Data.Markets {
+		code, _, _ := strings.Cut(market, ":")
+		if strings.TrimSpace(code) == "" {
+			addProblem("data.markets[%v] %q has no market code", i, market)
+		}
+	}
+
+	limits := map[string]int{
+		"clean_up_errors":                config.ErrorLimits.CleanUpErrors,
+		"sentiment_analysis_errors":      config.ErrorLimits.SentimentAnalysisErrors,
+		"creating_new_ai_client_errors":  config.ErrorLimits.CreatingNewAiClientErrors,
+		"ai_network_errors":              config.ErrorLimits.AiNetworkErrors,
+		"failed_ai_instruction_errors":   config.ErrorLimits.FailedAiInstructionErrors,
+		"getting_url_errors":             config.ErrorLimits.GettingURLErrors,
+		"getting_document_errors":        config.ErrorLimits.GettingDocumentErrors,
+		"parsing_errors":                 config.ErrorLimits.ParsingErrors,
+		"missing_document_errors":        config.ErrorLimits.MissingDocumentErrors,
+		"database_network_errors":        config.ErrorLimits.DatabaseNetworkErrors,
+		"general_database_errors":        config.ErrorLimits.GeneralDatabaseErrors,
+		"invalid_const_id_string_errors": config.ErrorLimits.InvalidConstIDStringErrors,
+	}
+	for name, limit := range limits {
+		if limit < 0 {
+			addProblem("error_limits.%v %v is negative", name, limit)
+		}
+	}
+
+	if config.AI.ModelName == "" {
+		addProblem("ai.model_name is empty")
+	}
+
+	if len(problems) > 0 {
+		slices.Sort(problems)
+		return errorTypes.NewInvalidConfigError("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func checkPositiveDurations(durations map[string]Duration, addProblem func(string, ...interface{})) {
+	for name, duration := range durations {
+		if duration <= 0 {
+			addProblem("%v %v is not positive", name, time.Duration(duration))
+		}
+	}
+}
+
+func checkDistinctNames(group string, names map[string]string, addProblem func(string, ...interface{})) {
+	fieldsByName := make(map[string]string)
+	for field, name := range names {
+		if name == "" {
+			addProblem("%v.%v is empty", group, field)
+			continue
+		}
+		if otherField, ok := fieldsByName[name]; ok {
+			addProblem("%v.%v and %v.%v are both %q", group, field, group, otherField, name)
+		}
+		fieldsByName[name] = field
+	}
+}
+
+// Redacted returns a copy of the configuration that is safe to show: the keys are replaced, and so is the password
+// of the Mongo URI.
+func (config *Config) Redacted() Config {
+	redacted := *config
+	for _, secret := range []*string{&redacted.Keys.GenAIKey, &redacted.Keys.CustomSearchKey, &redacted.Keys.AlertWebhookSecret} {
+		if *secret != "" {
+			*secret = redactedSecret
+		}
+	}
+	if uri, err := url.Parse(redacted.Mongo.URI); err == nil {
+		redacted.Mongo.URI = uri.Redacted()
+	} else {
+		redacted.Mongo.URI = redactedSecret
+	}
+	return redacted
+}
@@ -0,0 +1,92 @@
+package currencyConversion
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ratesFile holds how many units of each currency one unit of Base is worth, e.g.
+// {"base": "EUR", "rates": {"EUR": 1, "ILS": 4.02, "USD": 1.08}}.
+type ratesFile struct {
+	Base  string             `json:"base"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+// Converter converts with the rates of a rates file. The file is read on the first conversion and cached; a file
+// that fails to load is read again on the next one.
+type Converter struct {
+	path string
+	// mutex guards rates and isLoaded.
+	mutex    sync.Mutex
+	rates    ratesFile
+	isLoaded bool
+}
+
+func NewConverter(path string) *Converter {
+	return &Converter{path: path}
+}
+
+// Convert converts a whole amount between currencies, rounding to the nearest unit.
+func (converter *Converter) Convert(amount int, from, to string) (int, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return amount, nil
+	}
+
+	rates, err := converter.getRates()
+	if err != nil {
+		return 0, err
+	}
+	fromRate, ok := rates.Rates[from]
+	if !ok || fromRate <= 0 {
+		return 0, errorTypes.NewUnsupportedCurrencyError(fmt.Sprintf("in currencyConversion.Convert no rate for %v", from))
+	}
+	toRate, ok := rates.Rates[to]
+	if !ok || toRate <= 0 {
+		return 0, errorTypes.NewUnsupportedCurrencyError(fmt.Sprintf("in currencyConversion.Convert no rate for %v", to))
+	}
+	return int(math.Round(float64(amount) / fromRate * toRate)), nil
+}
+
+// getRates returns the cached rates, loading them if they weren't loaded yet.
+func (converter *Converter) getRates() (ratesFile, error) {
+	converter.mutex.Lock()
+	defer converter.mutex.Unlock()
+	if !converter.isLoaded {
+		rates, err := loadRates(converter.path)
+		if err != nil {
+			return ratesFile{}, err
+		}
+		converter.rates, converter.isLoaded = rates, true
+	}
+	return converter.rates, nil
+}
+
+func loadRates(path string) (ratesFile, error) {
+	ratesJson, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("in currencyConversion.loadRates failed to read rates file %v: %v", path, err)
+		return ratesFile{}, err
+	}
+	var rates ratesFile
+	if err = json.Unmarshal(ratesJson, &rates); err != nil {
+		log.Printf("in currencyConversion.loadRates failed to unmarshal rates file %v: %v", path, err)
+		return ratesFile{}, err
+	}
+
+	normalizedRates := make(map[string]float64, len(rates.Rates)+1)
+	for currency, rate := range rates.Rates {
+		normalizedRates[strings.ToUpper(currency)] = rate
+	}
+	if rates.Base != "" {
+		normalizedRates[strings.ToUpper(rates.Base)] = 1
+	}
+	rates.Rates = normalizedRates
+	return rates, nil
+}
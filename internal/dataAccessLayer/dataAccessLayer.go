@@ -1,9 +1,12 @@
 package dataAccessLayer
 
 import (
+	"github.com/ItaiHalperin/Device-Rec-API/internal/benchmarkScraper"
 	"github.com/ItaiHalperin/Device-Rec-API/internal/databaseInterface"
 )
 
 type DataAccessLayer struct {
 	Database databaseInterface.DatabaseInterface
+	// BenchmarkCatalog is the application's shared catalog, backed by Database.
+	BenchmarkCatalog *benchmarkScraper.Catalog
 }
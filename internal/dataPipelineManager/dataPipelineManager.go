@@ -1,13 +1,11 @@
 package dataPipelineManager
 
 import (
-	"context"
 	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
-	"github.com/ItaiHalperin/Device-Rec-API/internal/benchmarkScraper"
 	"github.com/ItaiHalperin/Device-Rec-API/internal/dataAccessLayer"
 	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/externalServices"
 	"github.com/ItaiHalperin/Device-Rec-API/internal/helpers"
-	"github.com/ItaiHalperin/Device-Rec-API/internal/priceScraper"
 	"github.com/ItaiHalperin/Device-Rec-API/internal/reviewer"
 	"github.com/ItaiHalperin/Device-Rec-API/internal/specAPI"
 	"log"
@@ -15,57 +13,48 @@ import (
 	"time"
 )
 
-func LaunchDataCollectionProcess(dalForEnqueuer, dalForUploader dataAccessLayer.DataAccessLayer) (<-chan struct{}, func()) {
-	ctx, cancel := context.WithCancel(context.Background())
-	stopChannel := make(chan struct{}, 1)
-
-	ctrl := dataTypes.FlowControl{
-		Ctx:                        ctx,
-		StopOnTooManyErrorsChannel: stopChannel,
-	}
-
-	go func() {
-		log.Println("Launching Enqueuer...")
-		launchEnqueuer(dalForEnqueuer, &ctrl)
-	}()
-
-	go func() {
-		log.Println("Launching Uploader...")
-		launchUploader(dalForUploader, &ctrl)
-	}()
-
-	return stopChannel, cancel
-}
-
-func launchEnqueuer(dal dataAccessLayer.DataAccessLayer, ctrl *dataTypes.FlowControl) {
+func (s *Supervisor) launchEnqueuer(dal dataAccessLayer.DataAccessLayer, ctrl *dataTypes.FlowControl) {
 	for {
-		if ctrl.Ctx.Err() != nil {
+		if !s.waitWhilePaused(ctrl) {
 			log.Printf("stopping dataPiplineManager.launchEnqueuer: %v", ctrl.Ctx.Err())
 			return
 		}
-		namesAndLinks, err := specAPI.GatherAllDeviceNamesAndLinks(ctrl)
+		namesAndLinks, err := specAPI.GatherAllDeviceNamesAndLinks(s.services, ctrl)
 		if err != nil {
-			time.Sleep(10 * time.Second)
+			sleepUnlessCanceled(ctrl, time.Duration(s.intervals.RetryInterval))
 			continue
 		}
 		if len(namesAndLinks) == 0 {
-			time.Sleep(24 * time.Second)
+			sleepUnlessCanceled(ctrl, time.Duration(s.intervals.EmptyListingInterval))
 			continue
 		}
-		err = dal.Database.EnqueueDeviceBatch(namesAndLinks, ctrl)
+		enqueuedDeviceNames, err := dal.Database.EnqueueDeviceBatch(namesAndLinks, ctrl)
 		if err != nil {
-			time.Sleep(10 * time.Second)
+			sleepUnlessCanceled(ctrl, time.Duration(s.intervals.RetryInterval))
 			continue
 		}
+		for _, deviceName := range enqueuedDeviceNames {
+			s.hooks.Events.Publish(dataTypes.DeviceEnqueuedEvent, deviceName, "")
+		}
 
-		time.Sleep(5 * time.Minute)
+		sleepUnlessCanceled(ctrl, time.Duration(s.intervals.EnqueueInterval))
 	}
 }
 
-func handleError(err error, message string, deviceName string, sleepDuration time.Duration) {
+func handleError(err error, message string, deviceName string, sleepDuration time.Duration, ctrl *dataTypes.FlowControl) {
 	log.Printf("in dataPipelineManager.launchUploader (device: %s) %s: %v",
 		deviceName, message, err)
-	time.Sleep(sleepDuration)
+	sleepUnlessCanceled(ctrl, sleepDuration)
+}
+
+// sleepUnlessCanceled lets a stop request interrupt the pipeline's long waits.
+func sleepUnlessCanceled(ctrl *dataTypes.FlowControl, duration time.Duration) {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctrl.Ctx.Done():
+	}
 }
 
 func processNormalization(dal dataAccessLayer.DataAccessLayer, device *dataTypes.Device,
@@ -94,12 +83,12 @@ func handleBenchmarkEstimation(dal dataAccessLayer.DataAccessLayer, count int, l
 	return count, nil
 }
 
-func launchUploader(dal dataAccessLayer.DataAccessLayer, ctrl *dataTypes.FlowControl) {
+func (s *Supervisor) launchUploader(dal dataAccessLayer.DataAccessLayer, ctrl *dataTypes.FlowControl) {
 	numberOfEstimatedBenchmarks := 0
 	benchmarkCycleLimit := 3
 
 	for {
-		if ctrl.Ctx.Err() != nil {
+		if !s.waitWhilePaused(ctrl) {
 			log.Printf("stopping dataPiplineManager.launchUploader: %v", ctrl.Ctx.Err())
 			return
 		}
@@ -107,16 +96,19 @@ func launchUploader(dal dataAccessLayer.DataAccessLayer, ctrl *dataTypes.FlowCon
 		deviceInQueue, err := dal.Database.Dequeue(ctrl)
 		if err != nil {
 			if errorTypes.IsMissingDocumentError(err) {
-				handleError(err, "no device to dequeue", "", 10*time.Second)
+				handleError(err, "no device to dequeue", "", time.Duration(s.intervals.RetryInterval), ctrl)
 				continue
 			}
-			handleError(err, "failed to dequeue", "", 10*time.Second)
+			handleError(err, "failed to dequeue", "", time.Duration(s.intervals.RetryInterval), ctrl)
 			continue
 		}
 
-		device, err := gatherData(deviceInQueue, dal, ctrl)
+		s.hooks.Events.Publish(dataTypes.DeviceDequeuedEvent, deviceInQueue.Name, "")
+		s.setCurrentDevice(deviceInQueue.Name)
+		device, err := ProcessDevice(deviceInQueue, dal, s.services, s.hooks, ctrl)
+		s.recordDeviceResult(err)
 		if err != nil {
-			handleError(err, "data gathering failed", deviceInQueue.Name, 10*time.Second)
+			handleError(err, "failed to process device", deviceInQueue.Name, time.Duration(s.intervals.RetryInterval), ctrl)
 			continue
 		}
 
@@ -124,72 +116,89 @@ func launchUploader(dal dataAccessLayer.DataAccessLayer, ctrl *dataTypes.FlowCon
 			numberOfEstimatedBenchmarks++
 		}
 
-		newMinMax, err := processNormalization(dal, device, ctrl)
-		if err != nil {
-			handleError(err, "normalization failed", deviceInQueue.Name, 10*time.Second)
-			continue
-		}
-		isInterruptedValidation, err := dal.Database.IsInterruptedValidation(ctrl)
-		if err != nil {
-			handleError(err, "failed to check interrupted validation", "", 10*time.Second)
-			continue
-		} else if isInterruptedValidation {
-			err = dal.Database.ValidateScores(newMinMax, ctrl)
-			if err != nil {
-				handleError(err, "failed to validate after failed validation", "", 10*time.Second)
-			}
-		}
-		if err = dal.Database.UploadDevice(device, newMinMax, ctrl); err != nil {
-			handleError(err, "failed to upload device", deviceInQueue.Name, 10*time.Second)
-			continue
-		}
-
 		numberOfEstimatedBenchmarks, err = handleBenchmarkEstimation(dal, numberOfEstimatedBenchmarks,
 			benchmarkCycleLimit, ctrl)
 		if err != nil {
-			handleError(err, "failed to reestimate benchmarks", deviceInQueue.Name, 10*time.Second)
+			s.recordError(err)
+			handleError(err, "failed to reestimate benchmarks", deviceInQueue.Name, time.Duration(s.intervals.RetryInterval), ctrl)
 			continue
 		}
 
-		time.Sleep(30 * time.Second)
+		sleepUnlessCanceled(ctrl, time.Duration(s.intervals.UploadInterval))
 	}
 }
 
-func gatherData(deviceInQueue dataTypes.DeviceInQueue, dal dataAccessLayer.DataAccessLayer, ctrl *dataTypes.FlowControl) (*dataTypes.Device, error) {
-	device := &dataTypes.Device{}
-	device.Image = deviceInQueue.Image
-	err := specAPI.SetSpecs(device, deviceInQueue.Detail, ctrl)
+// ProcessDevice gathers the data of a single device through services, normalizes it against the stored devices and
+// uploads it. The device's ingestion job, if it has one, is kept up to date along the way, and the progress is
+// published on the hooks' event bus.
+func ProcessDevice(deviceInQueue dataTypes.DeviceInQueue, dal dataAccessLayer.DataAccessLayer,
+	services externalServices.ExternalServices, hooks Hooks, ctrl *dataTypes.FlowControl) (*dataTypes.Device, error) {
+	hooks.Jobs.UpdateJob(deviceInQueue.JobID, dataTypes.IngestionJobProcessing, nil)
+	device, err := processDevice(deviceInQueue, dal, services, hooks, ctrl)
 	if err != nil {
-		log.Printf("in dataPipelineManager.gatherData (device: %v) failed to set specs: %v", deviceInQueue.Name, err)
-		return &dataTypes.Device{}, err
+		hooks.Jobs.UpdateJob(deviceInQueue.JobID, dataTypes.IngestionJobFailed, err)
+		hooks.Events.Publish(dataTypes.DeviceProcessingFailedEvent, deviceInQueue.Name, err.Error())
+		return nil, err
+	}
+	hooks.Jobs.UpdateJob(deviceInQueue.JobID, dataTypes.IngestionJobDone, nil)
+	return device, nil
+}
+
+func processDevice(deviceInQueue dataTypes.DeviceInQueue, dal dataAccessLayer.DataAccessLayer,
+	services externalServices.ExternalServices, hooks Hooks, ctrl *dataTypes.FlowControl) (*dataTypes.Device, error) {
+	if !deviceInQueue.DeviceID.IsZero() {
+		return refreshDevice(deviceInQueue, dal, services, hooks, ctrl)
 	}
-	err = priceScraper.SetPrice(device, ctrl)
+
+	device, err := gatherData(deviceInQueue, dal, services, hooks, ctrl)
 	if err != nil {
-		log.Printf("in dataPipelineManager.gatherData (device: %v) failed to set price: %v", deviceInQueue.Name, err)
-		return &dataTypes.Device{}, err
+		log.Printf("in dataPipelineManager.processDevice (device: %v) data gathering failed: %v", deviceInQueue.Name, err)
+		return nil, err
 	}
-	err = priceScraper.SetPriceCategory(device, ctrl)
+
+	newMinMax, err := processNormalization(dal, device, ctrl)
 	if err != nil {
-		log.Printf("in dataPipelineManager.launchUploader (device: %v) failed to set price category: %v", deviceInQueue.Name, err)
-		return &dataTypes.Device{}, err
+		log.Printf("in dataPipelineManager.processDevice (device: %v) normalization failed: %v", deviceInQueue.Name, err)
+		return nil, err
 	}
-	err = benchmarkScraper.SetBenchmarkScores(device, ctrl)
+	isInterruptedValidation, err := dal.Database.IsInterruptedValidation(ctrl)
 	if err != nil {
-		if errorTypes.IsNoSuchPhoneBenchmarkError(err) {
-			log.Printf("in dataPipelineManager.launchUploader (device: %v) failed to find benchmark: %v", deviceInQueue.Name, err)
-			device.Benchmark.IsEstimatedBenchmark = true
-			err = dal.Database.SetLastYearEquivalentBenchmarkScores(device, ctrl)
-			if err != nil {
-				log.Printf("in dataPipelineManager.launchUploader (device: %v) failed to set last year equivalent device: %v", deviceInQueue.Name, err)
-			}
-		} else {
-			log.Printf("in dataPipelineManager.launchUploader (device: %v) failed to access benchmark page: %v", deviceInQueue.Name, err)
-			return &dataTypes.Device{}, err
+		log.Printf("in dataPipelineManager.processDevice failed to check interrupted validation: %v", err)
+		return nil, err
+	} else if isInterruptedValidation {
+		err = dal.Database.ValidateScores(newMinMax, ctrl)
+		if err != nil {
+			log.Printf("in dataPipelineManager.processDevice failed to validate after failed validation: %v", err)
 		}
 	}
-	err = reviewer.Review(device, ctrl)
+	err = dal.Database.UploadDevice(device, newMinMax, ctrl)
+	if err != nil && !errorTypes.IsValidationError(err) {
+		log.Printf("in dataPipelineManager.processDevice (device: %v) failed to upload device: %v", deviceInQueue.Name, err)
+		return nil, err
+	}
+	hooks.Events.Publish(dataTypes.DeviceUploadedEvent, deviceInQueue.Name, "")
+	if err != nil {
+		log.Printf("in dataPipelineManager.processDevice (device: %v) uploaded device but failed to validate scores: %v", deviceInQueue.Name, err)
+		return nil, err
+	}
+	hooks.Events.Publish(dataTypes.DeviceValidatedEvent, deviceInQueue.Name, "")
+	return device, nil
+}
+
+func gatherData(deviceInQueue dataTypes.DeviceInQueue, dal dataAccessLayer.DataAccessLayer,
+	services externalServices.ExternalServices, hooks Hooks, ctrl *dataTypes.FlowControl) (*dataTypes.Device, error) {
+	pipeline, err := NewPipelineBuilder().Add(defaultEnrichers(dal, services, hooks)...).Build()
+	if err != nil {
+		log.Printf("in dataPipelineManager.gatherData failed to build enrichment pipeline: %v", err)
+		return &dataTypes.Device{}, err
+	}
+
+	device := &dataTypes.Device{}
+	device.Image = deviceInQueue.Image
+	device.Detail = deviceInQueue.Detail
+	err = pipeline.Run(device, ctrl)
 	if err != nil {
-		log.Printf("in dataPipelineManager.launchUploader (device: %v) failed to review device: %v", deviceInQueue.Name, err)
+		log.Printf("in dataPipelineManager.gatherData (device: %v) enrichment failed: %v", deviceInQueue.Name, err)
 		return &dataTypes.Device{}, err
 	}
 	return device, nil
@@ -0,0 +1,156 @@
+package dataPipelineManager
+
+import (
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/benchmarkEstimation"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/benchmarkScraper"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/dataAccessLayer"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/externalServices"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/priceScraper"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/reviewer"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/specAPI"
+	"log"
+)
+
+const (
+	SpecsStage         = "specs"
+	PriceStage         = "price"
+	PriceCategoryStage = "price-category"
+	BenchmarkStage     = "benchmark"
+	ReviewStage        = "review"
+)
+
+// defaultEnrichers are the stages every device goes through.
+func defaultEnrichers(dal dataAccessLayer.DataAccessLayer, services externalServices.ExternalServices, hooks Hooks) []Enricher {
+	return []Enricher{
+		specsEnricher{services: services, events: hooks.Events},
+		priceEnricher{services: services, prices: hooks.Prices, events: hooks.Events},
+		priceCategoryEnricher{services: services},
+		benchmarkEnricher{dal: dal, sources: benchmarkScraper.DefaultSources(dal.BenchmarkCatalog), events: hooks.Events},
+		reviewEnricher{services: services, events: hooks.Events},
+	}
+}
+
+type specsEnricher struct {
+	services externalServices.ExternalServices
+	events   *EventBus
+}
+
+func (specsEnricher) Name() string           { return SpecsStage }
+func (specsEnricher) Dependencies() []string { return nil }
+func (specsEnricher) IsRequired() bool       { return true }
+
+func (enricher specsEnricher) Enrich(device *dataTypes.Device, ctrl *dataTypes.FlowControl) error {
+	err := specAPI.SetSpecs(device, device.Detail, enricher.services, ctrl)
+	if err != nil {
+		log.Printf("in dataPipelineManager.Enrich (url: %v) failed to set specs: %v", device.Detail, err)
+		return err
+	}
+	enricher.events.Publish(dataTypes.SpecsSetEvent, device.Name, "")
+	return nil
+}
+
+type priceEnricher struct {
+	services externalServices.ExternalServices
+	prices   *priceScraper.Registry
+	events   *EventBus
+}
+
+func (priceEnricher) Name() string           { return PriceStage }
+func (priceEnricher) Dependencies() []string { return []string{SpecsStage} }
+func (priceEnricher) IsRequired() bool       { return true }
+
+func (enricher priceEnricher) Enrich(device *dataTypes.Device, ctrl *dataTypes.FlowControl) error {
+	err := priceScraper.SetPrice(device, enricher.prices, enricher.services, ctrl)
+	if err != nil {
+		log.Printf("in dataPipelineManager.Enrich (device: %v) failed to set price: %v", device.Name, err)
+		return err
+	}
+	enricher.events.Publish(dataTypes.PriceSetEvent, device.Name, "")
+	return nil
+}
+
+type priceCategoryEnricher struct {
+	services externalServices.ExternalServices
+}
+
+func (priceCategoryEnricher) Name() string           { return PriceCategoryStage }
+func (priceCategoryEnricher) Dependencies() []string { return []string{PriceStage} }
+func (priceCategoryEnricher) IsRequired() bool       { return true }
+
+func (enricher priceCategoryEnricher) Enrich(device *dataTypes.Device, ctrl *dataTypes.FlowControl) error {
+	err := priceScraper.SetPriceCategory(device, enricher.services.Analyzer, ctrl)
+	if err != nil {
+		log.Printf("in dataPipelineManager.Enrich (device: %v) failed to set price category: %v", device.Name, err)
+		return err
+	}
+	return nil
+}
+
+// benchmarkEnricher falls back to the scores of measured devices with the same chipset when the device has no
+// benchmark of its own, and to estimating the benchmark, with the brand's estimation method, when there are none.
+type benchmarkEnricher struct {
+	dal     dataAccessLayer.DataAccessLayer
+	sources []benchmarkScraper.BenchmarkSource
+	events  *EventBus
+}
+
+func (benchmarkEnricher) Name() string           { return BenchmarkStage }
+func (benchmarkEnricher) Dependencies() []string { return []string{PriceCategoryStage} }
+func (benchmarkEnricher) IsRequired() bool       { return true }
+
+func (enricher benchmarkEnricher) Enrich(device *dataTypes.Device, ctrl *dataTypes.FlowControl) error {
+	err := benchmarkScraper.SetBenchmarkScores(device, enricher.sources, ctrl)
+	if err == nil {
+		device.Benchmark.IsEstimatedBenchmark = false
+		device.Benchmark.IsChipsetInferred = false
+		device.Benchmark.Estimation = nil
+		enricher.events.Publish(dataTypes.BenchmarkSetEvent, device.Name, "")
+		return nil
+	}
+	if !errorTypes.IsNoSuchPhoneBenchmarkError(err) {
+		log.Printf("in dataPipelineManager.Enrich (device: %v) failed to access benchmark page: %v", device.Name, err)
+		return err
+	}
+
+	log.Printf("in dataPipelineManager.Enrich (device: %v) failed to find benchmark: %v", device.Name, err)
+	err = enricher.dal.Database.SetChipsetInferredBenchmarkScores(device, ctrl)
+	if err == nil {
+		enricher.events.Publish(dataTypes.BenchmarkInferredEvent, device.Name, device.Specs.Chipset)
+		return nil
+	}
+	if !errorTypes.IsNoChipsetPeerError(err) {
+		log.Printf("in dataPipelineManager.Enrich (device: %v) failed to infer benchmark from chipset: %v", device.Name, err)
+	}
+
+	device.Benchmark.IsEstimatedBenchmark = true
+	device.Benchmark.IsChipsetInferred = false
+	benchmarkEstimation.ClearInferredGPUScore(device)
+	err = enricher.dal.Database.SetEstimatedBenchmarkScores(device, ctrl)
+	if err != nil {
+		log.Printf("in dataPipelineManager.Enrich (device: %v) failed to estimate benchmark: %v", device.Name, err)
+		return nil
+	}
+	enricher.events.Publish(dataTypes.BenchmarkEstimatedEvent, device.Name, "")
+	return nil
+}
+
+type reviewEnricher struct {
+	services externalServices.ExternalServices
+	events   *EventBus
+}
+
+func (reviewEnricher) Name() string           { return ReviewStage }
+func (reviewEnricher) Dependencies() []string { return []string{SpecsStage} }
+func (reviewEnricher) IsRequired() bool       { return true }
+
+func (enricher reviewEnricher) Enrich(device *dataTypes.Device, ctrl *dataTypes.FlowControl) error {
+	err := reviewer.Review(device, enricher.services, ctrl)
+	if err != nil {
+		log.Printf("in dataPipelineManager.Enrich (device: %v) failed to review device: %v", device.Name, err)
+		return err
+	}
+	enricher.events.Publish(dataTypes.DeviceReviewedEvent, device.Name, "")
+	return nil
+}
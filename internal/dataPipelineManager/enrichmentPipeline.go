@@ -0,0 +1,143 @@
+package dataPipelineManager
+
+import (
+	"fmt"
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
+	"log"
+	"slices"
+	"time"
+)
+
+// Enricher is a single stage of data gathering. An enricher runs only after all of its dependencies succeeded.
+// A failing required enricher fails the whole device, a failing optional one is only recorded.
+type Enricher interface {
+	Name() string
+	Dependencies() []string
+	IsRequired() bool
+	Enrich(device *dataTypes.Device, ctrl *dataTypes.FlowControl) error
+}
+
+type EnrichmentPipeline struct {
+	stages []Enricher
+}
+
+type PipelineBuilder struct {
+	enrichers []Enricher
+}
+
+func NewPipelineBuilder() *PipelineBuilder {
+	return &PipelineBuilder{}
+}
+
+func (builder *PipelineBuilder) Add(enrichers ...Enricher) *PipelineBuilder {
+	builder.enrichers = append(builder.enrichers, enrichers...)
+	return builder
+}
+
+// Build orders the enrichers so that each one runs after its dependencies. Enrichers that don't depend on each
+// other keep the order in which they were added. A required enricher can only depend on required ones.
+func (builder *PipelineBuilder) Build() (*EnrichmentPipeline, error) {
+	enrichersByName := make(map[string]Enricher, len(builder.enrichers))
+	for _, enricher := range builder.enrichers {
+		if _, ok := enrichersByName[enricher.Name()]; ok {
+			return nil, errorTypes.NewInvalidPipelineError(fmt.Sprintf("in dataPipelineManager.Build duplicate stage %v", enricher.Name()))
+		}
+		enrichersByName[enricher.Name()] = enricher
+	}
+
+	remainingDependencies := make(map[string]int, len(builder.enrichers))
+	dependents := make(map[string][]string)
+	for _, enricher := range builder.enrichers {
+		for _, dependency := range enricher.Dependencies() {
+			if _, ok := enrichersByName[dependency]; !ok {
+				return nil, errorTypes.NewInvalidPipelineError(fmt.Sprintf("in dataPipelineManager.Build stage %v depends on unknown stage %v",
+					enricher.Name(), dependency))
+			}
+			if enricher.IsRequired() && !enrichersByName[dependency].IsRequired() {
+				return nil, errorTypes.NewInvalidPipelineError(fmt.Sprintf("in dataPipelineManager.Build required stage %v depends on optional stage %v",
+					enricher.Name(), dependency))
+			}
+			remainingDependencies[enricher.Name()]++
+			dependents[dependency] = append(dependents[dependency], enricher.Name())
+		}
+	}
+
+	stages := make([]Enricher, 0, len(builder.enrichers))
+	isOrdered := make(map[string]bool, len(builder.enrichers))
+	for len(stages) < len(builder.enrichers) {
+		progressed := false
+		for _, enricher := range builder.enrichers {
+			if isOrdered[enricher.Name()] || remainingDependencies[enricher.Name()] > 0 {
+				continue
+			}
+			stages = append(stages, enricher)
+			isOrdered[enricher.Name()] = true
+			for _, dependent := range dependents[enricher.Name()] {
+				remainingDependencies[dependent]--
+			}
+			progressed = true
+		}
+		if !progressed {
+			return nil, errorTypes.NewInvalidPipelineError("in dataPipelineManager.Build stage dependencies contain a cycle")
+		}
+	}
+	return &EnrichmentPipeline{stages: stages}, nil
+}
+
+// Run runs every stage on device and records each stage's result on it. Optional stages whose dependencies
+// didn't succeed are skipped.
+func (pipeline *EnrichmentPipeline) Run(device *dataTypes.Device, ctrl *dataTypes.FlowControl) error {
+	return pipeline.RunStages(device, nil, ctrl)
+}
+
+// RunStages is Run limited to the named stages, or to every stage if stageNames is empty. The stages that
+// aren't run are assumed to have enriched device already.
+func (pipeline *EnrichmentPipeline) RunStages(device *dataTypes.Device, stageNames []string, ctrl *dataTypes.FlowControl) error {
+	succeeded := make(map[string]bool, len(pipeline.stages))
+	for _, stage := range pipeline.stages {
+		if len(stageNames) > 0 && !slices.Contains(stageNames, stage.Name()) {
+			succeeded[stage.Name()] = true
+			continue
+		}
+		if ctrl.Ctx.Err() != nil {
+			log.Printf("stopping dataPipelineManager.RunStages: %v", ctrl.Ctx.Err())
+			return ctrl.Ctx.Err()
+		}
+
+		result := dataTypes.StageResult{Stage: stage.Name()}
+		if missingDependency, ok := firstMissingDependency(stage, succeeded); ok {
+			result.Status = dataTypes.StageSkipped
+			result.Error = fmt.Sprintf("dependency %v did not succeed", missingDependency)
+			device.StageResults = append(device.StageResults, result)
+			continue
+		}
+
+		start := time.Now()
+		err := stage.Enrich(device, ctrl)
+		result.Duration = time.Since(start)
+		if err != nil {
+			result.Status = dataTypes.StageFailed
+			result.Error = err.Error()
+			device.StageResults = append(device.StageResults, result)
+			if stage.IsRequired() {
+				return err
+			}
+			log.Printf("in dataPipelineManager.RunStages (device: %v) optional stage %v failed: %v", device.Name, stage.Name(), err)
+			continue
+		}
+		result.Status = dataTypes.StageSucceeded
+		device.StageResults = append(device.StageResults, result)
+		succeeded[stage.Name()] = true
+	}
+	return nil
+}
+
+func firstMissingDependency(stage Enricher, succeeded map[string]bool) (string, bool) {
+	for _, dependency := range stage.Dependencies() {
+		if !succeeded[dependency] {
+			return dependency, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,204 @@
+package dataPipelineManager
+
+import (
+	"context"
+	"errors"
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
+	"slices"
+	"testing"
+)
+
+var errStageFailed = errors.New("stage failed")
+
+type testEnricher struct {
+	name         string
+	dependencies []string
+	isRequired   bool
+	isFailing    bool
+	runs         *[]string
+}
+
+func (enricher testEnricher) Name() string           { return enricher.name }
+func (enricher testEnricher) Dependencies() []string { return enricher.dependencies }
+func (enricher testEnricher) IsRequired() bool       { return enricher.isRequired }
+
+func (enricher testEnricher) Enrich(*dataTypes.Device, *dataTypes.FlowControl) error {
+	*enricher.runs = append(*enricher.runs, enricher.name)
+	if enricher.isFailing {
+		return errStageFailed
+	}
+	return nil
+}
+
+func newTestCtrl() *dataTypes.FlowControl {
+	return &dataTypes.FlowControl{Ctx: context.Background(), StopOnTooManyErrorsChannel: make(chan struct{}, 1)}
+}
+
+func TestBuild(t *testing.T) {
+	tests := []struct {
+		name            string
+		enrichers       []testEnricher
+		expectedOrder   []string
+		isErrorExpected bool
+	}{
+		{
+			name: "dependencies run first",
+			enrichers: []testEnricher{
+				{name: "benchmark", dependencies: []string{"price"}, isRequired: true},
+				{name: "review", dependencies: []string{"specs"}},
+				{name: "price", dependencies: []string{"specs"}, isRequired: true},
+				{name: "specs", isRequired: true},
+			},
+			expectedOrder: []string{"specs", "review", "price", "benchmark"},
+		},
+		{
+			name: "optional stage depends on optional stage",
+			enrichers: []testEnricher{
+				{name: "specs", isRequired: true},
+				{name: "review", dependencies: []string{"specs"}},
+				{name: "summary", dependencies: []string{"review"}},
+			},
+			expectedOrder: []string{"specs", "review", "summary"},
+		},
+		{
+			name: "required stage depends on optional stage",
+			enrichers: []testEnricher{
+				{name: "review"},
+				{name: "price", dependencies: []string{"review"}, isRequired: true},
+			},
+			isErrorExpected: true,
+		},
+		{
+			name: "unknown dependency",
+			enrichers: []testEnricher{
+				{name: "price", dependencies: []string{"specs"}, isRequired: true},
+			},
+			isErrorExpected: true,
+		},
+		{
+			name: "duplicate stage",
+			enrichers: []testEnricher{
+				{name: "specs", isRequired: true},
+				{name: "specs", isRequired: true},
+			},
+			isErrorExpected: true,
+		},
+		{
+			name: "cycle",
+			enrichers: []testEnricher{
+				{name: "specs", dependencies: []string{"price"}, isRequired: true},
+				{name: "price", dependencies: []string{"specs"}, isRequired: true},
+			},
+			isErrorExpected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var runs []string
+			builder := NewPipelineBuilder()
+			for _, enricher := range test.enrichers {
+				enricher.runs = &runs
+				builder.Add(enricher)
+			}
+			pipeline, err := builder.Build()
+			if test.isErrorExpected {
+				if !errorTypes.IsInvalidPipelineError(err) {
+					t.Fatalf("expected an invalid pipeline error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err = pipeline.Run(&dataTypes.Device{}, newTestCtrl()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(runs, test.expectedOrder) {
+				t.Errorf("stages ran in order %v, expected %v", runs, test.expectedOrder)
+			}
+		})
+	}
+}
+
+func TestRunStages(t *testing.T) {
+	tests := []struct {
+		name             string
+		enrichers        []testEnricher
+		stageNames       []string
+		expectedRuns     []string
+		expectedStatuses map[string]string
+		isErrorExpected  bool
+	}{
+		{
+			name: "failing optional stage",
+			enrichers: []testEnricher{
+				{name: "specs", isRequired: true},
+				{name: "review", dependencies: []string{"specs"}, isFailing: true},
+				{name: "summary", dependencies: []string{"review"}},
+				{name: "price", dependencies: []string{"specs"}, isRequired: true},
+			},
+			expectedRuns: []string{"specs", "review", "price"},
+			expectedStatuses: map[string]string{
+				"specs":   dataTypes.StageSucceeded,
+				"review":  dataTypes.StageFailed,
+				"summary": dataTypes.StageSkipped,
+				"price":   dataTypes.StageSucceeded,
+			},
+		},
+		{
+			name: "failing required stage",
+			enrichers: []testEnricher{
+				{name: "specs", isRequired: true, isFailing: true},
+				{name: "price", dependencies: []string{"specs"}, isRequired: true},
+			},
+			expectedRuns:     []string{"specs"},
+			expectedStatuses: map[string]string{"specs": dataTypes.StageFailed},
+			isErrorExpected:  true,
+		},
+		{
+			name: "named stages only",
+			enrichers: []testEnricher{
+				{name: "specs", isRequired: true, isFailing: true},
+				{name: "price", dependencies: []string{"specs"}, isRequired: true},
+				{name: "review", dependencies: []string{"specs"}},
+			},
+			stageNames:       []string{"price"},
+			expectedRuns:     []string{"price"},
+			expectedStatuses: map[string]string{"price": dataTypes.StageSucceeded},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var runs []string
+			builder := NewPipelineBuilder()
+			for _, enricher := range test.enrichers {
+				enricher.runs = &runs
+				builder.Add(enricher)
+			}
+			pipeline, err := builder.Build()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			device := &dataTypes.Device{}
+			err = pipeline.RunStages(device, test.stageNames, newTestCtrl())
+			if test.isErrorExpected != errors.Is(err, errStageFailed) {
+				t.Fatalf("run returned %v, expected a stage error: %v", err, test.isErrorExpected)
+			}
+			if !slices.Equal(runs, test.expectedRuns) {
+				t.Errorf("ran stages %v, expected %v", runs, test.expectedRuns)
+			}
+			if len(device.StageResults) != len(test.expectedStatuses) {
+				t.Errorf("recorded %d stage results, expected %d", len(device.StageResults), len(test.expectedStatuses))
+			}
+			for _, result := range device.StageResults {
+				if expected := test.expectedStatuses[result.Stage]; result.Status != expected {
+					t.Errorf("stage %v is %v, expected %v", result.Stage, result.Status, expected)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package dataPipelineManager
+
+import (
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const subscriberBufferSize = 64
+
+// EventBus fans pipeline events out to its subscribers. Publishing never blocks the pipeline:
+// a subscriber that can't keep up misses events.
+type EventBus struct {
+	mutex       sync.Mutex
+	subscribers map[chan dataTypes.PipelineEvent]string
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: make(map[chan dataTypes.PipelineEvent]string)}
+}
+
+// Subscribe returns a channel of the events of deviceName, or of all devices if deviceName is empty.
+// The returned function must be called once the subscriber is done.
+func (bus *EventBus) Subscribe(deviceName string) (<-chan dataTypes.PipelineEvent, func()) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	events := make(chan dataTypes.PipelineEvent, subscriberBufferSize)
+	bus.subscribers[events] = deviceName
+	unsubscribe := func() {
+		bus.mutex.Lock()
+		defer bus.mutex.Unlock()
+		if _, ok := bus.subscribers[events]; ok {
+			delete(bus.subscribers, events)
+			close(events)
+		}
+	}
+	return events, unsubscribe
+}
+
+func (bus *EventBus) Publish(eventType, deviceName, message string) {
+	event := dataTypes.PipelineEvent{
+		Type:       eventType,
+		DeviceName: deviceName,
+		Message:    message,
+		Time:       time.Now(),
+	}
+
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	for events, deviceFilter := range bus.subscribers {
+		if deviceFilter != "" && !strings.EqualFold(deviceFilter, deviceName) {
+			continue
+		}
+		select {
+		case events <- event:
+		default:
+			log.Printf("in dataPipelineManager.Publish subscriber is lagging, dropping %v event of %v", eventType, deviceName)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package dataPipelineManager
+
+import (
+	"github.com/ItaiHalperin/Device-Rec-API/internal/ingestionJobs"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/priceScraper"
+)
+
+// Hooks are what the pipeline shares with the API besides the data: the events it publishes, the ingestion jobs it
+// keeps up to date and the price sources and listeners it scrapes prices with.
+type Hooks struct {
+	Events *EventBus
+	Jobs   *ingestionJobs.Store
+	Prices *priceScraper.Registry
+}
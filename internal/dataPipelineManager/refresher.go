@@ -0,0 +1,197 @@
+package dataPipelineManager
+
+import (
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/dataAccessLayer"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/externalServices"
+	"log"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	priceTTL              = 7 * 24 * time.Hour
+	estimatedBenchmarkTTL = 24 * time.Hour
+	// reviewSettlingPeriod is how long after launch the reviews are considered final.
+	reviewSettlingPeriod = 30 * 24 * time.Hour
+	maxRefreshesPerCheck = 10
+)
+
+// refreshStages are the stages each refresh field reruns. The price category is derived from the price, so it's
+// rerun along with it.
+var refreshStages = map[string][]string{
+	dataTypes.PriceRefresh:     {PriceStage, PriceCategoryStage},
+	dataTypes.BenchmarkRefresh: {BenchmarkStage},
+	dataTypes.ReviewRefresh:    {ReviewStage},
+}
+
+// launchRefresher periodically re-queues stored devices whose prices, estimated benchmarks or pre-launch reviews are stale.
+func (s *Supervisor) launchRefresher(dal dataAccessLayer.DataAccessLayer, ctrl *dataTypes.FlowControl) {
+	for {
+		if !s.waitWhilePaused(ctrl) {
+			log.Printf("stopping dataPiplineManager.launchRefresher: %v", ctrl.Ctx.Err())
+			return
+		}
+
+		err := enqueueStaleDevices(dal, s.hooks.Events, time.Now(), ctrl)
+		if err != nil {
+			log.Printf("in dataPipelineManager.launchRefresher failed to enqueue stale devices: %v", err)
+			sleepUnlessCanceled(ctrl, time.Duration(s.intervals.RefreshRetryInterval))
+			continue
+		}
+
+		sleepUnlessCanceled(ctrl, time.Duration(s.intervals.RefreshInterval))
+	}
+}
+
+func enqueueStaleDevices(dal dataAccessLayer.DataAccessLayer, events *EventBus, now time.Time, ctrl *dataTypes.FlowControl) error {
+	devices, err := dal.Database.GetAllDevices(ctrl)
+	if err != nil {
+		return err
+	}
+
+	// The devices refreshed the longest ago go first, so that devices that stay stale, like those whose benchmark is
+	// never measured, don't keep the others from being refreshed.
+	type staleDevice struct {
+		device dataTypes.Device
+		fields []string
+		since  time.Time
+	}
+	var staleDevices []staleDevice
+	for _, device := range devices {
+		if fields := staleFields(device, now); len(fields) > 0 {
+			staleDevices = append(staleDevices, staleDevice{device: device, fields: fields, since: staleSince(device, fields)})
+		}
+	}
+	slices.SortStableFunc(staleDevices, func(a, b staleDevice) int {
+		return a.since.Compare(b.since)
+	})
+	if len(staleDevices) > maxRefreshesPerCheck {
+		staleDevices = staleDevices[:maxRefreshesPerCheck]
+	}
+
+	for _, stale := range staleDevices {
+		device, fields := stale.device, stale.fields
+		deviceInQueue := dataTypes.DeviceInQueue{
+			Name:          device.Name,
+			Image:         device.Image,
+			Detail:        device.Detail,
+			Priority:      dataTypes.NormalQueuePriority,
+			DeviceID:      device.ID,
+			RefreshFields: fields,
+		}
+		if err = dal.Database.EnqueueDevice(deviceInQueue, ctrl); err != nil {
+			log.Printf("in dataPipelineManager.enqueueStaleDevices (device: %v) failed to enqueue refresh: %v", device.Name, err)
+			return err
+		}
+		events.Publish(dataTypes.DeviceEnqueuedEvent, device.Name, "refresh of "+strings.Join(fields, ", "))
+	}
+	return nil
+}
+
+// staleFields decides what to refresh: the price every priceTTL, an estimated or chipset-inferred benchmark every
+// estimatedBenchmarkTTL until a real one is found, and the reviews once, if they were gathered before they settled.
+func staleFields(device dataTypes.Device, now time.Time) []string {
+	var fields []string
+	if now.Sub(device.Provenance[dataTypes.RealPriceProvenance].Time) > priceTTL {
+		fields = append(fields, dataTypes.PriceRefresh)
+	}
+	if (device.Benchmark.IsEstimatedBenchmark || device.Benchmark.IsChipsetInferred) &&
+		now.Sub(device.Provenance[dataTypes.SingleCoreScoreProvenance].Time) > estimatedBenchmarkTTL {
+		fields = append(fields, dataTypes.BenchmarkRefresh)
+	}
+	reviewsSettledAt := device.Specs.ReleaseDate.Add(reviewSettlingPeriod)
+	if now.After(reviewsSettledAt) && reviewedAt(device).Before(reviewsSettledAt) {
+		fields = append(fields, dataTypes.ReviewRefresh)
+	}
+	return fields
+}
+
+// staleSince is when the stalest of the device's stale fields was last refreshed.
+func staleSince(device dataTypes.Device, fields []string) time.Time {
+	var oldest time.Time
+	for i, field := range fields {
+		var refreshedAt time.Time
+		switch field {
+		case dataTypes.PriceRefresh:
+			refreshedAt = device.Provenance[dataTypes.RealPriceProvenance].Time
+		case dataTypes.BenchmarkRefresh:
+			refreshedAt = device.Provenance[dataTypes.SingleCoreScoreProvenance].Time
+		case dataTypes.ReviewRefresh:
+			refreshedAt = reviewedAt(device)
+		}
+		if i == 0 || refreshedAt.Before(oldest) {
+			oldest = refreshedAt
+		}
+	}
+	return oldest
+}
+
+// reviewedAt is the time of the oldest review the device's review score is based on.
+func reviewedAt(device dataTypes.Device) time.Time {
+	var oldest time.Time
+	for field, provenance := range device.Provenance {
+		if !strings.HasPrefix(field, dataTypes.ReviewProvenancePrefix) {
+			continue
+		}
+		if oldest.IsZero() || provenance.Time.Before(oldest) {
+			oldest = provenance.Time
+		}
+	}
+	return oldest
+}
+
+// refreshDevice re-runs only the stale stages of a stored device and updates it in place.
+func refreshDevice(deviceInQueue dataTypes.DeviceInQueue, dal dataAccessLayer.DataAccessLayer,
+	services externalServices.ExternalServices, hooks Hooks, ctrl *dataTypes.FlowControl) (*dataTypes.Device, error) {
+	device, err := dal.Database.GetDeviceByID(deviceInQueue.DeviceID, ctrl)
+	if err != nil {
+		log.Printf("in dataPipelineManager.refreshDevice (device: %v) failed to get stored device: %v", deviceInQueue.Name, err)
+		return nil, err
+	}
+
+	var stageNames []string
+	for _, field := range deviceInQueue.RefreshFields {
+		stageNames = append(stageNames, refreshStages[field]...)
+	}
+	if len(stageNames) == 0 {
+		log.Printf("in dataPipelineManager.refreshDevice (device: %v) nothing to refresh", deviceInQueue.Name)
+		return &device, nil
+	}
+
+	pipeline, err := NewPipelineBuilder().Add(defaultEnrichers(dal, services, hooks)...).Build()
+	if err != nil {
+		log.Printf("in dataPipelineManager.refreshDevice failed to build enrichment pipeline: %v", err)
+		return nil, err
+	}
+	oldPrice := device.RealPrice
+	device.StageResults = nil
+	err = pipeline.RunStages(&device, stageNames, ctrl)
+	if err != nil {
+		log.Printf("in dataPipelineManager.refreshDevice (device: %v) enrichment failed: %v", deviceInQueue.Name, err)
+		return nil, err
+	}
+
+	newMinMax, err := processNormalization(dal, &device, ctrl)
+	if err != nil {
+		log.Printf("in dataPipelineManager.refreshDevice (device: %v) normalization failed: %v", deviceInQueue.Name, err)
+		return nil, err
+	}
+	err = dal.Database.UpdateDevice(&device, newMinMax, ctrl)
+	if err != nil && !errorTypes.IsValidationError(err) {
+		log.Printf("in dataPipelineManager.refreshDevice (device: %v) failed to update device: %v", deviceInQueue.Name, err)
+		return nil, err
+	}
+	// The new price, and its observation, are stored by now even if validating the scores failed.
+	if device.RealPrice != oldPrice {
+		hooks.Prices.NotifyPriceChangeListeners(&device, oldPrice, ctrl)
+	}
+	if err != nil {
+		log.Printf("in dataPipelineManager.refreshDevice (device: %v) updated device but failed to validate scores: %v", deviceInQueue.Name, err)
+		return nil, err
+	}
+	hooks.Events.Publish(dataTypes.DeviceRefreshedEvent, deviceInQueue.Name, strings.Join(deviceInQueue.RefreshFields, ", "))
+	return &device, nil
+}
@@ -0,0 +1,267 @@
+package dataPipelineManager
+
+import (
+	"context"
+	"fmt"
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/config"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/dataAccessLayer"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorMonitoring"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/externalServices"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/priceAlerts"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	PipelineIdle     = "idle"
+	PipelineRunning  = "running"
+	PipelinePaused   = "paused"
+	PipelineStopping = "stopping"
+	PipelineStopped  = "stopped"
+)
+
+// Supervisor owns a single run of the data collection process. Only one run can be active at a time.
+type Supervisor struct {
+	dal           dataAccessLayer.DataAccessLayer
+	services      externalServices.ExternalServices
+	hooks         Hooks
+	intervals     config.PipelineConfig
+	errorLimits   dataTypes.ErrorLimits
+	mutex         sync.Mutex
+	state         string
+	startedAt     time.Time
+	stoppedAt     time.Time
+	cancel        context.CancelFunc
+	resumeChannel chan struct{}
+	// stoppedChannel is closed once the current run is over.
+	stoppedChannel   chan struct{}
+	devicesProcessed int
+	devicesFailed    int
+	currentDevice    string
+	lastError        string
+}
+
+// NewSupervisor returns a supervisor whose runs work on dal, gather data through services, report through hooks,
+// sleep for the configured intervals and stop once errorLimits are exceeded. The database must be connected before a
+// run starts, and stay connected until it is over.
+func NewSupervisor(dal dataAccessLayer.DataAccessLayer, services externalServices.ExternalServices, hooks Hooks,
+	intervals config.PipelineConfig, errorLimits dataTypes.ErrorLimits) *Supervisor {
+	return &Supervisor{dal: dal, services: services, hooks: hooks, intervals: intervals, errorLimits: errorLimits, state: PipelineIdle}
+}
+
+// Start resets the error counters and launches the enqueuer, the uploader and the refresher.
+func (s *Supervisor) Start() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.state == PipelineRunning || s.state == PipelinePaused || s.state == PipelineStopping {
+		return errorTypes.NewPipelineStateError(fmt.Sprintf("in dataPipelineManager.Start pipeline is already %v", s.state))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopChannel := make(chan struct{}, 1)
+	ctrl := &dataTypes.FlowControl{
+		Ctx:                        ctx,
+		StopOnTooManyErrorsChannel: stopChannel,
+		ErrorLimits:                &s.errorLimits,
+	}
+	// The counters are only reset once the run is known to start, so a rejected start keeps those of the running one.
+	if err := errorMonitoring.ResetErrorCounters(ctrl); err != nil {
+		log.Printf("in dataPipelineManager.Start failed to reset error counters: %v", err)
+		cancel()
+		return err
+	}
+
+	s.state = PipelineRunning
+	s.startedAt = time.Now()
+	s.stoppedAt = time.Time{}
+	s.cancel = cancel
+	s.resumeChannel = nil
+	s.stoppedChannel = make(chan struct{})
+	s.devicesProcessed = 0
+	s.devicesFailed = 0
+	s.currentDevice = ""
+	s.lastError = ""
+
+	dal := s.dal
+	s.hooks.Prices.RegisterPriceChangeListener(priceAlerts.ListenerName, priceAlerts.NewNotifier(dal.Database, s.services.Keys.AlertWebhookSecret).OnPriceChange)
+
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(3)
+	go func() {
+		defer waitGroup.Done()
+		log.Println("Launching Enqueuer...")
+		s.launchEnqueuer(dal, ctrl)
+	}()
+
+	go func() {
+		defer waitGroup.Done()
+		log.Println("Launching Uploader...")
+		s.launchUploader(dal, ctrl)
+	}()
+
+	go func() {
+		defer waitGroup.Done()
+		log.Println("Launching Refresher...")
+		s.launchRefresher(dal, ctrl)
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		waitGroup.Wait()
+		close(done)
+	}()
+
+	go s.superviseRun(ctx, stopChannel, done, s.stoppedChannel)
+	return nil
+}
+
+// superviseRun stops the run on too many errors and keeps draining the error channel until both loops exit,
+// so that late error reports never block them.
+func (s *Supervisor) superviseRun(ctx context.Context, stopChannel <-chan struct{}, done <-chan struct{}, stoppedChannel chan<- struct{}) {
+	for {
+		select {
+		case <-stopChannel:
+			if ctx.Err() == nil {
+				log.Println("in dataPipelineManager.superviseRun too many errors, stopping pipeline...")
+				s.recordError(errorTypes.NewErrorsCounterOverflowError("stopped due to too many errors"))
+				_ = s.Stop()
+			}
+		case <-done:
+			s.mutex.Lock()
+			s.state = PipelineStopped
+			s.stoppedAt = time.Now()
+			s.currentDevice = ""
+			s.mutex.Unlock()
+			close(stoppedChannel)
+			log.Println("in dataPipelineManager.superviseRun pipeline stopped")
+			return
+		}
+	}
+}
+
+func (s *Supervisor) Stop() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.state != PipelineRunning && s.state != PipelinePaused {
+		return errorTypes.NewPipelineStateError(fmt.Sprintf("in dataPipelineManager.Stop pipeline is %v", s.state))
+	}
+
+	s.state = PipelineStopping
+	s.cancel()
+	if s.resumeChannel != nil {
+		close(s.resumeChannel)
+		s.resumeChannel = nil
+	}
+	return nil
+}
+
+// Wait blocks until the current run, if any, is over, or until ctx is done.
+func (s *Supervisor) Wait(ctx context.Context) error {
+	s.mutex.Lock()
+	stoppedChannel := s.stoppedChannel
+	s.mutex.Unlock()
+
+	if stoppedChannel == nil {
+		return nil
+	}
+	select {
+	case <-stoppedChannel:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Supervisor) Pause() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.state != PipelineRunning {
+		return errorTypes.NewPipelineStateError(fmt.Sprintf("in dataPipelineManager.Pause pipeline is %v", s.state))
+	}
+
+	s.state = PipelinePaused
+	s.resumeChannel = make(chan struct{})
+	return nil
+}
+
+func (s *Supervisor) Resume() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.state != PipelinePaused {
+		return errorTypes.NewPipelineStateError(fmt.Sprintf("in dataPipelineManager.Resume pipeline is %v", s.state))
+	}
+
+	s.state = PipelineRunning
+	close(s.resumeChannel)
+	s.resumeChannel = nil
+	return nil
+}
+
+func (s *Supervisor) Status() dataTypes.PipelineStatus {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	status := dataTypes.PipelineStatus{
+		State:            s.state,
+		StartedAt:        s.startedAt,
+		DevicesProcessed: s.devicesProcessed,
+		DevicesFailed:    s.devicesFailed,
+		CurrentDevice:    s.currentDevice,
+		LastError:        s.lastError,
+	}
+	if !s.startedAt.IsZero() {
+		end := time.Now()
+		if !s.stoppedAt.IsZero() {
+			end = s.stoppedAt
+		}
+		status.Uptime = end.Sub(s.startedAt).Round(time.Second).String()
+	}
+	return status
+}
+
+// waitWhilePaused blocks the calling loop while the pipeline is paused. It returns false once the run is canceled.
+func (s *Supervisor) waitWhilePaused(ctrl *dataTypes.FlowControl) bool {
+	s.mutex.Lock()
+	resumeChannel := s.resumeChannel
+	s.mutex.Unlock()
+
+	if resumeChannel != nil {
+		select {
+		case <-resumeChannel:
+		case <-ctrl.Ctx.Done():
+		}
+	}
+	return ctrl.Ctx.Err() == nil
+}
+
+func (s *Supervisor) setCurrentDevice(deviceName string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.currentDevice = deviceName
+}
+
+func (s *Supervisor) recordDeviceResult(err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.currentDevice = ""
+	if err != nil {
+		s.devicesFailed++
+		s.lastError = err.Error()
+		return
+	}
+	s.devicesProcessed++
+}
+
+func (s *Supervisor) recordError(err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.lastError = err.Error()
+}
@@ -0,0 +1,38 @@
+package databaseFactory
+
+import (
+	"github.com/ItaiHalperin/Device-Rec-API/internal/boltDatabase"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/brandCatalog"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/config"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/databaseInterface"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/memoryDatabase"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/mongoDatabase"
+	"sync"
+)
+
+var (
+	memoryDatabaseOnce     sync.Once
+	memoryDatabaseInstance *memoryDatabase.MemoryDatabase
+	boltDatabaseOnce       sync.Once
+	boltDatabaseInstance   *boltDatabase.BoltDatabase
+)
+
+// NewBackendDatabase returns an unconnected database of the backend, or of mongo if the backend is unknown. The
+// memory and bolt backends are shared by every caller, since a new memory database would start out empty and the
+// bolt file can only be opened once. Every backend estimates benchmarks with the methods of brands.
+func NewBackendDatabase(backend string, cfg *config.Config, brands *brandCatalog.Catalog) databaseInterface.SnapshotDatabase {
+	switch backend {
+	case config.MemoryBackend:
+		memoryDatabaseOnce.Do(func() {
+			memoryDatabaseInstance = memoryDatabase.NewMemoryDatabase(cfg.Database.MaxQueueSize, brands)
+		})
+		return memoryDatabaseInstance
+	case config.BoltBackend:
+		boltDatabaseOnce.Do(func() {
+			boltDatabaseInstance = boltDatabase.NewBoltDatabase(cfg.Database.BoltPath, cfg.Database.MaxQueueSize, brands)
+		})
+		return boltDatabaseInstance
+	default:
+		return mongoDatabase.NewMongoDatabase(cfg.Mongo, cfg.Database.MaxQueueSize, brands)
+	}
+}
@@ -2,10 +2,15 @@ package databaseInterface
 
 import (
 	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
 )
 
 type DatabaseInterface interface {
 	UploadDevice(*dataTypes.Device, dataTypes.MinMaxValues, *dataTypes.FlowControl) error
+	UpdateDevice(*dataTypes.Device, dataTypes.MinMaxValues, *dataTypes.FlowControl) error
+	GetAllDevices(*dataTypes.FlowControl) ([]dataTypes.Device, error)
+	GetDeviceByID(primitive.ObjectID, *dataTypes.FlowControl) (dataTypes.Device, error)
 	GetValidatedAndUnvalidatedMinMaxValues(*dataTypes.FlowControl) (dataTypes.ValidatedAndUnvalidatedMinMaxValues, error)
 	NormalizeUnvalidatedScores(dataTypes.MinMaxValues, *dataTypes.FlowControl) error
 	Connect(*dataTypes.FlowControl) error
@@ -13,11 +18,28 @@ type DatabaseInterface interface {
 	IsUp(*dataTypes.FlowControl) bool
 	GetLastYearEquivalentBenchmarkScores(*dataTypes.Device, *dataTypes.FlowControl) (float64, float64, error)
 	Dequeue(ctrl *dataTypes.FlowControl) (dataTypes.DeviceInQueue, error)
-	EnqueueDeviceBatch(map[string][]string, *dataTypes.FlowControl) error
+	EnqueueDeviceBatch(map[string][]string, *dataTypes.FlowControl) ([]string, error)
+	EnqueueDevice(dataTypes.DeviceInQueue, *dataTypes.FlowControl) error
+	IsStoredDevice(string, *dataTypes.FlowControl) (bool, error)
 	ReestimateBenchmarks(*dataTypes.FlowControl) error
 	ResetDatabase(*dataTypes.FlowControl) error
-	SetLastYearEquivalentBenchmarkScores(*dataTypes.Device, *dataTypes.FlowControl) error
+	SetEstimatedBenchmarkScores(*dataTypes.Device, *dataTypes.FlowControl) error
+	SetChipsetInferredBenchmarkScores(*dataTypes.Device, *dataTypes.FlowControl) error
 	GetTop3(*dataTypes.Filters, *dataTypes.FlowControl) ([]dataTypes.Device, error)
+	GetPriceHistory(primitive.ObjectID, time.Time, *dataTypes.FlowControl) ([]dataTypes.PricePoint, error)
+	AddAlertSubscription(*dataTypes.AlertSubscription, *dataTypes.FlowControl) error
+	GetAlertSubscriptions(primitive.ObjectID, *dataTypes.FlowControl) ([]dataTypes.AlertSubscription, error)
+	GetBenchmarkCatalog(string, *dataTypes.FlowControl) (dataTypes.BenchmarkCatalog, error)
+	SaveBenchmarkCatalog(*dataTypes.BenchmarkCatalog, *dataTypes.FlowControl) error
+	GetLowestPrices([]primitive.ObjectID, time.Time, *dataTypes.FlowControl) (map[primitive.ObjectID]dataTypes.LowestPrice, error)
 	IsInterruptedValidation(*dataTypes.FlowControl) (bool, error)
 	ValidateScores(dataTypes.MinMaxValues, *dataTypes.FlowControl) error
 }
+
+// SnapshotDatabase is a database whose whole content can be copied to another backend.
+type SnapshotDatabase interface {
+	DatabaseInterface
+	ExportSnapshot(*dataTypes.FlowControl) (dataTypes.DatabaseSnapshot, error)
+	// ImportSnapshot replaces the whole content of the database with the snapshot.
+	ImportSnapshot(*dataTypes.DatabaseSnapshot, *dataTypes.FlowControl) error
+}
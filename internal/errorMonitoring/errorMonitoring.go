@@ -8,21 +8,6 @@ import (
 	"os"
 )
 
-const (
-	CleanUpErrorsMaxAmount              = 10
-	SentimentAnalysisErrorsMaxAmount    = 3
-	CreatingNewAiClientErrorsMaxAmount  = 3
-	AiNetworkErrorsMaxAmount            = 3
-	FailedAiInstructionErrorsMaxAmount  = 1
-	GettingURLErrorsMaxAmount           = 5
-	GettingDocumentErrorsMaxAmount      = 5
-	ParsingErrorsMaxAmount              = 5
-	MissingDocumentErrorsMaxAmount      = 1
-	DatabaseNetworkErrorsMaxAmount      = 3
-	GeneralDatabaseErrorsMaxAmount      = 1
-	InvalidConstIDStringErrorsMaxAmount = 1
-)
-
 const (
 	CleanUpError              = "clean_up_error"
 	SentimentAnalysisError    = "sentiment_analysis_error"
@@ -64,43 +49,47 @@ func IncrementError(errorType string, ctrl *dataTypes.FlowControl) {
 		ctrl.StopOnTooManyErrorsChannel <- struct{}{}
 	}
 
+	var limits dataTypes.ErrorLimits
+	if ctrl.ErrorLimits != nil {
+		limits = *ctrl.ErrorLimits
+	}
 	switch errorType {
 	case CleanUpError:
 		errorCounters.CleanUpErrors++
-		checkErrorThreshold(errorCounters.CleanUpErrors, CleanUpErrorsMaxAmount, ctrl)
+		checkErrorThreshold(errorCounters.CleanUpErrors, limits.CleanUpErrors, ctrl)
 	case SentimentAnalysisError:
 		errorCounters.SentimentAnalysisErrors++
-		checkErrorThreshold(errorCounters.SentimentAnalysisErrors, SentimentAnalysisErrorsMaxAmount, ctrl)
+		checkErrorThreshold(errorCounters.SentimentAnalysisErrors, limits.SentimentAnalysisErrors, ctrl)
 	case CreatingNewAiClientError:
 		errorCounters.CreatingNewAiClientErrors++
-		checkErrorThreshold(errorCounters.CreatingNewAiClientErrors, CreatingNewAiClientErrorsMaxAmount, ctrl)
+		checkErrorThreshold(errorCounters.CreatingNewAiClientErrors, limits.CreatingNewAiClientErrors, ctrl)
 	case AiNetworkError:
 		errorCounters.AiNetworkErrors++
-		checkErrorThreshold(errorCounters.AiNetworkErrors, AiNetworkErrorsMaxAmount, ctrl)
+		checkErrorThreshold(errorCounters.AiNetworkErrors, limits.AiNetworkErrors, ctrl)
 	case FailedAiInstructionError:
 		errorCounters.FailedAiInstructionErrors++
-		checkErrorThreshold(errorCounters.FailedAiInstructionErrors, FailedAiInstructionErrorsMaxAmount, ctrl)
+		checkErrorThreshold(errorCounters.FailedAiInstructionErrors, limits.FailedAiInstructionErrors, ctrl)
 	case GettingURLError:
 		errorCounters.GettingURLErrors++
-		checkErrorThreshold(errorCounters.GettingURLErrors, GettingURLErrorsMaxAmount, ctrl)
+		checkErrorThreshold(errorCounters.GettingURLErrors, limits.GettingURLErrors, ctrl)
 	case GettingDocumentError:
 		errorCounters.GettingDocumentErrors++
-		checkErrorThreshold(errorCounters.GettingDocumentErrors, GettingDocumentErrorsMaxAmount, ctrl)
+		checkErrorThreshold(errorCounters.GettingDocumentErrors, limits.GettingDocumentErrors, ctrl)
 	case ParsingError:
 		errorCounters.ParsingErrors++
-		checkErrorThreshold(errorCounters.ParsingErrors, ParsingErrorsMaxAmount, ctrl)
+		checkErrorThreshold(errorCounters.ParsingErrors, limits.ParsingErrors, ctrl)
 	case MissingDocumentError:
 		errorCounters.MissingDocumentErrors++
-		checkErrorThreshold(errorCounters.MissingDocumentErrors, MissingDocumentErrorsMaxAmount, ctrl)
+		checkErrorThreshold(errorCounters.MissingDocumentErrors, limits.MissingDocumentErrors, ctrl)
 	case DatabaseNetworkError:
 		errorCounters.DatabaseNetworkErrors++
-		checkErrorThreshold(errorCounters.DatabaseNetworkErrors, DatabaseNetworkErrorsMaxAmount, ctrl)
+		checkErrorThreshold(errorCounters.DatabaseNetworkErrors, limits.DatabaseNetworkErrors, ctrl)
 	case GeneralDatabaseError:
 		errorCounters.GeneralDatabaseErrors++
-		checkErrorThreshold(errorCounters.GeneralDatabaseErrors, GeneralDatabaseErrorsMaxAmount, ctrl)
+		checkErrorThreshold(errorCounters.GeneralDatabaseErrors, limits.GeneralDatabaseErrors, ctrl)
 	case InvalidConstIDStringError:
 		errorCounters.InvalidConstIDStringErrors++
-		checkErrorThreshold(errorCounters.InvalidConstIDStringErrors, InvalidConstIDStringErrorsMaxAmount, ctrl)
+		checkErrorThreshold(errorCounters.InvalidConstIDStringErrors, limits.InvalidConstIDStringErrors, ctrl)
 	}
 
 	updatedJSON, _ := json.MarshalIndent(errorCounters, "", "  ")
@@ -112,8 +101,9 @@ func IncrementError(errorType string, ctrl *dataTypes.FlowControl) {
 	log.Printf("in errors file incremented %v", errorType)
 }
 
+// checkErrorThreshold stops the flow once currentErrors exceeds maxErrors, unless the flow has no error limits.
 func checkErrorThreshold(currentErrors int, maxErrors int, ctrl *dataTypes.FlowControl) {
-	if currentErrors > maxErrors {
+	if ctrl.ErrorLimits != nil && currentErrors > maxErrors {
 		ctrl.StopOnTooManyErrorsChannel <- struct{}{}
 	}
 }
@@ -74,6 +74,86 @@ type InvalidDeviceError struct {
 	Message string
 }
 
+type NoSuchDeviceError struct {
+	Message string
+}
+
+type DeviceAlreadyExistsError struct {
+	Message string
+}
+
+type DeviceAlreadyQueuedError struct {
+	Message string
+}
+
+type PipelineStateError struct {
+	Message string
+}
+
+type InvalidPipelineError struct {
+	Message string
+}
+
+func (e InvalidPipelineError) Error() string {
+	return e.Message
+}
+
+type UnsupportedCurrencyError struct {
+	Message string
+}
+
+func (e UnsupportedCurrencyError) Error() string {
+	return e.Message
+}
+
+type InvalidBrandCatalogError struct {
+	Message string
+}
+
+func (e InvalidBrandCatalogError) Error() string {
+	return e.Message
+}
+
+type RegressionFitError struct {
+	Message string
+}
+
+func (e RegressionFitError) Error() string {
+	return e.Message
+}
+
+type NoChipsetPeerError struct {
+	Message string
+}
+
+func (e NoChipsetPeerError) Error() string {
+	return e.Message
+}
+
+type NoPriceSourceError struct {
+	Message string
+}
+
+func (e NoPriceSourceError) Error() string {
+	return e.Message
+}
+
+func (e PipelineStateError) Error() string {
+	return e.Message
+}
+
+func (e NoSuchDeviceError) Error() string {
+	return e.Message
+}
+
+func (e DeviceAlreadyExistsError) Error() string {
+	return e.Message
+}
+
+func (e DeviceAlreadyQueuedError) Error() string {
+	return e.Message
+}
+
 func (e InvalidDeviceError) Error() string {
 	return e.Message
 }
@@ -142,6 +222,37 @@ func (e ErrorCounterOverflowError) Error() string {
 	return e.Message
 }
 
+type InvalidConfigError struct {
+	Message string
+}
+
+func (e InvalidConfigError) Error() string {
+	return e.Message
+}
+
+type ApplicationStateError struct {
+	Message string
+}
+
+func (e ApplicationStateError) Error() string {
+	return e.Message
+}
+
+// ValidationError is returned when a device was stored but validating the scores afterwards failed. It wraps the
+// failure, so that its kind is still reported by the other Is functions.
+type ValidationError struct {
+	Message string
+	Err     error
+}
+
+func (e ValidationError) Error() string {
+	return e.Message
+}
+
+func (e ValidationError) Unwrap() error {
+	return e.Err
+}
+
 func IsNoSuchPhoneBenchmarkError(err error) bool {
 	var noPhoneErr NoSuchPhoneBenchmarkError
 	return errors.As(err, &noPhoneErr)
@@ -156,3 +267,73 @@ func IsNoLastYearEquivalentError(err error) bool {
 	var noLastYearErr NoLastYearEquivalentError
 	return errors.As(err, &noLastYearErr)
 }
+
+func IsNoSuchDeviceError(err error) bool {
+	var noSuchDeviceErr NoSuchDeviceError
+	return errors.As(err, &noSuchDeviceErr)
+}
+
+func IsDeviceAlreadyExistsError(err error) bool {
+	var deviceExistsErr DeviceAlreadyExistsError
+	return errors.As(err, &deviceExistsErr)
+}
+
+func IsInvalidDeviceError(err error) bool {
+	var invalidDeviceErr InvalidDeviceError
+	return errors.As(err, &invalidDeviceErr)
+}
+
+func IsInvalidPipelineError(err error) bool {
+	var invalidPipelineErr InvalidPipelineError
+	return errors.As(err, &invalidPipelineErr)
+}
+
+func IsPipelineStateError(err error) bool {
+	var pipelineStateErr PipelineStateError
+	return errors.As(err, &pipelineStateErr)
+}
+
+func IsUnsupportedCurrencyError(err error) bool {
+	var unsupportedCurrencyErr UnsupportedCurrencyError
+	return errors.As(err, &unsupportedCurrencyErr)
+}
+
+func IsNoPriceSourceError(err error) bool {
+	var noPriceSourceErr NoPriceSourceError
+	return errors.As(err, &noPriceSourceErr)
+}
+
+func IsInvalidBrandCatalogError(err error) bool {
+	var invalidBrandCatalogErr InvalidBrandCatalogError
+	return errors.As(err, &invalidBrandCatalogErr)
+}
+
+func IsRegressionFitError(err error) bool {
+	var regressionFitErr RegressionFitError
+	return errors.As(err, &regressionFitErr)
+}
+
+func IsNoChipsetPeerError(err error) bool {
+	var noChipsetPeerErr NoChipsetPeerError
+	return errors.As(err, &noChipsetPeerErr)
+}
+
+func IsInvalidConfigError(err error) bool {
+	var invalidConfigErr InvalidConfigError
+	return errors.As(err, &invalidConfigErr)
+}
+
+func IsApplicationStateError(err error) bool {
+	var applicationStateErr ApplicationStateError
+	return errors.As(err, &applicationStateErr)
+}
+
+func IsValidationError(err error) bool {
+	var validationErr ValidationError
+	return errors.As(err, &validationErr)
+}
+
+func IsDeviceAlreadyQueuedError(err error) bool {
+	var deviceQueuedErr DeviceAlreadyQueuedError
+	return errors.As(err, &deviceQueuedErr)
+}
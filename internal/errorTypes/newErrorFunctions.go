@@ -63,3 +63,55 @@ func NewCleanUpError(message string) CleanUpError {
 func NewInvalidDeviceError(message string) InvalidDeviceError {
 	return InvalidDeviceError{message}
 }
+
+func NewNoSuchDeviceError(message string) NoSuchDeviceError {
+	return NoSuchDeviceError{message}
+}
+
+func NewDeviceAlreadyExistsError(message string) DeviceAlreadyExistsError {
+	return DeviceAlreadyExistsError{message}
+}
+
+func NewPipelineStateError(message string) PipelineStateError {
+	return PipelineStateError{message}
+}
+
+func NewInvalidPipelineError(message string) InvalidPipelineError {
+	return InvalidPipelineError{message}
+}
+
+func NewUnsupportedCurrencyError(message string) UnsupportedCurrencyError {
+	return UnsupportedCurrencyError{message}
+}
+
+func NewNoPriceSourceError(message string) NoPriceSourceError {
+	return NoPriceSourceError{message}
+}
+
+func NewInvalidBrandCatalogError(message string) InvalidBrandCatalogError {
+	return InvalidBrandCatalogError{message}
+}
+
+func NewRegressionFitError(message string) RegressionFitError {
+	return RegressionFitError{message}
+}
+
+func NewNoChipsetPeerError(message string) NoChipsetPeerError {
+	return NoChipsetPeerError{message}
+}
+
+func NewInvalidConfigError(message string) InvalidConfigError {
+	return InvalidConfigError{message}
+}
+
+func NewApplicationStateError(message string) ApplicationStateError {
+	return ApplicationStateError{message}
+}
+
+func NewValidationError(message string, err error) ValidationError {
+	return ValidationError{message, err}
+}
+
+func NewDeviceAlreadyQueuedError(message string) DeviceAlreadyQueuedError {
+	return DeviceAlreadyQueuedError{message}
+}
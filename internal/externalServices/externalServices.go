@@ -0,0 +1,24 @@
+package externalServices
+
+import (
+	"github.com/ItaiHalperin/Device-Rec-API/internal/aiAnalysis"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/brandCatalog"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/config"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/currencyConversion"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/nameMatching"
+	"net/http"
+)
+
+// ExternalServices are what the scrapers and the analyses reach outside the API with, and the reference data they
+// read. The application builds them once and passes them down the pipeline.
+type ExternalServices struct {
+	HTTPClient *http.Client
+	Analyzer   *aiAnalysis.Analyzer
+	Keys       config.KeysConfig
+	Brands     *brandCatalog.Catalog
+	// NameAliases resolve the names the scrapers match against the device's.
+	NameAliases       *nameMatching.Aliases
+	CurrencyConverter *currencyConversion.Converter
+	// Markets are the configured markets, as priceScraper.ParseMarkets reads them.
+	Markets []string
+}
@@ -17,6 +17,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 )
 
@@ -60,13 +61,14 @@ func GetAfterSubstring(s, sub string) string {
 	return s[index+len(sub):]
 }
 
-func GetDocumentByURL(url string, ctrl *dataTypes.FlowControl) (*goquery.Document, error) {
+// GetDocumentByURL sends the request with client and parses the HTML it gets back.
+func GetDocumentByURL(client *http.Client, url string, ctrl *dataTypes.FlowControl) (*goquery.Document, error) {
 	if ctrl.Ctx.Err() != nil {
 		log.Printf("stopping aiAnlysis.GetDocumentByURL: %v", ctrl.Ctx.Err())
 		return nil, ctrl.Ctx.Err()
 	}
 
-	resp, err := GetRespByURL(url, ctrl)
+	resp, err := GetRespByURL(client, url, ctrl)
 	if err != nil {
 		log.Printf("helpers.GetDocumentByURL got bad status code")
 		return nil, err
@@ -89,14 +91,14 @@ func GetDocumentByURL(url string, ctrl *dataTypes.FlowControl) (*goquery.Documen
 	return doc, nil
 }
 
-func GetRespByURL(url string, ctrl *dataTypes.FlowControl) (*http.Response, error) {
+func GetRespByURL(client *http.Client, url string, ctrl *dataTypes.FlowControl) (*http.Response, error) {
 	if ctrl.Ctx.Err() != nil {
 		log.Printf("stopping aiAnlysis.GetRespByURL: %v", ctrl.Ctx.Err())
 		return nil, ctrl.Ctx.Err()
 	}
 
 	url = strings.ReplaceAll(url, " ", "+")
-	resp, err := http.Get(url)
+	resp, err := client.Get(url)
 	if err != nil {
 		errorMonitoring.IncrementError(errorMonitoring.GettingURLError, ctrl)
 		return nil, errorTypes.NewErrorGettingURL("in aiAnalysis.GetDocumentByURL error getting HTML")
@@ -164,26 +166,24 @@ func ExtractFloat(s string) (float64, error) {
 	return num, nil
 }
 
-func DoesNameContainExcludedKeywords(name string, excluded []string) bool {
-	nameLower := strings.ToLower(name)
-	for _, keyword := range excluded {
-		if strings.Contains(nameLower, strings.ToLower(keyword)) {
-			return true
-		}
-	}
-	return false
-}
-
 func GetDefaultMinMax() dataTypes.MinMaxValues {
 	return dataTypes.MinMaxValues{Sentiment: dataTypes.MinMaxFloat{Min: 1e+308},
 		Magnitude:       dataTypes.MinMaxFloat{Min: 1e+308},
 		SingleCoreScore: dataTypes.MinMaxFloat{Min: 1e+308},
 		MultiCoreScore:  dataTypes.MinMaxFloat{Min: 1e+308},
+		GPUScore:        dataTypes.MinMaxFloat{Min: 1e+308},
 		BatteryCapacity: dataTypes.MinMaxFloat{Min: 1e+308},
 		PixelDensity:    dataTypes.MinMaxFloat{Min: 1e+308},
 		Nits:            dataTypes.MinMaxFloat{Min: 1e+308}}
 }
 
+func RecordProvenance(device *dataTypes.Device, field, source, url, method string) {
+	if device.Provenance == nil {
+		device.Provenance = make(map[string]dataTypes.Provenance)
+	}
+	device.Provenance[field] = dataTypes.Provenance{Source: source, URL: url, Method: method, Time: time.Now()}
+}
+
 func GetKeys(m map[string][]string) []string {
 	keys := make([]string, 0, len(m))
 	for key := range m {
@@ -244,6 +244,8 @@ func GetNewMinMax(device *dataTypes.Device, validatedAndUnvalidatedMinMaxValue d
 		Max: newMaxMultiCoreScore,
 	}
 
+	newGPUScoreMinMax := getNewOptionalMinMax(validatedAndUnvalidatedMinMaxValue.Validated.GPUScore, device.Benchmark.GPUScore)
+
 	newMinBatteryCapacity, newMaxBatteryCapacity := math.Min(validatedAndUnvalidatedMinMaxValue.Validated.BatteryCapacity.Min, device.Specs.BatteryCapacity),
 		math.Max(validatedAndUnvalidatedMinMaxValue.Validated.BatteryCapacity.Max, device.Specs.BatteryCapacity)
 	newBatteryCapacityMinMax := dataTypes.MinMaxFloat{
@@ -269,12 +271,26 @@ func GetNewMinMax(device *dataTypes.Device, validatedAndUnvalidatedMinMaxValue d
 		Magnitude:       newMagnitudeMinMax,
 		SingleCoreScore: newSingleCoreScoreMinMax,
 		MultiCoreScore:  newMultiCoreScoreMinMax,
+		GPUScore:        newGPUScoreMinMax,
 		BatteryCapacity: newBatteryCapacityMinMax,
 		PixelDensity:    newPixelDensityMinMax,
 		Nits:            newNitsMinMax,
 	}
 	return newMinMax
 }
+
+// getNewOptionalMinMax extends minMax with a value that may be missing (zero). A range that was never set, like one
+// stored before the field existed, starts over at the value.
+func getNewOptionalMinMax(minMax dataTypes.MinMaxFloat, value float64) dataTypes.MinMaxFloat {
+	if value <= 0 {
+		return minMax
+	}
+	if minMax.Max <= 0 {
+		return dataTypes.MinMaxFloat{Min: value, Max: value}
+	}
+	return dataTypes.MinMaxFloat{Min: math.Min(minMax.Min, value), Max: math.Max(minMax.Max, value)}
+}
+
 func CalculateNormalizedValue(min, max, current float64) float64 {
 	if min == max {
 		return 0
@@ -283,3 +299,27 @@ func CalculateNormalizedValue(min, max, current float64) float64 {
 			(max - min)
 	}
 }
+
+// IsVariantSearch reports whether the filters search device variants rather than devices.
+func IsVariantSearch(filters *dataTypes.Filters) bool {
+	return filters.Storage.Max > 0 || filters.RAM.Max > 0 || filters.SortByValue
+}
+
+// GetSearchedPrice returns the price the filters' Price range applies to: the price of the device, or of its only
+// variant in a variant search, in the filters' market or in the primary market when no market is set.
+func GetSearchedPrice(device *dataTypes.Device, filters *dataTypes.Filters) (dataTypes.MarketPrice, bool) {
+	realPrice, currency, prices := device.RealPrice, device.Currency, device.Prices
+	if IsVariantSearch(filters) && len(device.Variants) == 1 {
+		variant := device.Variants[0]
+		realPrice, currency, prices = variant.RealPrice, variant.Currency, variant.Prices
+	}
+	if filters.Market == "" {
+		return dataTypes.MarketPrice{Price: realPrice, Currency: currency}, realPrice > 0
+	}
+	for _, price := range prices {
+		if strings.EqualFold(price.Market, filters.Market) {
+			return price, true
+		}
+	}
+	return dataTypes.MarketPrice{}, false
+}
@@ -0,0 +1,82 @@
+package ingestionJobs
+
+import (
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"log"
+	"sync"
+	"time"
+)
+
+// finishedJobTTL is how long a done or failed job can still be looked up.
+const finishedJobTTL = time.Hour
+
+// Store keeps the ingestion jobs in memory. Finished jobs are evicted once finishedJobTTL passed since they finished.
+type Store struct {
+	mutex sync.Mutex
+	jobs  map[string]*dataTypes.IngestionJob
+}
+
+func NewStore() *Store {
+	return &Store{jobs: make(map[string]*dataTypes.IngestionJob)}
+}
+
+func (store *Store) NewJob(deviceInQueue dataTypes.DeviceInQueue, synchronous bool) dataTypes.IngestionJob {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := time.Now()
+	store.evictFinishedJobs(now)
+	job := &dataTypes.IngestionJob{
+		ID:          primitive.NewObjectID().Hex(),
+		DeviceName:  deviceInQueue.Name,
+		Detail:      deviceInQueue.Detail,
+		Synchronous: synchronous,
+		Status:      dataTypes.IngestionJobQueued,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	store.jobs[job.ID] = job
+	return *job
+}
+
+func (store *Store) GetJob(jobID string) (dataTypes.IngestionJob, bool) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	job, ok := store.jobs[jobID]
+	if !ok {
+		return dataTypes.IngestionJob{}, false
+	}
+	return *job, true
+}
+
+// UpdateJob is a no-op for devices that weren't ingested on demand (empty job ID).
+func (store *Store) UpdateJob(jobID, status string, err error) {
+	if jobID == "" {
+		return
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	job, ok := store.jobs[jobID]
+	if !ok {
+		log.Printf("in ingestionJobs.UpdateJob unknown job %v", jobID)
+		return
+	}
+	job.Status = status
+	job.UpdatedAt = time.Now()
+	if err != nil {
+		job.Error = err.Error()
+	}
+}
+
+func (store *Store) evictFinishedJobs(now time.Time) {
+	for jobID, job := range store.jobs {
+		isFinished := job.Status == dataTypes.IngestionJobDone || job.Status == dataTypes.IngestionJobFailed
+		if isFinished && now.Sub(job.UpdatedAt) > finishedJobTTL {
+			delete(store.jobs, jobID)
+		}
+	}
+}
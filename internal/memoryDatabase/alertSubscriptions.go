@@ -0,0 +1,46 @@
+package memoryDatabase
+
+import (
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"log"
+)
+
+func (mdb *MemoryDatabase) AddAlertSubscription(subscription *dataTypes.AlertSubscription, ctrl *dataTypes.FlowControl) error {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping memoryDatabase.AddAlertSubscription: %v", ctrl.Ctx.Err())
+		return ctrl.Ctx.Err()
+	}
+
+	if subscription.ID.IsZero() {
+		subscription.ID = primitive.NewObjectID()
+	}
+	storedSubscription, err := copyDocument(*subscription, ctrl)
+	if err != nil {
+		log.Printf("in memoryDatabase.AddAlertSubscription (device: %v) failed to insert subscription: %v", subscription.DeviceName, err)
+		return err
+	}
+
+	mdb.mutex.Lock()
+	defer mdb.mutex.Unlock()
+	tx := mdb.begin()
+	tx.addAlertSubscription(storedSubscription)
+	return tx.commit(ctrl)
+}
+
+func (mdb *MemoryDatabase) GetAlertSubscriptions(deviceID primitive.ObjectID, ctrl *dataTypes.FlowControl) ([]dataTypes.AlertSubscription, error) {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping memoryDatabase.GetAlertSubscriptions: %v", ctrl.Ctx.Err())
+		return nil, ctrl.Ctx.Err()
+	}
+
+	mdb.mutex.RLock()
+	defer mdb.mutex.RUnlock()
+	var subscriptions []dataTypes.AlertSubscription
+	for _, subscription := range mdb.alertSubscriptions {
+		if subscription.DeviceID == deviceID {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	return subscriptions, nil
+}
@@ -0,0 +1,44 @@
+package memoryDatabase
+
+import (
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
+	"log"
+)
+
+// GetBenchmarkCatalog returns the stored leaderboard of the platform, or a MissingDocumentError if it was never saved.
+func (mdb *MemoryDatabase) GetBenchmarkCatalog(platform string, ctrl *dataTypes.FlowControl) (dataTypes.BenchmarkCatalog, error) {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping memoryDatabase.GetBenchmarkCatalog: %v", ctrl.Ctx.Err())
+		return dataTypes.BenchmarkCatalog{}, ctrl.Ctx.Err()
+	}
+
+	mdb.mutex.RLock()
+	defer mdb.mutex.RUnlock()
+	catalog, ok := mdb.benchmarkCatalogs[platform]
+	if !ok {
+		log.Printf("in memoryDatabase.GetBenchmarkCatalog (platform: %v) no stored catalog", platform)
+		return dataTypes.BenchmarkCatalog{}, errorTypes.NewMissingDocumentError("failed to find document")
+	}
+	return copyDocument(catalog, ctrl)
+}
+
+// SaveBenchmarkCatalog replaces the stored leaderboard of the catalog's platform.
+func (mdb *MemoryDatabase) SaveBenchmarkCatalog(catalog *dataTypes.BenchmarkCatalog, ctrl *dataTypes.FlowControl) error {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping memoryDatabase.SaveBenchmarkCatalog: %v", ctrl.Ctx.Err())
+		return ctrl.Ctx.Err()
+	}
+
+	storedCatalog, err := copyDocument(*catalog, ctrl)
+	if err != nil {
+		log.Printf("in memoryDatabase.SaveBenchmarkCatalog (platform: %v) failed to save catalog: %v", catalog.Platform, err)
+		return err
+	}
+
+	mdb.mutex.Lock()
+	defer mdb.mutex.Unlock()
+	tx := mdb.begin()
+	tx.putBenchmarkCatalog(storedCatalog)
+	return tx.commit(ctrl)
+}
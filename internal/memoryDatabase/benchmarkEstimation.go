@@ -0,0 +1,159 @@
+package memoryDatabase
+
+import (
+	"fmt"
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/benchmarkEstimation"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/brandCatalog"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/helpers"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"log"
+)
+
+// ReestimateBenchmarks infers or estimates again every benchmark that wasn't measured, since the devices stored
+// since may improve it.
+func (mdb *MemoryDatabase) ReestimateBenchmarks(ctrl *dataTypes.FlowControl) error {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping memoryDatabase.ReestimateBenchmarks: %v", ctrl.Ctx.Err())
+		return ctrl.Ctx.Err()
+	}
+
+	allDevices, err := mdb.GetAllDevices(ctrl)
+	if err != nil {
+		log.Printf("in memoryDatabase.ReestimateBenchmarks failed to get all devices: %v", err)
+		return err
+	}
+	var allDevicesWithEstimatedBenchmark []dataTypes.Device
+	for _, device := range allDevices {
+		if benchmarkEstimation.IsReestimated(&device) {
+			allDevicesWithEstimatedBenchmark = append(allDevicesWithEstimatedBenchmark, device)
+		}
+	}
+
+	helpers.SortDevicesByDate(allDevicesWithEstimatedBenchmark)
+
+	for _, device := range allDevicesWithEstimatedBenchmark {
+		err = mdb.estimator.ReestimateBenchmarkScores(&device, ctrl)
+		if err != nil {
+			log.Printf("in memoryDatabase.ReestimateBenchmarks (device: %v) failed to reestimate benchmark score: %v", device.Name, err)
+			return err
+		}
+
+		updated, err := copyDocument(device, ctrl)
+		if err != nil {
+			log.Printf("in memoryDatabase.ReestimateBenchmarks failed to update device: %v", device.Name)
+			return err
+		}
+		mdb.mutex.Lock()
+		tx := mdb.begin()
+		if storedDevice, ok := mdb.devices[device.ID]; ok {
+			storedDevice.Benchmark = updated.Benchmark
+			storedDevice.Provenance = updated.Provenance
+			tx.putDevice(storedDevice)
+		}
+		err = tx.commit(ctrl)
+		mdb.mutex.Unlock()
+		if err != nil {
+			log.Printf("in memoryDatabase.ReestimateBenchmarks failed to update device: %v", device.Name)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (mdb *MemoryDatabase) GetLastYearEquivalentBenchmarkScores(device *dataTypes.Device, ctrl *dataTypes.FlowControl) (float64, float64, error) {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping memoryDatabase.GetLastYearEquivalentBenchmarkScores: %v", ctrl.Ctx.Err())
+		return 0, 0, ctrl.Ctx.Err()
+	}
+
+	brand, err := mdb.brands.GetBrand(device.Brand)
+	if err != nil {
+		log.Printf("in memoryDatabase.GetLastYearEquivalentBenchmarkScores (device: %v) failed to get brand: %v", device.Name, err)
+		return 0, 0, err
+	}
+	if brand.Estimation.Method == brandCatalog.NoEstimation {
+		log.Printf("in memoryDatabase.GetLastYearEquivalentBenchmarkScores (device: %v) estimation disabled for %v", device.Name, brand.Name)
+		return 0, 0, errorTypes.NewNoLastYearEquivalentError("in memoryDatabase.GetLastYearEquivalentBenchmarkScores estimation disabled")
+	}
+
+	mdb.mutex.RLock()
+	defer mdb.mutex.RUnlock()
+	lastYearModelName, err := helpers.DecrementNumberInString(device.Name)
+	if err == nil {
+		for _, deviceID := range mdb.deviceIDs {
+			if lastYearDevice := mdb.devices[deviceID]; lastYearDevice.Name == lastYearModelName {
+				return lastYearDevice.Benchmark.SingleCoreScore * (1 + benchmarkEstimation.YearOverYearIncrease), lastYearDevice.Benchmark.MultiCoreScore * (1 + benchmarkEstimation.YearOverYearIncrease), nil
+			}
+		}
+	}
+
+	lastYearNumber := device.Specs.ReleaseDate.Year() - 1
+	lastYearID := mdb.getYearIDIfExists(lastYearNumber)
+	if lastYearID.IsZero() {
+		log.Println("in memoryDatabase.GetLastYearEquivalentBenchmarkScores no last year equivalent")
+		return 0, 0, errorTypes.NewNoLastYearEquivalentError("in memoryDatabase.GetLastYearEquivalentBenchmarkScores no last year equivalent")
+	}
+
+	priceCategories := brand.Estimation.PriceCategories(device.PriceCategory)
+	for _, lastYearDevice := range mdb.textSearch(device.Name, lastYearID) {
+		if priceCategories.Min <= lastYearDevice.PriceCategory && lastYearDevice.PriceCategory <= priceCategories.Max {
+			singleCoreScore, multiCoreScore := benchmarkEstimation.ApplyPriceCategoryPenalty(lastYearDevice.Benchmark.SingleCoreScore,
+				lastYearDevice.Benchmark.MultiCoreScore, lastYearDevice.PriceCategory, device.PriceCategory,
+				brand.Estimation.PriceCategoryPenalty)
+			return singleCoreScore, multiCoreScore, nil
+		}
+	}
+
+	log.Println("in memoryDatabase.GetLastYearEquivalentBenchmarkScores no last year equivalent")
+	return 0, 0, errorTypes.NewNoLastYearEquivalentError("no last year equivalent")
+}
+
+// getYearIDIfExists returns the ID of the year, or a zero ID when no device of that year is stored. The caller must
+// hold the mutex.
+func (mdb *MemoryDatabase) getYearIDIfExists(yearNumber int) primitive.ObjectID {
+	for _, yearID := range mdb.yearIDs {
+		if mdb.years[yearID].YearNumber == yearNumber {
+			return yearID
+		}
+	}
+	return primitive.NilObjectID
+}
+
+// SetEstimatedBenchmarkScores estimates the device's benchmark with its brand's estimation method. Devices that
+// can't be estimated keep their scores.
+func (mdb *MemoryDatabase) SetEstimatedBenchmarkScores(device *dataTypes.Device, ctrl *dataTypes.FlowControl) error {
+	return mdb.estimator.SetEstimatedBenchmarkScores(device, ctrl)
+}
+
+// SetChipsetInferredBenchmarkScores sets the device's scores to the median scores of the measured devices with the
+// same chipset. It returns a NoChipsetPeerError when the chipset is unknown or no such device is stored.
+func (mdb *MemoryDatabase) SetChipsetInferredBenchmarkScores(device *dataTypes.Device, ctrl *dataTypes.FlowControl) error {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping memoryDatabase.SetChipsetInferredBenchmarkScores: %v", ctrl.Ctx.Err())
+		return ctrl.Ctx.Err()
+	}
+
+	chipset := benchmarkEstimation.GetChipsetKey(device.Specs.Chipset)
+	if chipset == "" {
+		return errorTypes.NewNoChipsetPeerError(fmt.Sprintf("in memoryDatabase.SetChipsetInferredBenchmarkScores (device: %v) unknown chipset", device.Name))
+	}
+
+	mdb.mutex.RLock()
+	var peers []dataTypes.Device
+	for _, deviceID := range mdb.deviceIDs {
+		if candidate := mdb.devices[deviceID]; benchmarkEstimation.IsChipsetPeer(device, &candidate, chipset) {
+			peers = append(peers, candidate)
+		}
+	}
+	validatedMinMax := mdb.minMax.Validated
+	mdb.mutex.RUnlock()
+	if len(peers) == 0 {
+		return errorTypes.NewNoChipsetPeerError(fmt.Sprintf("in memoryDatabase.SetChipsetInferredBenchmarkScores (device: %v) no measured device with chipset %v", device.Name, chipset))
+	}
+
+	benchmarkEstimation.SetChipsetInferredScores(device, peers, validatedMinMax)
+	return nil
+}
@@ -0,0 +1,27 @@
+package memoryDatabase
+
+import (
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"log"
+)
+
+// ResetDatabase deletes the devices, years, months and price history and resets the min-max values. Like the Mongo
+// backend, it keeps the queue, the alert subscriptions and the benchmark catalogs.
+func (mdb *MemoryDatabase) ResetDatabase(ctrl *dataTypes.FlowControl) error {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping memoryDatabase.ResetDatabase: %v", ctrl.Ctx.Err())
+		return ctrl.Ctx.Err()
+	}
+
+	mdb.mutex.Lock()
+	defer mdb.mutex.Unlock()
+	tx := mdb.begin()
+	tx.reset()
+	err := tx.commit(ctrl)
+	if err != nil {
+		log.Printf("in memoryDatabase.ResetDatabase failed to reset database: %v", err)
+		return err
+	}
+	log.Println("in memoryDatabase.ResetDatabase successfully reset database")
+	return nil
+}
@@ -0,0 +1,163 @@
+package memoryDatabase
+
+import (
+	"fmt"
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/aiAnalysis"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"log"
+	"time"
+)
+
+func (mdb *MemoryDatabase) UploadDevice(device *dataTypes.Device, unvalidatedMinMax dataTypes.MinMaxValues,
+	ctrl *dataTypes.FlowControl) error {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping memoryDatabase.UploadDevice: %v", ctrl.Ctx.Err())
+		return ctrl.Ctx.Err()
+	}
+
+	device.UnvalidatedFinalScore = aiAnalysis.GetFinalScore(unvalidatedMinMax, device, dataTypes.UnvalidatedScores)
+	err := mdb.insertDevice(device, unvalidatedMinMax, ctrl)
+	if err != nil {
+		log.Printf("in memoryDatabase.UploadDevice failed to insert device into database: %v", err)
+		return err
+	}
+
+	err = mdb.ValidateScores(unvalidatedMinMax, ctrl)
+	if err != nil {
+		log.Printf("in memoryDatabase.UploadDevice failed to upload device fully due to failed validation: %v", err)
+		return errorTypes.NewValidationError(fmt.Sprintf("in memoryDatabase.UploadDevice failed to validate after uploading %v: %v", device.Name, err), err)
+	}
+	log.Printf("in memoryDatabase.UploadDevice successfully uploaded %v into database", device.Name)
+	return nil
+}
+
+func (mdb *MemoryDatabase) insertDevice(device *dataTypes.Device, unvalidatedMinMax dataTypes.MinMaxValues,
+	ctrl *dataTypes.FlowControl) error {
+	storedDevice, err := copyDocument(*device, ctrl)
+	if err != nil {
+		return err
+	}
+
+	mdb.mutex.Lock()
+	defer mdb.mutex.Unlock()
+	tx := mdb.begin()
+	tx.setDeviceIDs(device)
+	storedDevice.ID, storedDevice.Year, storedDevice.Month = device.ID, device.Year, device.Month
+	tx.putDevice(storedDevice)
+	minMax := mdb.minMax
+	minMax.Unvalidated = unvalidatedMinMax
+	tx.setMinMax(minMax)
+	tx.recordPriceObservation(device)
+	return tx.commit(ctrl)
+}
+
+// UpdateDevice replaces a stored device in place, keeping its IDs, and revalidates the scores.
+func (mdb *MemoryDatabase) UpdateDevice(device *dataTypes.Device, unvalidatedMinMax dataTypes.MinMaxValues,
+	ctrl *dataTypes.FlowControl) error {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping memoryDatabase.UpdateDevice: %v", ctrl.Ctx.Err())
+		return ctrl.Ctx.Err()
+	}
+
+	device.UnvalidatedFinalScore = aiAnalysis.GetFinalScore(unvalidatedMinMax, device, dataTypes.UnvalidatedScores)
+	storedDevice, err := copyDocument(*device, ctrl)
+	if err != nil {
+		log.Printf("in memoryDatabase.UpdateDevice failed to replace device %v: %v", device.Name, err)
+		return err
+	}
+
+	mdb.mutex.Lock()
+	if _, ok := mdb.devices[device.ID]; !ok {
+		mdb.mutex.Unlock()
+		errMsg := fmt.Sprintf("in memoryDatabase.UpdateDevice device %v is not stored", device.Name)
+		log.Println(errMsg)
+		return errorTypes.NewMissingDocumentError(errMsg)
+	}
+	tx := mdb.begin()
+	tx.putDevice(storedDevice)
+	tx.recordPriceObservation(device)
+	err = tx.commit(ctrl)
+	mdb.mutex.Unlock()
+	if err != nil {
+		log.Printf("in memoryDatabase.UpdateDevice failed to replace device %v: %v", device.Name, err)
+		return err
+	}
+
+	err = mdb.ValidateScores(unvalidatedMinMax, ctrl)
+	if err != nil {
+		log.Printf("in memoryDatabase.UpdateDevice failed to update device fully due to failed validation: %v", err)
+		return errorTypes.NewValidationError(fmt.Sprintf("in memoryDatabase.UpdateDevice failed to validate after updating %v: %v", device.Name, err), err)
+	}
+	log.Printf("in memoryDatabase.UpdateDevice successfully updated %v", device.Name)
+	return nil
+}
+
+// setDeviceIDs gives the device a new ID and files it under its release month, adding the year and month when
+// they are new.
+func (tx *transaction) setDeviceIDs(device *dataTypes.Device) {
+	yearID := tx.getYearID(device.Specs.ReleaseDate.Year())
+	monthID := tx.getMonthID(int(device.Specs.ReleaseDate.Month()), yearID)
+
+	device.ID = primitive.NewObjectID()
+	device.Year = yearID
+	device.Month = monthID
+
+	month := tx.mdb.months[monthID]
+	month.Devices = append(month.Devices[:len(month.Devices):len(month.Devices)], device.ID)
+	tx.putMonth(month)
+}
+
+func (tx *transaction) getYearID(yearNumber int) primitive.ObjectID {
+	if yearID := tx.mdb.getYearIDIfExists(yearNumber); !yearID.IsZero() {
+		return yearID
+	}
+
+	yearID := primitive.NewObjectID()
+	tx.putYear(dataTypes.Year{ID: yearID, YearNumber: yearNumber})
+	log.Printf("in memoryDatabase.getYearID added year %v with id: %v", yearNumber, yearID)
+	return yearID
+}
+
+func (tx *transaction) getMonthID(monthNumber int, yearID primitive.ObjectID) primitive.ObjectID {
+	year := tx.mdb.years[yearID]
+	for _, monthID := range year.Months {
+		if tx.mdb.months[monthID].MonthNumber == monthNumber {
+			return monthID
+		}
+	}
+
+	monthID := primitive.NewObjectID()
+	tx.putMonth(dataTypes.Month{ID: monthID, MonthNumber: monthNumber, Year: yearID, Devices: []primitive.ObjectID{}})
+	year.Months = append(year.Months[:len(year.Months):len(year.Months)], monthID)
+	tx.putYear(year)
+	return monthID
+}
+
+// recordPriceObservation adds the device's current price to its price history, unless that price was already
+// recorded.
+func (tx *transaction) recordPriceObservation(device *dataTypes.Device) {
+	provenance := device.Provenance[dataTypes.RealPriceProvenance]
+	observedAt := provenance.Time
+	if observedAt.IsZero() {
+		observedAt = time.Now()
+	}
+	// Mongo stores times in milliseconds, and so does the history.
+	observedAt = observedAt.Truncate(time.Millisecond).UTC()
+
+	for _, pricePoint := range tx.mdb.priceHistory {
+		if pricePoint.DeviceID == device.ID && !observedAt.After(pricePoint.Time) {
+			return
+		}
+	}
+
+	tx.addPricePoint(dataTypes.PricePoint{
+		ID:        primitive.NewObjectID(),
+		DeviceID:  device.ID,
+		Price:     device.RealPrice,
+		Currency:  device.Currency,
+		SourceURL: provenance.URL,
+		Time:      observedAt,
+	})
+}
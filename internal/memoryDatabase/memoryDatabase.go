@@ -0,0 +1,156 @@
+package memoryDatabase
+
+import (
+	"fmt"
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/benchmarkEstimation"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/brandCatalog"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorMonitoring"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/helpers"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"log"
+	"sync"
+)
+
+// MemoryDatabase keeps the whole database in memory, for local runs without Mongo. Its data lives as long as the
+// process: Disconnect keeps it, so every handler sharing the instance sees the same data.
+// Documents are copied in and out through BSON, so callers never share them and read them back as Mongo returns them.
+type MemoryDatabase struct {
+	mutex sync.RWMutex
+	contents
+	maxQueueSize int
+	// brands name each brand's benchmark estimation method.
+	brands *brandCatalog.Catalog
+	// estimator keeps the regression model fitted on the stored devices.
+	estimator *benchmarkEstimation.Estimator
+	// committer persists every change before it becomes visible, see SetCommitter.
+	committer Committer
+}
+
+// contents is everything the database stores.
+type contents struct {
+	devices map[primitive.ObjectID]dataTypes.Device
+	// deviceIDs and yearIDs keep the insertion order, as the ID array documents do.
+	deviceIDs []primitive.ObjectID
+	years     map[primitive.ObjectID]dataTypes.Year
+	yearIDs   []primitive.ObjectID
+	months    map[primitive.ObjectID]dataTypes.Month
+	minMax    dataTypes.ValidatedAndUnvalidatedMinMaxValues
+	queue     []dataTypes.DeviceInQueue
+	// queueKeys are the keys the queued devices are committed under, in the order of the queue.
+	queueKeys          []uint64
+	nextQueueKey       uint64
+	priceHistory       []dataTypes.PricePoint
+	alertSubscriptions []dataTypes.AlertSubscription
+	benchmarkCatalogs  map[string]dataTypes.BenchmarkCatalog
+	// unfinishedValidations counts the validation flags of validations that haven't finished.
+	unfinishedValidations int
+}
+
+// NewMemoryDatabase returns an empty database, in the state a freshly reset Mongo database is in. The enqueuer keeps
+// at most maxQueueSize devices in its queue, and benchmarks are estimated with the methods of brands.
+func NewMemoryDatabase(maxQueueSize int, brands *brandCatalog.Catalog) *MemoryDatabase {
+	mdb := &MemoryDatabase{contents: contents{benchmarkCatalogs: make(map[string]dataTypes.BenchmarkCatalog)}, maxQueueSize: maxQueueSize,
+		brands: brands}
+	mdb.estimator = benchmarkEstimation.NewEstimator(mdb, brands)
+	mdb.reset()
+	return mdb
+}
+
+func (mdb *MemoryDatabase) Connect(ctrl *dataTypes.FlowControl) error {
+	return ctrl.Ctx.Err()
+}
+
+func (mdb *MemoryDatabase) Disconnect(ctrl *dataTypes.FlowControl) error {
+	return nil
+}
+
+func (mdb *MemoryDatabase) IsUp(ctrl *dataTypes.FlowControl) bool {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping memoryDatabase.IsUp: %v", ctrl.Ctx.Err())
+		return false
+	}
+	return true
+}
+
+// reset empties everything ResetDatabase resets: the devices, the year/month hierarchy, the price history and the
+// min-max values.
+func (mdb *MemoryDatabase) reset() {
+	mdb.devices = make(map[primitive.ObjectID]dataTypes.Device)
+	mdb.deviceIDs = make([]primitive.ObjectID, 0)
+	mdb.years = make(map[primitive.ObjectID]dataTypes.Year)
+	mdb.yearIDs = make([]primitive.ObjectID, 0)
+	mdb.months = make(map[primitive.ObjectID]dataTypes.Month)
+	mdb.priceHistory = nil
+	defaultMinMax := helpers.GetDefaultMinMax()
+	mdb.minMax = dataTypes.ValidatedAndUnvalidatedMinMaxValues{Validated: defaultMinMax, Unvalidated: defaultMinMax}
+	mdb.estimator.InvalidateModel()
+}
+
+func (mdb *MemoryDatabase) GetValidatedAndUnvalidatedMinMaxValues(ctrl *dataTypes.FlowControl) (dataTypes.ValidatedAndUnvalidatedMinMaxValues, error) {
+	if ctrl.Ctx.Err() != nil {
+		return dataTypes.ValidatedAndUnvalidatedMinMaxValues{}, ctrl.Ctx.Err()
+	}
+
+	mdb.mutex.RLock()
+	defer mdb.mutex.RUnlock()
+	return mdb.minMax, nil
+}
+
+func (mdb *MemoryDatabase) GetAllDevices(ctrl *dataTypes.FlowControl) ([]dataTypes.Device, error) {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping memoryDatabase.GetAllDevices: %v", ctrl.Ctx.Err())
+		return nil, ctrl.Ctx.Err()
+	}
+
+	mdb.mutex.RLock()
+	defer mdb.mutex.RUnlock()
+	return mdb.getAllDevices(ctrl)
+}
+
+func (mdb *MemoryDatabase) GetDeviceByID(deviceID primitive.ObjectID, ctrl *dataTypes.FlowControl) (dataTypes.Device, error) {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping memoryDatabase.GetDeviceByID: %v", ctrl.Ctx.Err())
+		return dataTypes.Device{}, ctrl.Ctx.Err()
+	}
+
+	mdb.mutex.RLock()
+	defer mdb.mutex.RUnlock()
+	device, ok := mdb.devices[deviceID]
+	if !ok {
+		log.Printf("in memoryDatabase.GetDeviceByID failed to get device %v", deviceID.Hex())
+		return dataTypes.Device{}, errorTypes.NewMissingDocumentError("failed to find document")
+	}
+	return copyDocument(device, ctrl)
+}
+
+// getAllDevices returns copies of the stored devices in the order they were stored. The caller must hold the mutex.
+func (mdb *MemoryDatabase) getAllDevices(ctrl *dataTypes.FlowControl) ([]dataTypes.Device, error) {
+	var allDevices []dataTypes.Device
+	for _, deviceID := range mdb.deviceIDs {
+		device, err := copyDocument(mdb.devices[deviceID], ctrl)
+		if err != nil {
+			log.Println("in memoryDatabase.getAllDevices failed to copy device")
+			return nil, err
+		}
+		allDevices = append(allDevices, device)
+	}
+	return allDevices, nil
+}
+
+// copyDocument returns a deep copy of document made by a BSON round trip.
+func copyDocument[T any](document T, ctrl *dataTypes.FlowControl) (T, error) {
+	var documentCopy T
+	data, err := bson.Marshal(document)
+	if err == nil {
+		err = bson.Unmarshal(data, &documentCopy)
+	}
+	if err != nil {
+		log.Printf("in memoryDatabase.copyDocument failed to copy %T: %v", document, err)
+		errorMonitoring.IncrementError(errorMonitoring.GeneralDatabaseError, ctrl)
+		return documentCopy, errorTypes.NewGeneralDatabaseError(fmt.Sprintf("failed to copy %T", document))
+	}
+	return documentCopy, nil
+}
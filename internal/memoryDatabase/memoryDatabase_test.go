@@ -0,0 +1,196 @@
+package memoryDatabase
+
+import (
+	"context"
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+)
+
+func newTestCtrl() *dataTypes.FlowControl {
+	return &dataTypes.FlowControl{Ctx: context.Background(), StopOnTooManyErrorsChannel: make(chan struct{}, 1)}
+}
+
+func TestEnqueueDeviceBatch(t *testing.T) {
+	ctrl := newTestCtrl()
+	mdb := NewMemoryDatabase(2, nil)
+	deviceNamesAndLinks := map[string][]string{
+		"Galaxy S24": {"galaxy-s24-detail", "galaxy-s24-image"},
+		"Pixel 8":    {"pixel-8-detail", "pixel-8-image"},
+		"iPhone 15":  {"iphone-15-detail", "iphone-15-image"},
+	}
+	links := maps.Clone(deviceNamesAndLinks)
+
+	enqueued, err := mdb.EnqueueDeviceBatch(deviceNamesAndLinks, ctrl)
+	if err != nil {
+		t.Fatalf("failed to enqueue batch: %v", err)
+	}
+	if len(enqueued) != 2 {
+		t.Fatalf("enqueued %v, expected the 2 devices the queue has room for", enqueued)
+	}
+	enqueued, err = mdb.EnqueueDeviceBatch(map[string][]string{"Galaxy A55": {"galaxy-a55-detail", "galaxy-a55-image"}}, ctrl)
+	if err != nil || len(enqueued) != 0 {
+		t.Fatalf("enqueued %v with error %v into a full queue, expected nothing", enqueued, err)
+	}
+
+	for range 2 {
+		deviceInQueue, err := mdb.Dequeue(ctrl)
+		if err != nil {
+			t.Fatalf("failed to dequeue: %v", err)
+		}
+		deviceLinks := links[deviceInQueue.Name]
+		if deviceInQueue.Detail != deviceLinks[dataTypes.DetailLink] || deviceInQueue.Image != deviceLinks[dataTypes.ImageLink] {
+			t.Errorf("device %v was queued with detail %q and image %q, expected %v", deviceInQueue.Name,
+				deviceInQueue.Detail, deviceInQueue.Image, deviceLinks)
+		}
+	}
+	if _, err = mdb.Dequeue(ctrl); !errorTypes.IsMissingDocumentError(err) {
+		t.Errorf("dequeued from an empty queue with error %v, expected a missing document error", err)
+	}
+}
+
+func TestDequeueOrder(t *testing.T) {
+	ctrl := newTestCtrl()
+	mdb := NewMemoryDatabase(1, nil)
+	queue := []dataTypes.DeviceInQueue{
+		{Name: "Galaxy S24", Priority: dataTypes.NormalQueuePriority},
+		{Name: "Pixel 8", Priority: dataTypes.NormalQueuePriority},
+		{Name: "iPhone 15", Priority: dataTypes.TopQueuePriority},
+		// Raising the priority of a queued device keeps its place in the queue.
+		{Name: "Pixel 8", Priority: dataTypes.TopQueuePriority},
+	}
+	for _, deviceInQueue := range queue {
+		if err := mdb.EnqueueDevice(deviceInQueue, ctrl); err != nil {
+			t.Fatalf("failed to enqueue %v past the maximum queue size: %v", deviceInQueue.Name, err)
+		}
+	}
+
+	var dequeued []string
+	for range 3 {
+		deviceInQueue, err := mdb.Dequeue(ctrl)
+		if err != nil {
+			t.Fatalf("failed to dequeue: %v", err)
+		}
+		dequeued = append(dequeued, deviceInQueue.Name)
+	}
+	if expected := []string{"Pixel 8", "iPhone 15", "Galaxy S24"}; !slices.Equal(dequeued, expected) {
+		t.Errorf("dequeued %v, expected %v", dequeued, expected)
+	}
+}
+
+func TestEnqueueQueuedDevice(t *testing.T) {
+	ctrl := newTestCtrl()
+	mdb := NewMemoryDatabase(10, nil)
+	err := mdb.EnqueueDevice(dataTypes.DeviceInQueue{Name: "Pixel 8", Priority: dataTypes.TopQueuePriority, JobID: "first-job"}, ctrl)
+	if err != nil {
+		t.Fatalf("failed to enqueue: %v", err)
+	}
+
+	err = mdb.EnqueueDevice(dataTypes.DeviceInQueue{Name: "Pixel 8", Priority: dataTypes.TopQueuePriority, JobID: "second-job"}, ctrl)
+	if !errorTypes.IsDeviceAlreadyQueuedError(err) {
+		t.Errorf("enqueued a device queued by another job with error %v, expected a device already queued error", err)
+	}
+	if err = mdb.EnqueueDevice(dataTypes.DeviceInQueue{Name: "Pixel 8", Priority: dataTypes.NormalQueuePriority}, ctrl); err != nil {
+		t.Fatalf("failed to enqueue the queued device again: %v", err)
+	}
+
+	deviceInQueue, err := mdb.Dequeue(ctrl)
+	if err != nil {
+		t.Fatalf("failed to dequeue: %v", err)
+	}
+	if deviceInQueue.Priority != dataTypes.TopQueuePriority || deviceInQueue.JobID != "first-job" {
+		t.Errorf("dequeued priority %v and job %q, expected the first job's top priority", deviceInQueue.Priority, deviceInQueue.JobID)
+	}
+}
+
+func TestEnqueueStoredDevice(t *testing.T) {
+	ctrl := newTestCtrl()
+	mdb := NewMemoryDatabase(10, nil)
+	device := newTestDevice("Pixel 8", 0.5)
+	if err := mdb.UploadDevice(&device, newTestMinMax(), ctrl); err != nil {
+		t.Fatalf("failed to upload device: %v", err)
+	}
+
+	err := mdb.EnqueueDevice(dataTypes.DeviceInQueue{Name: "Pixel 8"}, ctrl)
+	if !errorTypes.IsDeviceAlreadyExistsError(err) {
+		t.Errorf("enqueued a stored device with error %v, expected a device already exists error", err)
+	}
+	enqueued, err := mdb.EnqueueDeviceBatch(map[string][]string{"Pixel 8": {"pixel-8-detail", "pixel-8-image"}}, ctrl)
+	if err != nil || len(enqueued) != 0 {
+		t.Errorf("enqueued %v with error %v, expected the stored device to be skipped", enqueued, err)
+	}
+	err = mdb.EnqueueDevice(dataTypes.DeviceInQueue{Name: "Pixel 8", DeviceID: device.ID, RefreshFields: []string{dataTypes.PriceRefresh}}, ctrl)
+	if err != nil {
+		t.Errorf("failed to enqueue a refresh of the stored device: %v", err)
+	}
+}
+
+func TestValidation(t *testing.T) {
+	ctrl := newTestCtrl()
+	mdb := NewMemoryDatabase(10, nil)
+	minMax := newTestMinMax()
+
+	for i, name := range []string{"Galaxy S24", "Pixel 8"} {
+		device := newTestDevice(name, float64(i))
+		if err := mdb.UploadDevice(&device, minMax, ctrl); err != nil {
+			t.Fatalf("failed to upload %v: %v", name, err)
+		}
+	}
+
+	minMaxValues, err := mdb.GetValidatedAndUnvalidatedMinMaxValues(ctrl)
+	if err != nil {
+		t.Fatalf("failed to get min-max values: %v", err)
+	}
+	if minMaxValues.Validated != minMax || minMaxValues.Unvalidated != minMax {
+		t.Errorf("min-max values are %+v, expected both to be the uploaded %+v", minMaxValues, minMax)
+	}
+
+	devices, err := mdb.GetAllDevices(ctrl)
+	if err != nil {
+		t.Fatalf("failed to get devices: %v", err)
+	}
+	if len(devices) != 2 {
+		t.Fatalf("got %d devices, expected 2", len(devices))
+	}
+	for _, device := range devices {
+		if device.Review.ValidatedReviewScore != device.Review.UnvalidatedReviewScore {
+			t.Errorf("device %v has validated review score %v, expected its unvalidated %v", device.Name,
+				device.Review.ValidatedReviewScore, device.Review.UnvalidatedReviewScore)
+		}
+		if device.ValidatedFinalScore != device.UnvalidatedFinalScore {
+			t.Errorf("device %v has validated final score %v, expected its unvalidated %v", device.Name,
+				device.ValidatedFinalScore, device.UnvalidatedFinalScore)
+		}
+	}
+
+	isInterrupted, err := mdb.IsInterruptedValidation(ctrl)
+	if err != nil || isInterrupted {
+		t.Errorf("finished validation reported as interrupted: %v, %v", isInterrupted, err)
+	}
+
+	cancelledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = mdb.ValidateScores(minMax, &dataTypes.FlowControl{Ctx: cancelledCtx, StopOnTooManyErrorsChannel: ctrl.StopOnTooManyErrorsChannel})
+	if err == nil {
+		t.Error("validated with a cancelled context, expected an error")
+	}
+}
+
+func newTestDevice(name string, reviewScore float64) dataTypes.Device {
+	return dataTypes.Device{
+		Name:   name,
+		Specs:  dataTypes.Specifications{ReleaseDate: time.Date(2024, time.January, 17, 0, 0, 0, 0, time.UTC)},
+		Review: dataTypes.ReviewData{UnvalidatedReviewScore: reviewScore},
+	}
+}
+
+func newTestMinMax() dataTypes.MinMaxValues {
+	return dataTypes.MinMaxValues{
+		SingleCoreScore: dataTypes.MinMaxFloat{Min: 1000, Max: 2500},
+		MultiCoreScore:  dataTypes.MinMaxFloat{Min: 3000, Max: 7000},
+		BatteryCapacity: dataTypes.MinMaxFloat{Min: 3000, Max: 5500},
+	}
+}
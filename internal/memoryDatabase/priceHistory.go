@@ -0,0 +1,56 @@
+package memoryDatabase
+
+import (
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"log"
+	"slices"
+	"sort"
+	"time"
+)
+
+// GetPriceHistory returns the prices of a device observed since the given time, oldest first.
+func (mdb *MemoryDatabase) GetPriceHistory(deviceID primitive.ObjectID, since time.Time, ctrl *dataTypes.FlowControl) ([]dataTypes.PricePoint, error) {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping memoryDatabase.GetPriceHistory: %v", ctrl.Ctx.Err())
+		return nil, ctrl.Ctx.Err()
+	}
+
+	mdb.mutex.RLock()
+	defer mdb.mutex.RUnlock()
+	var prices []dataTypes.PricePoint
+	for _, pricePoint := range mdb.priceHistory {
+		if pricePoint.DeviceID == deviceID && !pricePoint.Time.Before(since) {
+			prices = append(prices, pricePoint)
+		}
+	}
+	sort.SliceStable(prices, func(a, b int) bool {
+		return prices[a].Time.Before(prices[b].Time)
+	})
+	return prices, nil
+}
+
+// GetLowestPrices returns, per device, the lowest price observed since the given time and the number of observations.
+// Devices without observations in that window are missing from the result.
+func (mdb *MemoryDatabase) GetLowestPrices(deviceIDs []primitive.ObjectID, since time.Time, ctrl *dataTypes.FlowControl) (map[primitive.ObjectID]dataTypes.LowestPrice, error) {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping memoryDatabase.GetLowestPrices: %v", ctrl.Ctx.Err())
+		return nil, ctrl.Ctx.Err()
+	}
+
+	mdb.mutex.RLock()
+	defer mdb.mutex.RUnlock()
+	lowestPrices := make(map[primitive.ObjectID]dataTypes.LowestPrice)
+	for _, pricePoint := range mdb.priceHistory {
+		if !slices.Contains(deviceIDs, pricePoint.DeviceID) || pricePoint.Time.Before(since) {
+			continue
+		}
+		lowestPrice, ok := lowestPrices[pricePoint.DeviceID]
+		if !ok || pricePoint.Price < lowestPrice.Price {
+			lowestPrice.Price = pricePoint.Price
+		}
+		lowestPrice.Observations++
+		lowestPrices[pricePoint.DeviceID] = lowestPrice
+	}
+	return lowestPrices, nil
+}
@@ -0,0 +1,159 @@
+package memoryDatabase
+
+import (
+	"fmt"
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/helpers"
+	"log"
+	"slices"
+)
+
+func (mdb *MemoryDatabase) EnqueueDeviceBatch(deviceNamesAndLinks map[string][]string, ctrl *dataTypes.FlowControl) ([]string, error) {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping memoryDatabase.EnqueueDeviceBatch: %v", ctrl.Ctx.Err())
+		return nil, ctrl.Ctx.Err()
+	}
+
+	mdb.mutex.Lock()
+	defer mdb.mutex.Unlock()
+	remainingSpace := mdb.maxQueueSize - len(mdb.queue)
+	if remainingSpace <= 0 {
+		return nil, nil
+	}
+	for _, deviceID := range mdb.deviceIDs {
+		delete(deviceNamesAndLinks, mdb.devices[deviceID].Name)
+	}
+	for _, deviceInQueue := range mdb.queue {
+		delete(deviceNamesAndLinks, deviceInQueue.Name)
+	}
+	deviceSubset := helpers.GetSubMap(deviceNamesAndLinks, remainingSpace)
+
+	if len(deviceSubset) == 0 {
+		return nil, nil
+	}
+
+	tx := mdb.begin()
+	for deviceName, detailAndImage := range deviceSubset {
+		tx.enqueue(dataTypes.DeviceInQueue{Name: deviceName, Detail: detailAndImage[dataTypes.DetailLink], Image: detailAndImage[dataTypes.ImageLink]})
+	}
+	err := tx.commit(ctrl)
+	if err != nil {
+		log.Printf("in memoryDatabase.EnqueueDeviceBatch failed to enqueue: %v", err)
+		return nil, err
+	}
+	enqueuedDeviceNames := helpers.GetKeys(deviceSubset)
+	log.Printf("successfully enqueued %v", enqueuedDeviceNames)
+	return enqueuedDeviceNames, nil
+}
+
+// EnqueueDevice adds a single device to the queue regardless of the maximum queue size, or raises the priority
+// of the device if it is already queued. A device with a DeviceID is a refresh of a stored device,
+// its refresh fields are merged into those of an already queued refresh. A device already queued by an ingestion
+// job can't be queued by another one.
+func (mdb *MemoryDatabase) EnqueueDevice(deviceInQueue dataTypes.DeviceInQueue, ctrl *dataTypes.FlowControl) error {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping memoryDatabase.EnqueueDevice: %v", ctrl.Ctx.Err())
+		return ctrl.Ctx.Err()
+	}
+
+	mdb.mutex.Lock()
+	defer mdb.mutex.Unlock()
+	isRefresh := !deviceInQueue.DeviceID.IsZero()
+	if !isRefresh && mdb.isStoredDevice(deviceInQueue.Name) {
+		return errorTypes.NewDeviceAlreadyExistsError(fmt.Sprintf("in memoryDatabase.EnqueueDevice device %v already exists", deviceInQueue.Name))
+	}
+
+	tx := mdb.begin()
+	for i := range mdb.queue {
+		queued := mdb.queue[i]
+		if queued.Name != deviceInQueue.Name {
+			continue
+		}
+		if queued.JobID != "" && deviceInQueue.JobID != "" {
+			return errorTypes.NewDeviceAlreadyQueuedError(fmt.Sprintf("in memoryDatabase.EnqueueDevice device %v is already queued by job %v", deviceInQueue.Name, queued.JobID))
+		}
+		queued.Priority = max(queued.Priority, deviceInQueue.Priority)
+		if deviceInQueue.JobID != "" {
+			queued.JobID = deviceInQueue.JobID
+		}
+		if isRefresh {
+			queued.DeviceID = deviceInQueue.DeviceID
+			queued.RefreshFields = slices.Clone(queued.RefreshFields)
+			for _, refreshField := range deviceInQueue.RefreshFields {
+				if !slices.Contains(queued.RefreshFields, refreshField) {
+					queued.RefreshFields = append(queued.RefreshFields, refreshField)
+				}
+			}
+		}
+		tx.replaceQueued(i, queued)
+		if err := tx.commit(ctrl); err != nil {
+			log.Printf("in memoryDatabase.EnqueueDevice failed to raise priority of queued device %v: %v", deviceInQueue.Name, err)
+			return err
+		}
+		log.Printf("in memoryDatabase.EnqueueDevice raised priority of queued device %v", deviceInQueue.Name)
+		return nil
+	}
+
+	deviceInQueue.RefreshFields = slices.Clone(deviceInQueue.RefreshFields)
+	tx.enqueue(deviceInQueue)
+	if err := tx.commit(ctrl); err != nil {
+		log.Printf("in memoryDatabase.EnqueueDevice failed to enqueue %v: %v", deviceInQueue.Name, err)
+		return err
+	}
+	log.Printf("successfully enqueued %v with priority %v", deviceInQueue.Name, deviceInQueue.Priority)
+	return nil
+}
+
+// Dequeue removes the highest priority device from the queue, the earliest enqueued among equals.
+func (mdb *MemoryDatabase) Dequeue(ctrl *dataTypes.FlowControl) (dataTypes.DeviceInQueue, error) {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping memoryDatabase.Dequeue: %v", ctrl.Ctx.Err())
+		return dataTypes.DeviceInQueue{}, ctrl.Ctx.Err()
+	}
+
+	mdb.mutex.Lock()
+	defer mdb.mutex.Unlock()
+	if len(mdb.queue) == 0 {
+		log.Println("in memoryDatabase.Dequeue failed to dequeue: queue is empty")
+		return dataTypes.DeviceInQueue{}, errorTypes.NewMissingDocumentError("in memoryDatabase.Dequeue failed to dequeue: queue is empty")
+	}
+
+	highest := 0
+	for i, deviceInQueue := range mdb.queue {
+		if deviceInQueue.Priority > mdb.queue[highest].Priority {
+			highest = i
+		}
+	}
+	result := mdb.queue[highest]
+	tx := mdb.begin()
+	tx.removeQueued(highest)
+	if err := tx.commit(ctrl); err != nil {
+		log.Printf("in memoryDatabase.Dequeue failed to dequeue %v: %v", result.Name, err)
+		return dataTypes.DeviceInQueue{}, err
+	}
+
+	log.Printf("in memoryDatabase.Dequeue successfully dequeued %v", result.Name)
+	return result, nil
+}
+
+func (mdb *MemoryDatabase) IsStoredDevice(deviceName string, ctrl *dataTypes.FlowControl) (bool, error) {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping memoryDatabase.IsStoredDevice: %v", ctrl.Ctx.Err())
+		return false, ctrl.Ctx.Err()
+	}
+
+	mdb.mutex.RLock()
+	defer mdb.mutex.RUnlock()
+	return mdb.isStoredDevice(deviceName), nil
+}
+
+// isStoredDevice is IsStoredDevice for callers that hold the mutex.
+func (mdb *MemoryDatabase) isStoredDevice(deviceName string) bool {
+	for _, device := range mdb.devices {
+		if device.Name == deviceName {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package memoryDatabase
+
+import (
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/aiAnalysis"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/helpers"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// GetTop3 matches the same filters as the Mongo backend's GetTop3.
+func (mdb *MemoryDatabase) GetTop3(filters *dataTypes.Filters, ctrl *dataTypes.FlowControl) ([]dataTypes.Device, error) {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping memoryDatabase.GetTop3: %v", ctrl.Ctx.Err())
+		return nil, ctrl.Ctx.Err()
+	}
+
+	mdb.mutex.RLock()
+	allDevices, err := mdb.getAllDevices(ctrl)
+	mdb.mutex.RUnlock()
+	if err != nil {
+		log.Printf("in memoryDatabase.GetTop3 failed to get all devices: %v", err)
+		return nil, err
+	}
+
+	isVariantSearch := helpers.IsVariantSearch(filters)
+	var results []dataTypes.Device
+	for _, device := range allDevices {
+		if !matchesDeviceFilters(&device, filters) {
+			continue
+		}
+		if !isVariantSearch {
+			if matchesPrice(device.RealPrice, device.Prices, filters) {
+				results = append(results, device)
+			}
+			continue
+		}
+		for _, variant := range device.Variants {
+			if matchesVariantFilters(&variant, filters) {
+				variantDevice := device
+				variantDevice.Variants = []dataTypes.Variant{variant}
+				results = append(results, variantDevice)
+			}
+		}
+	}
+
+	if isVariantSearch && filters.SortByValue {
+		return aiAnalysis.TopByValue(results, filters), nil
+	}
+	sort.SliceStable(results, func(a, b int) bool {
+		return results[a].ValidatedFinalScore > results[b].ValidatedFinalScore
+	})
+	return results[:min(3, len(results))], nil
+}
+
+// matchesDeviceFilters checks every filter but the price and the variant filters.
+func matchesDeviceFilters(device *dataTypes.Device, filters *dataTypes.Filters) bool {
+	specs := &device.Specs
+	if !slices.Contains(filters.Brands, device.Brand) ||
+		specs.DisplaySize < filters.DisplaySize.Min || specs.DisplaySize > filters.DisplaySize.Max ||
+		specs.RefreshRate < filters.RefreshRate.Min || specs.RefreshRate > filters.RefreshRate.Max {
+		return false
+	}
+
+	if filters.Chipset != "" && !strings.Contains(strings.ToLower(specs.Chipset), strings.ToLower(filters.Chipset)) {
+		return false
+	}
+	for value, floatRange := range map[*float64]dataTypes.MinMaxFloat{
+		&specs.WeightGrams:            filters.Weight,
+		&specs.Dimensions.HeightMM:    filters.Height,
+		&specs.Dimensions.WidthMM:     filters.Width,
+		&specs.Dimensions.ThicknessMM: filters.Thickness,
+		&specs.WiredChargingW:         filters.WiredCharging,
+		&specs.WirelessChargingW:      filters.WirelessCharging,
+		&specs.OSVersion:              filters.OSVersion,
+	} {
+		if floatRange.Max > 0 && (*value < floatRange.Min || *value > floatRange.Max) {
+			return false
+		}
+	}
+	if len(filters.IPRatings) > 0 && !slices.ContainsFunc(filters.IPRatings, func(ipRating string) bool {
+		return strings.ToUpper(ipRating) == specs.IPRating
+	}) {
+		return false
+	}
+	if filters.OS != "" && !strings.EqualFold(specs.OS, filters.OS) {
+		return false
+	}
+	return specs.MajorOSUpgrades >= filters.MinOSUpgrades && (!filters.Requires5G || specs.Has5G)
+}
+
+func matchesVariantFilters(variant *dataTypes.Variant, filters *dataTypes.Filters) bool {
+	if filters.Storage.Max > 0 && (variant.StorageGB < filters.Storage.Min || variant.StorageGB > filters.Storage.Max) {
+		return false
+	}
+	if filters.RAM.Max > 0 && (variant.RAMGB < filters.RAM.Min || variant.RAMGB > filters.RAM.Max) {
+		return false
+	}
+	return matchesPrice(variant.RealPrice, variant.Prices, filters)
+}
+
+// matchesPrice checks the price range against the price in the filters' market, or against realPrice when no
+// market is set.
+func matchesPrice(realPrice int, prices []dataTypes.MarketPrice, filters *dataTypes.Filters) bool {
+	isInRange := func(price int) bool {
+		return filters.Price.Min <= price && price <= filters.Price.Max
+	}
+	if filters.Market == "" {
+		return isInRange(realPrice)
+	}
+	market := strings.ToUpper(filters.Market)
+	return slices.ContainsFunc(prices, func(price dataTypes.MarketPrice) bool {
+		return price.Market == market && isInRange(price.Price)
+	})
+}
+
+// textSearch returns the devices of the year whose name shares words with modelName, best match first. Like Mongo's
+// text search it matches any of the words, case-insensitively; a device ranks by how many of them its name
+// contains, then by how much of its name they make up. The caller must hold the mutex.
+func (mdb *MemoryDatabase) textSearch(modelName string, yearID primitive.ObjectID) []dataTypes.Device {
+	searchWords := getWords(modelName)
+	type match struct {
+		device   dataTypes.Device
+		words    int
+		coverage float64
+	}
+	var matches []match
+	for _, deviceID := range mdb.deviceIDs {
+		device := mdb.devices[deviceID]
+		if device.Year != yearID {
+			continue
+		}
+		nameWords := getWords(device.Name)
+		matchingWords := 0
+		for _, word := range searchWords {
+			if slices.Contains(nameWords, word) {
+				matchingWords++
+			}
+		}
+		if matchingWords > 0 {
+			matches = append(matches, match{device: device, words: matchingWords, coverage: float64(matchingWords) / float64(len(nameWords))})
+		}
+	}
+
+	sort.SliceStable(matches, func(a, b int) bool {
+		if matches[a].words != matches[b].words {
+			return matches[a].words > matches[b].words
+		}
+		return matches[a].coverage > matches[b].coverage
+	})
+	devices := make([]dataTypes.Device, 0, len(matches))
+	for _, match := range matches {
+		devices = append(devices, match.device)
+	}
+	return devices
+}
+
+// getWords splits text into its distinct lowercase words.
+func getWords(text string) []string {
+	var words []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if !slices.Contains(words, word) {
+			words = append(words, word)
+		}
+	}
+	return words
+}
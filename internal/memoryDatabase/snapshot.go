@@ -0,0 +1,71 @@
+package memoryDatabase
+
+import (
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"log"
+	"slices"
+	"strings"
+)
+
+// ExportSnapshot returns a copy of the whole database, with the devices and years in the order they were stored.
+func (mdb *MemoryDatabase) ExportSnapshot(ctrl *dataTypes.FlowControl) (dataTypes.DatabaseSnapshot, error) {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping memoryDatabase.ExportSnapshot: %v", ctrl.Ctx.Err())
+		return dataTypes.DatabaseSnapshot{}, ctrl.Ctx.Err()
+	}
+
+	mdb.mutex.RLock()
+	snapshot := dataTypes.DatabaseSnapshot{
+		MinMax:                  mdb.minMax,
+		Queue:                   mdb.queue,
+		PriceHistory:            mdb.priceHistory,
+		AlertSubscriptions:      mdb.alertSubscriptions,
+		IsInterruptedValidation: mdb.unfinishedValidations > 0,
+	}
+	for _, deviceID := range mdb.deviceIDs {
+		snapshot.Devices = append(snapshot.Devices, mdb.devices[deviceID])
+	}
+	for _, yearID := range mdb.yearIDs {
+		year := mdb.years[yearID]
+		snapshot.Years = append(snapshot.Years, year)
+		for _, monthID := range year.Months {
+			snapshot.Months = append(snapshot.Months, mdb.months[monthID])
+		}
+	}
+	for _, catalog := range mdb.benchmarkCatalogs {
+		snapshot.BenchmarkCatalogs = append(snapshot.BenchmarkCatalogs, catalog)
+	}
+	// Copy while holding the mutex, the snapshot still shares the stored slices.
+	snapshotCopy, err := copyDocument(snapshot, ctrl)
+	mdb.mutex.RUnlock()
+	if err != nil {
+		log.Printf("in memoryDatabase.ExportSnapshot failed to copy database: %v", err)
+		return dataTypes.DatabaseSnapshot{}, err
+	}
+
+	slices.SortFunc(snapshotCopy.BenchmarkCatalogs, func(a, b dataTypes.BenchmarkCatalog) int {
+		return strings.Compare(a.Platform, b.Platform)
+	})
+	return snapshotCopy, nil
+}
+
+// ImportSnapshot replaces the whole database with a copy of the snapshot. Missing min-max values are reset to the
+// defaults, and price points and alert subscriptions without IDs are given new ones.
+func (mdb *MemoryDatabase) ImportSnapshot(snapshot *dataTypes.DatabaseSnapshot, ctrl *dataTypes.FlowControl) error {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping memoryDatabase.ImportSnapshot: %v", ctrl.Ctx.Err())
+		return ctrl.Ctx.Err()
+	}
+
+	snapshotCopy, err := copyDocument(*snapshot, ctrl)
+	if err != nil {
+		log.Printf("in memoryDatabase.ImportSnapshot failed to copy snapshot: %v", err)
+		return err
+	}
+
+	mdb.mutex.Lock()
+	defer mdb.mutex.Unlock()
+	tx := mdb.begin()
+	tx.replaceAll(&snapshotCopy)
+	return tx.commit(ctrl)
+}
@@ -0,0 +1,380 @@
+package memoryDatabase
+
+import (
+	"encoding/binary"
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/benchmarkEstimation"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"log"
+)
+
+// The collections changes are made to, named as the Mongo collections, and the keys of the metadata documents.
+const (
+	DevicesCollection            = "devices"
+	YearsCollection              = "years"
+	MonthsCollection             = "months"
+	QueueCollection              = "queue"
+	PriceHistoryCollection       = "price_history"
+	AlertSubscriptionsCollection = "alert_subscriptions"
+	BenchmarkCatalogCollection   = "benchmark_catalog"
+	MetadataCollection           = "metadata"
+	MinMaxKey                    = "min-max"
+	ValidationFlagKey            = "validation-flag"
+)
+
+// Collections lists every collection changes are made to.
+var Collections = []string{
+	DevicesCollection,
+	YearsCollection,
+	MonthsCollection,
+	QueueCollection,
+	PriceHistoryCollection,
+	AlertSubscriptionsCollection,
+	BenchmarkCatalogCollection,
+	MetadataCollection,
+}
+
+// Change is one write of an operation: Document stored under Key in Collection, or Key deleted when Document is nil.
+// A nil Key empties the whole collection.
+type Change struct {
+	Collection string
+	Key        []byte
+	Document   interface{}
+}
+
+// Committer persists the changes of one operation, all of them or none. It is called while the database is locked,
+// before the changes are visible, so it must not call back into the database.
+type Committer func(changes []Change, ctrl *dataTypes.FlowControl) error
+
+// SetCommitter makes every operation hand its changes to committer. If committer fails, the operation is undone and
+// returns the error, so the database never shows changes that weren't persisted.
+func (mdb *MemoryDatabase) SetCommitter(committer Committer) {
+	mdb.mutex.Lock()
+	defer mdb.mutex.Unlock()
+	mdb.committer = committer
+}
+
+// LoadSnapshot replaces the whole database with a copy of the snapshot, like ImportSnapshot, without handing the
+// changes to the committer. Loading numbers the queue anew, so it returns the changes that store the queue under its
+// new keys, for a committer loading what it persisted.
+func (mdb *MemoryDatabase) LoadSnapshot(snapshot *dataTypes.DatabaseSnapshot, ctrl *dataTypes.FlowControl) ([]Change, error) {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping memoryDatabase.LoadSnapshot: %v", ctrl.Ctx.Err())
+		return nil, ctrl.Ctx.Err()
+	}
+
+	snapshotCopy, err := copyDocument(*snapshot, ctrl)
+	if err != nil {
+		log.Printf("in memoryDatabase.LoadSnapshot failed to copy snapshot: %v", err)
+		return nil, err
+	}
+
+	mdb.mutex.Lock()
+	defer mdb.mutex.Unlock()
+	mdb.load(&snapshotCopy)
+	return mdb.queueChanges(), nil
+}
+
+// transaction collects the changes of one operation, with the steps that undo them. The caller must hold the mutex
+// from the first change until commit returns.
+type transaction struct {
+	mdb     *MemoryDatabase
+	changes []Change
+	undo    []func()
+}
+
+func (mdb *MemoryDatabase) begin() *transaction {
+	return &transaction{mdb: mdb}
+}
+
+// record adds the changes a step made, with the step that undoes it.
+func (tx *transaction) record(undo func(), changes ...Change) {
+	tx.changes = append(tx.changes, changes...)
+	tx.undo = append(tx.undo, undo)
+}
+
+// commit hands the changes to the committer, and undoes them if it fails.
+func (tx *transaction) commit(ctrl *dataTypes.FlowControl) error {
+	if tx.mdb.committer == nil || len(tx.changes) == 0 {
+		return nil
+	}
+
+	err := tx.mdb.committer(tx.changes, ctrl)
+	if err != nil {
+		log.Printf("in memoryDatabase.commit failed to commit %v changes, undoing them: %v", len(tx.changes), err)
+		for i := len(tx.undo) - 1; i >= 0; i-- {
+			tx.undo[i]()
+		}
+		return err
+	}
+	return nil
+}
+
+func (tx *transaction) putDevice(device dataTypes.Device) {
+	mdb := tx.mdb
+	previous, isStored := mdb.devices[device.ID]
+	mdb.devices[device.ID] = device
+	if !isStored {
+		mdb.deviceIDs = append(mdb.deviceIDs, device.ID)
+	}
+	var previousDevice *dataTypes.Device
+	if isStored {
+		previousDevice = &previous
+	}
+	changesModel := benchmarkEstimation.ChangesModel(previousDevice, &device)
+	if changesModel {
+		mdb.estimator.InvalidateModel()
+	}
+	tx.record(func() {
+		if changesModel {
+			mdb.estimator.InvalidateModel()
+		}
+		if isStored {
+			mdb.devices[device.ID] = previous
+			return
+		}
+		delete(mdb.devices, device.ID)
+		mdb.deviceIDs = mdb.deviceIDs[:len(mdb.deviceIDs)-1]
+	}, Change{Collection: DevicesCollection, Key: objectIDKey(device.ID), Document: device})
+}
+
+func (tx *transaction) putYear(year dataTypes.Year) {
+	mdb := tx.mdb
+	previous, isStored := mdb.years[year.ID]
+	mdb.years[year.ID] = year
+	if !isStored {
+		mdb.yearIDs = append(mdb.yearIDs, year.ID)
+	}
+	tx.record(func() {
+		if isStored {
+			mdb.years[year.ID] = previous
+			return
+		}
+		delete(mdb.years, year.ID)
+		mdb.yearIDs = mdb.yearIDs[:len(mdb.yearIDs)-1]
+	}, Change{Collection: YearsCollection, Key: objectIDKey(year.ID), Document: year})
+}
+
+func (tx *transaction) putMonth(month dataTypes.Month) {
+	mdb := tx.mdb
+	previous, isStored := mdb.months[month.ID]
+	mdb.months[month.ID] = month
+	tx.record(func() {
+		if isStored {
+			mdb.months[month.ID] = previous
+			return
+		}
+		delete(mdb.months, month.ID)
+	}, Change{Collection: MonthsCollection, Key: objectIDKey(month.ID), Document: month})
+}
+
+func (tx *transaction) setMinMax(minMax dataTypes.ValidatedAndUnvalidatedMinMaxValues) {
+	mdb := tx.mdb
+	previous := mdb.minMax
+	mdb.minMax = minMax
+	tx.record(func() {
+		mdb.minMax = previous
+	}, Change{Collection: MetadataCollection, Key: []byte(MinMaxKey), Document: minMax})
+}
+
+func (tx *transaction) setUnfinishedValidations(unfinishedValidations int) {
+	mdb := tx.mdb
+	previous := mdb.unfinishedValidations
+	mdb.unfinishedValidations = unfinishedValidations
+	validationFlag := dataTypes.ValidationFlag{IsUnfinishedValidation: unfinishedValidations > 0}
+	tx.record(func() {
+		mdb.unfinishedValidations = previous
+	}, Change{Collection: MetadataCollection, Key: []byte(ValidationFlagKey), Document: validationFlag})
+}
+
+func (tx *transaction) addPricePoint(pricePoint dataTypes.PricePoint) {
+	mdb := tx.mdb
+	mdb.priceHistory = append(mdb.priceHistory, pricePoint)
+	tx.record(func() {
+		mdb.priceHistory = mdb.priceHistory[:len(mdb.priceHistory)-1]
+	}, Change{Collection: PriceHistoryCollection, Key: objectIDKey(pricePoint.ID), Document: pricePoint})
+}
+
+func (tx *transaction) addAlertSubscription(subscription dataTypes.AlertSubscription) {
+	mdb := tx.mdb
+	mdb.alertSubscriptions = append(mdb.alertSubscriptions, subscription)
+	tx.record(func() {
+		mdb.alertSubscriptions = mdb.alertSubscriptions[:len(mdb.alertSubscriptions)-1]
+	}, Change{Collection: AlertSubscriptionsCollection, Key: objectIDKey(subscription.ID), Document: subscription})
+}
+
+func (tx *transaction) putBenchmarkCatalog(catalog dataTypes.BenchmarkCatalog) {
+	mdb := tx.mdb
+	previous, isStored := mdb.benchmarkCatalogs[catalog.Platform]
+	mdb.benchmarkCatalogs[catalog.Platform] = catalog
+	tx.record(func() {
+		if isStored {
+			mdb.benchmarkCatalogs[catalog.Platform] = previous
+			return
+		}
+		delete(mdb.benchmarkCatalogs, catalog.Platform)
+	}, Change{Collection: BenchmarkCatalogCollection, Key: []byte(catalog.Platform), Document: catalog})
+}
+
+// enqueue adds the device to the end of the queue, under a new key.
+func (tx *transaction) enqueue(deviceInQueue dataTypes.DeviceInQueue) {
+	mdb := tx.mdb
+	key := mdb.nextQueueKey
+	mdb.nextQueueKey++
+	mdb.queue = append(mdb.queue, deviceInQueue)
+	mdb.queueKeys = append(mdb.queueKeys, key)
+	tx.record(func() {
+		mdb.queue = mdb.queue[:len(mdb.queue)-1]
+		mdb.queueKeys = mdb.queueKeys[:len(mdb.queueKeys)-1]
+		mdb.nextQueueKey--
+	}, Change{Collection: QueueCollection, Key: queueKey(key), Document: deviceInQueue})
+}
+
+// replaceQueued replaces the i-th queued device in place, keeping its key.
+func (tx *transaction) replaceQueued(i int, deviceInQueue dataTypes.DeviceInQueue) {
+	mdb := tx.mdb
+	previous := mdb.queue[i]
+	mdb.queue[i] = deviceInQueue
+	tx.record(func() {
+		mdb.queue[i] = previous
+	}, Change{Collection: QueueCollection, Key: queueKey(mdb.queueKeys[i]), Document: deviceInQueue})
+}
+
+// removeQueued removes the i-th queued device.
+func (tx *transaction) removeQueued(i int) {
+	mdb := tx.mdb
+	previous, key := mdb.queue[i], mdb.queueKeys[i]
+	mdb.queue = append(mdb.queue[:i:i], mdb.queue[i+1:]...)
+	mdb.queueKeys = append(mdb.queueKeys[:i:i], mdb.queueKeys[i+1:]...)
+	tx.record(func() {
+		mdb.queue = append(mdb.queue[:i:i], append([]dataTypes.DeviceInQueue{previous}, mdb.queue[i:]...)...)
+		mdb.queueKeys = append(mdb.queueKeys[:i:i], append([]uint64{key}, mdb.queueKeys[i:]...)...)
+	}, Change{Collection: QueueCollection, Key: queueKey(key)})
+}
+
+// reset resets what ResetDatabase resets, see MemoryDatabase.reset.
+func (tx *transaction) reset() {
+	mdb := tx.mdb
+	previous := mdb.contents
+	mdb.reset()
+	tx.record(func() {
+		mdb.contents = previous
+		mdb.estimator.InvalidateModel()
+	},
+		Change{Collection: DevicesCollection},
+		Change{Collection: YearsCollection},
+		Change{Collection: MonthsCollection},
+		Change{Collection: PriceHistoryCollection},
+		Change{Collection: MetadataCollection, Key: []byte(MinMaxKey), Document: mdb.minMax},
+	)
+}
+
+// replaceAll replaces the whole database with the snapshot.
+func (tx *transaction) replaceAll(snapshot *dataTypes.DatabaseSnapshot) {
+	mdb := tx.mdb
+	previous := mdb.contents
+	mdb.load(snapshot)
+	tx.record(func() {
+		mdb.contents = previous
+		mdb.estimator.InvalidateModel()
+	}, mdb.allChanges()...)
+}
+
+// load replaces the whole database with the snapshot, which it keeps. Missing min-max values are reset to the
+// defaults, and price points and alert subscriptions without IDs are given new ones. The caller must hold the mutex.
+func (mdb *MemoryDatabase) load(snapshot *dataTypes.DatabaseSnapshot) {
+	mdb.contents = contents{}
+	mdb.reset()
+	for _, device := range snapshot.Devices {
+		mdb.devices[device.ID] = device
+		mdb.deviceIDs = append(mdb.deviceIDs, device.ID)
+	}
+	for _, year := range snapshot.Years {
+		mdb.years[year.ID] = year
+		mdb.yearIDs = append(mdb.yearIDs, year.ID)
+	}
+	for _, month := range snapshot.Months {
+		mdb.months[month.ID] = month
+	}
+	if snapshot.MinMax != (dataTypes.ValidatedAndUnvalidatedMinMaxValues{}) {
+		mdb.minMax = snapshot.MinMax
+	}
+	mdb.queue = snapshot.Queue
+	for range mdb.queue {
+		mdb.queueKeys = append(mdb.queueKeys, mdb.nextQueueKey)
+		mdb.nextQueueKey++
+	}
+	mdb.priceHistory = snapshot.PriceHistory
+	for i := range mdb.priceHistory {
+		if mdb.priceHistory[i].ID.IsZero() {
+			mdb.priceHistory[i].ID = primitive.NewObjectID()
+		}
+	}
+	mdb.alertSubscriptions = snapshot.AlertSubscriptions
+	for i := range mdb.alertSubscriptions {
+		if mdb.alertSubscriptions[i].ID.IsZero() {
+			mdb.alertSubscriptions[i].ID = primitive.NewObjectID()
+		}
+	}
+	mdb.benchmarkCatalogs = make(map[string]dataTypes.BenchmarkCatalog)
+	for _, catalog := range snapshot.BenchmarkCatalogs {
+		mdb.benchmarkCatalogs[catalog.Platform] = catalog
+	}
+	if snapshot.IsInterruptedValidation {
+		mdb.unfinishedValidations = 1
+	}
+}
+
+// allChanges returns the changes that empty every collection and store the whole database again. The caller must
+// hold the mutex.
+func (mdb *MemoryDatabase) allChanges() []Change {
+	var changes []Change
+	for _, collection := range Collections {
+		if collection != QueueCollection {
+			changes = append(changes, Change{Collection: collection})
+		}
+	}
+	changes = append(changes, mdb.queueChanges()...)
+	for _, deviceID := range mdb.deviceIDs {
+		changes = append(changes, Change{Collection: DevicesCollection, Key: objectIDKey(deviceID), Document: mdb.devices[deviceID]})
+	}
+	for _, yearID := range mdb.yearIDs {
+		changes = append(changes, Change{Collection: YearsCollection, Key: objectIDKey(yearID), Document: mdb.years[yearID]})
+	}
+	for monthID, month := range mdb.months {
+		changes = append(changes, Change{Collection: MonthsCollection, Key: objectIDKey(monthID), Document: month})
+	}
+	for _, pricePoint := range mdb.priceHistory {
+		changes = append(changes, Change{Collection: PriceHistoryCollection, Key: objectIDKey(pricePoint.ID), Document: pricePoint})
+	}
+	for _, subscription := range mdb.alertSubscriptions {
+		changes = append(changes, Change{Collection: AlertSubscriptionsCollection, Key: objectIDKey(subscription.ID), Document: subscription})
+	}
+	for platform, catalog := range mdb.benchmarkCatalogs {
+		changes = append(changes, Change{Collection: BenchmarkCatalogCollection, Key: []byte(platform), Document: catalog})
+	}
+	changes = append(changes,
+		Change{Collection: MetadataCollection, Key: []byte(MinMaxKey), Document: mdb.minMax},
+		Change{Collection: MetadataCollection, Key: []byte(ValidationFlagKey), Document: dataTypes.ValidationFlag{IsUnfinishedValidation: mdb.unfinishedValidations > 0}},
+	)
+	return changes
+}
+
+// queueChanges returns the changes that empty the queue and store it again. The caller must hold the mutex.
+func (mdb *MemoryDatabase) queueChanges() []Change {
+	changes := []Change{{Collection: QueueCollection}}
+	for i, deviceInQueue := range mdb.queue {
+		changes = append(changes, Change{Collection: QueueCollection, Key: queueKey(mdb.queueKeys[i]), Document: deviceInQueue})
+	}
+	return changes
+}
+
+// objectIDKey orders the documents by ID, which orders them by creation time.
+func objectIDKey(id primitive.ObjectID) []byte {
+	return id[:]
+}
+
+// queueKey orders the queued devices by the order they were enqueued in.
+func queueKey(key uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, key)
+}
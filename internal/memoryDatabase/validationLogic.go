@@ -0,0 +1,71 @@
+package memoryDatabase
+
+import (
+	"context"
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/aiAnalysis"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/reviewer"
+	"log"
+)
+
+// ValidateScores makes the unvalidated scores and min-max values the validated ones. Like the Mongo backend, it
+// holds a validation flag while it runs, which IsInterruptedValidation reports if the validation never finishes.
+func (mdb *MemoryDatabase) ValidateScores(unvalidatedMinMax dataTypes.MinMaxValues, ctrl *dataTypes.FlowControl) error {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping memoryDatabase.ValidateScores: %v", ctrl.Ctx.Err())
+		return ctrl.Ctx.Err()
+	}
+
+	mdb.mutex.Lock()
+	defer mdb.mutex.Unlock()
+	tx := mdb.begin()
+	tx.setUnfinishedValidations(mdb.unfinishedValidations + 1)
+	minMax := mdb.minMax
+	minMax.Validated = unvalidatedMinMax
+	tx.setMinMax(minMax)
+	for _, deviceID := range mdb.deviceIDs {
+		if ctrl.Ctx.Err() != nil {
+			log.Printf("stopping memoryDatabase.ValidateScores: %v", ctrl.Ctx.Err())
+			// What was validated is kept with the flag, as it is in Mongo.
+			if err := tx.commit(&dataTypes.FlowControl{Ctx: context.Background(), StopOnTooManyErrorsChannel: ctrl.StopOnTooManyErrorsChannel}); err != nil {
+				log.Printf("in memoryDatabase.ValidateScores failed to keep the interrupted validation: %v", err)
+			}
+			return ctrl.Ctx.Err()
+		}
+		device := mdb.devices[deviceID]
+		device.Review.ValidatedReviewScore = device.Review.UnvalidatedReviewScore
+		device.ValidatedFinalScore = device.UnvalidatedFinalScore
+		tx.putDevice(device)
+	}
+	tx.setUnfinishedValidations(mdb.unfinishedValidations - 1)
+	return tx.commit(ctrl)
+}
+
+func (mdb *MemoryDatabase) IsInterruptedValidation(ctrl *dataTypes.FlowControl) (bool, error) {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping memoryDatabase.IsInterruptedValidation: %v", ctrl.Ctx.Err())
+		return false, ctrl.Ctx.Err()
+	}
+
+	mdb.mutex.RLock()
+	defer mdb.mutex.RUnlock()
+	return mdb.unfinishedValidations > 0, nil
+}
+
+func (mdb *MemoryDatabase) NormalizeUnvalidatedScores(minMaxValues dataTypes.MinMaxValues, ctrl *dataTypes.FlowControl) error {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping memoryDatabase.NormalizeUnvalidatedScores: %v", ctrl.Ctx.Err())
+		return ctrl.Ctx.Err()
+	}
+
+	mdb.mutex.Lock()
+	defer mdb.mutex.Unlock()
+	tx := mdb.begin()
+	for _, deviceID := range mdb.deviceIDs {
+		device := mdb.devices[deviceID]
+		reviewer.SetUnvalidatedNormalizedReviewScore(minMaxValues, &device)
+		device.UnvalidatedFinalScore = aiAnalysis.GetFinalScore(minMaxValues, &device, dataTypes.UnvalidatedScores)
+		tx.putDevice(device)
+	}
+	return tx.commit(ctrl)
+}
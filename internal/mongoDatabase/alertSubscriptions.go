@@ -0,0 +1,68 @@
+package mongoDatabase
+
+import (
+	"context"
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorMonitoring"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"time"
+)
+
+func (mdb *MongoDatabase) AddAlertSubscription(subscription *dataTypes.AlertSubscription, ctrl *dataTypes.FlowControl) error {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping mongoDatabase.AddAlertSubscription: %v", ctrl.Ctx.Err())
+		return ctrl.Ctx.Err()
+	}
+
+	coll := mdb.collection(mdb.config.Collections.AlertSubscriptions)
+	if subscription.ID.IsZero() {
+		subscription.ID = primitive.NewObjectID()
+	}
+	ctx, cancel := context.WithTimeout(ctrl.Ctx, time.Second*10)
+	defer cancel()
+	_, err := coll.InsertOne(ctx, subscription)
+	if err != nil {
+		err = handleMongoError(err, false, ctrl)
+		log.Printf("in mongoDatabase.AddAlertSubscription (device: %v) failed to insert subscription: %v", subscription.DeviceName, err)
+		return err
+	}
+	return nil
+}
+
+func (mdb *MongoDatabase) GetAlertSubscriptions(deviceID primitive.ObjectID, ctrl *dataTypes.FlowControl) ([]dataTypes.AlertSubscription, error) {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping mongoDatabase.GetAlertSubscriptions: %v", ctrl.Ctx.Err())
+		return nil, ctrl.Ctx.Err()
+	}
+
+	coll := mdb.collection(mdb.config.Collections.AlertSubscriptions)
+	ctxForFind, cancelForFind := context.WithTimeout(ctrl.Ctx, time.Second*30)
+	defer cancelForFind()
+	cursor, err := coll.Find(ctxForFind, bson.M{"device-id": deviceID})
+	if err != nil {
+		err = handleMongoError(err, false, ctrl)
+		log.Printf("in mongoDatabase.GetAlertSubscriptions failed to find subscriptions: %v", err)
+		return nil, err
+	}
+	ctxForClose, cancelForClose := context.WithTimeout(ctrl.Ctx, time.Second*30)
+	defer cancelForClose()
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		err = cursor.Close(ctx)
+		if err != nil {
+			log.Printf("WARNING: Failed to close cursor: %v", err)
+			errorMonitoring.IncrementError(errorMonitoring.CleanUpError, ctrl)
+		}
+	}(cursor, ctxForClose)
+
+	ctxForDecode, cancelForDecode := context.WithTimeout(ctrl.Ctx, 10*time.Second)
+	defer cancelForDecode()
+	var subscriptions []dataTypes.AlertSubscription
+	if err = cursor.All(ctxForDecode, &subscriptions); err != nil {
+		log.Println("in mongoDatabase.GetAlertSubscriptions failed to decode cursor")
+		return nil, handleMongoError(err, true, ctrl)
+	}
+	return subscriptions, nil
+}
@@ -100,31 +100,57 @@ func (mdb *MongoDatabase) GetValidatedAndUnvalidatedMinMaxValues(ctrl *dataTypes
 		return dataTypes.ValidatedAndUnvalidatedMinMaxValues{}, ctrl.Ctx.Err()
 	}
 
-	coll := mdb.client.Database("local").Collection(DeviceDataCollection)
-
-	id, _ := primitive.ObjectIDFromHex(MinMaxValuesDocumentID)
 	var minMaxValues dataTypes.ValidatedAndUnvalidatedMinMaxValues
-	err := mdb.getAndDecodeDocumentByID(&minMaxValues, id, true, coll, ctrl)
+	err := mdb.getMetadataDocument(minMaxValuesDocument, &minMaxValues, ctrl)
 	if err != nil {
 		log.Println("in mongoDatabase.GetValidatedAndUnvalidatedMinMaxValues failed get minmax document")
-		return dataTypes.ValidatedAndUnvalidatedMinMaxValues{}, handleMongoError(err, true, ctrl)
+		return dataTypes.ValidatedAndUnvalidatedMinMaxValues{}, err
 	}
 
 	return minMaxValues, nil
 }
 
+// Connect connects and bootstraps the database, then applies the pending migrations if MigrateOnStartup is set.
 func (mdb *MongoDatabase) Connect(ctrl *dataTypes.FlowControl) error {
+	return mdb.connect(true, ctrl)
+}
+
+// ConnectWithoutBootstrap connects without creating, moving or migrating any document or index, for tools that must
+// not change the database.
+func (mdb *MongoDatabase) ConnectWithoutBootstrap(ctrl *dataTypes.FlowControl) error {
+	return mdb.connect(false, ctrl)
+}
+
+func (mdb *MongoDatabase) connect(isBootstrapped bool, ctrl *dataTypes.FlowControl) error {
 	if ctrl.Ctx.Err() != nil {
 		return ctrl.Ctx.Err()
 	}
 
-	client, err := getClient(ctrl)
+	client, err := getClient(mdb.config.URI, ctrl)
 	if err != nil {
 		log.Println("in mongoDatabase.Connect failed to connect to database")
 		return err
 	}
 
 	mdb.client = client
+	if !isBootstrapped {
+		return nil
+	}
+	err = mdb.Bootstrap(ctrl)
+	if err == nil && mdb.config.MigrateOnStartup {
+		_, err = mdb.Migrate(false, ctrl)
+	} else if err == nil {
+		mdb.warnOfPendingMigrations(ctrl)
+	}
+	if err != nil {
+		log.Println("in mongoDatabase.Connect failed to prepare database")
+		if disconnectErr := client.Disconnect(context.Background()); disconnectErr != nil {
+			log.Printf("WARNING: failed to disconnect MongoDB client: %v", disconnectErr)
+			errorMonitoring.IncrementError(errorMonitoring.CleanUpError, ctrl)
+		}
+		mdb.client = nil
+		return err
+	}
 	return nil
 }
 
@@ -138,8 +164,8 @@ func (mdb *MongoDatabase) Disconnect(ctrl *dataTypes.FlowControl) error {
 	return nil
 }
 
-func getClient(ctrl *dataTypes.FlowControl) (*mongo.Client, error) {
-	client, err := mongo.Connect(ctrl.Ctx, options.Client().ApplyURI(URI))
+func getClient(uri string, ctrl *dataTypes.FlowControl) (*mongo.Client, error) {
+	client, err := mongo.Connect(ctrl.Ctx, options.Client().ApplyURI(uri))
 	if err != nil {
 		log.Printf("failed to connect to MongoDB: %v", err)
 		return nil, handleMongoError(err, true, ctrl)
@@ -154,13 +180,29 @@ type Benchmark struct {
 	SingleCoreScore float64 `bson:"single-core-score"`
 }
 
+func (mdb *MongoDatabase) GetAllDevices(ctrl *dataTypes.FlowControl) ([]dataTypes.Device, error) {
+	coll := mdb.collection(mdb.config.Collections.Devices)
+	return mdb.getAllDevices(coll, ctrl)
+}
+
+func (mdb *MongoDatabase) GetDeviceByID(deviceID primitive.ObjectID, ctrl *dataTypes.FlowControl) (dataTypes.Device, error) {
+	coll := mdb.collection(mdb.config.Collections.Devices)
+	var device dataTypes.Device
+	err := mdb.getAndDecodeDocumentByID(&device, deviceID, false, coll, ctrl)
+	if err != nil {
+		log.Printf("in mongoDatabase.GetDeviceByID failed to get device %v: %v", deviceID.Hex(), err)
+		return dataTypes.Device{}, err
+	}
+	return device, nil
+}
+
 func (mdb *MongoDatabase) getAllDevices(coll *mongo.Collection, ctrl *dataTypes.FlowControl) ([]dataTypes.Device, error) {
 	if ctrl.Ctx.Err() != nil {
 		log.Printf("stopping mongoDatabase.getAllDevices: %v", ctrl.Ctx.Err())
 		return nil, ctrl.Ctx.Err()
 	}
 
-	allDeviceIDs, err := mdb.getAllDeviceIDs(coll, ctrl)
+	allDeviceIDs, err := mdb.getAllDeviceIDs(ctrl)
 	if err != nil {
 		log.Println("in mongoDatabase.getAllDevices failed to get all device IDs")
 		return nil, err
@@ -179,15 +221,9 @@ func (mdb *MongoDatabase) getAllDevices(coll *mongo.Collection, ctrl *dataTypes.
 	return toBeValidatedDevices, nil
 }
 
-func (mdb *MongoDatabase) getAllYearIDs(coll *mongo.Collection, ctrl *dataTypes.FlowControl) ([]primitive.ObjectID, error) {
-	yearsDocumentID, err := getObjectIDFromString(AllYearIDsArrayDocumentID, ctrl)
-	if err != nil {
-		log.Println("in mongoDatabase.getAllYearIDs yearsArrayDocumentID is invalid")
-		return nil, err
-	}
-
+func (mdb *MongoDatabase) getAllYearIDs(ctrl *dataTypes.FlowControl) ([]primitive.ObjectID, error) {
 	var yearsDocument dataTypes.YearIDsDocument
-	err = mdb.getAndDecodeDocumentByID(&yearsDocument, yearsDocumentID, true, coll, ctrl)
+	err := mdb.getMetadataDocument(allYearIDsDocument, &yearsDocument, ctrl)
 	if err != nil {
 		log.Printf("in mongoDatabase.getAllYearIDs failed to get years document")
 		return nil, err
@@ -195,15 +231,9 @@ func (mdb *MongoDatabase) getAllYearIDs(coll *mongo.Collection, ctrl *dataTypes.
 	return yearsDocument.YearIDs, nil
 }
 
-func (mdb *MongoDatabase) getAllDeviceIDs(deviceDataCollection *mongo.Collection, ctrl *dataTypes.FlowControl) ([]primitive.ObjectID, error) {
-	yearsDocumentID, err := getObjectIDFromString(AllDeviceIDsArrayDocumentID, ctrl)
-	if err != nil {
-		log.Println("in mongoDatabase.getAllDeviceIDs AllDeviceIDsArrayDocumentID is invalid")
-		return nil, err
-	}
-
+func (mdb *MongoDatabase) getAllDeviceIDs(ctrl *dataTypes.FlowControl) ([]primitive.ObjectID, error) {
 	var deviceIDsDocument dataTypes.DeviceIDsDocument
-	err = mdb.getAndDecodeDocumentByID(&deviceIDsDocument, yearsDocumentID, true, deviceDataCollection, ctrl)
+	err := mdb.getMetadataDocument(allDeviceIDsDocument, &deviceIDsDocument, ctrl)
 	if err != nil {
 		log.Printf("in mongoDatabase.getAllDeviceIDs failed to get device IDs document")
 		return nil, err
@@ -222,17 +252,9 @@ func (mdb *MongoDatabase) IsInterruptedValidation(ctrl *dataTypes.FlowControl) (
 		return false, ctrl.Ctx.Err()
 	}
 
-	coll := mdb.client.Database(Database).Collection(DeviceDataCollection)
-	filter := bson.M{"isUnfinishedValidation": true}
 	var result dataTypes.ValidationFlag
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
-	err := coll.FindOne(ctx, filter).Decode(&result)
+	err := mdb.getMetadataDocument(validationFlagDocument, &result, ctrl)
 	if err != nil {
-		err = handleMongoError(err, false, ctrl)
-		if errorTypes.IsMissingDocumentError(err) {
-			return false, nil
-		}
 		errorHandling.LogErrorToScreen(errorHandling.LogParams{
 			DeviceName: "",
 			Function:   "basicMongoFunctionality.IsInterruptedValidation",
@@ -241,5 +263,5 @@ func (mdb *MongoDatabase) IsInterruptedValidation(ctrl *dataTypes.FlowControl) (
 		}, err)
 		return false, err
 	}
-	return true, nil
+	return result.IsUnfinishedValidation, nil
 }
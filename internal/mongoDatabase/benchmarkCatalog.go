@@ -0,0 +1,48 @@
+package mongoDatabase
+
+import (
+	"context"
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"time"
+)
+
+// GetBenchmarkCatalog returns the stored leaderboard of the platform, or a MissingDocumentError if it was never saved.
+func (mdb *MongoDatabase) GetBenchmarkCatalog(platform string, ctrl *dataTypes.FlowControl) (dataTypes.BenchmarkCatalog, error) {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping mongoDatabase.GetBenchmarkCatalog: %v", ctrl.Ctx.Err())
+		return dataTypes.BenchmarkCatalog{}, ctrl.Ctx.Err()
+	}
+
+	coll := mdb.collection(mdb.config.Collections.BenchmarkCatalog)
+	ctx, cancel := context.WithTimeout(ctrl.Ctx, time.Second*10)
+	defer cancel()
+	var catalog dataTypes.BenchmarkCatalog
+	err := coll.FindOne(ctx, bson.M{"_id": platform}).Decode(&catalog)
+	if err != nil {
+		log.Printf("in mongoDatabase.GetBenchmarkCatalog (platform: %v) failed to find catalog: %v", platform, err)
+		return dataTypes.BenchmarkCatalog{}, handleMongoError(err, false, ctrl)
+	}
+	return catalog, nil
+}
+
+// SaveBenchmarkCatalog replaces the stored leaderboard of the catalog's platform.
+func (mdb *MongoDatabase) SaveBenchmarkCatalog(catalog *dataTypes.BenchmarkCatalog, ctrl *dataTypes.FlowControl) error {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping mongoDatabase.SaveBenchmarkCatalog: %v", ctrl.Ctx.Err())
+		return ctrl.Ctx.Err()
+	}
+
+	coll := mdb.collection(mdb.config.Collections.BenchmarkCatalog)
+	ctx, cancel := context.WithTimeout(ctrl.Ctx, time.Second*10)
+	defer cancel()
+	_, err := coll.ReplaceOne(ctx, bson.M{"_id": catalog.Platform}, catalog, options.Replace().SetUpsert(true))
+	if err != nil {
+		err = handleMongoError(err, false, ctrl)
+		log.Printf("in mongoDatabase.SaveBenchmarkCatalog (platform: %v) failed to save catalog: %v", catalog.Platform, err)
+		return err
+	}
+	return nil
+}
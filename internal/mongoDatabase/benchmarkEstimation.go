@@ -4,26 +4,24 @@ import (
 	"context"
 	"errors"
 	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
-	"github.com/ItaiHalperin/Device-Rec-API/internal/aiAnalysis"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/benchmarkEstimation"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/brandCatalog"
 	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
 	"github.com/ItaiHalperin/Device-Rec-API/internal/helpers"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"log"
-	"math"
 	"time"
 )
 
-const (
-	yearOverYearIncrease = 0.10
-)
-
+// ReestimateBenchmarks infers or estimates again every benchmark that wasn't measured, since the devices stored
+// since may improve it.
 func (mdb *MongoDatabase) ReestimateBenchmarks(ctrl *dataTypes.FlowControl) error {
 	if ctrl.Ctx.Err() != nil {
 		log.Printf("stopping mongoDatabase.ReestimateBenchmarks: %v", ctrl.Ctx.Err())
 		return ctrl.Ctx.Err()
 	}
-	coll := mdb.client.Database(Database).Collection(DeviceDataCollection)
+	coll := mdb.collection(mdb.config.Collections.Devices)
 
 	allDevicesWithEstimatedBenchmark, err := mdb.getAllDevicesWithEstimatedBenchmark(coll, ctrl)
 	if err != nil {
@@ -34,13 +32,16 @@ func (mdb *MongoDatabase) ReestimateBenchmarks(ctrl *dataTypes.FlowControl) erro
 	helpers.SortDevicesByDate(allDevicesWithEstimatedBenchmark)
 
 	for _, device := range allDevicesWithEstimatedBenchmark {
-		err = mdb.SetLastYearEquivalentBenchmarkScores(&device, ctrl)
+		err = mdb.estimator.ReestimateBenchmarkScores(&device, ctrl)
 		if err != nil {
-			log.Printf("in mongoDatabase.ReestimateBenchmarks (device: %v) failed to set last year equivalent benchmark score: %v", device.Name, err)
+			log.Printf("in mongoDatabase.ReestimateBenchmarks (device: %v) failed to reestimate benchmark score: %v", device.Name, err)
 			return err
 		}
 
-		update := bson.D{{"$set", bson.D{{"benchmark", device.Benchmark}}}}
+		update := bson.D{{Key: "$set", Value: bson.D{
+			{Key: "benchmark", Value: device.Benchmark},
+			{Key: "provenance", Value: device.Provenance},
+		}}}
 		ctx, cancel := context.WithTimeout(ctrl.Ctx, time.Second*30)
 		_, err = coll.UpdateByID(ctx, device.ID, update)
 		if err != nil {
@@ -68,7 +69,7 @@ func (mdb *MongoDatabase) getAllDevicesWithEstimatedBenchmark(coll *mongo.Collec
 
 	var devicesWithEstimatedBenchmark []dataTypes.Device
 	for _, device := range allDevices {
-		if device.Benchmark.IsEstimatedBenchmark {
+		if benchmarkEstimation.IsReestimated(&device) {
 			devicesWithEstimatedBenchmark = append(devicesWithEstimatedBenchmark, device)
 		}
 	}
@@ -82,7 +83,17 @@ func (mdb *MongoDatabase) GetLastYearEquivalentBenchmarkScores(device *dataTypes
 		return 0, 0, ctrl.Ctx.Err()
 	}
 
-	coll := mdb.client.Database(Database).Collection(DeviceDataCollection)
+	brand, err := mdb.brands.GetBrand(device.Brand)
+	if err != nil {
+		log.Printf("in mongoDatabase.GetLastYearEquivalentBenchmarkScores (device: %v) failed to get brand: %v", device.Name, err)
+		return 0, 0, err
+	}
+	if brand.Estimation.Method == brandCatalog.NoEstimation {
+		log.Printf("in mongoDatabase.GetLastYearEquivalentBenchmarkScores (device: %v) estimation disabled for %v", device.Name, brand.Name)
+		return 0, 0, errorTypes.NewNoLastYearEquivalentError("in mongoDatabase.GetLastYearEquivalentBenchmarkScores estimation disabled")
+	}
+
+	coll := mdb.collection(mdb.config.Collections.Devices)
 	lastYearModelName, err := helpers.DecrementNumberInString(device.Name)
 	if err == nil {
 		filter := bson.D{{"name", lastYearModelName}}
@@ -91,7 +102,7 @@ func (mdb *MongoDatabase) GetLastYearEquivalentBenchmarkScores(device *dataTypes
 		defer cancel()
 		err := coll.FindOne(ctx, filter).Decode(&lastYearDevice)
 		if err == nil {
-			return lastYearDevice.Benchmark.SingleCoreScore * (1 + yearOverYearIncrease), lastYearDevice.Benchmark.MultiCoreScore * (1 + yearOverYearIncrease), nil
+			return lastYearDevice.Benchmark.SingleCoreScore * (1 + benchmarkEstimation.YearOverYearIncrease), lastYearDevice.Benchmark.MultiCoreScore * (1 + benchmarkEstimation.YearOverYearIncrease), nil
 		}
 	}
 
@@ -100,7 +111,7 @@ func (mdb *MongoDatabase) GetLastYearEquivalentBenchmarkScores(device *dataTypes
 	var year dataTypes.Year
 	ctx, cancel := context.WithTimeout(ctrl.Ctx, time.Second*30)
 	defer cancel()
-	err = coll.FindOne(ctx, filter).Decode(&year)
+	err = mdb.collection(mdb.config.Collections.Years).FindOne(ctx, filter).Decode(&year)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			log.Println("in mongoDatabase.GetLastYearEquivalentBenchmarkScores no last year equivalent")
@@ -111,52 +122,21 @@ func (mdb *MongoDatabase) GetLastYearEquivalentBenchmarkScores(device *dataTypes
 		}
 	}
 
-	var priceCategories dataTypes.MinMaxInt
-	switch device.Brand {
-	case "Apple":
-		priceCategories = dataTypes.MinMaxInt{Min: dataTypes.LowEnd, Max: dataTypes.HighEnd}
-	case "Google":
-		priceCategories = dataTypes.MinMaxInt{Min: device.PriceCategory - 1, Max: device.PriceCategory + 1}
-	}
+	priceCategories := brand.Estimation.PriceCategories(device.PriceCategory)
 	lastYearsDeviceScoresAndID, err := fullTextSearch(device.Name, year.ID, priceCategories, coll, ctrl)
 	if err != nil {
 		log.Println("in mongoDatabase.GetLastYearEquivalentBenchmarkScores fullTextSearch err: ", err)
 		return 0, 0, err
 	}
 
-	if device.Brand == "Google" {
-		priceCategoryDiff := math.Abs(float64(lastYearsDeviceScoresAndID.PriceCategory - device.PriceCategory))
-		lastYearsDeviceScoresAndID.Benchmark.SingleCoreScore = lastYearsDeviceScoresAndID.Benchmark.SingleCoreScore * (1 - priceCategoryDiff*0.25)
-		lastYearsDeviceScoresAndID.Benchmark.MultiCoreScore = lastYearsDeviceScoresAndID.Benchmark.MultiCoreScore * (1 - priceCategoryDiff*0.25)
-	}
-
-	return lastYearsDeviceScoresAndID.Benchmark.SingleCoreScore, lastYearsDeviceScoresAndID.Benchmark.MultiCoreScore, nil
+	singleCoreScore, multiCoreScore := benchmarkEstimation.ApplyPriceCategoryPenalty(lastYearsDeviceScoresAndID.Benchmark.SingleCoreScore,
+		lastYearsDeviceScoresAndID.Benchmark.MultiCoreScore, lastYearsDeviceScoresAndID.PriceCategory, device.PriceCategory,
+		brand.Estimation.PriceCategoryPenalty)
+	return singleCoreScore, multiCoreScore, nil
 }
 
-func (mdb *MongoDatabase) SetLastYearEquivalentBenchmarkScores(device *dataTypes.Device, ctrl *dataTypes.FlowControl) error {
-	if ctrl.Ctx.Err() != nil {
-		log.Printf("stopping mongoDatabase.SetLastYearEquivalentBenchmarkScores: %v", ctrl.Ctx.Err())
-		return ctrl.Ctx.Err()
-	}
-
-	singleCoreScore, multiCoreScore, err := mdb.GetLastYearEquivalentBenchmarkScores(device, ctrl)
-	if err != nil {
-		if errorTypes.IsNoLastYearEquivalentError(err) {
-			return nil
-		} else {
-			log.Printf("in benchmarkScraper.SetLastYearEquivalentBenchmarkScores (device: %v) failed to get last year equivelant benchmark scores: %v", device.Name, err)
-			return err
-		}
-	}
-
-	minMax, err := mdb.GetValidatedAndUnvalidatedMinMaxValues(ctrl)
-	if err != nil {
-		log.Printf("in benchmarkScraper.SetLastYearEquivalentBenchmarkScores (device: %v) failed to get minmax: %v", device.Name, err)
-		return err
-	}
-
-	device.Benchmark.SingleCoreScore = singleCoreScore
-	device.Benchmark.MultiCoreScore = multiCoreScore
-	device.ValidatedFinalScore = aiAnalysis.GetFinalScore(minMax.Validated, device, dataTypes.ValidatedScores)
-	return nil
+// SetEstimatedBenchmarkScores estimates the device's benchmark with its brand's estimation method. Devices that
+// can't be estimated keep their scores.
+func (mdb *MongoDatabase) SetEstimatedBenchmarkScores(device *dataTypes.Device, ctrl *dataTypes.FlowControl) error {
+	return mdb.estimator.SetEstimatedBenchmarkScores(device, ctrl)
 }
@@ -0,0 +1,98 @@
+package mongoDatabase
+
+import (
+	"context"
+	"fmt"
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/benchmarkEstimation"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorMonitoring"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"regexp"
+	"time"
+)
+
+// SetChipsetInferredBenchmarkScores sets the device's scores to the median scores of the measured devices with the
+// same chipset. It returns a NoChipsetPeerError when the chipset is unknown or no such device is stored.
+func (mdb *MongoDatabase) SetChipsetInferredBenchmarkScores(device *dataTypes.Device, ctrl *dataTypes.FlowControl) error {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping mongoDatabase.SetChipsetInferredBenchmarkScores: %v", ctrl.Ctx.Err())
+		return ctrl.Ctx.Err()
+	}
+
+	chipset := benchmarkEstimation.GetChipsetKey(device.Specs.Chipset)
+	if chipset == "" {
+		return errorTypes.NewNoChipsetPeerError(fmt.Sprintf("in mongoDatabase.SetChipsetInferredBenchmarkScores (device: %v) unknown chipset", device.Name))
+	}
+
+	coll := mdb.collection(mdb.config.Collections.Devices)
+	peers, err := mdb.getChipsetPeers(device, chipset, coll, ctrl)
+	if err != nil {
+		log.Printf("in mongoDatabase.SetChipsetInferredBenchmarkScores (device: %v) failed to get devices with chipset %v: %v", device.Name, chipset, err)
+		return err
+	}
+	if len(peers) == 0 {
+		return errorTypes.NewNoChipsetPeerError(fmt.Sprintf("in mongoDatabase.SetChipsetInferredBenchmarkScores (device: %v) no measured device with chipset %v", device.Name, chipset))
+	}
+
+	minMax, err := mdb.GetValidatedAndUnvalidatedMinMaxValues(ctrl)
+	if err != nil {
+		log.Printf("in mongoDatabase.SetChipsetInferredBenchmarkScores (device: %v) failed to get minmax: %v", device.Name, err)
+		return err
+	}
+
+	benchmarkEstimation.SetChipsetInferredScores(device, peers, minMax.Validated)
+	return nil
+}
+
+// getChipsetPeers returns the other devices with the given chipset whose benchmark was measured.
+func (mdb *MongoDatabase) getChipsetPeers(device *dataTypes.Device, chipset string, coll *mongo.Collection, ctrl *dataTypes.FlowControl) ([]dataTypes.Device, error) {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping mongoDatabase.getChipsetPeers: %v", ctrl.Ctx.Err())
+		return nil, ctrl.Ctx.Err()
+	}
+
+	filter := bson.M{
+		"_id":                              bson.M{"$ne": device.ID},
+		"specs.chipset":                    bson.M{"$regex": "^\\s*" + regexp.QuoteMeta(chipset), "$options": "i"},
+		"benchmark.is-estimated-benchmark": false,
+		"benchmark.is-chipset-inferred":    bson.M{"$ne": true},
+		"benchmark.single-core-score":      bson.M{"$gt": 0},
+		"benchmark.multi-core-score":       bson.M{"$gt": 0},
+	}
+	ctxForFind, cancelForFind := context.WithTimeout(ctrl.Ctx, time.Second*30)
+	defer cancelForFind()
+	cursor, err := coll.Find(ctxForFind, filter)
+	if err != nil {
+		log.Printf("in mongoDatabase.getChipsetPeers failed to find devices: %v", err)
+		return nil, handleMongoError(err, false, ctrl)
+	}
+	ctxForClose, cancelForClose := context.WithTimeout(ctrl.Ctx, time.Second*30)
+	defer cancelForClose()
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		err = cursor.Close(ctx)
+		if err != nil {
+			log.Printf("WARNING: Failed to close cursor: %v", err)
+			errorMonitoring.IncrementError(errorMonitoring.CleanUpError, ctrl)
+		}
+	}(cursor, ctxForClose)
+
+	ctxForDecode, cancelForDecode := context.WithTimeout(ctrl.Ctx, 10*time.Second)
+	defer cancelForDecode()
+	var candidates []dataTypes.Device
+	if err = cursor.All(ctxForDecode, &candidates); err != nil {
+		log.Println("in mongoDatabase.getChipsetPeers failed to decode cursor")
+		return nil, handleMongoError(err, true, ctrl)
+	}
+
+	// The regex only matches the chipset's prefix; "Snapdragon 8 Gen 2" must not match "Snapdragon 8 Gen 2 Leading Version".
+	var peers []dataTypes.Device
+	for _, candidate := range candidates {
+		if benchmarkEstimation.IsChipsetPeer(device, &candidate, chipset) {
+			peers = append(peers, candidate)
+		}
+	}
+	return peers, nil
+}
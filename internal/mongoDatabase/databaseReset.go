@@ -1,13 +1,11 @@
 package mongoDatabase
 
 import (
-	"context"
 	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
 	"github.com/ItaiHalperin/Device-Rec-API/internal/helpers"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"log"
-	"time"
 )
 
 func (mdb *MongoDatabase) ResetDatabase(ctrl *dataTypes.FlowControl) error {
@@ -22,6 +20,12 @@ func (mdb *MongoDatabase) ResetDatabase(ctrl *dataTypes.FlowControl) error {
 		return err
 	}
 
+	err = mdb.deletePriceHistory(ctrl)
+	if err != nil {
+		log.Printf("error deleting price history: %v", err)
+		return err
+	}
+
 	err = mdb.ResetMinMax(ctrl)
 	if err != nil {
 		log.Printf("error reseting minmax: %v", err)
@@ -45,19 +49,8 @@ func (mdb *MongoDatabase) ResetDatabase(ctrl *dataTypes.FlowControl) error {
 }
 
 func (mdb *MongoDatabase) ResetAllYearIDsArray(ctrl *dataTypes.FlowControl) error {
-	coll := mdb.client.Database(Database).Collection(DeviceDataCollection)
-	minMaxValuedDocumentID, err := getObjectIDFromString(AllYearIDsArrayDocumentID, ctrl)
+	err := mdb.setMetadataFields(allYearIDsDocument, bson.D{{"year-ids", make([]primitive.ObjectID, 0)}}, ctrl)
 	if err != nil {
-		log.Println("in mongoDatabase.ResetAllYearIDsArray AllYearIDsArrayDocumentID is invalid")
-		return err
-	}
-
-	update := bson.D{{"$set", bson.D{{"year-ids", make([]primitive.ObjectID, 0)}}}}
-	ctx, cancel := context.WithTimeout(ctrl.Ctx, time.Second*30)
-	defer cancel()
-	_, err = coll.UpdateByID(ctx, minMaxValuedDocumentID, update)
-	if err != nil {
-		err = handleMongoError(err, true, ctrl)
 		log.Printf("in mongoDatabase.ResetAllYearIDsArray failed to update year ids document: %v", err)
 		return err
 	}
@@ -66,44 +59,25 @@ func (mdb *MongoDatabase) ResetAllYearIDsArray(ctrl *dataTypes.FlowControl) erro
 	return nil
 }
 
+// DeleteAllDocuments deletes every device, year and month.
 func (mdb *MongoDatabase) DeleteAllDocuments(ctrl *dataTypes.FlowControl) error {
-	coll := mdb.client.Database(Database).Collection(DeviceDataCollection)
-
-	fields := []string{"final-score", "year", "year-number"}
-	var conditions []bson.M
-	for _, field := range fields {
-		conditions = append(conditions, bson.M{field: bson.M{"$exists": true}})
-	}
-	filter := bson.M{"$or": conditions}
-	ctx, cancel := context.WithTimeout(ctrl.Ctx, time.Minute)
-	defer cancel()
-	_, err := coll.DeleteMany(ctx, filter)
-	if err != nil {
-		err = handleMongoError(err, true, ctrl)
-		log.Printf("in mongoDatabase.DeleteAllDevices failed to delete: %v", err)
-		return err
+	for _, collectionName := range []string{mdb.config.Collections.Devices, mdb.config.Collections.Years, mdb.config.Collections.Months} {
+		err := mdb.deleteDocuments(collectionName, bson.M{}, ctrl)
+		if err != nil {
+			log.Printf("in mongoDatabase.DeleteAllDevices failed to delete %v: %v", collectionName, err)
+			return err
+		}
 	}
 
+	mdb.estimator.InvalidateModel()
 	log.Printf("in mongoDatabase.DeleteAllDevices deleted all devices successfully")
 	return nil
 }
 
 func (mdb *MongoDatabase) ResetMinMax(ctrl *dataTypes.FlowControl) error {
-	coll := mdb.client.Database(Database).Collection(DeviceDataCollection)
-
-	minMaxValuedDocumentID, err := getObjectIDFromString(MinMaxValuesDocumentID, ctrl)
-	if err != nil {
-		log.Println("in mongoDatabase.ResetMinMax MinMaxValuesDocumentID is invalid")
-		return err
-	}
-
 	defaultMinMax := helpers.GetDefaultMinMax()
-	update := bson.D{{"$set", bson.D{{"validated", defaultMinMax}, {"unvalidated", defaultMinMax}}}}
-	ctx, cancel := context.WithTimeout(ctrl.Ctx, time.Second*30)
-	defer cancel()
-	_, err = coll.UpdateByID(ctx, minMaxValuedDocumentID, update)
+	err := mdb.setMetadataFields(minMaxValuesDocument, bson.D{{"validated", defaultMinMax}, {"unvalidated", defaultMinMax}}, ctrl)
 	if err != nil {
-		err = handleMongoError(err, true, ctrl)
 		log.Printf("in mongoDatabase.ResetMinMax failed to update minmax document: %v", err)
 		return err
 	}
@@ -113,19 +87,8 @@ func (mdb *MongoDatabase) ResetMinMax(ctrl *dataTypes.FlowControl) error {
 }
 
 func (mdb *MongoDatabase) ResetAllDeviceIDsArray(ctrl *dataTypes.FlowControl) error {
-	coll := mdb.client.Database(Database).Collection(DeviceDataCollection)
-	minMaxValuedDocumentID, err := getObjectIDFromString(AllDeviceIDsArrayDocumentID, ctrl)
-	if err != nil {
-		log.Println("in mongoDatabase.ResetAllDeviceIDsArray AllDeviceIDsArrayDocumentID is invalid")
-		return err
-	}
-
-	update := bson.D{{"$set", bson.D{{"device-ids", make([]primitive.ObjectID, 0)}}}}
-	ctx, cancel := context.WithTimeout(ctrl.Ctx, time.Second*30)
-	defer cancel()
-	_, err = coll.UpdateByID(ctx, minMaxValuedDocumentID, update)
+	err := mdb.setMetadataFields(allDeviceIDsDocument, bson.D{{"device-ids", make([]primitive.ObjectID, 0)}}, ctrl)
 	if err != nil {
-		err = handleMongoError(err, true, ctrl)
 		log.Printf("in mongoDatabase.ResetAllDeviceIDsArray failed to update device ids document: %v", err)
 		return err
 	}
@@ -2,11 +2,13 @@ package mongoDatabase
 
 import (
 	"context"
+	"fmt"
 	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
 	"github.com/ItaiHalperin/Device-Rec-API/internal/aiAnalysis"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/benchmarkEstimation"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 	"log"
 	"time"
 )
@@ -18,9 +20,9 @@ func (mdb *MongoDatabase) UploadDevice(device *dataTypes.Device, unvalidatedMinM
 		return ctrl.Ctx.Err()
 	}
 
-	coll := mdb.client.Database(Database).Collection(DeviceDataCollection)
+	coll := mdb.collection(mdb.config.Collections.Devices)
 	device.UnvalidatedFinalScore = aiAnalysis.GetFinalScore(unvalidatedMinMax, device, dataTypes.UnvalidatedScores)
-	err := mdb.SetDeviceIDs(device, coll, ctrl)
+	err := mdb.SetDeviceIDs(device, ctrl)
 	if err != nil {
 		log.Printf("in mongoDatabase.UploadDevice failed to set device ID's")
 		return err
@@ -34,23 +36,70 @@ func (mdb *MongoDatabase) UploadDevice(device *dataTypes.Device, unvalidatedMinM
 		log.Printf("in mongoDatabase.ValidateScores failed to insert device into database: %v", err)
 		return err
 	}
-	err = mdb.incrementUnvalidatedNumberOfDevices(&unvalidatedMinMax, coll, ctrl)
+	if benchmarkEstimation.ChangesModel(nil, device) {
+		mdb.estimator.InvalidateModel()
+	}
+	err = mdb.incrementUnvalidatedNumberOfDevices(&unvalidatedMinMax, ctrl)
 	if err != nil {
 		log.Println("in mongoDatabase.ValidateScores failed to increment unvalidated number of devices")
 		return err
 	}
+	if err = mdb.recordPriceObservation(device, ctrl); err != nil {
+		log.Printf("WARNING: in mongoDatabase.UploadDevice failed to record price of %v: %v", device.Name, err)
+	}
 
 	err = mdb.ValidateScores(unvalidatedMinMax, ctrl)
 	if err != nil {
 		log.Printf("in mongoDatabase.UploadDevice failed to upload device fully due to failed validation: %v", err)
-		return err
+		return errorTypes.NewValidationError(fmt.Sprintf("in mongoDatabase.UploadDevice failed to validate after uploading %v: %v", device.Name, err), err)
 	}
 	log.Printf("in mongoDatabase.UploadDevice successfully uploaded %v into database", device.Name)
 	return nil
 }
 
-func (mdb *MongoDatabase) SetDeviceIDs(device *dataTypes.Device, deviceDataCollection *mongo.Collection,
+// UpdateDevice replaces a stored device in place, keeping its IDs, and revalidates the scores.
+func (mdb *MongoDatabase) UpdateDevice(device *dataTypes.Device, unvalidatedMinMax dataTypes.MinMaxValues,
 	ctrl *dataTypes.FlowControl) error {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping mongoDatabase.UpdateDevice: %v", ctrl.Ctx.Err())
+		return ctrl.Ctx.Err()
+	}
+
+	coll := mdb.collection(mdb.config.Collections.Devices)
+	device.UnvalidatedFinalScore = aiAnalysis.GetFinalScore(unvalidatedMinMax, device, dataTypes.UnvalidatedScores)
+	ctx, cancel := context.WithTimeout(ctrl.Ctx, time.Second*30)
+	defer cancel()
+	result, err := coll.ReplaceOne(ctx, bson.M{"_id": device.ID}, device)
+	if err != nil {
+		err = handleMongoError(err, true, ctrl)
+		log.Printf("in mongoDatabase.UpdateDevice failed to replace device %v: %v", device.Name, err)
+		return err
+	}
+	if result.MatchedCount == 0 {
+		errMsg := fmt.Sprintf("in mongoDatabase.UpdateDevice device %v is not stored", device.Name)
+		log.Println(errMsg)
+		return errorTypes.NewMissingDocumentError(errMsg)
+	}
+	// The replaced device isn't read back, so any device that is trained on may have changed the model.
+	if benchmarkEstimation.ChangesModel(nil, device) {
+		mdb.estimator.InvalidateModel()
+	}
+	err = mdb.recordPriceObservation(device, ctrl)
+	if err != nil {
+		log.Printf("in mongoDatabase.UpdateDevice failed to record price of %v: %v", device.Name, err)
+		return err
+	}
+
+	err = mdb.ValidateScores(unvalidatedMinMax, ctrl)
+	if err != nil {
+		log.Printf("in mongoDatabase.UpdateDevice failed to update device fully due to failed validation: %v", err)
+		return errorTypes.NewValidationError(fmt.Sprintf("in mongoDatabase.UpdateDevice failed to validate after updating %v: %v", device.Name, err), err)
+	}
+	log.Printf("in mongoDatabase.UpdateDevice successfully updated %v", device.Name)
+	return nil
+}
+
+func (mdb *MongoDatabase) SetDeviceIDs(device *dataTypes.Device, ctrl *dataTypes.FlowControl) error {
 	if ctrl.Ctx.Err() != nil {
 		log.Printf("stopping mongoDatabase.SetDeviceIDs: %v", ctrl.Ctx.Err())
 		return ctrl.Ctx.Err()
@@ -60,24 +109,24 @@ func (mdb *MongoDatabase) SetDeviceIDs(device *dataTypes.Device, deviceDataColle
 	yearNumber := device.Specs.ReleaseDate.Year()
 	monthNumber := int(device.Specs.ReleaseDate.Month())
 
-	yearID, err := mdb.getYearID(yearNumber, deviceDataCollection, ctrl)
+	yearID, err := mdb.getYearID(yearNumber, ctrl)
 	if err != nil {
 		log.Println("in mongoDatabase.SetDeviceIDs failed to get year")
 		return err
 	}
-	monthID, err := mdb.getMonthID(monthNumber, yearID, deviceDataCollection, ctrl)
+	monthID, err := mdb.getMonthID(monthNumber, yearID, ctrl)
 	if err != nil {
 		log.Println("in mongoDatabase.SetDeviceIDs failed to get month")
 		return err
 	}
 
-	err = mdb.addIDToDeviceArray(newDeviceID, deviceDataCollection, ctrl)
+	err = mdb.addIDToDeviceArray(newDeviceID, ctrl)
 	if err != nil {
 		log.Println("in mongoDatabase.SetDeviceIDs failed to add ID to devices array")
 		return err
 	}
 
-	err = mdb.addIDToMonth(newDeviceID, monthID, deviceDataCollection, ctrl)
+	err = mdb.addIDToMonth(newDeviceID, monthID, ctrl)
 	if err != nil {
 		log.Println("in mongoDatabase.SetDeviceIDs failed to add ID to month devices array")
 		return err
@@ -89,9 +138,10 @@ func (mdb *MongoDatabase) SetDeviceIDs(device *dataTypes.Device, deviceDataColle
 	return nil
 }
 
-func (mdb *MongoDatabase) addIDToMonth(deviceID, monthID primitive.ObjectID, deviceDataCollection *mongo.Collection, ctrl *dataTypes.FlowControl) error {
+func (mdb *MongoDatabase) addIDToMonth(deviceID, monthID primitive.ObjectID, ctrl *dataTypes.FlowControl) error {
+	monthsCollection := mdb.collection(mdb.config.Collections.Months)
 	var month dataTypes.Month
-	err := mdb.getAndDecodeDocumentByID(&month, monthID, true, deviceDataCollection, ctrl)
+	err := mdb.getAndDecodeDocumentByID(&month, monthID, true, monthsCollection, ctrl)
 	if err != nil {
 		log.Printf("in mongoDatabase.addIDToMonth failed get month with id %v document", monthID)
 		return handleMongoError(err, true, ctrl)
@@ -106,7 +156,7 @@ func (mdb *MongoDatabase) addIDToMonth(deviceID, monthID primitive.ObjectID, dev
 	}
 	ctx, cancel := context.WithTimeout(ctrl.Ctx, time.Second*30)
 	defer cancel()
-	_, err = deviceDataCollection.UpdateByID(ctx, monthID, update)
+	_, err = monthsCollection.UpdateByID(ctx, monthID, update)
 	if err != nil {
 		log.Printf("in mongoDatabase.addIDToMonth failed to update month %v's devices", month.MonthNumber)
 		return handleMongoError(err, true, ctrl)
@@ -115,81 +165,57 @@ func (mdb *MongoDatabase) addIDToMonth(deviceID, monthID primitive.ObjectID, dev
 	return nil
 }
 
-func (mdb *MongoDatabase) addIDToDeviceArray(deviceID primitive.ObjectID, deviceDataCollection *mongo.Collection, ctrl *dataTypes.FlowControl) error {
+func (mdb *MongoDatabase) addIDToDeviceArray(deviceID primitive.ObjectID, ctrl *dataTypes.FlowControl) error {
 	if ctrl.Ctx.Err() != nil {
 		log.Printf("stopping mongoDatabase.addIDToDeviceArray: %v", ctrl.Ctx.Err())
 		return ctrl.Ctx.Err()
 	}
 
-	allDeviceIDs, err := mdb.getAllDeviceIDs(deviceDataCollection, ctrl)
+	allDeviceIDs, err := mdb.getAllDeviceIDs(ctrl)
 	if err != nil {
 		log.Println("in mongoDatabase.addIDToDeviceArray failed to get all year IDs")
 		return err
 	}
 	allDeviceIDs = append(allDeviceIDs, deviceID)
-	allDeviceIdsArrayDocumentID, err := getObjectIDFromString(AllDeviceIDsArrayDocumentID, ctrl)
-	if err != nil {
-		log.Println("in mongoDatabase.addIDToDeviceArray yearsArrayDocumentID is invalid")
-		return err
-	}
-	update := bson.M{
-		"$set": bson.M{
-			"device-ids": allDeviceIDs,
-		},
-	}
-	ctx, cancel := context.WithTimeout(ctrl.Ctx, time.Second*30)
-	defer cancel()
-	_, err = deviceDataCollection.UpdateByID(ctx, allDeviceIdsArrayDocumentID, update)
+	err = mdb.setMetadataFields(allDeviceIDsDocument, bson.D{{"device-ids", allDeviceIDs}}, ctrl)
 	if err != nil {
 		log.Println("in mongoDatabase.addIDToDeviceArray failed to update device IDs document")
-		return handleMongoError(err, true, ctrl)
+		return err
 	}
 
 	log.Printf("in mongoDatabase.addIDToDeviceArray added ID to device IDs array: %v", deviceID)
 	return nil
 }
 
-func (mdb *MongoDatabase) addIDToYearArray(yearID primitive.ObjectID, deviceDataCollection *mongo.Collection, ctrl *dataTypes.FlowControl) error {
+func (mdb *MongoDatabase) addIDToYearArray(yearID primitive.ObjectID, ctrl *dataTypes.FlowControl) error {
 	if ctrl.Ctx.Err() != nil {
 		log.Printf("stopping mongoDatabase.addIDToYearArray: %v", ctrl.Ctx.Err())
 		return ctrl.Ctx.Err()
 	}
 
-	allYearIDs, err := mdb.getAllYearIDs(deviceDataCollection, ctrl)
+	allYearIDs, err := mdb.getAllYearIDs(ctrl)
 	if err != nil {
 		log.Println("in mongoDatabase.addIDToYearArray failed to get all year IDs")
 		return err
 	}
 	allYearIDs = append(allYearIDs, yearID)
-	yearsDocumentID, err := getObjectIDFromString(AllYearIDsArrayDocumentID, ctrl)
-	if err != nil {
-		log.Println("in mongoDatabase.addIDToYearArray yearsArrayDocumentID is invalid")
-		return err
-	}
-	update := bson.M{
-		"$set": bson.M{
-			"year-ids": allYearIDs,
-		},
-	}
-	ctx, cancel := context.WithTimeout(ctrl.Ctx, time.Second*30)
-	defer cancel()
-	_, err = deviceDataCollection.UpdateByID(ctx, yearsDocumentID, update)
+	err = mdb.setMetadataFields(allYearIDsDocument, bson.D{{"year-ids", allYearIDs}}, ctrl)
 	if err != nil {
 		log.Println("in mongoDatabase.addNewYear failed to update years document")
-		return handleMongoError(err, true, ctrl)
+		return err
 	}
-	log.Printf("in mongoDatabase.addIDToYearArray added year IDs array: %v", yearsDocumentID)
+	log.Printf("in mongoDatabase.addIDToYearArray added year ID to year IDs array: %v", yearID)
 	return nil
 }
 
-func (mdb *MongoDatabase) getMonthID(monthNumber int, yearID primitive.ObjectID, coll *mongo.Collection, ctrl *dataTypes.FlowControl) (primitive.ObjectID, error) {
+func (mdb *MongoDatabase) getMonthID(monthNumber int, yearID primitive.ObjectID, ctrl *dataTypes.FlowControl) (primitive.ObjectID, error) {
 	if ctrl.Ctx.Err() != nil {
 		log.Printf("stopping mongoDatabase.getMonthID: %v", ctrl.Ctx.Err())
 		return primitive.NilObjectID, ctrl.Ctx.Err()
 	}
 
 	var year dataTypes.Year
-	err := mdb.getAndDecodeDocumentByID(&year, yearID, true, coll, ctrl)
+	err := mdb.getAndDecodeDocumentByID(&year, yearID, true, mdb.collection(mdb.config.Collections.Years), ctrl)
 	if err != nil {
 		log.Println("in MongoDatabase.getMonthID failed to gather month's year document")
 		return primitive.NilObjectID, err
@@ -197,7 +223,7 @@ func (mdb *MongoDatabase) getMonthID(monthNumber int, yearID primitive.ObjectID,
 
 	for _, monthID := range year.Months {
 		var month dataTypes.Month
-		err = mdb.getAndDecodeDocumentByID(&month, monthID, true, coll, ctrl)
+		err = mdb.getAndDecodeDocumentByID(&month, monthID, true, mdb.collection(mdb.config.Collections.Months), ctrl)
 		if err != nil {
 			log.Println("in mongoDatabase.getMonthID failed to get one of year's month documents")
 			return primitive.NilObjectID, err
@@ -208,7 +234,7 @@ func (mdb *MongoDatabase) getMonthID(monthNumber int, yearID primitive.ObjectID,
 		}
 	}
 
-	newMonthID, err := mdb.addNewMonth(monthNumber, year, yearID, coll, ctrl)
+	newMonthID, err := mdb.addNewMonth(monthNumber, year, yearID, ctrl)
 	if err != nil {
 		log.Println("in mongoDatabase.getMonthID failed to add new month")
 		return primitive.NilObjectID, err
@@ -217,13 +243,13 @@ func (mdb *MongoDatabase) getMonthID(monthNumber int, yearID primitive.ObjectID,
 	return newMonthID, nil
 }
 
-func (mdb *MongoDatabase) getYearID(yearNumber int, coll *mongo.Collection, ctrl *dataTypes.FlowControl) (primitive.ObjectID, error) {
+func (mdb *MongoDatabase) getYearID(yearNumber int, ctrl *dataTypes.FlowControl) (primitive.ObjectID, error) {
 	if ctrl.Ctx.Err() != nil {
 		log.Printf("stopping mongoDatabase.getYearID: %v", ctrl.Ctx.Err())
 		return primitive.ObjectID{}, ctrl.Ctx.Err()
 	}
 
-	allYearIDs, err := mdb.getAllYearIDs(coll, ctrl)
+	allYearIDs, err := mdb.getAllYearIDs(ctrl)
 	if err != nil {
 		log.Println("in mongoDatabase.getYearID failed to gather all year ids")
 		return primitive.NilObjectID, err
@@ -231,7 +257,7 @@ func (mdb *MongoDatabase) getYearID(yearNumber int, coll *mongo.Collection, ctrl
 
 	for _, yearID := range allYearIDs {
 		var year dataTypes.Year
-		err = mdb.getAndDecodeDocumentByID(&year, yearID, true, coll, ctrl)
+		err = mdb.getAndDecodeDocumentByID(&year, yearID, true, mdb.collection(mdb.config.Collections.Years), ctrl)
 		if err != nil {
 			log.Println("in mongoDatabase.getYearID failed to get year document")
 			return primitive.NilObjectID, err
@@ -242,7 +268,7 @@ func (mdb *MongoDatabase) getYearID(yearNumber int, coll *mongo.Collection, ctrl
 		}
 	}
 
-	newYearID, err := mdb.addNewYear(yearNumber, coll, ctrl)
+	newYearID, err := mdb.addNewYear(yearNumber, ctrl)
 	if err != nil {
 		log.Println("in mongoDatabase.getYearID failed to add new year")
 		return primitive.ObjectID{}, err
@@ -251,7 +277,7 @@ func (mdb *MongoDatabase) getYearID(yearNumber int, coll *mongo.Collection, ctrl
 	return newYearID, nil
 }
 
-func (mdb *MongoDatabase) addNewYear(yearNumber int, deviceDataCollection *mongo.Collection, ctrl *dataTypes.FlowControl) (primitive.ObjectID, error) {
+func (mdb *MongoDatabase) addNewYear(yearNumber int, ctrl *dataTypes.FlowControl) (primitive.ObjectID, error) {
 	if ctrl.Ctx.Err() != nil {
 		log.Printf("stopping mongoDatabase.addNewYear: %v", ctrl.Ctx.Err())
 		return primitive.NilObjectID, ctrl.Ctx.Err()
@@ -266,13 +292,13 @@ func (mdb *MongoDatabase) addNewYear(yearNumber int, deviceDataCollection *mongo
 	ctx, cancel := context.WithTimeout(ctrl.Ctx, time.Second*30)
 	defer cancel()
 	log.Printf("adding new year %v to database...", yearNumber)
-	_, err := deviceDataCollection.InsertOne(ctx, newYear)
+	_, err := mdb.collection(mdb.config.Collections.Years).InsertOne(ctx, newYear)
 	if err != nil {
 		log.Println("in MongoDatabase.addNewYear failed to insert new year")
 		return primitive.ObjectID{}, handleMongoError(err, false, ctrl)
 	}
 
-	err = mdb.addIDToYearArray(yearID, deviceDataCollection, ctrl)
+	err = mdb.addIDToYearArray(yearID, ctrl)
 	if err != nil {
 		log.Println("in MongoDatabase.addNewYear failed to add id to years array")
 		return primitive.ObjectID{}, err
@@ -282,7 +308,7 @@ func (mdb *MongoDatabase) addNewYear(yearNumber int, deviceDataCollection *mongo
 	return yearID, nil
 }
 
-func (mdb *MongoDatabase) addNewMonth(monthNumber int, year dataTypes.Year, yearID primitive.ObjectID, coll *mongo.Collection, ctrl *dataTypes.FlowControl) (primitive.ObjectID, error) {
+func (mdb *MongoDatabase) addNewMonth(monthNumber int, year dataTypes.Year, yearID primitive.ObjectID, ctrl *dataTypes.FlowControl) (primitive.ObjectID, error) {
 	if ctrl.Ctx.Err() != nil {
 		log.Printf("stopping mongoDatabase.addNewMonth: %v", ctrl.Ctx.Err())
 		return primitive.NilObjectID, ctrl.Ctx.Err()
@@ -298,7 +324,7 @@ func (mdb *MongoDatabase) addNewMonth(monthNumber int, year dataTypes.Year, year
 	ctxForInsert, cancelForInsert := context.WithTimeout(ctrl.Ctx, time.Second*30)
 	defer cancelForInsert()
 	log.Printf("adding new month %v with year %v to database...", monthNumber, year.YearNumber)
-	_, err := coll.InsertOne(ctxForInsert, newMonth)
+	_, err := mdb.collection(mdb.config.Collections.Months).InsertOne(ctxForInsert, newMonth)
 	if err != nil {
 		log.Println("in MongoDatabase.addNewYear failed to insert new month")
 		return primitive.ObjectID{}, handleMongoError(err, false, ctrl)
@@ -308,7 +334,7 @@ func (mdb *MongoDatabase) addNewMonth(monthNumber int, year dataTypes.Year, year
 	update := bson.D{{"$set", bson.D{{"months", year.Months}}}}
 	ctxForUpdate, cancelForUpdate := context.WithTimeout(ctrl.Ctx, time.Second*30)
 	defer cancelForUpdate()
-	_, err = coll.UpdateByID(ctxForUpdate, yearID, update)
+	_, err = mdb.collection(mdb.config.Collections.Years).UpdateByID(ctxForUpdate, yearID, update)
 	if err != nil {
 		log.Printf("in MongoDatabase.addNewMonth failed to update year %v with month %v", year.Months, monthNumber)
 		return primitive.ObjectID{}, handleMongoError(err, true, ctrl)
@@ -317,24 +343,16 @@ func (mdb *MongoDatabase) addNewMonth(monthNumber int, year dataTypes.Year, year
 	return monthID, nil
 }
 
-func (mdb *MongoDatabase) incrementUnvalidatedNumberOfDevices(unvalidatedMinMax *dataTypes.MinMaxValues, deviceDataCollection *mongo.Collection, ctrl *dataTypes.FlowControl) error {
+func (mdb *MongoDatabase) incrementUnvalidatedNumberOfDevices(unvalidatedMinMax *dataTypes.MinMaxValues, ctrl *dataTypes.FlowControl) error {
 	if ctrl.Ctx.Err() != nil {
 		log.Printf("stopping mongoDatabase.incrementUnvalidatedNumberOfDevices: %v", ctrl.Ctx.Err())
 		return ctrl.Ctx.Err()
 	}
 
-	minMaxValuedDocumentID, err := getObjectIDFromString(MinMaxValuesDocumentID, ctrl)
-	if err != nil {
-		log.Println("in mongoDatabase.incrementUnvalidatedNumberOfDevices MinMaxValuesDocumentID is invalid")
-		return err
-	}
-	update := bson.D{{"$set", bson.D{{"unvalidated", unvalidatedMinMax}}}}
-	ctx, cancel := context.WithTimeout(ctrl.Ctx, time.Second*30)
-	defer cancel()
-	_, err = deviceDataCollection.UpdateByID(ctx, minMaxValuedDocumentID, update)
+	err := mdb.setMetadataFields(minMaxValuesDocument, bson.D{{"unvalidated", unvalidatedMinMax}}, ctrl)
 	if err != nil {
 		log.Println("in mongoDatabase.incrementUnvalidatedNumberOfDevices failed to update minmax document")
-		return handleMongoError(err, true, ctrl)
+		return err
 	}
 	return nil
 }
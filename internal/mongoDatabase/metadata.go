@@ -0,0 +1,254 @@
+package mongoDatabase
+
+import (
+	"context"
+	"errors"
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/helpers"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"time"
+)
+
+// The singleton documents are kept in the metadata collection under these names.
+const (
+	minMaxValuesDocument = "min-max-values"
+	allYearIDsDocument   = "all-year-ids"
+	allDeviceIDsDocument = "all-device-ids"
+	queueSizeDocument    = "queue-size"
+	// validationFlagDocument tells whether a validation of the scores began and didn't finish.
+	validationFlagDocument = "validation-flag"
+)
+
+var metadataDocuments = []string{minMaxValuesDocument, allYearIDsDocument, allDeviceIDsDocument, queueSizeDocument,
+	validationFlagDocument}
+
+// legacyMetadataDocumentIDs are the fixed IDs the singleton documents had in device_data, and in queue_size_counter
+// for the queue size, before they moved to the metadata collection.
+var legacyMetadataDocumentIDs = map[string]string{
+	minMaxValuesDocument: "671e570d57bc90b562fd6715",
+	allYearIDsDocument:   "6749c756c1347240b05702ca",
+	allDeviceIDsDocument: "6749c833c1347240b05702cb",
+	queueSizeDocument:    "673f65570a8dbe79b55eaaf9",
+}
+
+const (
+	legacyQueueSizeCollection = "queue_size_counter"
+	// legacyDeviceDataCollection held the devices, years, months, validation flags and metadata documents together.
+	legacyDeviceDataCollection = "device_data"
+)
+
+// Codes Mongo answers with when an index with the same name or keys already exists with other options.
+const (
+	indexOptionsConflictCode   = 85
+	indexKeySpecsConflictCode  = 86
+	bootstrapOperationsTimeout = 30 * time.Second
+)
+
+func (mdb *MongoDatabase) getMetadataDocument(name string, result interface{}, ctrl *dataTypes.FlowControl) error {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping mongoDatabase.getMetadataDocument: %v", ctrl.Ctx.Err())
+		return ctrl.Ctx.Err()
+	}
+
+	ctx, cancel := context.WithTimeout(ctrl.Ctx, 10*time.Second)
+	defer cancel()
+	err := mdb.collection(mdb.config.Collections.Metadata).FindOne(ctx, bson.M{"_id": name}).Decode(result)
+	if err != nil {
+		log.Printf("in mongoDatabase.getMetadataDocument failed to find %v document: %v", name, err)
+		return handleMongoError(err, true, ctrl)
+	}
+	return nil
+}
+
+func (mdb *MongoDatabase) setMetadataFields(name string, fields bson.D, ctrl *dataTypes.FlowControl) error {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping mongoDatabase.setMetadataFields: %v", ctrl.Ctx.Err())
+		return ctrl.Ctx.Err()
+	}
+
+	ctx, cancel := context.WithTimeout(ctrl.Ctx, 30*time.Second)
+	defer cancel()
+	_, err := mdb.collection(mdb.config.Collections.Metadata).UpdateByID(ctx, name, bson.D{{"$set", fields}})
+	if err != nil {
+		log.Printf("in mongoDatabase.setMetadataFields failed to update %v document: %v", name, err)
+		return handleMongoError(err, true, ctrl)
+	}
+	return nil
+}
+
+// Bootstrap creates what a fresh Mongo instance lacks: the metadata documents and the indexes, among them the text
+// index fullTextSearch needs. It leaves whatever already exists as it is, so it is safe to run on every Connect.
+// Metadata documents still kept under their legacy fixed IDs are moved to the metadata collection.
+func (mdb *MongoDatabase) Bootstrap(ctrl *dataTypes.FlowControl) error {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping mongoDatabase.Bootstrap: %v", ctrl.Ctx.Err())
+		return ctrl.Ctx.Err()
+	}
+
+	defaultDocuments, err := mdb.getDefaultMetadataDocuments(ctrl)
+	if err != nil {
+		log.Printf("in mongoDatabase.Bootstrap failed to build default metadata documents: %v", err)
+		return err
+	}
+	for _, name := range metadataDocuments {
+		err = mdb.bootstrapMetadataDocument(name, defaultDocuments[name], ctrl)
+		if err != nil {
+			log.Printf("in mongoDatabase.Bootstrap failed to bootstrap %v document: %v", name, err)
+			return err
+		}
+	}
+
+	err = mdb.createIndexes(ctrl)
+	if err != nil {
+		log.Printf("in mongoDatabase.Bootstrap failed to create indexes: %v", err)
+		return err
+	}
+	return nil
+}
+
+// getDefaultMetadataDocuments returns the documents of an empty database. The queue size counts the queue, in case
+// the queue outlived its counter.
+func (mdb *MongoDatabase) getDefaultMetadataDocuments(ctrl *dataTypes.FlowControl) (map[string]bson.M, error) {
+	ctx, cancel := context.WithTimeout(ctrl.Ctx, bootstrapOperationsTimeout)
+	defer cancel()
+	queueSize, err := mdb.collection(mdb.config.Collections.Queue).CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return nil, handleMongoError(err, false, ctrl)
+	}
+
+	defaultMinMax := helpers.GetDefaultMinMax()
+	return map[string]bson.M{
+		minMaxValuesDocument:   {"validated": defaultMinMax, "unvalidated": defaultMinMax},
+		allYearIDsDocument:     {"year-ids": make([]primitive.ObjectID, 0)},
+		allDeviceIDsDocument:   {"device-ids": make([]primitive.ObjectID, 0)},
+		queueSizeDocument:      {"queue-size": int(queueSize)},
+		validationFlagDocument: {"is-unfinished-validation": false},
+	}, nil
+}
+
+// bootstrapMetadataDocument creates the named document, from its legacy document if there is one and from
+// defaultDocument otherwise, unless it exists. The legacy document is deleted once the named one exists.
+func (mdb *MongoDatabase) bootstrapMetadataDocument(name string, defaultDocument bson.M, ctrl *dataTypes.FlowControl) error {
+	metadataCollection := mdb.collection(mdb.config.Collections.Metadata)
+	legacyCollection := mdb.collection(legacyDeviceDataCollection)
+	if name == queueSizeDocument {
+		legacyCollection = mdb.collection(legacyQueueSizeCollection)
+	}
+	legacyIDString, hasLegacyDocument := legacyMetadataDocumentIDs[name]
+	var legacyID primitive.ObjectID
+	if hasLegacyDocument {
+		var err error
+		legacyID, err = getObjectIDFromString(legacyIDString, ctrl)
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctrl.Ctx, bootstrapOperationsTimeout)
+	defer cancel()
+	count, err := metadataCollection.CountDocuments(ctx, bson.M{"_id": name}, options.Count().SetLimit(1))
+	if err != nil {
+		return handleMongoError(err, false, ctrl)
+	}
+	if count == 0 {
+		document := defaultDocument
+		var legacyDocument bson.M
+		if hasLegacyDocument {
+			err = legacyCollection.FindOne(ctx, bson.M{"_id": legacyID}).Decode(&legacyDocument)
+			if err == nil {
+				log.Printf("in mongoDatabase.bootstrapMetadataDocument moving legacy %v document %v", name, legacyID.Hex())
+				document = legacyDocument
+			} else if !errors.Is(err, mongo.ErrNoDocuments) {
+				return handleMongoError(err, false, ctrl)
+			}
+		}
+		document["_id"] = name
+
+		_, err = metadataCollection.InsertOne(ctx, document)
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return handleMongoError(err, false, ctrl)
+		}
+		log.Printf("in mongoDatabase.bootstrapMetadataDocument created %v document", name)
+	}
+
+	if !hasLegacyDocument {
+		return nil
+	}
+	_, err = legacyCollection.DeleteOne(ctx, bson.M{"_id": legacyID})
+	if err != nil {
+		return handleMongoError(err, false, ctrl)
+	}
+	return nil
+}
+
+// createIndexes creates the indexes the queries rely on. An index that exists already under other options is kept,
+// with a warning.
+func (mdb *MongoDatabase) createIndexes(ctrl *dataTypes.FlowControl) error {
+	for collectionName, models := range mdb.getIndexModels() {
+		err := mdb.createCollectionIndexes(collectionName, models, ctrl)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getIndexModels returns the indexes of each collection.
+func (mdb *MongoDatabase) getIndexModels() map[string][]mongo.IndexModel {
+	collections := mdb.config.Collections
+	return map[string][]mongo.IndexModel{
+		collections.Devices: {
+			{Keys: bson.D{{"name", "text"}}},
+			{Keys: bson.D{{"name", 1}}},
+			{Keys: bson.D{{"year", 1}}},
+			{Keys: bson.D{{"brand", 1}, {"validated-final-score", -1}}},
+			{Keys: bson.D{{"real-price", 1}}},
+			{Keys: bson.D{{"validated-final-score", -1}}},
+			{Keys: bson.D{{"specs.release-date", 1}}},
+		},
+		collections.Years: {
+			{Keys: bson.D{{"year-number", 1}}},
+		},
+		collections.Months: {
+			{Keys: bson.D{{"year", 1}, {"month-number", 1}}},
+		},
+		collections.Queue: {
+			{Keys: bson.D{{"priority", -1}}},
+			{Keys: bson.D{{"name", 1}}},
+		},
+		collections.PriceHistory: {
+			{Keys: bson.D{{"device-id", 1}, {"time", -1}}},
+		},
+		collections.AlertSubscriptions: {
+			{Keys: bson.D{{"device-id", 1}}},
+		},
+	}
+}
+
+func (mdb *MongoDatabase) createCollectionIndexes(collectionName string, models []mongo.IndexModel, ctrl *dataTypes.FlowControl) error {
+	for _, model := range models {
+		ctx, cancel := context.WithTimeout(ctrl.Ctx, bootstrapOperationsTimeout)
+		_, err := mdb.collection(collectionName).Indexes().CreateOne(ctx, model)
+		cancel()
+		if isIndexConflict(err) {
+			log.Printf("WARNING: in mongoDatabase.createCollectionIndexes kept the existing index of %v on %v: %v", collectionName, model.Keys, err)
+			continue
+		}
+		if err != nil {
+			return handleMongoError(err, false, ctrl)
+		}
+	}
+	return nil
+}
+
+func isIndexConflict(err error) bool {
+	var commandErr mongo.CommandError
+	if !errors.As(err, &commandErr) {
+		return false
+	}
+	return commandErr.Code == indexOptionsConflictCode || commandErr.Code == indexKeySpecsConflictCode
+}
@@ -0,0 +1,264 @@
+package mongoDatabase
+
+import (
+	"context"
+	"fmt"
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorMonitoring"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"time"
+)
+
+const migrationOperationsTimeout = 5 * time.Minute
+
+// migration is a forward change of the stored documents. run changes them and reports how many it changed, or, on a
+// dry run, only counts the documents it would change. It must be safe to run twice, since two instances starting
+// together may both run it before either records it.
+type migration struct {
+	version int
+	name    string
+	run     func(mdb *MongoDatabase, isDryRun bool, ctrl *dataTypes.FlowControl) (int64, error)
+}
+
+// migrations are applied in this order. Append new ones with the next version and never change applied ones.
+var migrations = []migration{
+	{version: 1, name: "move-final-score-to-validated-and-unvalidated-scores", run: (*MongoDatabase).moveFinalScore},
+	{version: 2, name: "split-device-data-into-devices-years-and-months", run: (*MongoDatabase).splitDeviceData},
+}
+
+// Migrate applies the migrations that aren't recorded in the schema migrations collection, in order, and records
+// each one once it is applied. With isDryRun, nothing is changed or recorded. It returns the migrations it applied, or
+// would apply, with the documents each one affected.
+func (mdb *MongoDatabase) Migrate(isDryRun bool, ctrl *dataTypes.FlowControl) ([]dataTypes.SchemaMigration, error) {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping mongoDatabase.Migrate: %v", ctrl.Ctx.Err())
+		return nil, ctrl.Ctx.Err()
+	}
+
+	appliedMigrations, err := mdb.GetAppliedMigrations(ctrl)
+	if err != nil {
+		log.Printf("in mongoDatabase.Migrate failed to get applied migrations: %v", err)
+		return nil, err
+	}
+	isApplied := make(map[int]bool, len(appliedMigrations))
+	for _, appliedMigration := range appliedMigrations {
+		isApplied[appliedMigration.Version] = true
+	}
+
+	var results []dataTypes.SchemaMigration
+	for _, pendingMigration := range migrations {
+		if isApplied[pendingMigration.version] {
+			continue
+		}
+		affectedDocuments, err := pendingMigration.run(mdb, isDryRun, ctrl)
+		if err != nil {
+			log.Printf("in mongoDatabase.Migrate migration %v %v failed: %v", pendingMigration.version, pendingMigration.name, err)
+			return results, err
+		}
+		result := dataTypes.SchemaMigration{
+			Version:           pendingMigration.version,
+			Name:              pendingMigration.name,
+			AffectedDocuments: affectedDocuments,
+		}
+		if isDryRun {
+			log.Printf("in mongoDatabase.Migrate migration %v %v would affect %v documents", result.Version, result.Name, affectedDocuments)
+			results = append(results, result)
+			continue
+		}
+
+		result.AppliedAt = time.Now()
+		err = mdb.recordMigration(result, ctrl)
+		if err != nil {
+			log.Printf("in mongoDatabase.Migrate failed to record migration %v: %v", result.Version, err)
+			return results, err
+		}
+		log.Printf("in mongoDatabase.Migrate applied migration %v %v to %v documents", result.Version, result.Name, affectedDocuments)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// warnOfPendingMigrations logs the migrations cmd/migrateSchema has yet to apply.
+func (mdb *MongoDatabase) warnOfPendingMigrations(ctrl *dataTypes.FlowControl) {
+	appliedMigrations, err := mdb.GetAppliedMigrations(ctrl)
+	if err != nil {
+		log.Printf("WARNING: in mongoDatabase.warnOfPendingMigrations failed to get applied migrations: %v", err)
+		return
+	}
+	if pending := len(migrations) - len(appliedMigrations); pending > 0 {
+		log.Printf("WARNING: %v schema migrations are pending, apply them with cmd/migrateSchema", pending)
+	}
+}
+
+// GetAppliedMigrations returns the recorded migrations by version.
+func (mdb *MongoDatabase) GetAppliedMigrations(ctrl *dataTypes.FlowControl) ([]dataTypes.SchemaMigration, error) {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping mongoDatabase.GetAppliedMigrations: %v", ctrl.Ctx.Err())
+		return nil, ctrl.Ctx.Err()
+	}
+
+	ctx, cancel := context.WithTimeout(ctrl.Ctx, 30*time.Second)
+	defer cancel()
+	cursor, err := mdb.collection(mdb.config.Collections.SchemaMigrations).Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, handleMongoError(err, false, ctrl)
+	}
+	appliedMigrations := make([]dataTypes.SchemaMigration, 0)
+	err = cursor.All(ctx, &appliedMigrations)
+	if err != nil {
+		return nil, handleMongoError(err, false, ctrl)
+	}
+	return appliedMigrations, nil
+}
+
+// recordMigration records an applied migration. A record another instance wrote first is kept.
+func (mdb *MongoDatabase) recordMigration(appliedMigration dataTypes.SchemaMigration, ctrl *dataTypes.FlowControl) error {
+	ctx, cancel := context.WithTimeout(ctrl.Ctx, 30*time.Second)
+	defer cancel()
+	_, err := mdb.collection(mdb.config.Collections.SchemaMigrations).InsertOne(ctx, appliedMigration)
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return handleMongoError(err, false, ctrl)
+	}
+	return nil
+}
+
+// moveFinalScore moves the final-score of devices stored before the score was split into validated and unvalidated
+// scores onto both of them, unless a device has them already, and removes it.
+func (mdb *MongoDatabase) moveFinalScore(isDryRun bool, ctrl *dataTypes.FlowControl) (int64, error) {
+	coll := mdb.collection(legacyDeviceDataCollection)
+	filter := bson.M{"final-score": bson.M{"$exists": true}}
+	ctx, cancel := context.WithTimeout(ctrl.Ctx, migrationOperationsTimeout)
+	defer cancel()
+	if isDryRun {
+		count, err := coll.CountDocuments(ctx, filter)
+		if err != nil {
+			return 0, handleMongoError(err, false, ctrl)
+		}
+		return count, nil
+	}
+
+	update := mongo.Pipeline{
+		{{"$set", bson.D{
+			{"validated-final-score", bson.D{{"$ifNull", bson.A{"$validated-final-score", "$final-score"}}}},
+			{"unvalidated-final-score", bson.D{{"$ifNull", bson.A{"$unvalidated-final-score", "$final-score"}}}},
+		}}},
+		{{"$unset", "final-score"}},
+	}
+	result, err := coll.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, handleMongoError(err, false, ctrl)
+	}
+	return result.ModifiedCount, nil
+}
+
+// splitDeviceData moves the years, months and devices out of device_data into their own collections, and an
+// unfinished validation flag into the validation flag document. device_data is dropped once nothing is left in it.
+func (mdb *MongoDatabase) splitDeviceData(isDryRun bool, ctrl *dataTypes.FlowControl) (int64, error) {
+	legacyCollection := mdb.collection(legacyDeviceDataCollection)
+	targets := []struct {
+		collectionName string
+		filter         bson.M
+	}{
+		{mdb.config.Collections.Years, bson.M{"year-number": bson.M{"$exists": true}}},
+		{mdb.config.Collections.Months, bson.M{"month-number": bson.M{"$exists": true}}},
+		{mdb.config.Collections.Devices, bson.M{"name": bson.M{"$exists": true}}},
+	}
+	ctx, cancel := context.WithTimeout(ctrl.Ctx, migrationOperationsTimeout)
+	defer cancel()
+
+	var movedDocuments int64
+	for _, target := range targets {
+		count, err := legacyCollection.CountDocuments(ctx, target.filter)
+		if err != nil {
+			return movedDocuments, handleMongoError(err, false, ctrl)
+		}
+		movedDocuments += count
+		if isDryRun || count == 0 {
+			continue
+		}
+
+		// Documents already in the target, from an earlier run that stopped halfway, are kept.
+		pipeline := mongo.Pipeline{
+			{{"$match", target.filter}},
+			{{"$merge", bson.D{{"into", target.collectionName}, {"on", "_id"}, {"whenMatched", "keepExisting"}, {"whenNotMatched", "insert"}}}},
+		}
+		cursor, err := legacyCollection.Aggregate(ctx, pipeline)
+		if err != nil {
+			log.Printf("in mongoDatabase.splitDeviceData failed to copy into %v: %v", target.collectionName, err)
+			return movedDocuments, handleMongoError(err, false, ctrl)
+		}
+		if err = cursor.Close(ctx); err != nil {
+			log.Printf("WARNING: Failed to close cursor: %v", err)
+			errorMonitoring.IncrementError(errorMonitoring.CleanUpError, ctrl)
+		}
+		err = mdb.checkCopied(legacyCollection, target.collectionName, target.filter, ctrl)
+		if err != nil {
+			log.Printf("in mongoDatabase.splitDeviceData kept the documents copied into %v: %v", target.collectionName, err)
+			return movedDocuments, err
+		}
+		_, err = legacyCollection.DeleteMany(ctx, target.filter)
+		if err != nil {
+			log.Printf("in mongoDatabase.splitDeviceData failed to delete what was copied into %v: %v", target.collectionName, err)
+			return movedDocuments, handleMongoError(err, false, ctrl)
+		}
+	}
+
+	validationFlagFilter := bson.M{"is-unfinished-validation": bson.M{"$exists": true}}
+	count, err := legacyCollection.CountDocuments(ctx, validationFlagFilter)
+	if err != nil {
+		return movedDocuments, handleMongoError(err, false, ctrl)
+	}
+	movedDocuments += count
+	if isDryRun {
+		return movedDocuments, nil
+	}
+	if count > 0 {
+		err = mdb.setMetadataFields(validationFlagDocument, bson.D{{"is-unfinished-validation", true}}, ctrl)
+		if err != nil {
+			log.Println("in mongoDatabase.splitDeviceData failed to set unfinished validation flag")
+			return movedDocuments, err
+		}
+		_, err = legacyCollection.DeleteMany(ctx, validationFlagFilter)
+		if err != nil {
+			return movedDocuments, handleMongoError(err, false, ctrl)
+		}
+	}
+
+	remaining, err := legacyCollection.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return movedDocuments, handleMongoError(err, false, ctrl)
+	}
+	if remaining > 0 {
+		log.Printf("WARNING: in mongoDatabase.splitDeviceData kept %v unrecognized documents in %v", remaining, legacyDeviceDataCollection)
+		return movedDocuments, nil
+	}
+	err = legacyCollection.Drop(ctx)
+	if err != nil {
+		return movedDocuments, handleMongoError(err, false, ctrl)
+	}
+	return movedDocuments, nil
+}
+
+// checkCopied returns an error unless every document of the source collection that matches the filter is in the
+// target collection, so that nothing is deleted before it is copied.
+func (mdb *MongoDatabase) checkCopied(source *mongo.Collection, targetName string, filter bson.M, ctrl *dataTypes.FlowControl) error {
+	ctx, cancel := context.WithTimeout(ctrl.Ctx, migrationOperationsTimeout)
+	defer cancel()
+	ids, err := source.Distinct(ctx, "_id", filter)
+	if err != nil {
+		return handleMongoError(err, false, ctrl)
+	}
+	copied, err := mdb.collection(targetName).CountDocuments(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return handleMongoError(err, false, ctrl)
+	}
+	if copied != int64(len(ids)) {
+		errorMonitoring.IncrementError(errorMonitoring.GeneralDatabaseError, ctrl)
+		return errorTypes.NewGeneralDatabaseError(fmt.Sprintf("only %v of %v documents were copied into %v", copied, len(ids), targetName))
+	}
+	return nil
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
 	"github.com/ItaiHalperin/Device-Rec-API/internal/aiAnalysis"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/benchmarkEstimation"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/brandCatalog"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/config"
 	"github.com/ItaiHalperin/Device-Rec-API/internal/reviewer"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -12,21 +15,24 @@ import (
 	"time"
 )
 
-const (
-	URI                         = "mongodb://localhost:27017"
-	MinMaxValuesDocumentID      = "671e570d57bc90b562fd6715"
-	AllYearIDsArrayDocumentID   = "6749c756c1347240b05702ca"
-	AllDeviceIDsArrayDocumentID = "6749c833c1347240b05702cb"
-	MaxQueueSize                = 30
-	QueueSizeDocumentID         = "673f65570a8dbe79b55eaaf9"
-	Database                    = "local"
-	QueueCollection             = "queue"
-	QueueSizeCollection         = "queue_size_counter"
-	DeviceDataCollection        = "device_data"
-)
-
+// MongoDatabase stores everything in the collections the configuration names, and the singleton documents in its metadata collection.
 type MongoDatabase struct {
-	client *mongo.Client
+	client       *mongo.Client
+	config       config.MongoConfig
+	maxQueueSize int
+	brands       *brandCatalog.Catalog
+	// estimator keeps the regression model fitted on the stored devices.
+	estimator *benchmarkEstimation.Estimator
+}
+
+func NewMongoDatabase(mongoConfig config.MongoConfig, maxQueueSize int, brands *brandCatalog.Catalog) *MongoDatabase {
+	mdb := &MongoDatabase{config: mongoConfig, maxQueueSize: maxQueueSize, brands: brands}
+	mdb.estimator = benchmarkEstimation.NewEstimator(mdb, brands)
+	return mdb
+}
+
+func (mdb *MongoDatabase) collection(name string) *mongo.Collection {
+	return mdb.client.Database(mdb.config.Database).Collection(name)
 }
 
 func (mdb *MongoDatabase) NormalizeUnvalidatedScores(minMaxValues dataTypes.MinMaxValues, ctrl *dataTypes.FlowControl) error {
@@ -35,9 +41,9 @@ func (mdb *MongoDatabase) NormalizeUnvalidatedScores(minMaxValues dataTypes.MinM
 		return ctrl.Ctx.Err()
 	}
 
-	deviceDataCollection := mdb.client.Database("local").Collection(DeviceDataCollection)
+	devicesCollection := mdb.collection(mdb.config.Collections.Devices)
 
-	allDeviceIDs, err := mdb.getAllDeviceIDs(deviceDataCollection, ctrl)
+	allDeviceIDs, err := mdb.getAllDeviceIDs(ctrl)
 
 	if err != nil {
 		log.Println("in mongoDatabase.NormalizeUnvalidatedScores failed to get all device IDs")
@@ -45,7 +51,7 @@ func (mdb *MongoDatabase) NormalizeUnvalidatedScores(minMaxValues dataTypes.MinM
 	}
 
 	for _, curDeviceID := range allDeviceIDs {
-		err = mdb.normalizeDeviceScoresByID(curDeviceID, minMaxValues, deviceDataCollection, ctrl)
+		err = mdb.normalizeDeviceScoresByID(curDeviceID, minMaxValues, devicesCollection, ctrl)
 		if err != nil {
 			log.Printf("in mongoDatabase.NormalizeUnvalidatedScores failed to normalize review scores: %v", err)
 			return err
@@ -0,0 +1,161 @@
+package mongoDatabase
+
+import (
+	"context"
+	"errors"
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorMonitoring"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"time"
+)
+
+// recordPriceObservation adds the device's current price to its price history, unless that price was
+// already recorded.
+func (mdb *MongoDatabase) recordPriceObservation(device *dataTypes.Device, ctrl *dataTypes.FlowControl) error {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping mongoDatabase.recordPriceObservation: %v", ctrl.Ctx.Err())
+		return ctrl.Ctx.Err()
+	}
+
+	coll := mdb.collection(mdb.config.Collections.PriceHistory)
+	provenance := device.Provenance[dataTypes.RealPriceProvenance]
+	observedAt := provenance.Time
+	if observedAt.IsZero() {
+		observedAt = time.Now()
+	}
+
+	var latest dataTypes.PricePoint
+	ctxForFind, cancelForFind := context.WithTimeout(ctrl.Ctx, time.Second*10)
+	defer cancelForFind()
+	err := coll.FindOne(ctxForFind, bson.M{"device-id": device.ID},
+		options.FindOne().SetSort(bson.D{{Key: "time", Value: -1}})).Decode(&latest)
+	if err == nil && !observedAt.After(latest.Time) {
+		return nil
+	}
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		err = handleMongoError(err, false, ctrl)
+		log.Printf("in mongoDatabase.recordPriceObservation (device: %v) failed to get latest price: %v", device.Name, err)
+		return err
+	}
+
+	pricePoint := dataTypes.PricePoint{
+		DeviceID:  device.ID,
+		Price:     device.RealPrice,
+		Currency:  device.Currency,
+		SourceURL: provenance.URL,
+		Time:      observedAt,
+	}
+	ctxForInsert, cancelForInsert := context.WithTimeout(ctrl.Ctx, time.Second*10)
+	defer cancelForInsert()
+	_, err = coll.InsertOne(ctxForInsert, pricePoint)
+	if err != nil {
+		err = handleMongoError(err, false, ctrl)
+		log.Printf("in mongoDatabase.recordPriceObservation (device: %v) failed to insert price: %v", device.Name, err)
+		return err
+	}
+	return nil
+}
+
+// GetPriceHistory returns the prices of a device observed since the given time, oldest first.
+func (mdb *MongoDatabase) GetPriceHistory(deviceID primitive.ObjectID, since time.Time, ctrl *dataTypes.FlowControl) ([]dataTypes.PricePoint, error) {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping mongoDatabase.GetPriceHistory: %v", ctrl.Ctx.Err())
+		return nil, ctrl.Ctx.Err()
+	}
+
+	coll := mdb.collection(mdb.config.Collections.PriceHistory)
+	filter := bson.M{"device-id": deviceID, "time": bson.M{"$gte": since}}
+	ctxForFind, cancelForFind := context.WithTimeout(ctrl.Ctx, time.Second*30)
+	defer cancelForFind()
+	cursor, err := coll.Find(ctxForFind, filter, options.Find().SetSort(bson.D{{Key: "time", Value: 1}}))
+	if err != nil {
+		err = handleMongoError(err, false, ctrl)
+		log.Printf("in mongoDatabase.GetPriceHistory failed to find prices: %v", err)
+		return nil, err
+	}
+	ctxForClose, cancelForClose := context.WithTimeout(ctrl.Ctx, time.Second*30)
+	defer cancelForClose()
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		err = cursor.Close(ctx)
+		if err != nil {
+			log.Printf("WARNING: Failed to close cursor: %v", err)
+			errorMonitoring.IncrementError(errorMonitoring.CleanUpError, ctrl)
+		}
+	}(cursor, ctxForClose)
+
+	ctxForDecode, cancelForDecode := context.WithTimeout(ctrl.Ctx, 10*time.Second)
+	defer cancelForDecode()
+	var prices []dataTypes.PricePoint
+	if err = cursor.All(ctxForDecode, &prices); err != nil {
+		log.Println("in mongoDatabase.GetPriceHistory failed to decode cursor")
+		return nil, handleMongoError(err, true, ctrl)
+	}
+	return prices, nil
+}
+
+// GetLowestPrices returns, per device, the lowest price observed since the given time and the number of observations.
+// Devices without observations in that window are missing from the result.
+func (mdb *MongoDatabase) GetLowestPrices(deviceIDs []primitive.ObjectID, since time.Time, ctrl *dataTypes.FlowControl) (map[primitive.ObjectID]dataTypes.LowestPrice, error) {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping mongoDatabase.GetLowestPrices: %v", ctrl.Ctx.Err())
+		return nil, ctrl.Ctx.Err()
+	}
+
+	coll := mdb.collection(mdb.config.Collections.PriceHistory)
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"device-id": bson.M{"$in": deviceIDs}, "time": bson.M{"$gte": since}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$device-id", "lowest-price": bson.M{"$min": "$price"}, "observations": bson.M{"$sum": 1}}}},
+	}
+	ctxForAggregate, cancelForAggregate := context.WithTimeout(ctrl.Ctx, time.Second*30)
+	defer cancelForAggregate()
+	cursor, err := coll.Aggregate(ctxForAggregate, pipeline)
+	if err != nil {
+		err = handleMongoError(err, false, ctrl)
+		log.Printf("in mongoDatabase.GetLowestPrices failed to aggregate prices: %v", err)
+		return nil, err
+	}
+	ctxForClose, cancelForClose := context.WithTimeout(ctrl.Ctx, time.Second*30)
+	defer cancelForClose()
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		err = cursor.Close(ctx)
+		if err != nil {
+			log.Printf("WARNING: Failed to close cursor: %v", err)
+			errorMonitoring.IncrementError(errorMonitoring.CleanUpError, ctrl)
+		}
+	}(cursor, ctxForClose)
+
+	ctxForDecode, cancelForDecode := context.WithTimeout(ctrl.Ctx, 10*time.Second)
+	defer cancelForDecode()
+	var results []struct {
+		DeviceID     primitive.ObjectID `bson:"_id"`
+		LowestPrice  int                `bson:"lowest-price"`
+		Observations int                `bson:"observations"`
+	}
+	if err = cursor.All(ctxForDecode, &results); err != nil {
+		log.Println("in mongoDatabase.GetLowestPrices failed to decode cursor")
+		return nil, handleMongoError(err, true, ctrl)
+	}
+
+	lowestPrices := make(map[primitive.ObjectID]dataTypes.LowestPrice, len(results))
+	for _, result := range results {
+		lowestPrices[result.DeviceID] = dataTypes.LowestPrice{Price: result.LowestPrice, Observations: result.Observations}
+	}
+	return lowestPrices, nil
+}
+
+func (mdb *MongoDatabase) deletePriceHistory(ctrl *dataTypes.FlowControl) error {
+	coll := mdb.collection(mdb.config.Collections.PriceHistory)
+	ctx, cancel := context.WithTimeout(ctrl.Ctx, time.Minute)
+	defer cancel()
+	_, err := coll.DeleteMany(ctx, bson.M{})
+	if err != nil {
+		err = handleMongoError(err, true, ctrl)
+		log.Printf("in mongoDatabase.deletePriceHistory failed to delete: %v", err)
+		return err
+	}
+	return nil
+}
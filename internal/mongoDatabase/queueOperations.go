@@ -9,74 +9,156 @@ import (
 	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
 	"github.com/ItaiHalperin/Device-Rec-API/internal/helpers"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"log"
 	"time"
 )
 
-const (
-	Detail = 0
-	Image  = 1
-)
-
-func (mdb *MongoDatabase) EnqueueDeviceBatch(deviceNamesAndLinks map[string][]string, ctrl *dataTypes.FlowControl) error {
+func (mdb *MongoDatabase) EnqueueDeviceBatch(deviceNamesAndLinks map[string][]string, ctrl *dataTypes.FlowControl) ([]string, error) {
 	if ctrl.Ctx.Err() != nil {
 		log.Printf("stopping mongoDatabase.EnqueueDeviceBatch: %v", ctrl.Ctx.Err())
-		return ctrl.Ctx.Err()
+		return nil, ctrl.Ctx.Err()
 	}
 
-	queueSizeCollection := mdb.client.Database("local").Collection(QueueSizeCollection)
-	queueCollection := mdb.client.Database("local").Collection(QueueCollection)
+	queueCollection := mdb.collection(mdb.config.Collections.Queue)
 
-	queueSize, err := mdb.GetQueueSize(queueSizeCollection, ctrl)
+	queueSize, err := mdb.GetQueueSize(ctrl)
 	if err != nil {
 		log.Printf("in mongoDatabase.EnqueueDeviceBatch error getting queue size: %v", err)
-		return err
+		return nil, err
 	}
-	remainingSpace := MaxQueueSize - queueSize
+	remainingSpace := mdb.maxQueueSize - queueSize
 
-	if remainingSpace == 0 {
-		return nil
+	if remainingSpace <= 0 {
+		return nil, nil
 	}
 	err = mdb.excludeAllExistingDevices(deviceNamesAndLinks, ctrl)
 	if err != nil {
 		log.Printf("in mongoDatabase.EnqueueDeviceBatch error excluding existing devices: %v", err)
-		return err
+		return nil, err
 	}
 	deviceSubset := helpers.GetSubMap(deviceNamesAndLinks, remainingSpace)
 
+	if len(deviceSubset) == 0 {
+		return nil, nil
+	}
+
 	var devicesForUploadToQueue []interface{}
 
 	for deviceName, detailAndImage := range deviceSubset {
-		devicesForUploadToQueue = append(devicesForUploadToQueue, dataTypes.DeviceInQueue{Name: deviceName, Detail: detailAndImage[Detail], Image: detailAndImage[Image]})
+		devicesForUploadToQueue = append(devicesForUploadToQueue, dataTypes.DeviceInQueue{Name: deviceName, Detail: detailAndImage[dataTypes.DetailLink], Image: detailAndImage[dataTypes.ImageLink]})
 	}
 	ctx, cancel := context.WithTimeout(ctrl.Ctx, time.Second*30)
 	defer cancel()
 	_, err = queueCollection.InsertMany(ctx, devicesForUploadToQueue)
 	if err != nil {
 		log.Printf("in mongoDatabase.EnqueueDeviceBatch error inserting devices: %v", err)
-		return handleMongoError(err, false, ctrl)
+		return nil, handleMongoError(err, false, ctrl)
 	}
 
-	err = mdb.updateQueueSize(queueSizeCollection, queueSize, len(devicesForUploadToQueue), ctrl)
+	err = mdb.updateQueueSize(queueSize, len(devicesForUploadToQueue), ctrl)
 	if err != nil {
 		log.Printf("in mongoDatabase.EnqueueDeviceBatch error updating queue size: %v", err)
+		return nil, err
+	}
+	enqueuedDeviceNames := helpers.GetKeys(deviceSubset)
+	log.Printf("successfully enqueued %v", enqueuedDeviceNames)
+	return enqueuedDeviceNames, nil
+}
+
+// EnqueueDevice adds a single device to the queue regardless of the maximum queue size, or raises the priority
+// of the device if it is already queued. A device with a DeviceID is a refresh of a stored device,
+// its refresh fields are merged into those of an already queued refresh. A device already queued by an ingestion
+// job can't be queued by another one.
+func (mdb *MongoDatabase) EnqueueDevice(deviceInQueue dataTypes.DeviceInQueue, ctrl *dataTypes.FlowControl) error {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping mongoDatabase.EnqueueDevice: %v", ctrl.Ctx.Err())
+		return ctrl.Ctx.Err()
+	}
+
+	queueCollection := mdb.collection(mdb.config.Collections.Queue)
+
+	isRefresh := !deviceInQueue.DeviceID.IsZero()
+	if !isRefresh {
+		isStoredDevice, err := mdb.IsStoredDevice(deviceInQueue.Name, ctrl)
+		if err != nil {
+			log.Printf("in mongoDatabase.EnqueueDevice error checking for stored device: %v", err)
+			return err
+		}
+		if isStoredDevice {
+			return errorTypes.NewDeviceAlreadyExistsError(fmt.Sprintf("in mongoDatabase.EnqueueDevice device %v already exists", deviceInQueue.Name))
+		}
+	}
+
+	filter := bson.M{"name": deviceInQueue.Name}
+	update := bson.M{"$max": bson.M{"priority": deviceInQueue.Priority}}
+	set := bson.M{}
+	if deviceInQueue.JobID != "" {
+		filter["job-id"] = bson.M{"$in": bson.A{nil, ""}}
+		set["job-id"] = deviceInQueue.JobID
+	}
+	if isRefresh {
+		set["device-id"] = deviceInQueue.DeviceID
+		update["$addToSet"] = bson.M{"refresh-fields": bson.M{"$each": deviceInQueue.RefreshFields}}
+	}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	ctxForUpdate, cancelForUpdate := context.WithTimeout(ctrl.Ctx, time.Second*10)
+	defer cancelForUpdate()
+	updateResult, err := queueCollection.UpdateOne(ctxForUpdate, filter, update)
+	if err != nil {
+		log.Printf("in mongoDatabase.EnqueueDevice error updating queued device: %v", err)
+		return handleMongoError(err, false, ctrl)
+	}
+	if updateResult.MatchedCount > 0 {
+		log.Printf("in mongoDatabase.EnqueueDevice raised priority of queued device %v", deviceInQueue.Name)
+		return nil
+	}
+	if deviceInQueue.JobID != "" {
+		ctxForCount, cancelForCount := context.WithTimeout(ctrl.Ctx, time.Second*10)
+		defer cancelForCount()
+		count, err := queueCollection.CountDocuments(ctxForCount, bson.M{"name": deviceInQueue.Name})
+		if err != nil {
+			log.Printf("in mongoDatabase.EnqueueDevice error checking for queued device: %v", err)
+			return handleMongoError(err, false, ctrl)
+		}
+		if count > 0 {
+			return errorTypes.NewDeviceAlreadyQueuedError(fmt.Sprintf("in mongoDatabase.EnqueueDevice device %v is already queued by another job", deviceInQueue.Name))
+		}
+	}
+
+	queueSize, err := mdb.GetQueueSize(ctrl)
+	if err != nil {
+		log.Printf("in mongoDatabase.EnqueueDevice error getting queue size: %v", err)
 		return err
 	}
-	log.Printf("successfully enqueued %v", helpers.GetKeys(deviceSubset))
+	ctxForInsert, cancelForInsert := context.WithTimeout(ctrl.Ctx, time.Second*10)
+	defer cancelForInsert()
+	_, err = queueCollection.InsertOne(ctxForInsert, deviceInQueue)
+	if err != nil {
+		log.Printf("in mongoDatabase.EnqueueDevice error inserting device: %v", err)
+		return handleMongoError(err, false, ctrl)
+	}
+	err = mdb.updateQueueSize(queueSize, 1, ctrl)
+	if err != nil {
+		log.Printf("in mongoDatabase.EnqueueDevice error updating queue size: %v", err)
+		return err
+	}
+
+	log.Printf("successfully enqueued %v with priority %v", deviceInQueue.Name, deviceInQueue.Priority)
 	return nil
 }
 
-// Dequeue removes the oldest document from the queue
+// Dequeue removes the highest priority document from the queue
 func (mdb *MongoDatabase) Dequeue(ctrl *dataTypes.FlowControl) (dataTypes.DeviceInQueue, error) {
-	queueCollection := mdb.client.Database(Database).Collection(QueueCollection)
-	queueSizeCollection := mdb.client.Database(Database).Collection(QueueSizeCollection)
+	queueCollection := mdb.collection(mdb.config.Collections.Queue)
 	var result dataTypes.DeviceInQueue
 	ctx, cancel := context.WithTimeout(ctrl.Ctx, time.Second*10)
 	defer cancel()
-	err := queueCollection.FindOneAndDelete(ctx, bson.D{}).Decode(&result)
+	dequeueOptions := options.FindOneAndDelete().SetSort(bson.D{{Key: "priority", Value: -1}})
+	err := queueCollection.FindOneAndDelete(ctx, bson.D{}, dequeueOptions).Decode(&result)
 	if err != nil {
 		log.Printf("in mongoDatabase.Dequeue failed to dequeue: %v", err)
 		if errors.Is(err, mongo.ErrNoDocuments) {
@@ -86,12 +168,12 @@ func (mdb *MongoDatabase) Dequeue(ctrl *dataTypes.FlowControl) (dataTypes.Device
 		}
 	}
 
-	queueSize, err := mdb.GetQueueSize(queueSizeCollection, ctrl)
+	queueSize, err := mdb.GetQueueSize(ctrl)
 	if err != nil {
 		log.Println("in mongoDatabase.Dequeue failed to get queue size")
 		return dataTypes.DeviceInQueue{}, err
 	}
-	err = mdb.updateQueueSize(queueSizeCollection, queueSize, -1, ctrl)
+	err = mdb.updateQueueSize(queueSize, -1, ctrl)
 	if err != nil {
 		log.Println("in mongoDatabase.Dequeue failed to update queue size")
 		return dataTypes.DeviceInQueue{}, err
@@ -101,39 +183,25 @@ func (mdb *MongoDatabase) Dequeue(ctrl *dataTypes.FlowControl) (dataTypes.Device
 	return result, nil
 }
 
-func (mdb *MongoDatabase) GetQueueSize(queueSizeCollection *mongo.Collection, ctrl *dataTypes.FlowControl) (int, error) {
+func (mdb *MongoDatabase) GetQueueSize(ctrl *dataTypes.FlowControl) (int, error) {
 	var Result struct {
 		QueueSize int `bson:"queue-size"`
 	}
 
-	queueSizeDocumentID, err := getObjectIDFromString(QueueSizeDocumentID, ctrl)
-	if err != nil {
-		log.Printf("in mongoDatabase.GetQueueSize failed to get queueSizeDocumentID ID: %v", err)
-		return 0, err
-	}
-
-	err = mdb.getAndDecodeDocumentByID(&Result, queueSizeDocumentID, true, queueSizeCollection, ctrl)
+	err := mdb.getMetadataDocument(queueSizeDocument, &Result, ctrl)
 	if err != nil {
-		log.Printf("in mongoDatabase.GetQueueSize failed to get queueSizeDocumentID: %v", err)
+		log.Printf("in mongoDatabase.GetQueueSize failed to get queue size document: %v", err)
 		return 0, err
 	}
 
 	return Result.QueueSize, nil
 }
 
-func (mdb *MongoDatabase) updateQueueSize(queueSizeCollection *mongo.Collection, originalSize, sizeOffset int, ctrl *dataTypes.FlowControl) error {
-	queueSizeDocumentID, _ := primitive.ObjectIDFromHex(QueueSizeDocumentID)
-	update := bson.M{
-		"$set": bson.M{
-			"queue-size": originalSize + sizeOffset,
-		},
-	}
-	ctx, cancel := context.WithTimeout(ctrl.Ctx, 10*time.Second)
-	defer cancel()
-	_, err := queueSizeCollection.UpdateByID(ctx, queueSizeDocumentID, update)
+func (mdb *MongoDatabase) updateQueueSize(originalSize, sizeOffset int, ctrl *dataTypes.FlowControl) error {
+	err := mdb.setMetadataFields(queueSizeDocument, bson.D{{"queue-size", originalSize + sizeOffset}}, ctrl)
 	if err != nil {
 		log.Printf("stopping mongoDatabase.updateQueueSize failed to update queue size: %v", err)
-		return handleMongoError(err, true, ctrl)
+		return err
 	}
 
 	log.Printf("in mongoDatabase.updateQueueSize successfully updated queue size from %v to %v", originalSize, originalSize+sizeOffset)
@@ -146,7 +214,7 @@ func (mdb *MongoDatabase) excludeAllExistingDevices(deviceNamesAndLinks map[stri
 		return ctrl.Ctx.Err()
 	}
 
-	coll := mdb.client.Database(Database).Collection(DeviceDataCollection)
+	coll := mdb.collection(mdb.config.Collections.Devices)
 
 	allDevices, err := mdb.getAllDevices(coll, ctrl)
 	if err != nil {
@@ -160,7 +228,7 @@ func (mdb *MongoDatabase) excludeAllExistingDevices(deviceNamesAndLinks map[stri
 		}
 	}
 
-	coll = mdb.client.Database(Database).Collection(QueueCollection)
+	coll = mdb.collection(mdb.config.Collections.Queue)
 	filter := bson.M{"name": bson.M{"$exists": true}}
 	projection := bson.D{{"name", 1}, {"detail", 1}}
 	ctxForFind, cancelForFind := context.WithTimeout(ctrl.Ctx, time.Second*30)
@@ -195,3 +263,20 @@ func (mdb *MongoDatabase) excludeAllExistingDevices(deviceNamesAndLinks map[stri
 	}
 	return nil
 }
+
+func (mdb *MongoDatabase) IsStoredDevice(deviceName string, ctrl *dataTypes.FlowControl) (bool, error) {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping mongoDatabase.IsStoredDevice: %v", ctrl.Ctx.Err())
+		return false, ctrl.Ctx.Err()
+	}
+
+	coll := mdb.collection(mdb.config.Collections.Devices)
+	ctx, cancel := context.WithTimeout(ctrl.Ctx, time.Second*10)
+	defer cancel()
+	count, err := coll.CountDocuments(ctx, bson.M{"name": deviceName}, options.Count().SetLimit(1))
+	if err != nil {
+		log.Printf("in mongoDatabase.IsStoredDevice (device: %v) failed to count devices: %v", deviceName, err)
+		return false, handleMongoError(err, false, ctrl)
+	}
+	return count > 0, nil
+}
@@ -3,13 +3,17 @@ package mongoDatabase
 import (
 	"context"
 	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/aiAnalysis"
 	"github.com/ItaiHalperin/Device-Rec-API/internal/errorMonitoring"
 	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/helpers"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"log"
+	"regexp"
+	"strings"
 	"time"
 )
 
@@ -18,7 +22,10 @@ func (mdb *MongoDatabase) GetTop3(filters *dataTypes.Filters, ctrl *dataTypes.Fl
 		log.Printf("stopping mongoDatabase.GetTop3: %v", ctrl.Ctx.Err())
 		return nil, ctrl.Ctx.Err()
 	}
-	coll := mdb.client.Database(Database).Collection(DeviceDataCollection)
+	if helpers.IsVariantSearch(filters) {
+		return mdb.getTop3Variants(filters, ctrl)
+	}
+	coll := mdb.collection(mdb.config.Collections.Devices)
 
 	filter := bson.M{
 		"real-price":         bson.M{"$gte": filters.Price.Min, "$lte": filters.Price.Max},
@@ -26,6 +33,14 @@ func (mdb *MongoDatabase) GetTop3(filters *dataTypes.Filters, ctrl *dataTypes.Fl
 		"specs.refresh-rate": bson.M{"$gte": filters.RefreshRate.Min, "$lte": filters.RefreshRate.Max},
 		"brand":              bson.M{"$in": filters.Brands},
 	}
+	addSpecFilters(filter, filters)
+	if filters.Market != "" {
+		delete(filter, "real-price")
+		filter["prices"] = bson.M{"$elemMatch": bson.M{
+			"market": strings.ToUpper(filters.Market),
+			"price":  bson.M{"$gte": filters.Price.Min, "$lte": filters.Price.Max},
+		}}
+	}
 
 	searchOptions := options.Find().SetLimit(3).SetSort(bson.M{"validated-final-score": -1})
 	ctxForFind, cancelForFind := context.WithTimeout(ctrl.Ctx, time.Second*30)
@@ -56,6 +71,110 @@ func (mdb *MongoDatabase) GetTop3(filters *dataTypes.Filters, ctrl *dataTypes.Fl
 	return results, err
 }
 
+// addSpecFilters adds the optional spec filters that were set.
+func addSpecFilters(filter bson.M, filters *dataTypes.Filters) {
+	if filters.Chipset != "" {
+		filter["specs.chipset"] = bson.M{"$regex": regexp.QuoteMeta(filters.Chipset), "$options": "i"}
+	}
+	for field, floatRange := range map[string]dataTypes.MinMaxFloat{
+		"specs.weight-grams":            filters.Weight,
+		"specs.dimensions.height-mm":    filters.Height,
+		"specs.dimensions.width-mm":     filters.Width,
+		"specs.dimensions.thickness-mm": filters.Thickness,
+		"specs.wired-charging-w":        filters.WiredCharging,
+		"specs.wireless-charging-w":     filters.WirelessCharging,
+		"specs.os-version":              filters.OSVersion,
+	} {
+		if floatRange.Max > 0 {
+			filter[field] = bson.M{"$gte": floatRange.Min, "$lte": floatRange.Max}
+		}
+	}
+	if len(filters.IPRatings) > 0 {
+		ipRatings := make([]string, 0, len(filters.IPRatings))
+		for _, ipRating := range filters.IPRatings {
+			ipRatings = append(ipRatings, strings.ToUpper(ipRating))
+		}
+		filter["specs.ip-rating"] = bson.M{"$in": ipRatings}
+	}
+	if filters.OS != "" {
+		filter["specs.os"] = bson.M{"$regex": "^" + regexp.QuoteMeta(filters.OS) + "$", "$options": "i"}
+	}
+	if filters.MinOSUpgrades > 0 {
+		filter["specs.major-os-upgrades"] = bson.M{"$gte": filters.MinOSUpgrades}
+	}
+	if filters.Requires5G {
+		filter["specs.has-5g"] = true
+	}
+}
+
+// getTop3Variants ranks variants, each returned as its device holding just that variant, by score or by value.
+func (mdb *MongoDatabase) getTop3Variants(filters *dataTypes.Filters, ctrl *dataTypes.FlowControl) ([]dataTypes.Device, error) {
+	coll := mdb.collection(mdb.config.Collections.Devices)
+
+	deviceFilter := bson.M{
+		"specs.display-size": bson.M{"$gte": filters.DisplaySize.Min, "$lte": filters.DisplaySize.Max},
+		"specs.refresh-rate": bson.M{"$gte": filters.RefreshRate.Min, "$lte": filters.RefreshRate.Max},
+		"brand":              bson.M{"$in": filters.Brands},
+	}
+	addSpecFilters(deviceFilter, filters)
+	priceRange := bson.M{"$gte": filters.Price.Min, "$lte": filters.Price.Max}
+	variantFilter := bson.M{"variants.real-price": priceRange}
+	if filters.Market != "" {
+		variantFilter = bson.M{"variants.prices": bson.M{"$elemMatch": bson.M{
+			"market": strings.ToUpper(filters.Market),
+			"price":  priceRange,
+		}}}
+	}
+	if filters.Storage.Max > 0 {
+		variantFilter["variants.storage-gb"] = bson.M{"$gte": filters.Storage.Min, "$lte": filters.Storage.Max}
+	}
+	if filters.RAM.Max > 0 {
+		variantFilter["variants.ram-gb"] = bson.M{"$gte": filters.RAM.Min, "$lte": filters.RAM.Max}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: deviceFilter}},
+		{{Key: "$unwind", Value: "$variants"}},
+		{{Key: "$match", Value: variantFilter}},
+		{{Key: "$set", Value: bson.M{"variants": bson.A{"$variants"}}}},
+	}
+	if !filters.SortByValue {
+		pipeline = append(pipeline,
+			bson.D{{Key: "$sort", Value: bson.M{"validated-final-score": -1}}},
+			bson.D{{Key: "$limit", Value: 3}})
+	}
+
+	ctxForAggregate, cancelForAggregate := context.WithTimeout(ctrl.Ctx, time.Second*30)
+	defer cancelForAggregate()
+	cursor, err := coll.Aggregate(ctxForAggregate, pipeline)
+	if err != nil {
+		err = handleMongoError(err, false, ctrl)
+		log.Printf("in mongoDatabase.getTop3Variants failed to find variants: %v", err)
+		return nil, err
+	}
+	ctxForClose, cancelForClose := context.WithTimeout(ctrl.Ctx, time.Second*30)
+	defer cancelForClose()
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		err = cursor.Close(ctx)
+		if err != nil {
+			log.Printf("WARNING: Failed to close cursor: %v", err)
+			errorMonitoring.IncrementError(errorMonitoring.CleanUpError, ctrl)
+		}
+	}(cursor, ctxForClose)
+	ctxForDecode, cancelForDecode := context.WithTimeout(ctrl.Ctx, 10*time.Second)
+	defer cancelForDecode()
+	var results []dataTypes.Device
+	if err = cursor.All(ctxForDecode, &results); err != nil {
+		log.Println("in mongoDatabase.getTop3Variants adding cursor results to devices array failed")
+		return nil, handleMongoError(err, true, ctrl)
+	}
+
+	if filters.SortByValue {
+		results = aiAnalysis.TopByValue(results, filters)
+	}
+	return results, nil
+}
+
 type Document struct {
 	ID            primitive.ObjectID `bson:"_id"`
 	Score         float64            `bson:"score,omitempty"`     // Text search score
@@ -0,0 +1,285 @@
+package mongoDatabase
+
+import (
+	"context"
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorMonitoring"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"time"
+)
+
+// ExportSnapshot returns the whole database, with the devices and years in the order of the ID array documents.
+func (mdb *MongoDatabase) ExportSnapshot(ctrl *dataTypes.FlowControl) (dataTypes.DatabaseSnapshot, error) {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping mongoDatabase.ExportSnapshot: %v", ctrl.Ctx.Err())
+		return dataTypes.DatabaseSnapshot{}, ctrl.Ctx.Err()
+	}
+
+	var snapshot dataTypes.DatabaseSnapshot
+	var err error
+	snapshot.Devices, err = mdb.getAllDevices(mdb.collection(mdb.config.Collections.Devices), ctrl)
+	if err != nil {
+		log.Printf("in mongoDatabase.ExportSnapshot failed to get all devices: %v", err)
+		return dataTypes.DatabaseSnapshot{}, err
+	}
+
+	allYearIDs, err := mdb.getAllYearIDs(ctrl)
+	if err != nil {
+		log.Printf("in mongoDatabase.ExportSnapshot failed to get all year IDs: %v", err)
+		return dataTypes.DatabaseSnapshot{}, err
+	}
+	for _, yearID := range allYearIDs {
+		var year dataTypes.Year
+		err = mdb.getAndDecodeDocumentByID(&year, yearID, true, mdb.collection(mdb.config.Collections.Years), ctrl)
+		if err != nil {
+			log.Printf("in mongoDatabase.ExportSnapshot failed to get year %v: %v", yearID.Hex(), err)
+			return dataTypes.DatabaseSnapshot{}, err
+		}
+		snapshot.Years = append(snapshot.Years, year)
+
+		for _, monthID := range year.Months {
+			var month dataTypes.Month
+			err = mdb.getAndDecodeDocumentByID(&month, monthID, true, mdb.collection(mdb.config.Collections.Months), ctrl)
+			if err != nil {
+				log.Printf("in mongoDatabase.ExportSnapshot failed to get month %v: %v", monthID.Hex(), err)
+				return dataTypes.DatabaseSnapshot{}, err
+			}
+			snapshot.Months = append(snapshot.Months, month)
+		}
+	}
+
+	snapshot.MinMax, err = mdb.GetValidatedAndUnvalidatedMinMaxValues(ctrl)
+	if err != nil {
+		log.Printf("in mongoDatabase.ExportSnapshot failed to get minmax values: %v", err)
+		return dataTypes.DatabaseSnapshot{}, err
+	}
+
+	for collectionName, documents := range map[string]interface{}{
+		mdb.config.Collections.Queue:              &snapshot.Queue,
+		mdb.config.Collections.PriceHistory:       &snapshot.PriceHistory,
+		mdb.config.Collections.AlertSubscriptions: &snapshot.AlertSubscriptions,
+		mdb.config.Collections.BenchmarkCatalog:   &snapshot.BenchmarkCatalogs,
+	} {
+		err = mdb.findAllDocuments(collectionName, documents, ctrl)
+		if err != nil {
+			log.Printf("in mongoDatabase.ExportSnapshot failed to get %v: %v", collectionName, err)
+			return dataTypes.DatabaseSnapshot{}, err
+		}
+	}
+
+	snapshot.IsInterruptedValidation, err = mdb.IsInterruptedValidation(ctrl)
+	if err != nil {
+		log.Printf("in mongoDatabase.ExportSnapshot failed to check for interrupted validation: %v", err)
+		return dataTypes.DatabaseSnapshot{}, err
+	}
+	return snapshot, nil
+}
+
+// ImportSnapshot replaces the whole database with the snapshot. The snapshot's documents are inserted into staging
+// collections first, so that a failed insert leaves the database as it was. The staging collections are then renamed
+// over the devices, years, months, queue, price history, alert subscriptions and benchmark catalogs, and the metadata
+// documents are pointed at them. A failure after the first rename is reported, and importing again completes it.
+func (mdb *MongoDatabase) ImportSnapshot(snapshot *dataTypes.DatabaseSnapshot, ctrl *dataTypes.FlowControl) error {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping mongoDatabase.ImportSnapshot: %v", ctrl.Ctx.Err())
+		return ctrl.Ctx.Err()
+	}
+
+	collections := mdb.config.Collections
+	documentsByCollection := map[string][]interface{}{
+		collections.Devices:            toDocuments(snapshot.Devices),
+		collections.Years:              toDocuments(snapshot.Years),
+		collections.Months:             toDocuments(snapshot.Months),
+		collections.Queue:              toDocuments(snapshot.Queue),
+		collections.PriceHistory:       toDocuments(snapshot.PriceHistory),
+		collections.AlertSubscriptions: toDocuments(snapshot.AlertSubscriptions),
+		collections.BenchmarkCatalog:   toDocuments(snapshot.BenchmarkCatalogs),
+	}
+	indexModels := mdb.getIndexModels()
+
+	for collectionName, documents := range documentsByCollection {
+		err := mdb.stageCollection(collectionName, documents, indexModels[collectionName], ctrl)
+		if err != nil {
+			log.Printf("in mongoDatabase.ImportSnapshot failed to stage %v, the database is unchanged: %v", collectionName, err)
+			mdb.dropStagingCollections(documentsByCollection, ctrl)
+			return err
+		}
+	}
+
+	mdb.estimator.InvalidateModel()
+	for collectionName := range documentsByCollection {
+		err := mdb.renameCollection(stagingCollectionName(collectionName), collectionName, ctrl)
+		if err != nil {
+			log.Printf("in mongoDatabase.ImportSnapshot failed to replace %v: %v", collectionName, err)
+			return err
+		}
+	}
+
+	err := mdb.setMetadataDocuments(snapshot, ctrl)
+	if err != nil {
+		log.Printf("in mongoDatabase.ImportSnapshot failed to set metadata documents: %v", err)
+		return err
+	}
+
+	log.Printf("in mongoDatabase.ImportSnapshot successfully imported %v devices", len(snapshot.Devices))
+	return nil
+}
+
+func stagingCollectionName(collectionName string) string {
+	return collectionName + "_import"
+}
+
+// stageCollection fills the staging collection of collectionName with the documents and indexes them, replacing what
+// an earlier import left in it.
+func (mdb *MongoDatabase) stageCollection(collectionName string, documents []interface{}, models []mongo.IndexModel,
+	ctrl *dataTypes.FlowControl) error {
+	stagingName := stagingCollectionName(collectionName)
+	ctx, cancel := context.WithTimeout(ctrl.Ctx, time.Minute)
+	defer cancel()
+	err := mdb.collection(stagingName).Drop(ctx)
+	if err != nil {
+		return handleMongoError(err, false, ctrl)
+	}
+	// The collection is created even without documents, so that renaming it empties the target.
+	err = mdb.client.Database(mdb.config.Database).CreateCollection(ctx, stagingName)
+	if err != nil {
+		return handleMongoError(err, false, ctrl)
+	}
+
+	err = mdb.insertDocuments(stagingName, documents, ctrl)
+	if err != nil {
+		return err
+	}
+	return mdb.createCollectionIndexes(stagingName, models, ctrl)
+}
+
+// dropStagingCollections drops what a failed import staged.
+func (mdb *MongoDatabase) dropStagingCollections(documentsByCollection map[string][]interface{}, ctrl *dataTypes.FlowControl) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	for collectionName := range documentsByCollection {
+		err := mdb.collection(stagingCollectionName(collectionName)).Drop(ctx)
+		if err != nil {
+			log.Printf("WARNING: failed to drop %v: %v", stagingCollectionName(collectionName), err)
+			errorMonitoring.IncrementError(errorMonitoring.CleanUpError, ctrl)
+		}
+	}
+}
+
+// renameCollection renames the collection from over the collection to, which is dropped.
+func (mdb *MongoDatabase) renameCollection(from, to string, ctrl *dataTypes.FlowControl) error {
+	ctx, cancel := context.WithTimeout(ctrl.Ctx, time.Minute)
+	defer cancel()
+	command := bson.D{
+		{"renameCollection", mdb.config.Database + "." + from},
+		{"to", mdb.config.Database + "." + to},
+		{"dropTarget", true},
+	}
+	err := mdb.client.Database("admin").RunCommand(ctx, command).Err()
+	if err != nil {
+		return handleMongoError(err, false, ctrl)
+	}
+	return nil
+}
+
+// setMetadataDocuments points the ID array, min-max and queue size documents at the snapshot's documents and sets
+// the validation flag. Min-max values missing from the snapshot stay reset.
+func (mdb *MongoDatabase) setMetadataDocuments(snapshot *dataTypes.DatabaseSnapshot, ctrl *dataTypes.FlowControl) error {
+	deviceIDs := make([]interface{}, 0, len(snapshot.Devices))
+	for _, device := range snapshot.Devices {
+		deviceIDs = append(deviceIDs, device.ID)
+	}
+	yearIDs := make([]interface{}, 0, len(snapshot.Years))
+	for _, year := range snapshot.Years {
+		yearIDs = append(yearIDs, year.ID)
+	}
+	updates := map[string]bson.D{
+		allDeviceIDsDocument:   {{"device-ids", deviceIDs}},
+		allYearIDsDocument:     {{"year-ids", yearIDs}},
+		validationFlagDocument: {{"is-unfinished-validation", snapshot.IsInterruptedValidation}},
+	}
+	if snapshot.MinMax != (dataTypes.ValidatedAndUnvalidatedMinMaxValues{}) {
+		updates[minMaxValuesDocument] = bson.D{{"validated", snapshot.MinMax.Validated}, {"unvalidated", snapshot.MinMax.Unvalidated}}
+	}
+
+	for name, fields := range updates {
+		err := mdb.setMetadataFields(name, fields, ctrl)
+		if err != nil {
+			log.Printf("in mongoDatabase.setMetadataDocuments failed to update document %v: %v", name, err)
+			return err
+		}
+	}
+
+	return mdb.updateQueueSize(len(snapshot.Queue), 0, ctrl)
+}
+
+// findAllDocuments decodes every document of the collection into documents, a pointer to a slice, in insertion order.
+func (mdb *MongoDatabase) findAllDocuments(collectionName string, documents interface{}, ctrl *dataTypes.FlowControl) error {
+	coll := mdb.collection(collectionName)
+	ctxForFind, cancelForFind := context.WithTimeout(ctrl.Ctx, time.Second*30)
+	defer cancelForFind()
+	cursor, err := coll.Find(ctxForFind, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		err = handleMongoError(err, false, ctrl)
+		log.Printf("in mongoDatabase.findAllDocuments (collection: %v) failed to find documents: %v", collectionName, err)
+		return err
+	}
+	ctxForClose, cancelForClose := context.WithTimeout(ctrl.Ctx, time.Second*30)
+	defer cancelForClose()
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		err = cursor.Close(ctx)
+		if err != nil {
+			log.Printf("WARNING: Failed to close cursor: %v", err)
+			errorMonitoring.IncrementError(errorMonitoring.CleanUpError, ctrl)
+		}
+	}(cursor, ctxForClose)
+
+	ctxForDecode, cancelForDecode := context.WithTimeout(ctrl.Ctx, time.Minute)
+	defer cancelForDecode()
+	if err = cursor.All(ctxForDecode, documents); err != nil {
+		log.Printf("in mongoDatabase.findAllDocuments (collection: %v) failed to decode cursor", collectionName)
+		return handleMongoError(err, true, ctrl)
+	}
+	return nil
+}
+
+func (mdb *MongoDatabase) deleteDocuments(collectionName string, filter bson.M, ctrl *dataTypes.FlowControl) error {
+	coll := mdb.collection(collectionName)
+	ctx, cancel := context.WithTimeout(ctrl.Ctx, time.Minute)
+	defer cancel()
+	_, err := coll.DeleteMany(ctx, filter)
+	if err != nil {
+		err = handleMongoError(err, true, ctrl)
+		log.Printf("in mongoDatabase.deleteDocuments (collection: %v) failed to delete: %v", collectionName, err)
+		return err
+	}
+	return nil
+}
+
+func (mdb *MongoDatabase) insertDocuments(collectionName string, documents []interface{}, ctrl *dataTypes.FlowControl) error {
+	if len(documents) == 0 {
+		return nil
+	}
+
+	coll := mdb.collection(collectionName)
+	ctx, cancel := context.WithTimeout(ctrl.Ctx, time.Minute)
+	defer cancel()
+	_, err := coll.InsertMany(ctx, documents)
+	if err != nil {
+		err = handleMongoError(err, false, ctrl)
+		log.Printf("in mongoDatabase.insertDocuments (collection: %v) failed to insert: %v", collectionName, err)
+		return err
+	}
+	return nil
+}
+
+func toDocuments[T any](values []T) []interface{} {
+	documents := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		documents = append(documents, value)
+	}
+	return documents
+}
@@ -17,23 +17,20 @@ func (mdb *MongoDatabase) ValidateScores(unvalidatedMinMax dataTypes.MinMaxValue
 		log.Printf("stopping mongoDatabase.ValidateScores: %v", ctrl.Ctx.Err())
 		return ctrl.Ctx.Err()
 	}
-	unfinishedValidationDoc := dataTypes.ValidationFlag{IsUnfinishedValidation: true}
-	ctxForInsert, cancelForInsert := context.WithTimeout(ctrl.Ctx, time.Second*30)
-	defer cancelForInsert()
 	log.Printf("adding unfinished validation flag to database...")
-	coll := mdb.client.Database(Database).Collection(DeviceDataCollection)
-	insertResult, err := coll.InsertOne(ctxForInsert, unfinishedValidationDoc)
+	err := mdb.setMetadataFields(validationFlagDocument, bson.D{{"is-unfinished-validation", true}}, ctrl)
 	if err != nil {
-		log.Println("in MongoDatabase.ValidateScores failed to insert unfinished validation flag")
-		return handleMongoError(err, false, ctrl)
+		log.Println("in MongoDatabase.ValidateScores failed to set unfinished validation flag")
+		return err
 	}
 
-	err = mdb.validateMinMaxValuesDocument(unvalidatedMinMax, coll, ctrl)
+	err = mdb.validateMinMaxValuesDocument(unvalidatedMinMax, ctrl)
 	if err != nil {
 		log.Println("in mongoDatabase.ValidateScores failed to validate minmax document")
 		return err
 	}
 
+	coll := mdb.collection(mdb.config.Collections.Devices)
 	toBeValidatedDevices, err := mdb.getAllDevices(coll, ctrl)
 	if err != nil {
 		log.Println("in mongoDatabase.ValidateScores failed get all devices for validation")
@@ -47,37 +44,25 @@ func (mdb *MongoDatabase) ValidateScores(unvalidatedMinMax dataTypes.MinMaxValue
 			return err
 		}
 	}
-	ctxForDelete, cancelForDelete := context.WithTimeout(ctrl.Ctx, time.Second*30)
-	defer cancelForDelete()
-	_, err = coll.DeleteOne(ctxForDelete, bson.M{"_id": insertResult.InsertedID})
+	err = mdb.setMetadataFields(validationFlagDocument, bson.D{{"is-unfinished-validation", false}}, ctrl)
 	if err != nil {
-		log.Println("in MongoDatabase.ValidateScores failed to delete unfinished validation flag")
-		return handleMongoError(err, false, ctrl)
+		log.Println("in MongoDatabase.ValidateScores failed to clear unfinished validation flag")
+		return err
 	}
 	return nil
 }
 
-func (mdb *MongoDatabase) validateMinMaxValuesDocument(unvalidatedMinMax dataTypes.MinMaxValues, coll *mongo.Collection, ctrl *dataTypes.FlowControl) error {
+func (mdb *MongoDatabase) validateMinMaxValuesDocument(unvalidatedMinMax dataTypes.MinMaxValues, ctrl *dataTypes.FlowControl) error {
 	if ctrl.Ctx.Err() != nil {
 		log.Printf("stopping mongoDatabase.validateMinMaxValuesDocument: %v", ctrl.Ctx.Err())
 		return ctrl.Ctx.Err()
 	}
 
-	minMaxValuedDocumentID, err := getObjectIDFromString(MinMaxValuesDocumentID, ctrl)
-	if err != nil {
-		log.Println("in mongoDatabase.ValidateScores MinMaxValuesDocumentID is invalid")
-		return err
-	}
-
-	update := bson.D{{"$set", bson.D{{"validated", unvalidatedMinMax}}}}
-	ctx, cancel := context.WithTimeout(ctrl.Ctx, time.Second*30)
-	defer cancel()
-	_, err = coll.UpdateByID(ctx, minMaxValuedDocumentID, update)
+	err := mdb.setMetadataFields(minMaxValuesDocument, bson.D{{"validated", unvalidatedMinMax}}, ctrl)
 	if err != nil {
 		log.Println("in mongoDatabase.validateMinMaxValuesDocument failed to update minmax document")
-		return handleMongoError(err, true, ctrl)
+		return err
 	}
-
 	return nil
 }
 
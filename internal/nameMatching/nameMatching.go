@@ -0,0 +1,183 @@
+package nameMatching
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+const (
+	// DefaultThreshold is the similarity two names need to be considered the same model.
+	DefaultThreshold = 0.75
+)
+
+var (
+	parenthesesRegex = regexp.MustCompile(`\([^)]*\)`)
+	// plusRegex matches the "+" of names like "Galaxy S24+", which is a model word rather than a separator.
+	plusRegex = regexp.MustCompile(`([\p{L}\p{N}])\+`)
+	// memoryRegex matches storage and RAM sizes, which name a variant rather than the model.
+	memoryRegex = regexp.MustCompile(`^\d+(gb|tb)$`)
+	// suffixes are network and region markers that don't change which model a name refers to.
+	suffixes = []string{"5g", "4g", "lte", "uw", "global", "international", "intl", "china", "india", "usa", "us",
+		"eu", "europe", "dual", "sim"}
+	// modelWords tell apart models of the same generation, so two names must agree on them to match. Single letters
+	// like the "a" of "A54" and the "e" of "16e" are left out: they only tell models apart when attached to the
+	// digits, which makes them tokens with digits, and on their own they are English words of listing titles.
+	modelWords = []string{"pro", "max", "plus", "ultra", "mini", "lite", "fe", "xl", "fold", "flip", "edge", "neo",
+		"se", "note"}
+)
+
+// Matcher compares model names from different sources, e.g. "Galaxy S24 Ultra" and "Samsung Galaxy S24 Ultra 5G".
+type Matcher struct {
+	Threshold   float64
+	brandTokens []string
+	aliases     map[string]string
+}
+
+// Aliases is the alias table matchers resolve names with. It is read once, when it is created.
+type Aliases struct {
+	// names maps a name as a source spells it to the name the device is stored under.
+	names map[string]string
+}
+
+// NewAliases loads the alias table from the file at path. A missing or invalid table only disables aliases.
+func NewAliases(path string) *Aliases {
+	aliases := &Aliases{}
+	aliasesJson, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("WARNING: failed to read name aliases %v: %v", path, err)
+		}
+		return aliases
+	}
+	var file aliasesFile
+	if err = json.Unmarshal(aliasesJson, &file); err != nil {
+		log.Printf("WARNING: failed to unmarshal name aliases %v: %v", path, err)
+		return aliases
+	}
+	aliases.names = file.Aliases
+	return aliases
+}
+
+type aliasesFile struct {
+	// Aliases maps a name as a source spells it to the name the device is stored under.
+	Aliases map[string]string `json:"aliases"`
+}
+
+// NewMatcher returns a matcher for names of the given brand, which is ignored when comparing them, that resolves
+// names with aliases.
+func NewMatcher(brand string, aliases *Aliases) *Matcher {
+	matcher := &Matcher{Threshold: DefaultThreshold, brandTokens: tokenize(brand), aliases: make(map[string]string)}
+	for alias, name := range aliases.names {
+		matcher.aliases[strings.Join(matcher.normalizedTokens(alias, false), " ")] = name
+	}
+	return matcher
+}
+
+// Normalize returns the name as the matcher compares it: lowercase tokens without the brand, parenthesized
+// remarks, suffixes and memory sizes, after resolving aliases.
+func (matcher *Matcher) Normalize(name string) string {
+	return strings.Join(matcher.normalizedTokens(name, true), " ")
+}
+
+func (matcher *Matcher) normalizedTokens(name string, resolveAliases bool) []string {
+	var tokens []string
+	name = plusRegex.ReplaceAllString(parenthesesRegex.ReplaceAllString(name, " "), "$1 plus")
+	for _, token := range tokenize(name) {
+		if slices.Contains(matcher.brandTokens, token) || slices.Contains(suffixes, token) || memoryRegex.MatchString(token) {
+			continue
+		}
+		tokens = append(tokens, token)
+	}
+	if resolveAliases {
+		if aliasedName, ok := matcher.aliases[strings.Join(tokens, " ")]; ok {
+			return matcher.normalizedTokens(aliasedName, false)
+		}
+	}
+	return tokens
+}
+
+// Similarity scores how alike two names are, from 0 to 1. Names that differ in a model number or a model word,
+// like "S24" and "S23" or "15 Pro" and "15 Pro Max", score 0.
+func (matcher *Matcher) Similarity(a, b string) float64 {
+	return similarity(matcher.normalizedTokens(a, true), matcher.normalizedTokens(b, true))
+}
+
+// IsMatch reports whether two names refer to the same model.
+func (matcher *Matcher) IsMatch(a, b string) bool {
+	return matcher.Similarity(a, b) >= matcher.Threshold
+}
+
+// BestMatch returns the index of the candidate most similar to name, or -1 if none reaches the threshold.
+func (matcher *Matcher) BestMatch(name string, candidates []string) (int, float64) {
+	nameTokens := matcher.normalizedTokens(name, true)
+	bestIndex, bestScore := -1, 0.0
+	for i, candidate := range candidates {
+		score := similarity(nameTokens, matcher.normalizedTokens(candidate, true))
+		if score >= matcher.Threshold && score > bestScore {
+			bestIndex, bestScore = i, score
+		}
+	}
+	return bestIndex, bestScore
+}
+
+// Mentions reports whether text, such as a search result's title, contains the name, e.g.
+// "Galaxy S24 Ultra review: still the best" mentions "Samsung Galaxy S24 Ultra 5G".
+func (matcher *Matcher) Mentions(text, name string) bool {
+	nameTokens := matcher.normalizedTokens(name, true)
+	if len(nameTokens) == 0 {
+		return false
+	}
+	textTokens := matcher.normalizedTokens(text, false)
+	for size := max(1, len(nameTokens)-1); size <= len(nameTokens)+1; size++ {
+		for start := 0; start+size <= len(textTokens); start++ {
+			if similarity(nameTokens, textTokens[start:start+size]) >= matcher.Threshold {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func similarity(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	if !slices.Equal(modelTokens(a), modelTokens(b)) {
+		return 0
+	}
+
+	remaining := slices.Clone(b)
+	common := 0
+	for _, token := range a {
+		if i := slices.Index(remaining, token); i != -1 {
+			common++
+			remaining = slices.Delete(remaining, i, i+1)
+		}
+	}
+	return 2 * float64(common) / float64(len(a)+len(b))
+}
+
+// modelTokens are the sorted tokens that identify the model: those with digits and the model words.
+func modelTokens(tokens []string) []string {
+	var identifying []string
+	for _, token := range tokens {
+		if slices.Contains(modelWords, token) || strings.ContainsFunc(token, unicode.IsDigit) {
+			identifying = append(identifying, token)
+		}
+	}
+	slices.Sort(identifying)
+	return identifying
+}
+
+func tokenize(name string) []string {
+	return strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,205 @@
+package nameMatching
+
+import (
+	"testing"
+)
+
+func TestIsMatch(t *testing.T) {
+	aliases := &Aliases{names: map[string]string{"SM-S928B": "Galaxy S24 Ultra"}}
+	tests := []struct {
+		name            string
+		brand           string
+		a               string
+		b               string
+		isMatchExpected bool
+	}{
+		{
+			name:            "brand and network suffix",
+			brand:           "Samsung",
+			a:               "Galaxy S24 Ultra",
+			b:               "Samsung Galaxy S24 Ultra 5G",
+			isMatchExpected: true,
+		},
+		{
+			name:            "memory sizes and parenthesized remarks",
+			brand:           "Samsung",
+			a:               "Galaxy A54 5G (8GB 256GB)",
+			b:               "Galaxy A54",
+			isMatchExpected: true,
+		},
+		{
+			name:            "plus sign and plus word",
+			brand:           "Samsung",
+			a:               "Galaxy S24+",
+			b:               "Galaxy S24 Plus",
+			isMatchExpected: true,
+		},
+		{
+			name:  "missing model word",
+			brand: "Samsung",
+			a:     "Galaxy S24",
+			b:     "Galaxy S24 Ultra",
+		},
+		{
+			name:  "extra model word",
+			brand: "Apple",
+			a:     "iPhone 15 Pro",
+			b:     "iPhone 15 Pro Max",
+		},
+		{
+			name:  "different model number",
+			brand: "Samsung",
+			a:     "Galaxy A54",
+			b:     "Galaxy A55",
+		},
+		{
+			name:  "a attached to the model number",
+			brand: "Samsung",
+			a:     "Galaxy A54",
+			b:     "Galaxy 54",
+		},
+		{
+			name:  "e attached to the model number",
+			brand: "Apple",
+			a:     "iPhone 16e",
+			b:     "iPhone 16",
+		},
+		{
+			name:            "alias",
+			brand:           "Samsung",
+			a:               "Samsung SM-S928B",
+			b:               "Galaxy S24 Ultra",
+			isMatchExpected: true,
+		},
+		{
+			name:  "alias doesn't match another model",
+			brand: "Samsung",
+			a:     "SM-S928B",
+			b:     "Galaxy S24",
+		},
+		{
+			name:  "only the brand",
+			brand: "Samsung",
+			a:     "Samsung",
+			b:     "Samsung 5G",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			matcher := NewMatcher(test.brand, aliases)
+			if isMatch := matcher.IsMatch(test.a, test.b); isMatch != test.isMatchExpected {
+				t.Errorf("match of %q and %q is %v with similarity %v, expected %v", test.a, test.b, isMatch,
+					matcher.Similarity(test.a, test.b), test.isMatchExpected)
+			}
+			if isMatch := matcher.IsMatch(test.b, test.a); isMatch != test.isMatchExpected {
+				t.Errorf("match of %q and %q is %v, expected %v", test.b, test.a, isMatch, test.isMatchExpected)
+			}
+		})
+	}
+}
+
+func TestBestMatch(t *testing.T) {
+	tests := []struct {
+		name          string
+		deviceName    string
+		candidates    []string
+		expectedIndex int
+	}{
+		{
+			name:          "exact model among variants",
+			deviceName:    "Galaxy S24",
+			candidates:    []string{"Galaxy S24 Ultra", "Galaxy S24+", "Samsung Galaxy S24 5G", "Galaxy S23"},
+			expectedIndex: 2,
+		},
+		{
+			name:          "more similar candidate",
+			deviceName:    "Galaxy Z Fold 6",
+			candidates:    []string{"Z Fold 6", "Samsung Galaxy Z Fold 6"},
+			expectedIndex: 1,
+		},
+		{
+			name:          "only near misses",
+			deviceName:    "Galaxy S24",
+			candidates:    []string{"Galaxy S24 Ultra", "Galaxy S24 FE", "Galaxy S25"},
+			expectedIndex: -1,
+		},
+		{
+			name:          "no candidates",
+			deviceName:    "Galaxy S24",
+			expectedIndex: -1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			matcher := NewMatcher("Samsung", &Aliases{})
+			index, score := matcher.BestMatch(test.deviceName, test.candidates)
+			if index != test.expectedIndex {
+				t.Fatalf("best match is %d with score %v, expected %d", index, score, test.expectedIndex)
+			}
+			if index != -1 && score < matcher.Threshold {
+				t.Errorf("score %v of the best match is below the threshold %v", score, matcher.Threshold)
+			}
+		})
+	}
+}
+
+func TestMentions(t *testing.T) {
+	tests := []struct {
+		name              string
+		brand             string
+		text              string
+		deviceName        string
+		isMentionExpected bool
+	}{
+		{
+			name:              "title with the name",
+			brand:             "Samsung",
+			text:              "Galaxy S24 Ultra review: still the best",
+			deviceName:        "Samsung Galaxy S24 Ultra 5G",
+			isMentionExpected: true,
+		},
+		{
+			name:              "a as an English word",
+			brand:             "Samsung",
+			text:              "A week with the Galaxy S24",
+			deviceName:        "Galaxy S24",
+			isMentionExpected: true,
+		},
+		{
+			name:       "title with a model word less",
+			brand:      "Samsung",
+			text:       "Galaxy S24 review: small and mighty",
+			deviceName: "Galaxy S24 Ultra",
+		},
+		{
+			name:       "title with the model number attached to e",
+			brand:      "Apple",
+			text:       "iPhone 16e review",
+			deviceName: "iPhone 16",
+		},
+		{
+			name:       "title without the name",
+			brand:      "Samsung",
+			text:       "Best phones of the year",
+			deviceName: "Galaxy S24",
+		},
+		{
+			name:       "name of only the brand",
+			brand:      "Samsung",
+			text:       "Samsung Galaxy S24 review",
+			deviceName: "Samsung",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			matcher := NewMatcher(test.brand, &Aliases{})
+			if isMention := matcher.Mentions(test.text, test.deviceName); isMention != test.isMentionExpected {
+				t.Errorf("mention of %q in %q is %v, expected %v", test.deviceName, test.text, isMention,
+					test.isMentionExpected)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package priceAlerts
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/databaseInterface"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	ListenerName    = "price-alerts"
+	SignatureHeader = "X-Signature-256"
+	maxAttempts     = 4
+	firstRetryDelay = 2 * time.Second
+	deliveryTimeout = 10 * time.Second
+	signaturePrefix = "sha256="
+)
+
+// Notifier delivers a signed PriceAlert to every subscription whose threshold a price drop went below.
+type Notifier struct {
+	database databaseInterface.DatabaseInterface
+	client   *http.Client
+	secret   []byte
+}
+
+// NewNotifier returns a notifier that signs the alerts with secret.
+func NewNotifier(database databaseInterface.DatabaseInterface, secret string) *Notifier {
+	if secret == "" {
+		log.Printf("WARNING: no alert webhook secret is configured, price alerts are signed with an empty secret")
+	}
+	return &Notifier{
+		database: database,
+		client:   &http.Client{Timeout: deliveryTimeout},
+		secret:   []byte(secret),
+	}
+}
+
+// OnPriceChange is a priceScraper.PriceChangeListener. Deliveries run in the background so that a slow
+// callback never holds up the pipeline.
+func (notifier *Notifier) OnPriceChange(device *dataTypes.Device, oldPrice int, ctrl *dataTypes.FlowControl) {
+	if device.RealPrice >= oldPrice {
+		return
+	}
+
+	subscriptions, err := notifier.database.GetAlertSubscriptions(device.ID, ctrl)
+	if err != nil {
+		log.Printf("in priceAlerts.OnPriceChange (device: %v) failed to get subscriptions: %v", device.Name, err)
+		return
+	}
+
+	for _, subscription := range subscriptions {
+		// Only the drop that crosses the threshold alerts; later drops below it don't alert again.
+		if oldPrice <= subscription.Threshold || device.RealPrice > subscription.Threshold {
+			continue
+		}
+		alert := dataTypes.PriceAlert{
+			SubscriptionID: subscription.ID.Hex(),
+			DeviceID:       device.ID.Hex(),
+			DeviceName:     device.Name,
+			Threshold:      subscription.Threshold,
+			OldPrice:       oldPrice,
+			NewPrice:       device.RealPrice,
+			Currency:       device.Currency,
+			SourceURL:      device.Provenance[dataTypes.RealPriceProvenance].URL,
+			Time:           time.Now(),
+		}
+		go notifier.deliver(subscription.CallbackURL, alert)
+	}
+}
+
+// deliver posts alert to callbackURL, retrying with exponential backoff on network errors and non-2xx responses.
+func (notifier *Notifier) deliver(callbackURL string, alert dataTypes.PriceAlert) {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		log.Printf("in priceAlerts.deliver failed to encode alert %v: %v", alert.SubscriptionID, err)
+		return
+	}
+	signature := Sign(body, notifier.secret)
+
+	delay := firstRetryDelay
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		err = notifier.post(callbackURL, body, signature)
+		if err == nil {
+			log.Printf("in priceAlerts.deliver delivered alert %v (device: %v)", alert.SubscriptionID, alert.DeviceName)
+			return
+		}
+		log.Printf("in priceAlerts.deliver attempt %v/%v to deliver alert %v failed: %v", attempt, maxAttempts, alert.SubscriptionID, err)
+		if attempt < maxAttempts {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+	log.Printf("in priceAlerts.deliver giving up on alert %v", alert.SubscriptionID)
+}
+
+func (notifier *Notifier) post(callbackURL string, body []byte, signature string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), deliveryTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(SignatureHeader, signature)
+
+	response, err := notifier.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := response.Body.Close(); err != nil {
+			log.Printf("WARNING: Failed to close webhook response: %v", err)
+		}
+	}()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return errorTypes.NewErrorGettingURL(fmt.Sprintf("in priceAlerts.post callback responded with %v", response.Status))
+	}
+	return nil
+}
+
+// Sign returns the value of the SignatureHeader for body: the hex HMAC-SHA256 of body under secret.
+func Sign(body, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// IsValidSignature reports whether signature is the SignatureHeader value of body under secret.
+func IsValidSignature(body, secret []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(body, secret)), []byte(signature))
+}
@@ -1,172 +1,166 @@
 package priceScraper
 
 import (
-	"encoding/json"
-	"errors"
 	"fmt"
 	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
 	"github.com/ItaiHalperin/Device-Rec-API/internal/aiAnalysis"
-	"github.com/ItaiHalperin/Device-Rec-API/internal/errorMonitoring"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/currencyConversion"
 	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/externalServices"
 	"github.com/ItaiHalperin/Device-Rec-API/internal/helpers"
-	"github.com/ItaiHalperin/Device-Rec-API/internal/parsingErrorLogger"
-	"github.com/PuerkitoBio/goquery"
-	"io"
 	"log"
-	"net/url"
-	"os"
 	"strings"
 )
 
-func SetPrice(device *dataTypes.Device, ctrl *dataTypes.FlowControl) error {
+// PriceChangeListener is called after a re-scraped price of a stored device changed and was recorded.
+type PriceChangeListener func(device *dataTypes.Device, oldPrice int, ctrl *dataTypes.FlowControl)
+
+// RegisterPriceChangeListener registers listener under name, replacing any listener previously registered under it.
+func (registry *Registry) RegisterPriceChangeListener(name string, listener PriceChangeListener) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.listeners[name] = listener
+}
+
+// NotifyPriceChangeListeners calls every registered listener. It's up to the caller to call it only once the
+// device's new price is stored.
+func (registry *Registry) NotifyPriceChangeListeners(device *dataTypes.Device, oldPrice int, ctrl *dataTypes.FlowControl) {
+	registry.mutex.Lock()
+	listeners := make([]PriceChangeListener, 0, len(registry.listeners))
+	for _, listener := range registry.listeners {
+		listeners = append(listeners, listener)
+	}
+	registry.mutex.Unlock()
+
+	for _, listener := range listeners {
+		listener(device, oldPrice, ctrl)
+	}
+}
+
+// SetPrice sets the device's price, and the price of each of its variants, in every target market, from the sources
+// of registry. The primary market's price must come from one of its sources; the other markets fall back to converting it when they have no
+// source or all their sources fail. A variant that can't be priced keeps its previous prices.
+func SetPrice(device *dataTypes.Device, registry *Registry, services externalServices.ExternalServices, ctrl *dataTypes.FlowControl) error {
 	if ctrl.Ctx.Err() != nil {
-		log.Printf("stopping priceScraper.getPriceURL: %v", ctrl.Ctx.Err())
+		log.Printf("stopping priceScraper.SetPrice: %v", ctrl.Ctx.Err())
 		return ctrl.Ctx.Err()
 	}
-	priceURL, err := getPriceURL(device.Brand+" "+device.Name, "השוואת+מחירים+טלפונים+סלולריים", ctrl)
-	if err != nil {
-		var aiInstructionErr errorTypes.FailedAiInstructionError
-		if errors.As(err, &aiInstructionErr) {
-			priceURL, err = getPriceURL(device.Brand+" "+device.Name, "", ctrl)
-			if err != nil {
-				log.Printf("in priceScraper.SetPrice (device: %v) failed to get price url: %v", device.Name, err)
-				parsingErrorLogger.LogErrorInJsonFile(fmt.Sprintf("in priceScraper.SetPrice (device: %v) failed to get price url: %v", device.Name, err), ctrl)
-				return err
-			}
-		} else {
-			parsingErrorLogger.LogErrorInJsonFile(fmt.Sprintf("in priceScraper.SetPrice (device: %v) failed to get price url: %v", device.Name, err), ctrl)
-			return err
-		}
-	}
-	document, err := helpers.GetDocumentByURL(priceURL, ctrl)
-	if err != nil {
-		log.Println("in priceScraper.SetPrice failed to get document by url")
-		return err
-	}
-	price, err := getPriceFromDocument(priceURL, document, ctrl)
+	markets, err := ParseMarkets(services.Markets)
 	if err != nil {
-		log.Println("in priceScraper.SetPrice failed to get price from document")
+		log.Printf("in priceScraper.SetPrice failed to get target markets: %v", err)
 		return err
 	}
-	device.RealPrice = price
-	return nil
-}
 
-func getPriceFromDocument(URL string, document *goquery.Document, ctrl *dataTypes.FlowControl) (int, error) {
-	priceString := document.Find("h2.price-value.total").Text()
-	if priceString == "" {
-		errMsg := fmt.Sprintf("in priceScraper.getPriceFromDocument price not found in link: %v", URL)
-		log.Println(errMsg)
-		parsingErrorLogger.LogErrorInJsonFile(errMsg, ctrl)
-		errorMonitoring.IncrementError(errorMonitoring.ParsingError, ctrl)
-		return 0, errorTypes.NewParsingError(errMsg)
-	}
-	price, err := helpers.ExtractFloat(priceString)
+	prices, err := getPrices(device, nil, markets, registry, services, ctrl)
 	if err != nil {
-		errMsg := fmt.Sprintf("in priceScraper.getPriceFromDocument (url: %v) failed to parse price: %v", URL, err)
-		log.Printf(errMsg)
-		parsingErrorLogger.LogErrorInJsonFile(errMsg, ctrl)
-		errorMonitoring.IncrementError(errorMonitoring.ParsingError, ctrl)
-		return 0, err
+		log.Printf("in priceScraper.SetPrice (device: %v) failed to get price in primary market %v: %v", device.Name, markets[0].Code, err)
+		return err
 	}
-	return int(price), nil
-}
-
-func getPriceURL(brandAndName, searchTerm string, ctrl *dataTypes.FlowControl) (string, error) {
-	if ctrl.Ctx.Err() != nil {
-		log.Printf("stopping priceScraper.getPriceURL: %v", ctrl.Ctx.Err())
-		return "", ctrl.Ctx.Err()
+	for i := range device.Variants {
+		variant := &device.Variants[i]
+		variantPrices, err := getPrices(device, variant, markets, registry, services, ctrl)
+		if ctrl.Ctx.Err() != nil {
+			return ctrl.Ctx.Err()
+		}
+		if err != nil {
+			log.Printf("in priceScraper.SetPrice (device: %v) failed to price %v variant: %v", device.Name, variantLabel(variant), err)
+			continue
+		}
+		variant.RealPrice = variantPrices[0].Price
+		variant.Currency = variantPrices[0].Currency
+		variant.Prices = variantPrices
 	}
 
-	apiKey := os.Getenv("CUSTOM_SEARCH_KEY")
-	searchEngineID := os.Getenv("PRICE_SEARCH_ENGINE_ID")
-	brandAndName = strings.ReplaceAll(brandAndName, " ", "+")
-	priceUrl := fmt.Sprintf("https://www.googleapis.com/customsearch/v1?key=%s&cx=%s&q=%s",
-		apiKey, searchEngineID, url.QueryEscape(brandAndName+searchTerm))
+	device.RealPrice = prices[0].Price
+	device.Currency = prices[0].Currency
+	device.Prices = prices
+	helpers.RecordProvenance(device, dataTypes.RealPriceProvenance, prices[0].Source, prices[0].URL, dataTypes.ParsedMethod)
+	return nil
+}
 
-	resp, err := helpers.GetRespByURL(priceUrl, ctrl)
+// getPrices returns the price in every market, the primary market's first.
+func getPrices(device *dataTypes.Device, variant *dataTypes.Variant, markets []Market, registry *Registry,
+	services externalServices.ExternalServices, ctrl *dataTypes.FlowControl) ([]dataTypes.MarketPrice, error) {
+	primaryPrice, err := getMarketPrice(device, variant, markets[0], registry, services, ctrl)
 	if err != nil {
-		log.Printf("in priceScraper.getPriceURL failed to get response (device: %v)", brandAndName)
-		return "", err
+		return nil, err
 	}
-	defer func(Body io.ReadCloser) {
-		err = Body.Close()
+	prices := []dataTypes.MarketPrice{primaryPrice}
+	for _, market := range markets[1:] {
+		price, err := getMarketPrice(device, variant, market, registry, services, ctrl)
+		if ctrl.Ctx.Err() != nil {
+			return nil, ctrl.Ctx.Err()
+		}
 		if err != nil {
-			log.Printf("WARNING: Failed to close HTML reader: %v", err)
-			errorMonitoring.IncrementError(errorMonitoring.CleanUpError, ctrl)
+			price, err = convertMarketPrice(primaryPrice, market, services.CurrencyConverter)
+			if err != nil {
+				log.Printf("in priceScraper.getPrices (device: %v) failed to convert price to market %v: %v", device.Name, market.Code, err)
+				continue
+			}
 		}
-	}(resp.Body)
-
-	var result struct {
-		Items []struct {
-			Link    string `json:"link"`
-			Title   string `json:"title"`
-			Snippet string `json:"snippet"`
-		} `json:"items"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		log.Println("in priceScraper.getPriceURL failed to decode results")
-		parsingErrorLogger.LogErrorInJsonFile(fmt.Sprintf("in priceScraper.getPriceURL failed decode results (device: %v)", brandAndName), ctrl)
-		return "", errorTypes.NewParsingError(fmt.Sprintf("in priceScraper.getPriceURL failed decode results (device: %v)", brandAndName))
+		prices = append(prices, price)
 	}
+	return prices, nil
+}
 
-	for _, item := range result.Items {
-		isCorrectUrl, err := aiAnalysis.IsCorrectWebpage("You get a phone model in the format "+
-			"\"[brand]+[phone name]\" and a description of a webpage written in hebrew. "+
-			"You need to return TRUE if the webpage is solely about the current phone model"+
-			", or FALSE otherwise",
-			brandAndName, item.Title+" "+item.Snippet, ctrl)
-		if err != nil {
-			log.Printf("in priceScraper.getPriceURL failed to check if url leads to correct webpage (device: %v)", brandAndName)
-			return "", err
-		}
+// getMarketPrice tries the market's sources in registration order, converting to the market's currency when a
+// source quotes in another one.
+func getMarketPrice(device *dataTypes.Device, variant *dataTypes.Variant, market Market, registry *Registry,
+	services externalServices.ExternalServices, ctrl *dataTypes.FlowControl) (dataTypes.MarketPrice, error) {
+	sources := registry.getPriceSources(market.Code)
+	if len(sources) == 0 {
+		return dataTypes.MarketPrice{}, errorTypes.NewNoPriceSourceError(fmt.Sprintf("in priceScraper.getMarketPrice no price source for market %v", market.Code))
+	}
 
-		icr, err := isComponentReplacement(item.Link, ctrl)
+	var err error
+	for _, source := range sources {
+		var price dataTypes.MarketPrice
+		price, err = source.GetPrice(device, variant, services, ctrl)
 		if err != nil {
-			log.Printf("in priceScraper.getPriceURL failed to check if url leads to component replacement (device: %v)", brandAndName)
-			return "", err
+			log.Printf("in priceScraper.getMarketPrice (device: %v) source %v failed: %v", device.Name, source.Name(), err)
+			if ctrl.Ctx.Err() != nil {
+				return dataTypes.MarketPrice{}, ctrl.Ctx.Err()
+			}
+			continue
 		}
-		isCorrectUrl = isCorrectUrl && !icr
-		if strings.Contains(item.Link, "zap.co.il") && isCorrectUrl {
-			log.Printf("in priceScraper.getPriceURL successfully found price url with search term: %v (device: %v)", searchTerm, brandAndName)
-			return item.Link, err
+		if !strings.EqualFold(price.Currency, market.Currency) {
+			price.Price, err = services.CurrencyConverter.Convert(price.Price, price.Currency, market.Currency)
+			if err != nil {
+				log.Printf("in priceScraper.getMarketPrice (device: %v) failed to convert price of %v: %v", device.Name, source.Name(), err)
+				continue
+			}
+			price.Currency = market.Currency
+			price.IsConverted = true
 		}
+		price.Market = market.Code
+		return price, nil
 	}
-	log.Printf("in priceScraper.getPriceURL failed to find price url with search term: %v (device: %v)", searchTerm, brandAndName)
-	return "", errorTypes.NewFailedAiInstructionError(fmt.Sprintf("in priceScraper.getPriceURL failed find price url (device: %v)", brandAndName))
+	return dataTypes.MarketPrice{}, err
 }
 
-func SetPriceCategory(device *dataTypes.Device, ctrl *dataTypes.FlowControl) error {
+func convertMarketPrice(price dataTypes.MarketPrice, market Market, converter *currencyConversion.Converter) (dataTypes.MarketPrice, error) {
+	convertedPrice, err := converter.Convert(price.Price, price.Currency, market.Currency)
+	if err != nil {
+		return dataTypes.MarketPrice{}, err
+	}
+	price.Market = market.Code
+	price.Price = convertedPrice
+	price.Currency = market.Currency
+	price.IsConverted = true
+	return price, nil
+}
+func SetPriceCategory(device *dataTypes.Device, analyzer *aiAnalysis.Analyzer, ctrl *dataTypes.FlowControl) error {
 	if ctrl.Ctx.Err() != nil {
 		log.Printf("stopping priceScraper.SetPriceCategory: %v", ctrl.Ctx.Err())
 		return ctrl.Ctx.Err()
 	}
 
-	priceCategory, err := aiAnalysis.GetPriceCategory(device.Name, ctrl)
+	priceCategory, err := analyzer.GetPriceCategory(device.Name, ctrl)
 	if err != nil {
 		log.Printf("in priceScraper.setPriceCategory failed to find price category (device: %v)", device.Name)
 		return err
 	}
 	device.PriceCategory = priceCategory
+	helpers.RecordProvenance(device, "price-category", analyzer.GetAiSource(), "", dataTypes.AiMethod)
 	return nil
 }
-
-func isComponentReplacement(url string, ctrl *dataTypes.FlowControl) (bool, error) {
-	if ctrl.Ctx.Err() != nil {
-		log.Printf("stopping priceScraper.isComponentReplacement: %v", ctrl.Ctx.Err())
-		return false, ctrl.Ctx.Err()
-	}
-
-	doc, err := helpers.GetDocumentByURL(url, ctrl)
-	if err != nil {
-		log.Printf("in priceScraper.isComponentReplacement: %v", err)
-		return false, err
-	}
-	selector := `a[href="/models.aspx?sog=e-cellphone"][aria-label="השוואת מחירים טלפונים סלולריים"]`
-	componentReplacementString := doc.Find(selector).Text()
-	if componentReplacementString != "" {
-		return false, nil
-	}
-
-	return true, nil
-}
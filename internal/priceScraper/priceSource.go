@@ -0,0 +1,110 @@
+package priceScraper
+
+import (
+	"fmt"
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/externalServices"
+	"log"
+	"strings"
+	"sync"
+)
+
+const defaultMarket = zapMarket
+
+// PriceSource scrapes a device's price for a single market. A nil variant asks for the device's headline price.
+type PriceSource interface {
+	Name() string
+	Market() string
+	Currency() string
+	GetPrice(device *dataTypes.Device, variant *dataTypes.Variant, services externalServices.ExternalServices, ctrl *dataTypes.FlowControl) (dataTypes.MarketPrice, error)
+}
+
+// Market is a storefront prices are gathered for, and the currency they are shown in.
+type Market struct {
+	Code     string
+	Currency string
+}
+
+var marketCurrencies = map[string]string{
+	"IL": "ILS",
+	"EU": "EUR",
+	"US": "USD",
+	"UK": "GBP",
+}
+
+// Registry holds the price sources prices are scraped from and the listeners notified of price changes.
+type Registry struct {
+	mutex     sync.Mutex
+	sources   []PriceSource
+	listeners map[string]PriceChangeListener
+}
+
+// NewRegistry returns a registry with the built-in price sources and no listeners.
+func NewRegistry() *Registry {
+	return &Registry{sources: []PriceSource{zapPriceSource{}}, listeners: make(map[string]PriceChangeListener)}
+}
+
+// RegisterPriceSource adds source after the already registered sources of its market, which are tried first.
+func (registry *Registry) RegisterPriceSource(source PriceSource) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.sources = append(registry.sources, source)
+}
+
+func (registry *Registry) getPriceSources(market string) []PriceSource {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	var sources []PriceSource
+	for _, source := range registry.sources {
+		if strings.EqualFold(source.Market(), market) {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
+
+// ParseMarkets reads market codes, each optionally followed by ":" and the currency to use instead of the market's
+// default, e.g. "IL" or "US:EUR". The first market is the primary one; its price is the device's RealPrice.
+// Without markets, it returns IL.
+func ParseMarkets(marketStrings []string) ([]Market, error) {
+	var markets []Market
+	for _, marketString := range marketStrings {
+		code, currency, _ := strings.Cut(strings.TrimSpace(marketString), ":")
+		code = strings.ToUpper(strings.TrimSpace(code))
+		currency = strings.ToUpper(strings.TrimSpace(currency))
+		if code == "" {
+			continue
+		}
+		if currency == "" {
+			var ok bool
+			currency, ok = marketCurrencies[code]
+			if !ok {
+				return nil, errorTypes.NewUnsupportedCurrencyError(fmt.Sprintf("in priceScraper.ParseMarkets no currency for market %v", code))
+			}
+		}
+		markets = append(markets, Market{Code: code, Currency: currency})
+	}
+	if len(markets) == 0 {
+		log.Printf("WARNING: no markets are configured, using %v", defaultMarket)
+		markets = append(markets, Market{Code: defaultMarket, Currency: marketCurrencies[defaultMarket]})
+	}
+	return markets, nil
+}
+
+// variantLabel formats a variant's memory the way storefronts list it, the RAM first when it's known, e.g.
+// "8GB/256GB" or "1TB". Variants that differ only in RAM are priced separately.
+func variantLabel(variant *dataTypes.Variant) string {
+	if variant.RAMGB <= 0 {
+		return storageLabel(variant.StorageGB)
+	}
+	return fmt.Sprintf("%gGB/%v", variant.RAMGB, storageLabel(variant.StorageGB))
+}
+
+// storageLabel formats a storage size the way storefronts list it, e.g. "256GB" or "1TB".
+func storageLabel(storageGB int) string {
+	if storageGB >= 1024 && storageGB%1024 == 0 {
+		return fmt.Sprintf("%dTB", storageGB/1024)
+	}
+	return fmt.Sprintf("%dGB", storageGB)
+}
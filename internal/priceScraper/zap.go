@@ -0,0 +1,184 @@
+package priceScraper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorMonitoring"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/externalServices"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/helpers"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/nameMatching"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/parsingErrorLogger"
+	"github.com/PuerkitoBio/goquery"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	zapSource   = "zap.co.il"
+	zapMarket   = "IL"
+	zapCurrency = "ILS"
+)
+
+// zapPriceSource finds the device's zap.co.il comparison page through Google Custom Search and reads its lowest price.
+type zapPriceSource struct{}
+
+func (zapPriceSource) Name() string     { return zapSource }
+func (zapPriceSource) Market() string   { return zapMarket }
+func (zapPriceSource) Currency() string { return zapCurrency }
+
+func (source zapPriceSource) GetPrice(device *dataTypes.Device, variant *dataTypes.Variant, services externalServices.ExternalServices, ctrl *dataTypes.FlowControl) (dataTypes.MarketPrice, error) {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping priceScraper.GetPrice: %v", ctrl.Ctx.Err())
+		return dataTypes.MarketPrice{}, ctrl.Ctx.Err()
+	}
+	brandAndName := device.Brand + " " + device.Name
+	if variant != nil {
+		brandAndName += " " + variantLabel(variant)
+	}
+	priceURL, err := getPriceURL(brandAndName, "השוואת+מחירים+טלפונים+סלולריים", services, ctrl)
+	if err != nil {
+		var aiInstructionErr errorTypes.FailedAiInstructionError
+		if errors.As(err, &aiInstructionErr) {
+			priceURL, err = getPriceURL(brandAndName, "", services, ctrl)
+			if err != nil {
+				log.Printf("in priceScraper.GetPrice (device: %v) failed to get price url: %v", device.Name, err)
+				parsingErrorLogger.LogErrorInJsonFile(fmt.Sprintf("in priceScraper.GetPrice (device: %v) failed to get price url: %v", device.Name, err), ctrl)
+				return dataTypes.MarketPrice{}, err
+			}
+		} else {
+			parsingErrorLogger.LogErrorInJsonFile(fmt.Sprintf("in priceScraper.GetPrice (device: %v) failed to get price url: %v", device.Name, err), ctrl)
+			return dataTypes.MarketPrice{}, err
+		}
+	}
+	document, err := helpers.GetDocumentByURL(services.HTTPClient, priceURL, ctrl)
+	if err != nil {
+		log.Println("in priceScraper.GetPrice failed to get document by url")
+		return dataTypes.MarketPrice{}, err
+	}
+	price, err := getPriceFromDocument(priceURL, document, ctrl)
+	if err != nil {
+		log.Println("in priceScraper.GetPrice failed to get price from document")
+		return dataTypes.MarketPrice{}, err
+	}
+	return dataTypes.MarketPrice{
+		Market:   source.Market(),
+		Price:    price,
+		Currency: source.Currency(),
+		Source:   source.Name(),
+		URL:      priceURL,
+	}, nil
+}
+
+func getPriceFromDocument(URL string, document *goquery.Document, ctrl *dataTypes.FlowControl) (int, error) {
+	priceString := document.Find("h2.price-value.total").Text()
+	if priceString == "" {
+		errMsg := fmt.Sprintf("in priceScraper.getPriceFromDocument price not found in link: %v", URL)
+		log.Println(errMsg)
+		parsingErrorLogger.LogErrorInJsonFile(errMsg, ctrl)
+		errorMonitoring.IncrementError(errorMonitoring.ParsingError, ctrl)
+		return 0, errorTypes.NewParsingError(errMsg)
+	}
+	price, err := helpers.ExtractFloat(priceString)
+	if err != nil {
+		errMsg := fmt.Sprintf("in priceScraper.getPriceFromDocument (url: %v) failed to parse price: %v", URL, err)
+		log.Printf(errMsg)
+		parsingErrorLogger.LogErrorInJsonFile(errMsg, ctrl)
+		errorMonitoring.IncrementError(errorMonitoring.ParsingError, ctrl)
+		return 0, err
+	}
+	return int(price), nil
+}
+
+func getPriceURL(brandAndName, searchTerm string, services externalServices.ExternalServices, ctrl *dataTypes.FlowControl) (string, error) {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping priceScraper.getPriceURL: %v", ctrl.Ctx.Err())
+		return "", ctrl.Ctx.Err()
+	}
+
+	keys := services.Keys
+	matcher := nameMatching.NewMatcher("", services.NameAliases)
+	modelName := brandAndName
+	brandAndName = strings.ReplaceAll(brandAndName, " ", "+")
+	priceUrl := fmt.Sprintf("https://www.googleapis.com/customsearch/v1?key=%s&cx=%s&q=%s",
+		keys.CustomSearchKey, keys.PriceSearchEngineID, url.QueryEscape(brandAndName+searchTerm))
+
+	resp, err := helpers.GetRespByURL(services.HTTPClient, priceUrl, ctrl)
+	if err != nil {
+		log.Printf("in priceScraper.getPriceURL failed to get response (device: %v)", brandAndName)
+		return "", err
+	}
+	defer func(Body io.ReadCloser) {
+		err = Body.Close()
+		if err != nil {
+			log.Printf("WARNING: Failed to close HTML reader: %v", err)
+			errorMonitoring.IncrementError(errorMonitoring.CleanUpError, ctrl)
+		}
+	}(resp.Body)
+
+	var result struct {
+		Items []struct {
+			Link    string `json:"link"`
+			Title   string `json:"title"`
+			Snippet string `json:"snippet"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		log.Println("in priceScraper.getPriceURL failed to decode results")
+		parsingErrorLogger.LogErrorInJsonFile(fmt.Sprintf("in priceScraper.getPriceURL failed decode results (device: %v)", brandAndName), ctrl)
+		return "", errorTypes.NewParsingError(fmt.Sprintf("in priceScraper.getPriceURL failed decode results (device: %v)", brandAndName))
+	}
+
+	for _, item := range result.Items {
+		if !strings.Contains(item.Link, zapSource) || !matcher.Mentions(item.Title+" "+item.Snippet, modelName) {
+			continue
+		}
+		isCorrectUrl, err := services.Analyzer.IsCorrectWebpage("You get a phone model in the format "+
+			"\"[brand]+[phone name]\" and a description of a webpage written in hebrew. "+
+			"You need to return TRUE if the webpage is solely about the current phone model"+
+			", or FALSE otherwise",
+			brandAndName, item.Title+" "+item.Snippet, ctrl)
+		if err != nil {
+			log.Printf("in priceScraper.getPriceURL failed to check if url leads to correct webpage (device: %v)", brandAndName)
+			return "", err
+		}
+
+		icr, err := isComponentReplacement(item.Link, services.HTTPClient, ctrl)
+		if err != nil {
+			log.Printf("in priceScraper.getPriceURL failed to check if url leads to component replacement (device: %v)", brandAndName)
+			return "", err
+		}
+		isCorrectUrl = isCorrectUrl && !icr
+		if isCorrectUrl {
+			log.Printf("in priceScraper.getPriceURL successfully found price url with search term: %v (device: %v)", searchTerm, brandAndName)
+			return item.Link, err
+		}
+	}
+	log.Printf("in priceScraper.getPriceURL failed to find price url with search term: %v (device: %v)", searchTerm, brandAndName)
+	return "", errorTypes.NewFailedAiInstructionError(fmt.Sprintf("in priceScraper.getPriceURL failed find price url (device: %v)", brandAndName))
+}
+
+func isComponentReplacement(url string, client *http.Client, ctrl *dataTypes.FlowControl) (bool, error) {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping priceScraper.isComponentReplacement: %v", ctrl.Ctx.Err())
+		return false, ctrl.Ctx.Err()
+	}
+
+	doc, err := helpers.GetDocumentByURL(client, url, ctrl)
+	if err != nil {
+		log.Printf("in priceScraper.isComponentReplacement: %v", err)
+		return false, err
+	}
+	selector := `a[href="/models.aspx?sog=e-cellphone"][aria-label="השוואת מחירים טלפונים סלולריים"]`
+	componentReplacementString := doc.Find(selector).Text()
+	if componentReplacementString != "" {
+		return false, nil
+	}
+
+	return true, nil
+}
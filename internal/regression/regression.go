@@ -0,0 +1,152 @@
+package regression
+
+import (
+	"fmt"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
+	"math"
+)
+
+// singularityTolerance is the smallest pivot accepted when inverting the normal equations.
+const singularityTolerance = 1e-12
+
+// Model is a linear model fitted by least squares, with an intercept.
+type Model struct {
+	// Coefficients starts with the intercept, followed by one coefficient per feature.
+	Coefficients []float64
+	// ResidualStdDev estimates the spread of observations around the fitted line.
+	ResidualStdDev float64
+	SampleSize     int
+	// inverse is (X'X + ridge*I)^-1, used for the uncertainty of predictions.
+	inverse [][]float64
+}
+
+// Fit fits targets to features by ridge-regularized least squares. The ridge penalty, which keeps the fit stable
+// when features are collinear (e.g. a brand that always uses the same chipset), doesn't apply to the intercept.
+// It needs more samples than coefficients.
+func Fit(features [][]float64, targets []float64, ridge float64) (*Model, error) {
+	if len(features) != len(targets) {
+		return nil, errorTypes.NewRegressionFitError(fmt.Sprintf("in regression.Fit got %d feature rows for %d targets", len(features), len(targets)))
+	}
+	if len(features) == 0 {
+		return nil, errorTypes.NewRegressionFitError("in regression.Fit no samples")
+	}
+
+	size := len(features[0]) + 1
+	if len(features) <= size {
+		return nil, errorTypes.NewRegressionFitError(fmt.Sprintf("in regression.Fit %d samples are too few for %d coefficients", len(features), size))
+	}
+
+	normal := make([][]float64, size)
+	for i := range normal {
+		normal[i] = make([]float64, size)
+	}
+	moments := make([]float64, size)
+	for i, row := range features {
+		if len(row)+1 != size {
+			return nil, errorTypes.NewRegressionFitError(fmt.Sprintf("in regression.Fit row %d has %d features instead of %d", i, len(row), size-1))
+		}
+		x := withIntercept(row)
+		for j := range x {
+			moments[j] += x[j] * targets[i]
+			for k := range x {
+				normal[j][k] += x[j] * x[k]
+			}
+		}
+	}
+	for j := 1; j < size; j++ {
+		normal[j][j] += ridge
+	}
+
+	inverse, err := invert(normal)
+	if err != nil {
+		return nil, err
+	}
+	model := &Model{Coefficients: multiply(inverse, moments), SampleSize: len(features), inverse: inverse}
+
+	var squaredErrors float64
+	for i, row := range features {
+		residual := targets[i] - model.Predict(row)
+		squaredErrors += residual * residual
+	}
+	model.ResidualStdDev = math.Sqrt(squaredErrors / float64(len(features)-size))
+	return model, nil
+}
+
+func (model *Model) Predict(features []float64) float64 {
+	var prediction float64
+	for i, x := range withIntercept(features) {
+		prediction += model.Coefficients[i] * x
+	}
+	return prediction
+}
+
+// PredictionInterval returns the range a new observation with the given features falls in, z standard errors around
+// the prediction (1.96 for 95%). It accounts for both the noise of observations and the uncertainty of the fit.
+func (model *Model) PredictionInterval(features []float64, z float64) (float64, float64) {
+	x := withIntercept(features)
+	var leverage float64
+	for i, row := range multiply(model.inverse, x) {
+		leverage += x[i] * row
+	}
+	prediction := model.Predict(features)
+	margin := z * model.ResidualStdDev * math.Sqrt(1+leverage)
+	return prediction - margin, prediction + margin
+}
+
+func withIntercept(features []float64) []float64 {
+	return append([]float64{1}, features...)
+}
+
+func multiply(matrix [][]float64, vector []float64) []float64 {
+	product := make([]float64, len(matrix))
+	for i, row := range matrix {
+		for j, value := range row {
+			product[i] += value * vector[j]
+		}
+	}
+	return product
+}
+
+// invert inverts a square matrix by Gauss-Jordan elimination with partial pivoting.
+func invert(matrix [][]float64) ([][]float64, error) {
+	size := len(matrix)
+	augmented := make([][]float64, size)
+	for i := range matrix {
+		augmented[i] = make([]float64, 2*size)
+		copy(augmented[i], matrix[i])
+		augmented[i][size+i] = 1
+	}
+
+	for column := 0; column < size; column++ {
+		pivot := column
+		for row := column + 1; row < size; row++ {
+			if math.Abs(augmented[row][column]) > math.Abs(augmented[pivot][column]) {
+				pivot = row
+			}
+		}
+		if math.Abs(augmented[pivot][column]) < singularityTolerance {
+			return nil, errorTypes.NewRegressionFitError("in regression.invert features are linearly dependent")
+		}
+		augmented[column], augmented[pivot] = augmented[pivot], augmented[column]
+
+		scale := augmented[column][column]
+		for j := range augmented[column] {
+			augmented[column][j] /= scale
+		}
+		for row := 0; row < size; row++ {
+			if row == column || augmented[row][column] == 0 {
+				continue
+			}
+			factor := augmented[row][column]
+			for j := range augmented[row] {
+				augmented[row][j] -= factor * augmented[column][j]
+			}
+		}
+	}
+
+	inverse := make([][]float64, size)
+	for i := range augmented {
+		inverse[i] = augmented[i][size:]
+	}
+	return inverse, nil
+}
@@ -0,0 +1,119 @@
+package regression
+
+import (
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
+	"math"
+	"testing"
+)
+
+const tolerance = 1e-9
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		name                 string
+		features             [][]float64
+		targets              []float64
+		ridge                float64
+		expectedCoefficients []float64
+		isErrorExpected      bool
+	}{
+		{
+			name:                 "single feature line",
+			features:             [][]float64{{0}, {1}, {2}, {3}},
+			targets:              []float64{2, 5, 8, 11},
+			expectedCoefficients: []float64{2, 3},
+		},
+		{
+			name:                 "two features plane",
+			features:             [][]float64{{0, 0}, {1, 0}, {0, 1}, {1, 1}, {2, 3}},
+			targets:              []float64{1, 3, 0, 2, 2},
+			expectedCoefficients: []float64{1, 2, -1},
+		},
+		{
+			name:            "linearly dependent features",
+			features:        [][]float64{{1, 2}, {2, 4}, {3, 6}, {4, 8}},
+			targets:         []float64{1, 2, 3, 4},
+			isErrorExpected: true,
+		},
+		{
+			name:     "ridge keeps linearly dependent features solvable",
+			features: [][]float64{{1, 2}, {2, 4}, {3, 6}, {4, 8}},
+			targets:  []float64{1, 2, 3, 4},
+			ridge:    1e-6,
+		},
+		{
+			name:            "as many samples as coefficients",
+			features:        [][]float64{{0}, {1}},
+			targets:         []float64{0, 1},
+			isErrorExpected: true,
+		},
+		{
+			name:            "no samples",
+			isErrorExpected: true,
+		},
+		{
+			name:            "more feature rows than targets",
+			features:        [][]float64{{0}, {1}, {2}},
+			targets:         []float64{0, 1},
+			isErrorExpected: true,
+		},
+		{
+			name:            "rows of different lengths",
+			features:        [][]float64{{0}, {1, 1}, {2}, {3}},
+			targets:         []float64{0, 1, 2, 3},
+			isErrorExpected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			model, err := Fit(test.features, test.targets, test.ridge)
+			if test.isErrorExpected {
+				if !errorTypes.IsRegressionFitError(err) {
+					t.Fatalf("expected a regression fit error, got model %v and error %v", model, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if model.SampleSize != len(test.features) {
+				t.Errorf("sample size is %d, expected %d", model.SampleSize, len(test.features))
+			}
+			for i, row := range test.features {
+				if prediction := model.Predict(row); math.Abs(prediction-test.targets[i]) > 1e-3 {
+					t.Errorf("prediction of row %d is %v, expected %v", i, prediction, test.targets[i])
+				}
+			}
+			for i, expected := range test.expectedCoefficients {
+				if math.Abs(model.Coefficients[i]-expected) > tolerance {
+					t.Errorf("coefficient %d is %v, expected %v", i, model.Coefficients[i], expected)
+				}
+			}
+		})
+	}
+}
+
+func TestPredictionInterval(t *testing.T) {
+	model, err := Fit([][]float64{{0}, {1}, {2}, {3}, {4}}, []float64{1, 2.5, 5.5, 6.5, 9}, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if model.ResidualStdDev <= 0 {
+		t.Fatalf("residual standard deviation is %v, expected a positive spread", model.ResidualStdDev)
+	}
+
+	low, high := model.PredictionInterval([]float64{2}, 1.96)
+	prediction := model.Predict([]float64{2})
+	if low >= prediction || high <= prediction {
+		t.Errorf("interval [%v, %v] doesn't surround the prediction %v", low, high, prediction)
+	}
+	if math.Abs((prediction-low)-(high-prediction)) > tolerance {
+		t.Errorf("interval [%v, %v] isn't centred on the prediction %v", low, high, prediction)
+	}
+
+	farLow, farHigh := model.PredictionInterval([]float64{10}, 1.96)
+	if farHigh-farLow <= high-low {
+		t.Errorf("interval far from the samples, %v wide, isn't wider than the one among them, %v wide", farHigh-farLow, high-low)
+	}
+}
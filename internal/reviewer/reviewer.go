@@ -4,31 +4,31 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
-	"github.com/ItaiHalperin/Device-Rec-API/internal/aiAnalysis"
 	"github.com/ItaiHalperin/Device-Rec-API/internal/errorMonitoring"
 	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/externalServices"
 	"github.com/ItaiHalperin/Device-Rec-API/internal/helpers"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/nameMatching"
 	"github.com/ItaiHalperin/Device-Rec-API/internal/parsingErrorLogger"
 	"github.com/PuerkitoBio/goquery"
 	"log"
-	"os"
 	"strconv"
 	"strings"
 )
 
-func Review(device *dataTypes.Device, ctrl *dataTypes.FlowControl) error {
+func Review(device *dataTypes.Device, services externalServices.ExternalServices, ctrl *dataTypes.FlowControl) error {
 	if ctrl.Ctx.Err() != nil {
 		log.Printf("stopping reviewer.Review: %v", ctrl.Ctx.Err())
 		return ctrl.Ctx.Err()
 	}
 	var cnetReviewer Cnet
 	var tomsGuideReviewer TomsGuide
-	cnetReviewSentiment, cnetReviewMagnitude, err := getSentimentMagnitude(cnetReviewer, device.Name, ctrl)
+	cnetReviewSentiment, cnetReviewMagnitude, cnetReviewURL, err := getSentimentMagnitude(cnetReviewer, device.Name, services, ctrl)
 	if err != nil {
 		log.Printf("in reviewer.Review (device: %v) failed to find cnet review: %v", device.Name, err)
 		return err
 	}
-	tomsGuideReviewSentiment, tomsGuideReviewMagnitude, err := getSentimentMagnitude(tomsGuideReviewer, device.Name, ctrl)
+	tomsGuideReviewSentiment, tomsGuideReviewMagnitude, tomsGuideReviewURL, err := getSentimentMagnitude(tomsGuideReviewer, device.Name, services, ctrl)
 	if err != nil {
 		log.Printf("in reviewer.Review (device: %v) failed to find tom's guide review: %v", device.Name, err)
 		return err
@@ -38,45 +38,47 @@ func Review(device *dataTypes.Device, ctrl *dataTypes.FlowControl) error {
 
 	device.Review.ReviewMagnitude = averageMagnitude
 	device.Review.ReviewSentiment = averageSentiment
+	helpers.RecordProvenance(device, dataTypes.ReviewProvenancePrefix+cnetReviewer.GetDomain(), cnetReviewer.GetDomain(), cnetReviewURL, dataTypes.AiMethod)
+	helpers.RecordProvenance(device, dataTypes.ReviewProvenancePrefix+tomsGuideReviewer.GetDomain(), tomsGuideReviewer.GetDomain(), tomsGuideReviewURL, dataTypes.AiMethod)
 	return nil
 }
 
-// We get score (-1 to 1), magnitude (0 to infinity), publish time and error
-func getSentimentMagnitude(reviewer Reviewer, model string, ctrl *dataTypes.FlowControl) (float64, float64, error) {
+// We get score (-1 to 1), magnitude (0 to infinity), the review url and error
+func getSentimentMagnitude(reviewer Reviewer, model string, services externalServices.ExternalServices, ctrl *dataTypes.FlowControl) (float64, float64, string, error) {
 	if ctrl.Ctx.Err() != nil {
 		log.Printf("stopping reviewer.getSentimentMagnitude: %v", ctrl.Ctx.Err())
-		return 0, 0, ctrl.Ctx.Err()
+		return 0, 0, "", ctrl.Ctx.Err()
 	}
 
-	url, err := GetReviewURLByModel(model, reviewer.GetDomain(), ctrl)
+	url, err := GetReviewURLByModel(model, reviewer.GetDomain(), services, ctrl)
 	if err != nil {
 		log.Printf("in reviewer.getSentimentMagnitude (device: %v) failed to get review url: %v", model, err)
-		return 0, 0, err
+		return 0, 0, "", err
 	}
 
-	doc, err := helpers.GetDocumentByURL(url, ctrl)
+	doc, err := helpers.GetDocumentByURL(services.HTTPClient, url, ctrl)
 	if err != nil {
 		log.Printf("in reviewer.getSentimentMagnitude (device: %v) failed to get document from review url: %v", model, err)
-		return 0, 0, err
+		return 0, 0, "", err
 	}
 
 	review, err := reviewer.getReviewString(model, url, doc, ctrl)
 	if err != nil {
 		log.Printf("in reviewer.getSentimentMagnitude (device: %v) failed to get review string from document: %v", model, err)
-		return 0, 0, err
+		return 0, 0, "", err
 	}
-	sentiment, magnitude, err := aiAnalysis.AnalyseSentimentMagnitude(review, ctrl)
+	sentiment, magnitude, err := services.Analyzer.AnalyseSentimentMagnitude(review, ctrl)
 	if err != nil {
 		log.Printf("in reviewer.getSentimentMagnitude (device: %v) failed to analyze review string: %v", model, err)
-		return 0, 0, err
+		return 0, 0, "", err
 	}
 
 	stars, err := reviewer.GetStars(model, doc, ctrl)
 	if err != nil {
-		return sentiment, magnitude, nil
+		return sentiment, magnitude, url, nil
 	}
 
-	return sentiment*0.6 + ((stars-3)/2)*0.4, magnitude, nil
+	return sentiment*0.6 + ((stars-3)/2)*0.4, magnitude, url, nil
 }
 
 func SetUnvalidatedNormalizedReviewScore(newMinMaxMagnitudeSentiment dataTypes.MinMaxValues, device *dataTypes.Device) {
@@ -87,20 +89,19 @@ func SetUnvalidatedNormalizedReviewScore(newMinMaxMagnitudeSentiment dataTypes.M
 	device.Review.UnvalidatedReviewScore = reviewScore
 }
 
-func GetReviewURLByModel(brandAndName string, reviewerDomain string, ctrl *dataTypes.FlowControl) (string, error) {
+func GetReviewURLByModel(brandAndName string, reviewerDomain string, services externalServices.ExternalServices, ctrl *dataTypes.FlowControl) (string, error) {
 	if ctrl.Ctx.Err() != nil {
 		log.Printf("stopping reviewer.GetReviewURLByModel: %v", ctrl.Ctx.Err())
 		return "", ctrl.Ctx.Err()
 	}
 
-	apiKey := os.Getenv("CUSTOM_SEARCH_KEY")
-	searchEngineID := os.Getenv("REVIEW_SEARCH_ENGINE_ID")
+	keys := services.Keys
 	searchTerm := strings.ReplaceAll(brandAndName, " ", "+") + "+review+" + reviewerDomain
 
 	url := fmt.Sprintf("https://www.googleapis.com/customsearch/v1?key=%s&cx=%s&q=%s",
-		apiKey, searchEngineID, searchTerm)
+		keys.CustomSearchKey, keys.ReviewSearchEngineID, searchTerm)
 
-	resp, err := helpers.GetRespByURL(url, ctrl)
+	resp, err := helpers.GetRespByURL(services.HTTPClient, url, ctrl)
 	if err != nil {
 		log.Printf("in reviewer.GetReviewURLByModel (device: %v) failed to get review search results: %v", brandAndName, err)
 		return "", err
@@ -120,8 +121,12 @@ func GetReviewURLByModel(brandAndName string, reviewerDomain string, ctrl *dataT
 		return "", errorTypes.NewParsingError(fmt.Sprintf("in reviewer.GetReviewURLByModel (device: %v) failed to decode search results", brandAndName))
 	}
 
+	matcher := nameMatching.NewMatcher("", services.NameAliases)
 	for _, item := range result.Items {
-		isCorrectUrl, err := aiAnalysis.IsCorrectWebpage("You get a phone model in the format "+
+		if !strings.Contains(item.Link, reviewerDomain) || !matcher.Mentions(item.Title+" "+item.Snippet, brandAndName) {
+			continue
+		}
+		isCorrectUrl, err := services.Analyzer.IsCorrectWebpage("You get a phone model in the format "+
 			"\"[brand]+[phone name]\" and a description of a review. "+
 			"You need to return TRUE if the review is about the current phone model, "+
 			"or FALSE otherwise. Ignore suffixes like 5G, focus on model name and number",
@@ -130,7 +135,7 @@ func GetReviewURLByModel(brandAndName string, reviewerDomain string, ctrl *dataT
 			log.Printf("in reviewer.GetReviewURLByModel (device: %v) failed to check if url leads to correct webpage: %v", brandAndName, err)
 			return "", err
 		}
-		if strings.Contains(item.Link, reviewerDomain) && isCorrectUrl {
+		if isCorrectUrl {
 			return item.Link, err
 		}
 	}
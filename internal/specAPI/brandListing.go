@@ -0,0 +1,230 @@
+package specAPI
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/brandCatalog"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorMonitoring"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/parsingErrorLogger"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	pageCacheDir            = "specPageCache"
+	enumerationProgressPath = "brandEnumerationProgress.json"
+	// enumerationProgressTTL is how long an interrupted enumeration may be resumed before it starts over.
+	enumerationProgressTTL = 24 * time.Hour
+)
+
+// cachedPage is a listing page kept on disk with the validators needed to revalidate it.
+type cachedPage struct {
+	ETag         string `json:"etag"`
+	LastModified string `json:"last_modified"`
+	Body         []byte `json:"body"`
+}
+
+// enumerationProgress records how far the brand enumeration got, so an interrupted run resumes where it stopped.
+type enumerationProgress struct {
+	StartedAt time.Time                `json:"started_at"`
+	Brands    map[string]brandProgress `json:"brands"`
+}
+
+type brandProgress struct {
+	NextPage    int     `json:"next_page"`
+	IsCompleted bool    `json:"is_completed"`
+	Phones      []Phone `json:"phones"`
+}
+
+func getAllNamesAndLinksByBrand(brand brandCatalog.Brand, progress *enumerationProgress, client *http.Client, ctrl *dataTypes.FlowControl) ([]Phone, error) {
+	brandProgress := progress.Brands[brand.Name]
+	if brandProgress.IsCompleted {
+		log.Printf("in specAPI.getAllNamesAndLinksByBrand already read all devices in %v", brand.Directory)
+		return brandProgress.Phones, nil
+	}
+	if brandProgress.NextPage < 1 {
+		brandProgress.NextPage = 1
+	}
+
+	baseURL := specAPIBaseURL + "/brands/" + brand.Directory + "?page="
+	for page := brandProgress.NextPage; brand.MaxPage == 0 || page <= brand.MaxPage; page++ {
+		url := fmt.Sprintf("%s%d", baseURL, page)
+		log.Printf("Fetching data from: %s\n", url)
+
+		body, err := getListingPage(client, url, ctrl)
+		if err != nil {
+			log.Printf("in specAPI.getAllNamesAndLinksByBrand (url: %v)\nfailed to get page: %v", url, err)
+			return nil, err
+		}
+
+		var apiResponse Response
+		err = json.Unmarshal(body, &apiResponse)
+		if err != nil {
+			errMsg := fmt.Sprintf("in specAPI.getAllNamesAndLinksByBrand (url: %v)\nfailed to unmarshal resp body", url)
+			log.Println(errMsg)
+			parsingErrorLogger.LogErrorInJsonFile(errMsg, ctrl)
+			return nil, err
+		}
+
+		if len(apiResponse.Data.Phones) == 0 {
+			log.Printf("in specAPI.getAllNamesAndLinksByBrand finished reading all devices in %v", brand.Directory)
+			break
+		}
+		for _, phone := range apiResponse.Data.Phones {
+			if brand.IsListed(phone.PhoneName) {
+				brandProgress.Phones = append(brandProgress.Phones, phone)
+			}
+		}
+
+		brandProgress.NextPage = page + 1
+		progress.Brands[brand.Name] = brandProgress
+		saveEnumerationProgress(progress)
+		if apiResponse.Data.LastPage > 0 && page >= apiResponse.Data.LastPage {
+			log.Printf("in specAPI.getAllNamesAndLinksByBrand finished reading all %v pages of %v", apiResponse.Data.LastPage, brand.Directory)
+			break
+		}
+	}
+
+	brandProgress.IsCompleted = true
+	progress.Brands[brand.Name] = brandProgress
+	saveEnumerationProgress(progress)
+	return brandProgress.Phones, nil
+}
+
+// getListingPage returns the body of a listing page, revalidating the cached copy with its ETag or Last-Modified
+// date when there is one.
+func getListingPage(client *http.Client, url string, ctrl *dataTypes.FlowControl) ([]byte, error) {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping specAPI.getListingPage: %v", ctrl.Ctx.Err())
+		return nil, ctrl.Ctx.Err()
+	}
+
+	cachePath := getPageCachePath(url)
+	cached, isCached := readCachedPage(cachePath)
+	request, err := http.NewRequestWithContext(ctrl.Ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, errorTypes.NewErrorGettingURL(fmt.Sprintf("in specAPI.getListingPage (url: %v) invalid request: %v", url, err))
+	}
+	if isCached && cached.ETag != "" {
+		request.Header.Set("If-None-Match", cached.ETag)
+	}
+	if isCached && cached.LastModified != "" {
+		request.Header.Set("If-Modified-Since", cached.LastModified)
+	}
+
+	resp, err := client.Do(request)
+	if err != nil {
+		errorMonitoring.IncrementError(errorMonitoring.GettingURLError, ctrl)
+		return nil, errorTypes.NewErrorGettingURL(fmt.Sprintf("in specAPI.getListingPage (url: %v) error getting page: %v", url, err))
+	}
+	defer func(Body io.ReadCloser) {
+		err = Body.Close()
+		if err != nil {
+			log.Printf("WARNING: Failed to close HTML reader: %v", err)
+			errorMonitoring.IncrementError(errorMonitoring.CleanUpError, ctrl)
+		}
+	}(resp.Body)
+
+	if resp.StatusCode == http.StatusNotModified && isCached {
+		return cached.Body, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		errorMonitoring.IncrementError(errorMonitoring.GettingURLError, ctrl)
+		return nil, errorTypes.NewErrorGettingURL(fmt.Sprintf("in specAPI.getListingPage got bad status code: %d %s", resp.StatusCode, resp.Status))
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Printf("in specAPI.getListingPage (url: %v)\nfailed to read resp body", url)
+		return nil, err
+	}
+
+	page := cachedPage{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified"), Body: body}
+	if page.ETag != "" || page.LastModified != "" {
+		writeCachedPage(cachePath, page)
+	}
+	return body, nil
+}
+
+func getPageCachePath(url string) string {
+	hash := sha256.Sum256([]byte(url))
+	return filepath.Join(pageCacheDir, hex.EncodeToString(hash[:])+".json")
+}
+
+func readCachedPage(path string) (cachedPage, bool) {
+	pageJson, err := os.ReadFile(path)
+	if err != nil {
+		return cachedPage{}, false
+	}
+	var page cachedPage
+	if err = json.Unmarshal(pageJson, &page); err != nil {
+		log.Printf("WARNING: ignoring corrupt cached page %v: %v", path, err)
+		return cachedPage{}, false
+	}
+	return page, true
+}
+
+// writeCachedPage only logs failures: without the cache the page is simply downloaded again next time.
+func writeCachedPage(path string, page cachedPage) {
+	pageJson, err := json.Marshal(page)
+	if err != nil {
+		log.Printf("WARNING: failed to encode cached page %v: %v", path, err)
+		return
+	}
+	if err = os.MkdirAll(pageCacheDir, 0755); err != nil {
+		log.Printf("WARNING: failed to create page cache directory: %v", err)
+		return
+	}
+	if err = os.WriteFile(path, pageJson, 0644); err != nil {
+		log.Printf("WARNING: failed to write cached page %v: %v", path, err)
+	}
+}
+
+// loadEnumerationProgress returns the progress of an interrupted enumeration, or a fresh one if there is none or it
+// has expired.
+func loadEnumerationProgress() *enumerationProgress {
+	fresh := &enumerationProgress{StartedAt: time.Now(), Brands: make(map[string]brandProgress)}
+	progressJson, err := os.ReadFile(enumerationProgressPath)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("WARNING: failed to read enumeration progress, starting over: %v", err)
+		}
+		return fresh
+	}
+	var progress enumerationProgress
+	if err = json.Unmarshal(progressJson, &progress); err != nil {
+		log.Printf("WARNING: failed to unmarshal enumeration progress, starting over: %v", err)
+		return fresh
+	}
+	if time.Since(progress.StartedAt) > enumerationProgressTTL || progress.Brands == nil {
+		return fresh
+	}
+	log.Printf("in specAPI.loadEnumerationProgress resuming enumeration started at %v", progress.StartedAt)
+	return &progress
+}
+
+func saveEnumerationProgress(progress *enumerationProgress) {
+	progressJson, err := json.Marshal(progress)
+	if err != nil {
+		log.Printf("WARNING: failed to encode enumeration progress: %v", err)
+		return
+	}
+	if err = os.WriteFile(enumerationProgressPath, progressJson, 0644); err != nil {
+		log.Printf("WARNING: failed to write enumeration progress: %v", err)
+	}
+}
+
+func clearEnumerationProgress() {
+	if err := os.Remove(enumerationProgressPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("WARNING: failed to remove enumeration progress: %v", err)
+	}
+}
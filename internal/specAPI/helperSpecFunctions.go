@@ -9,6 +9,8 @@ import (
 	"github.com/ItaiHalperin/Device-Rec-API/internal/helpers"
 	"github.com/ItaiHalperin/Device-Rec-API/internal/parsingErrorLogger"
 	"log"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -57,7 +59,8 @@ func extractCameraSetup(deviceName, deviceURL string, specsByKeys []SpecByKey, c
 	return "", errorTypes.NewParsingError(errMsg)
 }
 
-func setDisplayDetails(deviceName, deviceURL string, curSpecs *dataTypes.Specifications, specsByKeys []SpecByKey, ctrl *dataTypes.FlowControl) error {
+func setDisplayDetails(deviceName, deviceURL string, curSpecs *dataTypes.Specifications, specsByKeys []SpecByKey, analyzer *aiAnalysis.Analyzer, ctrl *dataTypes.FlowControl) (string, error) {
+	nitsMethod := dataTypes.ParsedMethod
 	numOfSpecsCollected := 0
 	numOfSpecsExpected := 4
 	for _, detail := range specsByKeys {
@@ -66,7 +69,7 @@ func setDisplayDetails(deviceName, deviceURL string, curSpecs *dataTypes.Specifi
 			displaySize, err := extractDisplaySize(deviceName, deviceURL, detail.Val, ctrl)
 			if err != nil {
 				log.Printf("in helperSpecFunctions.setDisplayDetails error extracting display size: %v", err)
-				return err
+				return "", err
 			}
 			curSpecs.DisplaySize = displaySize
 			numOfSpecsCollected++
@@ -74,7 +77,7 @@ func setDisplayDetails(deviceName, deviceURL string, curSpecs *dataTypes.Specifi
 			displayResolution, err := extractDisplayResolution(deviceName, deviceURL, detail.Val, ctrl)
 			if err != nil {
 				log.Printf("in helperSpecFunctions.setDisplayDetails error extracting display resolution: %v", err)
-				return err
+				return "", err
 			}
 			curSpecs.DisplayResolution = displayResolution
 			numOfSpecsCollected++
@@ -83,28 +86,29 @@ func setDisplayDetails(deviceName, deviceURL string, curSpecs *dataTypes.Specifi
 			numOfSpecsCollected++
 			nits, err := extractNits(deviceName, deviceURL, detail.Val)
 			if err != nil {
-				nits, err = getNitsFromAi(deviceName, deviceURL, ctrl)
+				nits, err = getNitsFromAi(deviceName, deviceURL, analyzer, ctrl)
 				if err != nil {
 					log.Printf("in helperSpecFunctions.setDisplayDetails error extracting display nits (both ai and api): %v", err)
-					return err
+					return "", err
 				}
+				nitsMethod = dataTypes.AiMethod
 			}
 			curSpecs.Nits = nits
 			numOfSpecsCollected++
 		}
 	}
 	if numOfSpecsCollected == numOfSpecsExpected {
-		return nil
+		return nitsMethod, nil
 	}
 	log.Printf("in helperSpecFunctions.setDisplayDetails failed to all display details")
 	parsingErrorLogger.LogErrorInJsonFile("in helperSpecFunctions.setDisplayDetails failed to all "+
 		"display details", ctrl)
 	errorMonitoring.IncrementError(errorMonitoring.ParsingError, ctrl)
-	return errorTypes.NewParsingError("in helperSpecFunctions.setDisplayDetails failed to all display details")
+	return "", errorTypes.NewParsingError("in helperSpecFunctions.setDisplayDetails failed to all display details")
 }
 
-func getNitsFromAi(deviceName, deviceURL string, ctrl *dataTypes.FlowControl) (int, error) {
-	nits, err := aiAnalysis.GetIntAIResponse("You're given a phone model, and you need to output how many "+
+func getNitsFromAi(deviceName, deviceURL string, analyzer *aiAnalysis.Analyzer, ctrl *dataTypes.FlowControl) (int, error) {
+	nits, err := analyzer.GetIntAIResponse("You're given a phone model, and you need to output how many "+
 		"nits at max brightness its display has. If you don't know output 0. Output just a number.", deviceName, ctrl)
 	if err != nil || nits == 0 || nits == 123 {
 		log.Printf("in helperSpecFunctions.getNitsFromAi (device: %v, url: %v)\nfailed to get response from ai",
@@ -265,3 +269,144 @@ func setSelfieCameraSetup(deviceName, deviceURL string, curSpecs *dataTypes.Spec
 	curSpecs.SelfieCamerasSetup = cameraSetup
 	return nil
 }
+
+// memoryOptionRegex matches one internal storage option, e.g. "256GB 8GB RAM", "1TB 12GB RAM" or just "64GB".
+var memoryOptionRegex = regexp.MustCompile(`^(\d+)\s*(GB|TB)(?:\s+(\d+(?:\.\d+)?)\s*(GB|MB)\s+RAM)?`)
+
+// extractMemoryVariants reads the storage/RAM options listed under the Memory section's "Internal" key.
+// Options it can't parse, such as the storage type ("UFS 4.0"), are skipped.
+func extractMemoryVariants(specsByKeys []SpecByKey) []dataTypes.Variant {
+	var variants []dataTypes.Variant
+	type memory struct {
+		storageGB int
+		ramGB     float64
+	}
+	seen := make(map[memory]bool)
+	for _, detail := range specsByKeys {
+		if detail.Key != "Internal" {
+			continue
+		}
+		for _, val := range detail.Val {
+			for _, option := range strings.Split(val, ",") {
+				match := memoryOptionRegex.FindStringSubmatch(strings.TrimSpace(option))
+				if match == nil {
+					continue
+				}
+				storage, err := strconv.Atoi(match[1])
+				if err != nil {
+					continue
+				}
+				if match[2] == "TB" {
+					storage *= 1024
+				}
+				variant := dataTypes.Variant{StorageGB: storage}
+				if match[3] != "" {
+					ram, err := strconv.ParseFloat(match[3], 64)
+					if err != nil {
+						continue
+					}
+					if match[4] == "MB" {
+						ram /= 1024
+					}
+					variant.RAMGB = ram
+				}
+				if key := (memory{variant.StorageGB, variant.RAMGB}); !seen[key] {
+					seen[key] = true
+					variants = append(variants, variant)
+				}
+			}
+		}
+	}
+	return variants
+}
+
+var (
+	wiredChargingRegex    = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*W\s+wired`)
+	wirelessChargingRegex = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*W\s+wireless`)
+	ipRatingRegex         = regexp.MustCompile(`\bIP[0-9X][0-9X]\b`)
+	weightRegex           = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*g\b`)
+	dimensionsRegex       = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*x\s*(\d+(?:\.\d+)?)\s*x\s*(\d+(?:\.\d+)?)\s*mm`)
+	osVersionRegex        = regexp.MustCompile(`^(Android|iOS)\s+(\d+(?:\.\d+)?)`)
+	osUpgradesRegex       = regexp.MustCompile(`up to (\d+) major`)
+)
+
+// setPlatformDetails reads the chipset and the OS, its version and the promised number of major upgrades,
+// e.g. "Android 14, up to 7 major Android upgrades, One UI 6.1".
+func setPlatformDetails(curSpecs *dataTypes.Specifications, specsByKeys []SpecByKey) {
+	for _, detail := range specsByKeys {
+		value := strings.TrimSpace(strings.Join(detail.Val, ", "))
+		switch detail.Key {
+		case "Chipset":
+			curSpecs.Chipset = strings.TrimSpace(helpers.GetBeforeSubstring(value, " ("))
+		case "OS":
+			if match := osVersionRegex.FindStringSubmatch(value); match != nil {
+				curSpecs.OS = match[1]
+				curSpecs.OSVersion, _ = strconv.ParseFloat(match[2], 64)
+			}
+			if match := osUpgradesRegex.FindStringSubmatch(value); match != nil {
+				curSpecs.MajorOSUpgrades, _ = strconv.Atoi(match[1])
+			}
+		}
+	}
+}
+
+// setBodyDetails reads the dimensions ("147.6 x 71.6 x 7.8 mm (...)"), the weight ("171 g (6.03 oz)") and the
+// IP rating, which is listed among the body's unnamed details.
+func setBodyDetails(curSpecs *dataTypes.Specifications, specsByKeys []SpecByKey) {
+	for _, detail := range specsByKeys {
+		value := strings.TrimSpace(strings.Join(detail.Val, ", "))
+		switch detail.Key {
+		case "Dimensions":
+			if match := dimensionsRegex.FindStringSubmatch(value); match != nil {
+				curSpecs.Dimensions.HeightMM, _ = strconv.ParseFloat(match[1], 64)
+				curSpecs.Dimensions.WidthMM, _ = strconv.ParseFloat(match[2], 64)
+				curSpecs.Dimensions.ThicknessMM, _ = strconv.ParseFloat(match[3], 64)
+			}
+		case "Weight":
+			if match := weightRegex.FindStringSubmatch(value); match != nil {
+				curSpecs.WeightGrams, _ = strconv.ParseFloat(match[1], 64)
+			}
+		default:
+			if ipRating := ipRatingRegex.FindString(value); ipRating != "" && curSpecs.IPRating == "" {
+				curSpecs.IPRating = ipRating
+			}
+		}
+	}
+}
+
+// setChargingDetails reads the wired and wireless charging wattage, e.g. "45W wired, PD3.0" and "15W wireless".
+// Reverse wireless charging ("4.5W reverse wireless") doesn't match.
+func setChargingDetails(curSpecs *dataTypes.Specifications, specsByKeys []SpecByKey) {
+	for _, detail := range specsByKeys {
+		if detail.Key != "Charging" {
+			continue
+		}
+		for _, value := range detail.Val {
+			if match := wiredChargingRegex.FindStringSubmatch(value); match != nil {
+				curSpecs.WiredChargingW, _ = strconv.ParseFloat(match[1], 64)
+			}
+			if match := wirelessChargingRegex.FindStringSubmatch(value); match != nil {
+				curSpecs.WirelessChargingW, _ = strconv.ParseFloat(match[1], 64)
+			}
+		}
+	}
+}
+
+func setNetworkDetails(curSpecs *dataTypes.Specifications, specsByKeys []SpecByKey) {
+	for _, detail := range specsByKeys {
+		if detail.Key == "Technology" {
+			curSpecs.Has5G = strings.Contains(strings.Join(detail.Val, " "), "5G")
+		}
+	}
+}
+
+func setMemoryOptions(curSpecs *dataTypes.Specifications, variants []dataTypes.Variant) {
+	for _, variant := range variants {
+		if !slices.Contains(curSpecs.StorageOptionsGB, variant.StorageGB) {
+			curSpecs.StorageOptionsGB = append(curSpecs.StorageOptionsGB, variant.StorageGB)
+		}
+		if variant.RAMGB > 0 && !slices.Contains(curSpecs.RAMOptionsGB, variant.RAMGB) {
+			curSpecs.RAMOptionsGB = append(curSpecs.RAMOptionsGB, variant.RAMGB)
+		}
+	}
+}
@@ -6,17 +6,19 @@ import (
 	"github.com/ItaiHalperin/Device-Rec-API/dataTypes"
 	"github.com/ItaiHalperin/Device-Rec-API/internal/errorMonitoring"
 	"github.com/ItaiHalperin/Device-Rec-API/internal/errorTypes"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/externalServices"
 	"github.com/ItaiHalperin/Device-Rec-API/internal/helpers"
 	"github.com/ItaiHalperin/Device-Rec-API/internal/parsingErrorLogger"
 	"io"
 	"log"
+	"net/http"
+	"net/url"
 	"strings"
 )
 
 const (
-	maxApplePage   = 4
-	maxGooglePage  = 1
-	maxSamsungPage = 9
+	specAPISource  = "phone-specs-api"
+	specAPIBaseURL = "https://phone-specs-api.vercel.app"
 )
 
 type SpecByKey struct {
@@ -32,6 +34,7 @@ type SpecsByTitle struct {
 type DeviceData struct {
 	Brand       string         `json:"brand"`
 	PhoneName   string         `json:"phone_name"`
+	Thumbnail   string         `json:"thumbnail"`
 	ReleaseDate string         `json:"release_date"`
 	RawSpecs    []SpecsByTitle `json:"specifications"`
 }
@@ -41,21 +44,8 @@ type APIResponse struct {
 	Data   DeviceData `json:"data"`
 }
 
-func getMaxPage(brand string) int {
-	switch brand {
-	case "Apple":
-		return maxApplePage
-	case "Google":
-		return maxGooglePage
-	case "Samsung":
-		return maxSamsungPage
-	default:
-		return -1
-	}
-}
-
-func SetSpecs(device *dataTypes.Device, url string, ctrl *dataTypes.FlowControl) error {
-	resp, err := helpers.GetRespByURL(url, ctrl)
+func SetSpecs(device *dataTypes.Device, url string, services externalServices.ExternalServices, ctrl *dataTypes.FlowControl) error {
+	resp, err := helpers.GetRespByURL(services.HTTPClient, url, ctrl)
 	if err != nil {
 		log.Println("in specAPI.SetSpecs error getting response")
 		return err
@@ -80,6 +70,8 @@ func SetSpecs(device *dataTypes.Device, url string, ctrl *dataTypes.FlowControl)
 	device.Brand = strings.TrimSpace(responseData.Data.Brand)
 
 	curSpecs := dataTypes.Specifications{}
+	nitsMethod := dataTypes.ParsedMethod
+	var variants []dataTypes.Variant
 	err = setReleaseDate(device.Name, url, &curSpecs, responseData.Data.ReleaseDate, ctrl)
 	if err != nil {
 		log.Printf("in specAPI.SetSpecs (device: %v, url: %v)\n error setting release date: %v", device.Name, url, err)
@@ -109,14 +101,24 @@ func SetSpecs(device *dataTypes.Device, url string, ctrl *dataTypes.FlowControl)
 				log.Printf("in specAPI.SetSpecs failed to set battery size for device: %v", device.Name)
 				return err
 			}
+			setChargingDetails(&curSpecs, spec.SpecsByKeys)
 			numOfSpecsCollected++
 		case "Display":
-			err = setDisplayDetails(device.Name, url, &curSpecs, spec.SpecsByKeys, ctrl)
+			nitsMethod, err = setDisplayDetails(device.Name, url, &curSpecs, spec.SpecsByKeys, services.Analyzer, ctrl)
 			if err != nil {
 				log.Printf("in specAPI.SetSpecs failed to set display details for device: %v", device.Name)
 				return err
 			}
 			numOfSpecsCollected++
+		case "Memory":
+			variants = extractMemoryVariants(spec.SpecsByKeys)
+			setMemoryOptions(&curSpecs, variants)
+		case "Platform":
+			setPlatformDetails(&curSpecs, spec.SpecsByKeys)
+		case "Body":
+			setBodyDetails(&curSpecs, spec.SpecsByKeys)
+		case "Network":
+			setNetworkDetails(&curSpecs, spec.SpecsByKeys)
 		}
 
 	}
@@ -135,37 +137,76 @@ func SetSpecs(device *dataTypes.Device, url string, ctrl *dataTypes.FlowControl)
 	}
 	curSpecs.PixelDensity = pixelDensity
 	device.Specs = curSpecs
+	setVariants(device, url, variants)
+	recordSpecsProvenance(device, url, nitsMethod, services.Analyzer.GetAiSource())
 	return nil
 }
 
-func GatherAllDeviceNamesAndLinks(ctrl *dataTypes.FlowControl) (map[string][]string, error) {
-	iphones, err := getAllNamesAndLinksByBrand("apple-phones-48", "iphone", "Apple", ctrl, "ipad", "cdma", "watch")
-	if err != nil {
-		log.Printf("in specAPI.GatherAllDeviceNamesAndLinks failed to get iphones: %v", err)
-		return nil, err
+// setVariants replaces the device's variants, keeping the prices of the variants that are still listed.
+// A device without listed memory options keeps the variants it has.
+func setVariants(device *dataTypes.Device, url string, variants []dataTypes.Variant) {
+	if len(variants) == 0 {
+		log.Printf("in specAPI.setVariants (device: %v) no memory variants listed", device.Name)
+		return
 	}
-	pixels, err := getAllNamesAndLinksByBrand("google-phones-107", "pixel", "Google", ctrl, "tablet", "fold", "watch")
-	if err != nil {
-		log.Printf("in specAPI.GatherAllDeviceNamesAndLinks failed to get pixels: %v", err)
-		return nil, err
+	for i := range variants {
+		for _, oldVariant := range device.Variants {
+			if oldVariant.StorageGB == variants[i].StorageGB && oldVariant.RAMGB == variants[i].RAMGB {
+				variants[i] = oldVariant
+				break
+			}
+		}
 	}
+	device.Variants = variants
+	helpers.RecordProvenance(device, "specs-variants", specAPISource, url, dataTypes.ParsedMethod)
+}
 
-	samsungs, err := getAllNamesAndLinksByBrand("samsung-phones-9", "galaxy", "Samsung", ctrl,
-		"watch", "tab", "flip", "fold", "(india)", "Grand", "Indulge", "Nexus", "LTE", "Prevail", "Attain",
-		" Star ", " zoom ", " Duos ", "(", " Pop ", " S ", " Young ", " Express ", "Core", "alpha", "Sport", "Edge", "ii",
-		" Active ", " Quantum ", " Lite ", " Stellar ", " Apollo ", " Ace ", "View", " Light ", "Xcover", "Galaxy M", "Neo")
-	if err != nil {
-		log.Println("in specAPI.GatherAllDeviceNamesAndLinks failed to get samsungs")
-		return nil, err
+func recordSpecsProvenance(device *dataTypes.Device, url, nitsMethod, aiSource string) {
+	for _, field := range []string{"specs-release-date", "specs-battery-capacity", "specs-display-size",
+		"specs-display-resolution", "specs-main-cameras-setup", "specs-selfie-cameras-setup", "specs-pixel-density",
+		"specs-refresh-rate"} {
+		helpers.RecordProvenance(device, field, specAPISource, url, dataTypes.ParsedMethod)
 	}
+	for field, isListed := range map[string]bool{
+		"specs-chipset":           device.Specs.Chipset != "",
+		"specs-memory-options":    len(device.Specs.StorageOptionsGB) > 0,
+		"specs-weight":            device.Specs.WeightGrams > 0,
+		"specs-dimensions":        device.Specs.Dimensions.HeightMM > 0,
+		"specs-wired-charging":    device.Specs.WiredChargingW > 0,
+		"specs-wireless-charging": device.Specs.WirelessChargingW > 0,
+		"specs-ip-rating":         device.Specs.IPRating != "",
+		"specs-os":                device.Specs.OS != "",
+		"specs-5g":                device.Specs.Has5G,
+	} {
+		if isListed {
+			helpers.RecordProvenance(device, field, specAPISource, url, dataTypes.ParsedMethod)
+		}
+	}
+	if nitsMethod == dataTypes.AiMethod {
+		helpers.RecordProvenance(device, "specs-nits", aiSource, "", nitsMethod)
+		return
+	}
+	helpers.RecordProvenance(device, "specs-nits", specAPISource, url, nitsMethod)
+}
 
+// GatherAllDeviceNamesAndLinks lists the phones of every brand in the brand catalog. A failed run is resumed from
+// the page it stopped at by the next call.
+func GatherAllDeviceNamesAndLinks(services externalServices.ExternalServices, ctrl *dataTypes.FlowControl) (map[string][]string, error) {
+	brands := services.Brands.GetBrands()
+	progress := loadEnumerationProgress()
 	var phoneAndLinkMap = make(map[string][]string)
-	for _, series := range [][]Phone{iphones, pixels, samsungs} {
-		for _, phone := range series {
+	for _, brand := range brands {
+		phones, err := getAllNamesAndLinksByBrand(brand, progress, services.HTTPClient, ctrl)
+		if err != nil {
+			log.Printf("in specAPI.GatherAllDeviceNamesAndLinks failed to get %v phones: %v", brand.Name, err)
+			return nil, err
+		}
+		for _, phone := range phones {
 			phoneAndLinkMap[phone.PhoneName] = []string{phone.Detail, phone.Image}
 		}
 	}
 
+	clearEnumerationProgress()
 	return phoneAndLinkMap, nil
 }
 
@@ -178,60 +219,133 @@ type Phone struct {
 type Response struct {
 	Status bool `json:"status"`
 	Data   struct {
-		Phones []Phone `json:"phones"`
+		CurrentPage int     `json:"current_page"`
+		LastPage    int     `json:"last_page"`
+		Phones      []Phone `json:"phones"`
 	} `json:"data"`
 }
 
-func getAllNamesAndLinksByBrand(brandDirectory, series, brand string, ctrl *dataTypes.FlowControl, excludedTerms ...string) ([]Phone, error) {
-	baseURL := "https://phone-specs-api.vercel.app/brands/" + brandDirectory + "?page="
-	page := 1
-	var allPhones []Phone
+type searchResponse struct {
+	Status bool `json:"status"`
+	Data   struct {
+		Phones []searchedPhone `json:"phones"`
+	} `json:"data"`
+}
 
-	for i := 1; i < getMaxPage(brand); page++ {
-		url := fmt.Sprintf("%s%d", baseURL, page)
-		log.Printf("Fetching data from: %s\n", url)
+type searchedPhone struct {
+	Brand     string `json:"brand"`
+	PhoneName string `json:"phone_name"`
+	Detail    string `json:"detail"`
+	Image     string `json:"image"`
+}
 
-		resp, err := helpers.GetRespByURL(url, ctrl)
-		if err != nil {
-			errMsg := fmt.Sprintf("in specAPI.getAllNamesAndLinksByBrand (url: %v)\nfailed to get response", url)
-			log.Println(errMsg)
-			return nil, err
-		}
+func ResolveDevice(brand, model string, client *http.Client, ctrl *dataTypes.FlowControl) (dataTypes.DeviceInQueue, error) {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping specAPI.ResolveDevice: %v", ctrl.Ctx.Err())
+		return dataTypes.DeviceInQueue{}, ctrl.Ctx.Err()
+	}
 
-		body, err := io.ReadAll(resp.Body)
+	query := strings.TrimSpace(brand + " " + model)
+	searchURL := specAPIBaseURL + "/search?query=" + url.QueryEscape(query)
+	resp, err := helpers.GetRespByURL(client, searchURL, ctrl)
+	if err != nil {
+		log.Printf("in specAPI.ResolveDevice (device: %v) failed to get search results: %v", query, err)
+		return dataTypes.DeviceInQueue{}, err
+	}
+	defer func(Body io.ReadCloser) {
+		err = Body.Close()
 		if err != nil {
-			errMsg := fmt.Sprintf("in specAPI.getAllNamesAndLinksByBrand (url: %v)\nfailed to read resp body", url)
-			log.Println(errMsg)
-			return nil, err
+			log.Printf("WARNING: Failed to close HTML reader: %v", err)
+			errorMonitoring.IncrementError(errorMonitoring.CleanUpError, ctrl)
 		}
+	}(resp.Body)
 
-		var apiResponse Response
-		err = json.Unmarshal(body, &apiResponse)
-		if err != nil {
-			errMsg := fmt.Sprintf("in specAPI.getAllNamesAndLinksByBrand (url: %v)\nfailed to unmarshal resp body", url)
-			log.Println(errMsg)
-			parsingErrorLogger.LogErrorInJsonFile(errMsg, ctrl)
-			return nil, err
-		}
+	var result searchResponse
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		errMsg := fmt.Sprintf("in specAPI.ResolveDevice (device: %v) failed to decode search results: %v", query, err)
+		log.Println(errMsg)
+		parsingErrorLogger.LogErrorInJsonFile(errMsg, ctrl)
+		errorMonitoring.IncrementError(errorMonitoring.ParsingError, ctrl)
+		return dataTypes.DeviceInQueue{}, errorTypes.NewParsingError(errMsg)
+	}
 
-		if len(apiResponse.Data.Phones) == 0 {
-			log.Printf("in specAPI.getAllNamesAndLinksByBrand finished reading all devices in %v", brandDirectory)
-			break
-		}
+	phone, ok := pickSearchedPhone(brand, model, result.Data.Phones)
+	if !ok {
+		log.Printf("in specAPI.ResolveDevice no device matching %v", query)
+		return dataTypes.DeviceInQueue{}, errorTypes.NewNoSuchDeviceError(fmt.Sprintf("in specAPI.ResolveDevice no device matching %v", query))
+	}
 
-		for _, phone := range apiResponse.Data.Phones {
-			if helpers.DoesNameContainExcludedKeywords(phone.PhoneName, excludedTerms) {
-				continue
-			} else if strings.Contains(strings.ToLower(phone.PhoneName), series) {
-				allPhones = append(allPhones, phone)
-			}
+	return dataTypes.DeviceInQueue{Name: phone.PhoneName, Detail: phone.Detail, Image: phone.Image}, nil
+}
+
+// pickSearchedPhone prefers an exact model match and falls back to the first result containing the model name.
+func pickSearchedPhone(brand, model string, phones []searchedPhone) (searchedPhone, bool) {
+	lowerBrand := strings.ToLower(strings.TrimSpace(brand))
+	lowerModel := strings.ToLower(strings.TrimSpace(model))
+	var fallback *searchedPhone
+	for i, phone := range phones {
+		if lowerBrand != "" && strings.ToLower(phone.Brand) != lowerBrand {
+			continue
+		}
+		lowerName := strings.ToLower(strings.TrimSpace(phone.PhoneName))
+		if lowerName == lowerModel || lowerName == strings.TrimSpace(lowerBrand+" "+lowerModel) {
+			return phone, true
+		}
+		if fallback == nil && strings.Contains(lowerName, lowerModel) {
+			fallback = &phones[i]
 		}
-		err = resp.Body.Close()
+	}
+	if fallback != nil {
+		return *fallback, true
+	}
+	return searchedPhone{}, false
+}
+
+// IsSpecAPIURL reports whether rawURL is an https URL of the spec API, the only host details are fetched from.
+func IsSpecAPIURL(rawURL string) bool {
+	detailURL, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	baseURL, _ := url.Parse(specAPIBaseURL)
+	return detailURL.Scheme == baseURL.Scheme && detailURL.Host == baseURL.Host && detailURL.User == nil
+}
+
+func ResolveDeviceByDetail(detail string, client *http.Client, ctrl *dataTypes.FlowControl) (dataTypes.DeviceInQueue, error) {
+	if ctrl.Ctx.Err() != nil {
+		log.Printf("stopping specAPI.ResolveDeviceByDetail: %v", ctrl.Ctx.Err())
+		return dataTypes.DeviceInQueue{}, ctrl.Ctx.Err()
+	}
+
+	if !IsSpecAPIURL(detail) {
+		errMsg := fmt.Sprintf("in specAPI.ResolveDeviceByDetail %v is not a spec API URL", detail)
+		log.Println(errMsg)
+		return dataTypes.DeviceInQueue{}, errorTypes.NewNoSuchDeviceError(errMsg)
+	}
+
+	resp, err := helpers.GetRespByURL(client, detail, ctrl)
+	if err != nil {
+		log.Printf("in specAPI.ResolveDeviceByDetail (url: %v) failed to get response: %v", detail, err)
+		return dataTypes.DeviceInQueue{}, err
+	}
+	defer func(Body io.ReadCloser) {
+		err = Body.Close()
 		if err != nil {
+			log.Printf("WARNING: Failed to close HTML reader: %v", err)
 			errorMonitoring.IncrementError(errorMonitoring.CleanUpError, ctrl)
-			return nil, errorTypes.NewParsingError("failed to close response body")
 		}
-		page++
+	}(resp.Body)
+
+	var responseData APIResponse
+	if err = json.NewDecoder(resp.Body).Decode(&responseData); err != nil || !responseData.Status {
+		errMsg := fmt.Sprintf("in specAPI.ResolveDeviceByDetail (url: %v) failed to decode device: %v", detail, err)
+		log.Println(errMsg)
+		return dataTypes.DeviceInQueue{}, errorTypes.NewNoSuchDeviceError(errMsg)
 	}
-	return allPhones, nil
+
+	return dataTypes.DeviceInQueue{
+		Name:   strings.TrimSpace(responseData.Data.PhoneName),
+		Detail: detail,
+		Image:  responseData.Data.Thumbnail,
+	}, nil
 }
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"github.com/ItaiHalperin/Device-Rec-API/api"
 	_ "github.com/ItaiHalperin/Device-Rec-API/docs" // docs is generated by Swag CLI
+	"github.com/ItaiHalperin/Device-Rec-API/internal/application"
+	"github.com/ItaiHalperin/Device-Rec-API/internal/config"
 	"github.com/gin-gonic/gin"
 	"github.com/swaggo/files"
 	"github.com/swaggo/gin-swagger"
@@ -19,7 +21,22 @@ import (
 
 func main() {
 	quit := make(chan os.Signal, 1)
-	service := &api.ServerCtrl{ServerShutdownChannel: quit}
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	app, err := application.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to build application: %v", err)
+	}
+	service := &api.ServerCtrl{App: app}
+	startCtx, cancelStart := context.WithCancel(context.Background())
+	go func() {
+		if err := app.Start(startCtx); err != nil {
+			log.Printf("Application start: %v", err)
+		}
+	}()
+
 	// Create a new Gin router
 	router := gin.Default()
 
@@ -29,15 +46,37 @@ func main() {
 	// api routes
 	v1 := router.Group("/api/v1")
 	{
-		v1.GET("/ping", api.Ping)                       // removed trailing slash
-		v1.GET("/user/:id", api.GetUser)                // already correct
-		v1.GET("/launchProcess", service.LaunchProcess) // removed trailing slash
-		v1.GET("/resetDatabase", api.ResetDatabase)     // removed trailing slash and fixed case
-		v1.GET("/top-devices", api.TopDevices)          // removed trailing slash and fixed case
+		v1.GET("/ping", api.Ping)        // removed trailing slash
+		v1.GET("/user/:id", api.GetUser) // already correct
+		v1.GET("/ready", service.Ready)
+		v1.GET("/ingest/:id", service.GetIngestionJob)
+		v1.GET("/admin/config", service.GetConfig)
+
+		// routes that need the database wait for the application to be ready
+		database := v1.Group("", service.RequireReady)
+		{
+			database.GET("/launchProcess", service.StartPipeline) // removed trailing slash
+			database.GET("/resetDatabase", service.ResetDatabase) // removed trailing slash and fixed case
+			database.GET("/top-devices", service.TopDevices)      // removed trailing slash and fixed case
+			database.POST("/ingest", service.Ingest)
+			database.GET("/devices/:id/prices", service.GetDevicePrices)
+			database.POST("/alerts", service.CreateAlert)
+			database.GET("/benchmarks/:platform", service.GetBenchmarkCatalog)
+		}
+
+		pipeline := v1.Group("/pipeline")
+		{
+			pipeline.POST("/start", service.RequireReady, service.StartPipeline)
+			pipeline.POST("/stop", service.StopPipeline)
+			pipeline.POST("/pause", service.PausePipeline)
+			pipeline.POST("/resume", service.ResumePipeline)
+			pipeline.GET("/status", service.PipelineStatus)
+			pipeline.GET("/events", service.PipelineEvents)
+		}
 	}
 
 	srv := &http.Server{
-		Addr:    ":8080",
+		Addr:    app.Config.Server.Address,
 		Handler: router,
 	}
 
@@ -51,6 +90,7 @@ func main() {
 	<-quit
 	close(quit)
 	log.Println("Shutting down server...")
+	cancelStart()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -59,5 +99,12 @@ func main() {
 		log.Printf("Server shutdown error: %v", err)
 	}
 
+	appCtx, cancelApp := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelApp()
+
+	if err := app.Shutdown(appCtx); err != nil {
+		log.Printf("Application shutdown error: %v", err)
+	}
+
 	log.Println("Server exiting")
 }